@@ -0,0 +1,141 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// LoadFunc loads the value of a key, returning how long it's valid for
+type LoadFunc func(ctx context.Context) (interface{}, time.Duration, error)
+
+// Cache holds values until they expire, collapsing concurrent loads of the
+// same key into one. Expired values are pruned whenever a value is stored.
+type Cache struct {
+	mu      sync.Mutex
+	entries map[string]entry
+	calls   map[string]*inflight
+}
+
+// entry is a value valid until it expires
+type entry struct {
+	value   interface{}
+	expires time.Time
+}
+
+// inflight is a load in flight, shared by concurrent loads of the same key
+type inflight struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+// New returns an empty Cache
+func New() *Cache {
+	return &Cache{
+		entries: make(map[string]entry),
+		calls:   make(map[string]*inflight),
+	}
+}
+
+// Get returns the value of the key, unless it expired
+func (c *Cache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.get(key)
+}
+
+// Set stores the value of the key for the ttl
+func (c *Cache) Set(key string, value interface{}, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.set(key, value, ttl)
+}
+
+// Load returns the value of the key, loading it when it expired. It returns
+// true when the value was cached. Errors aren't cached.
+func (c *Cache) Load(ctx context.Context, key string, load LoadFunc) (interface{}, bool, error) {
+	c.mu.Lock()
+	if value, found := c.get(key); found {
+		c.mu.Unlock()
+		return value, true, nil
+	}
+
+	call := c.start(key, load)
+	c.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.value, false, call.err
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+}
+
+// Prefetch starts loading the key in the background, unless its value is
+// still valid or it's already being loaded
+func (c *Cache) Prefetch(key string, load LoadFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, found := c.get(key); found {
+		return
+	}
+
+	c.start(key, load)
+}
+
+// get returns the value of the key, unless it expired. It must be called
+// holding the lock.
+func (c *Cache) get(key string) (interface{}, bool) {
+	e, found := c.entries[key]
+	if !found || time.Now().After(e.expires) {
+		return nil, false
+	}
+
+	return e.value, true
+}
+
+// set stores the value of the key and prunes expired values. It must be
+// called holding the lock.
+func (c *Cache) set(key string, value interface{}, ttl time.Duration) {
+	now := time.Now()
+	for k, e := range c.entries {
+		if now.After(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+
+	c.entries[key] = entry{value: value, expires: now.Add(ttl)}
+}
+
+// start returns the load in flight for the key, starting one if there's
+// none. It must be called holding the lock.
+func (c *Cache) start(key string, load LoadFunc) *inflight {
+	if call, found := c.calls[key]; found {
+		return call
+	}
+
+	call := &inflight{done: make(chan struct{})}
+	c.calls[key] = call
+
+	// the load outlives the caller starting it, as it's shared with
+	// concurrent callers loading the same key
+	go func() {
+		var ttl time.Duration
+		call.value, ttl, call.err = load(context.Background())
+
+		c.mu.Lock()
+		delete(c.calls, key)
+		if call.err == nil {
+			c.set(key, call.value, ttl)
+		}
+		c.mu.Unlock()
+
+		close(call.done)
+	}()
+
+	return call
+}
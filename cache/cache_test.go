@@ -0,0 +1,149 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// counting returns a load of the value counting its calls, blocking until
+// released when release isn't nil
+func counting(value interface{}, ttl time.Duration, err error, calls *int32, release <-chan struct{}) LoadFunc {
+	return func(context.Context) (interface{}, time.Duration, error) {
+		atomic.AddInt32(calls, 1)
+		if release != nil {
+			<-release
+		}
+
+		return value, ttl, err
+	}
+}
+
+func TestCache_Load(t *testing.T) {
+	t.Run("when a key is loaded concurrently, expect a single load", func(t *testing.T) {
+		var calls int32
+		release := make(chan struct{})
+		c := New()
+
+		var wg sync.WaitGroup
+		values := make([]interface{}, 5)
+		for i := range values {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				values[i], _, _ = c.Load(context.Background(), "key", counting("value", time.Minute, nil, &calls, release))
+			}(i)
+		}
+		close(release)
+		wg.Wait()
+
+		if calls != 1 {
+			t.Errorf("Wrong number of loads: expect: 1, got %v", calls)
+		}
+
+		for _, v := range values {
+			if v != "value" {
+				t.Errorf("Wrong value: expect: value, got %v", v)
+			}
+		}
+	})
+
+	t.Run("when a value is cached, expect it to be reused until it expires", func(t *testing.T) {
+		var calls int32
+		c := New()
+
+		c.Load(context.Background(), "key", counting("value", time.Minute, nil, &calls, nil))
+		if v, cached, err := c.Load(context.Background(), "key", counting("value", time.Minute, nil, &calls, nil)); !cached || err != nil || v != "value" {
+			t.Errorf("Expected the value to be cached, got %v, %v, %v", v, cached, err)
+		}
+
+		c.Set("key", "value", -time.Second)
+		if _, cached, _ := c.Load(context.Background(), "key", counting("value", time.Minute, nil, &calls, nil)); cached {
+			t.Error("Expected an expired value to be loaded again")
+		}
+
+		if calls != 2 {
+			t.Errorf("Wrong number of loads: expect: 2, got %v", calls)
+		}
+	})
+
+	t.Run("when a load fails, expect the error not to be cached", func(t *testing.T) {
+		var calls int32
+		c := New()
+
+		for i := 0; i < 2; i++ {
+			if _, _, err := c.Load(context.Background(), "key", counting(nil, time.Minute, errors.New("failed"), &calls, nil)); err == nil {
+				t.Error("Expected an error, got nil")
+			}
+		}
+
+		if calls != 2 {
+			t.Errorf("Wrong number of loads: expect: 2, got %v", calls)
+		}
+	})
+
+	t.Run("when the context is done while waiting, expect its error", func(t *testing.T) {
+		var calls int32
+		release := make(chan struct{})
+		defer close(release)
+		c := New()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, _, err := c.Load(ctx, "key", counting("value", time.Minute, nil, &calls, release)); !errors.Is(err, context.Canceled) {
+			t.Errorf("Wrong error: expect: %v, got %v", context.Canceled, err)
+		}
+	})
+}
+
+func TestCache_Prefetch(t *testing.T) {
+	t.Run("when a key is prefetched, expect loads to join it", func(t *testing.T) {
+		var calls int32
+		release := make(chan struct{})
+		c := New()
+
+		c.Prefetch("key", counting("value", time.Minute, nil, &calls, release))
+		c.Prefetch("key", counting("value", time.Minute, nil, &calls, release))
+		close(release)
+
+		if v, _, err := c.Load(context.Background(), "key", counting("value", time.Minute, nil, &calls, nil)); err != nil || v != "value" {
+			t.Errorf("Expected the prefetched value, got %v, %v", v, err)
+		}
+
+		if calls != 1 {
+			t.Errorf("Wrong number of loads: expect: 1, got %v", calls)
+		}
+	})
+
+	t.Run("when a value is cached, expect it not to be prefetched", func(t *testing.T) {
+		var calls int32
+		c := New()
+
+		c.Set("key", "value", time.Minute)
+		c.Prefetch("key", counting("value", time.Minute, nil, &calls, nil))
+
+		if calls != 0 {
+			t.Errorf("Wrong number of loads: expect: 0, got %v", calls)
+		}
+	})
+}
+
+func TestCache_Set(t *testing.T) {
+	t.Run("when a value is stored, expect expired values to be pruned", func(t *testing.T) {
+		c := New()
+
+		c.Set("expired", "value", -time.Second)
+		c.Set("valid", "value", time.Minute)
+
+		if _, found := c.entries["expired"]; found {
+			t.Error("Expected the expired value to be pruned")
+		}
+
+		if v, found := c.Get("valid"); !found || v != "value" {
+			t.Errorf("Expected the valid value, got %v, %v", v, found)
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// CDNs holds the hosts urls are rewritten to when selecting a CDN. The
+// hosts are set as a comma separated list of `name=scheme://host`, e.g.
+//
+//	BAKERY_CDN_HOSTS=akamai=https://cbsi.akamaized.net,fastly=https://cbsi.global.ssl.fastly.net
+type CDNs struct {
+	List  string              `envconfig:"CDN_HOSTS"`
+	Hosts map[string]*url.URL `ignored:"true"`
+}
+
+func (c *CDNs) init() error {
+	if c.List == "" {
+		return nil
+	}
+
+	hosts, err := readCDNHosts(c.List)
+	if err != nil {
+		return fmt.Errorf("loading cdn hosts: %w", err)
+	}
+
+	c.Hosts = hosts
+
+	return nil
+}
+
+// Host returns the scheme and host of the named CDN
+func (c CDNs) Host(name string) (*url.URL, bool) {
+	host, found := c.Hosts[name]
+	return host, found
+}
+
+// Names returns the names of the configured CDNs
+func (c CDNs) Names() []string {
+	var names []string
+	for name := range c.Hosts {
+		names = append(names, name)
+	}
+
+	return names
+}
+
+func readCDNHosts(list string) (map[string]*url.URL, error) {
+	hosts := map[string]*url.URL{}
+	for _, entry := range strings.Split(list, ",") {
+		i := strings.Index(entry, "=")
+		if i == -1 {
+			return nil, fmt.Errorf("%v: expected `name=scheme://host`", entry)
+		}
+
+		name, value := strings.TrimSpace(entry[:i]), strings.TrimSpace(entry[i+1:])
+		host, err := url.Parse(value)
+		if name == "" || err != nil || host.Scheme == "" || host.Host == "" {
+			return nil, fmt.Errorf("%v: expected `name=scheme://host`", entry)
+		}
+
+		if _, found := hosts[name]; found {
+			return nil, fmt.Errorf("cdn %v is already defined", name)
+		}
+
+		hosts[name] = &url.URL{Scheme: host.Scheme, Host: host.Host}
+	}
+
+	return hosts, nil
+}
@@ -15,14 +15,16 @@ type HTTPClient interface {
 // Client holds configuration for http clients
 type Client struct {
 	Timeout time.Duration `envconfig:"CLIENT_TIMEOUT" default:"5s"`
-	Tracer  tracing.Tracer
+	// BlockingReloadTimeout bounds LL-HLS blocking playlist reloads of
+	// playlists whose target duration isn't known yet
+	BlockingReloadTimeout time.Duration `envconfig:"CLIENT_BLOCKING_RELOAD_TIMEOUT" default:"30s"`
+	Tracer                tracing.Tracer
 	HTTPClient
 }
 
-// SetContext will set the context on the incoming requests
+// SetContext will set the context on the incoming requests. Requests are
+// timed out by their context, as blocking playlist reloads take longer.
 func (c *Client) init(t tracing.Tracer) {
 	c.Tracer = t
-	c.HTTPClient = c.Tracer.Client(&http.Client{
-		Timeout: c.Timeout,
-	})
+	c.HTTPClient = c.Tracer.Client(&http.Client{})
 }
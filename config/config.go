@@ -26,6 +26,8 @@ type Config struct {
 	Tracer
 	Client
 	Propeller
+	Presets
+	CDNs
 }
 
 // LoadConfig loads the configuration with environment variables injected
@@ -41,6 +43,14 @@ func LoadConfig() (Config, error) {
 	tracer := c.Tracer.init(c.Logger)
 	c.Client.init(tracer)
 
+	if err := c.Presets.init(); err != nil {
+		return c, err
+	}
+
+	if err := c.CDNs.init(); err != nil {
+		return c, err
+	}
+
 	return c, c.Propeller.init(tracer, c.Client.Timeout)
 }
 
@@ -1,12 +1,14 @@
 package config
 
 import (
+	"bufio"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -39,9 +41,10 @@ func getDefaultConfig(auth bool, c Client, t Tracer, p Propeller) Config {
 // getClientConfig will return a Cient config to use in tests based on provided values
 func getClientConfig(t time.Duration, trace tracing.Tracer) Client {
 	return Client{
-		Timeout:    t,
-		Tracer:     trace,
-		HTTPClient: trace.Client(&http.Client{Timeout: t}),
+		Timeout:               t,
+		BlockingReloadTimeout: 30 * time.Second,
+		Tracer:                trace,
+		HTTPClient:            trace.Client(&http.Client{}),
 	}
 }
 
@@ -302,3 +305,109 @@ func TestConfig_Middleware(t *testing.T) {
 		})
 	}
 }
+
+func TestConfig_ReadPresets(t *testing.T) {
+	tests := []struct {
+		name          string
+		file          string
+		expectPresets map[string]string
+		expectErr     bool
+	}{
+		{
+			name: "when presets are defined, expect them keyed by name",
+			file: `# device policies
+mobile-cellular = v(avc,b(0,3000000)),a(mp4a),tags(iframe)
+
+hd=res(720,)
+`,
+			expectPresets: map[string]string{
+				"mobile-cellular": "v(avc,b(0,3000000)),a(mp4a),tags(iframe)",
+				"hd":              "res(720,)",
+			},
+		},
+		{
+			name:      "when a line is missing its filters, expect error",
+			file:      "mobile-cellular =\n",
+			expectErr: true,
+		},
+		{
+			name:      "when a preset has an invalid filter, expect error",
+			file:      "hd = v(res(1080,720))\n",
+			expectErr: true,
+		},
+		{
+			name:      "when a preset is defined twice, expect error",
+			file:      "hd = res(720,)\nhd = res(1080,)\n",
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := readPresets(bufio.NewScanner(strings.NewReader(tc.file)))
+			if err != nil && !tc.expectErr {
+				t.Errorf("readPresets() didnt expect an error to be returned, got: %v", err)
+				return
+			} else if err == nil && tc.expectErr {
+				t.Error("readPresets() expected an error, got nil")
+				return
+			}
+
+			if !cmp.Equal(got, tc.expectPresets) {
+				t.Errorf("Wrong presets loaded\ngot %v\nexpected %v\ndiff: %v",
+					got, tc.expectPresets, cmp.Diff(got, tc.expectPresets))
+			}
+		})
+	}
+}
+
+func TestConfig_ReadCDNHosts(t *testing.T) {
+	tests := []struct {
+		name        string
+		list        string
+		expectHosts map[string]*url.URL
+		expectErr   bool
+	}{
+		{
+			name: "when cdn hosts are defined, expect their scheme and host keyed by name",
+			list: "akamai=https://cbsi.akamaized.net, fastly = http://cbsi.global.ssl.fastly.net/ignored/path",
+			expectHosts: map[string]*url.URL{
+				"akamai": {Scheme: "https", Host: "cbsi.akamaized.net"},
+				"fastly": {Scheme: "http", Host: "cbsi.global.ssl.fastly.net"},
+			},
+		},
+		{
+			name:      "when a host is missing its scheme, expect error",
+			list:      "akamai=cbsi.akamaized.net",
+			expectErr: true,
+		},
+		{
+			name:      "when a cdn is missing its host, expect error",
+			list:      "akamai",
+			expectErr: true,
+		},
+		{
+			name:      "when a cdn is defined twice, expect error",
+			list:      "akamai=https://a.akamaized.net,akamai=https://b.akamaized.net",
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := readCDNHosts(tc.list)
+			if err != nil && !tc.expectErr {
+				t.Errorf("readCDNHosts() didnt expect an error to be returned, got: %v", err)
+				return
+			} else if err == nil && tc.expectErr {
+				t.Error("readCDNHosts() expected an error, got nil")
+				return
+			}
+
+			if !cmp.Equal(got, tc.expectHosts) {
+				t.Errorf("Wrong cdn hosts loaded\ngot %v\nexpected %v\ndiff: %v",
+					got, tc.expectHosts, cmp.Diff(got, tc.expectHosts))
+			}
+		})
+	}
+}
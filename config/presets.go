@@ -0,0 +1,76 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cbsinteractive/bakery/parsers"
+)
+
+// Presets holds named filter presets loaded from a file. Each line of the
+// file defines a preset as `name = filters`, with filters following the
+// path grammar and separated by commas, e.g.
+//
+//	mobile-cellular = v(avc,b(0,3000000)),a(mp4a),tags(iframe)
+//
+// Empty lines and lines starting with # are ignored. Presets are validated
+// when loaded, so an invalid preset fails at startup rather than on request.
+type Presets struct {
+	File    string            `envconfig:"PRESETS_FILE"`
+	Filters map[string]string `ignored:"true"`
+}
+
+func (p *Presets) init() error {
+	if p.File == "" {
+		return nil
+	}
+
+	f, err := os.Open(p.File)
+	if err != nil {
+		return fmt.Errorf("loading presets: %w", err)
+	}
+	defer f.Close()
+
+	filters, err := readPresets(bufio.NewScanner(f))
+	if err != nil {
+		return fmt.Errorf("loading presets from %v: %w", p.File, err)
+	}
+
+	p.Filters = filters
+
+	return nil
+}
+
+func readPresets(s *bufio.Scanner) (map[string]string, error) {
+	filters := map[string]string{}
+	for line := 1; s.Scan(); line++ {
+		text := strings.TrimSpace(s.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		i := strings.Index(text, "=")
+		if i == -1 {
+			return nil, fmt.Errorf("line %v: expected `name = filters`", line)
+		}
+
+		name, value := strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:])
+		if name == "" || value == "" {
+			return nil, fmt.Errorf("line %v: expected `name = filters`", line)
+		}
+
+		if _, found := filters[name]; found {
+			return nil, fmt.Errorf("line %v: preset %v is already defined", line, name)
+		}
+
+		if err := (parsers.Presets{name: value}).Validate(); err != nil {
+			return nil, fmt.Errorf("line %v: %w", line, err)
+		}
+
+		filters[name] = value
+	}
+
+	return filters, s.Err()
+}
@@ -0,0 +1,33 @@
+package filters
+
+import (
+	"github.com/grafov/m3u8"
+)
+
+// adBreakSegments returns whether each segment of the playlist is part of an
+// ad break, signaled by cues of any dialect or by SCTE-35 date ranges. The
+// segments following a break get a program date time when the playlist has
+// them, as the segments before them are spliced out.
+func adBreakSegments(segments []*m3u8.MediaSegment, dateRanges map[int][]string) []bool {
+	t := newCueTranslator("", segments, dateRanges)
+	ads := make([]bool, len(segments))
+	for i, s := range segments {
+		if s == nil {
+			continue
+		}
+
+		var cue *m3u8.SCTE
+		if s.SCTE != nil && s.SCTE.Syntax != m3u8.SCTE35_67_2014 {
+			cue = s.SCTE
+		}
+
+		ended, _ := t.follow(i, cue)
+		ads[i] = t.open != nil
+
+		if ended != nil && !ads[i] && t.hasPDT && s.ProgramDateTime.IsZero() {
+			s.ProgramDateTime = t.clock[i]
+		}
+	}
+
+	return ads
+}
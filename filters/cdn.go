@@ -0,0 +1,99 @@
+package filters
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/cbsinteractive/bakery/config"
+	"github.com/cbsinteractive/bakery/parsers"
+	"github.com/grafov/m3u8"
+	"github.com/zencoder/go-dash/v3/mpd"
+)
+
+// cdnHost returns the host of the cdn selected by the filters, or nil when
+// no cdn is selected
+func cdnHost(c config.Config, filters *parsers.MediaFilters) (*url.URL, error) {
+	if filters.CDN == "" {
+		return nil, nil
+	}
+
+	host, found := c.CDNs.Host(filters.CDN)
+	if !found {
+		return nil, fmt.Errorf("cdn %v is not configured", filters.CDN)
+	}
+
+	return host, nil
+}
+
+// rewriteHost replaces the scheme and host of an absolute http url with the
+// ones of the cdn. Relative urls and urls of other schemes, e.g. skd:// and
+// data: key URIs, are returned as they are.
+func rewriteHost(uri string, cdn *url.URL) (string, error) {
+	if cdn == nil || uri == "" {
+		return uri, nil
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return uri, fmt.Errorf("rewriting host: %w", err)
+	}
+
+	if !u.IsAbs() || (u.Scheme != "http" && u.Scheme != "https") {
+		return uri, nil
+	}
+
+	u.Scheme, u.Host = cdn.Scheme, cdn.Host
+
+	return u.String(), nil
+}
+
+// rewriteVariantHosts points the variant and its alternatives to the cdn
+func rewriteVariantHosts(v *m3u8.Variant, cdn *url.URL) error {
+	for _, a := range v.Alternatives {
+		uri, err := rewriteHost(a.URI, cdn)
+		if err != nil {
+			return err
+		}
+		a.URI = uri
+	}
+
+	uri, err := rewriteHost(v.URI, cdn)
+	if err != nil {
+		return err
+	}
+	v.URI = uri
+
+	return nil
+}
+
+// rewriteBaseURLs points the absolute base urls of the manifest, its periods
+// and representations to the cdn
+func rewriteBaseURLs(manifest *mpd.MPD, cdn *url.URL) error {
+	baseURL, err := rewriteHost(manifest.BaseURL, cdn)
+	if err != nil {
+		return err
+	}
+	manifest.BaseURL = baseURL
+
+	for _, period := range manifest.Periods {
+		if period.BaseURL, err = rewriteHost(period.BaseURL, cdn); err != nil {
+			return err
+		}
+
+		for _, as := range period.AdaptationSets {
+			for _, r := range as.Representations {
+				if r.BaseURL == nil {
+					continue
+				}
+
+				baseURL, err := rewriteHost(*r.BaseURL, cdn)
+				if err != nil {
+					return err
+				}
+				r.BaseURL = strptr(baseURL)
+			}
+		}
+	}
+
+	return nil
+}
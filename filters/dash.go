@@ -3,17 +3,20 @@ package filters
 import (
 	"context"
 	"fmt"
+	"math"
+	"math/bits"
 	"net/url"
 	"path"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/cbsinteractive/bakery/config"
 	"github.com/cbsinteractive/bakery/parsers"
 	"github.com/zencoder/go-dash/v3/mpd"
 )
 
-type execFilter func(filters *parsers.MediaFilters, manifest *mpd.MPD)
+type execFilter func(filters *parsers.MediaFilters, manifest *mpd.MPD) error
 
 // DASHFilter implements the Filter interface for DASH manifests
 type DASHFilter struct {
@@ -65,8 +68,21 @@ func (d *DASHFilter) FilterContent(_ context.Context, filters *parsers.MediaFilt
 		manifest.BaseURL = baseURLWithPath(path.Join(path.Dir(u.Path), manifest.BaseURL))
 	}
 
+	cdn, err := cdnHost(d.config, filters)
+	if err != nil {
+		return "", err
+	}
+
+	if cdn != nil {
+		if err := rewriteBaseURLs(manifest, cdn); err != nil {
+			return "", err
+		}
+	}
+
 	for _, filter := range d.getFilters(filters) {
-		filter(filters, manifest)
+		if err := filter(filters, manifest); err != nil {
+			return "", err
+		}
 	}
 
 	for _, plugin := range filters.Plugins {
@@ -100,45 +116,118 @@ func (d *DASHFilter) getFilters(filters *parsers.MediaFilters) []execFilter {
 		filterList = append(filterList, d.filterCaptionTypes)
 	}
 
+	if filters.Videos.Only != nil || filters.Audios.Only != nil || filters.Captions.Only != nil {
+		filterList = append(filterList, d.filterAllowedTypes)
+	}
+
 	if filters.FrameRate != nil {
 		filterList = append(filterList, d.filterFrameRate)
 	}
 
+	if filters.VideoRange != nil {
+		filterList = append(filterList, d.filterVideoRange)
+	}
+
+	if filters.Videos.Height != nil || filters.Videos.Width != nil {
+		filterList = append(filterList, d.filterResolution)
+	}
+
+	if filters.Audios.Channels != nil {
+		filterList = append(filterList, d.filterChannels)
+	}
+
+	if filters.Renditions != nil {
+		filterList = append(filterList, d.filterRenditions)
+	}
+
+	if filters.Sort != nil {
+		filterList = append(filterList, d.sortRepresentations)
+	}
+
 	if filters.Audios.Language != nil || filters.Captions.Language != nil {
 		filterList = append(filterList, d.filterAdaptationSetLanguage)
 	}
 
+	if len(filters.DRM) > 0 {
+		filterList = append(filterList, d.filterContentProtection)
+	}
+
+	if filters.DVR > 0 {
+		filterList = append(filterList, d.filterDVR)
+	}
+
+	if filters.PlaylistType == parsers.PlaylistTypeVOD {
+		filterList = append(filterList, d.filterPlaylistType)
+	}
+
 	return filterList
 }
 
-func (d *DASHFilter) filterVideoTypes(filters *parsers.MediaFilters, manifest *mpd.MPD) {
+func (d *DASHFilter) filterVideoTypes(filters *parsers.MediaFilters, manifest *mpd.MPD) error {
 	supportedVideoTypes := map[string]struct{}{}
 	for _, videoType := range filters.Videos.Codecs {
 		supportedVideoTypes[string(videoType)] = struct{}{}
 	}
 
 	filterContentType(videoContentType, supportedVideoTypes, manifest)
+
+	return nil
 }
 
-func (d *DASHFilter) filterAudioTypes(filters *parsers.MediaFilters, manifest *mpd.MPD) {
+func (d *DASHFilter) filterAudioTypes(filters *parsers.MediaFilters, manifest *mpd.MPD) error {
 	supportedAudioTypes := map[string]struct{}{}
 	for _, audioType := range filters.Audios.Codecs {
 		supportedAudioTypes[string(audioType)] = struct{}{}
 	}
 
 	filterContentType(audioContentType, supportedAudioTypes, manifest)
+
+	return nil
 }
 
-func (d *DASHFilter) filterCaptionTypes(filters *parsers.MediaFilters, manifest *mpd.MPD) {
+func (d *DASHFilter) filterCaptionTypes(filters *parsers.MediaFilters, manifest *mpd.MPD) error {
 	supportedCaptionTypes := map[string]struct{}{}
 	for _, captionType := range filters.Captions.Codecs {
 		supportedCaptionTypes[string(captionType)] = struct{}{}
 	}
 
 	filterContentType(captionContentType, supportedCaptionTypes, manifest)
+
+	return nil
+}
+
+func (d *DASHFilter) filterAllowedTypes(filters *parsers.MediaFilters, manifest *mpd.MPD) error {
+	for filter, only := range map[ContentType][]string{
+		videoContentType:   filters.Videos.Only,
+		audioContentType:   filters.Audios.Only,
+		captionContentType: filters.Captions.Only,
+	} {
+		if only == nil {
+			continue
+		}
+
+		allowedTypes := map[string]struct{}{}
+		for _, t := range only {
+			allowedTypes[t] = struct{}{}
+		}
+
+		filterRepresentations(filter, manifest, func(codecs string) bool {
+			return !matchCodec(codecs, filter, allowedTypes)
+		})
+	}
+
+	return nil
 }
 
 func filterContentType(filter ContentType, supportedContentTypes map[string]struct{}, manifest *mpd.MPD) {
+	filterRepresentations(filter, manifest, func(codecs string) bool {
+		return matchCodec(codecs, filter, supportedContentTypes)
+	})
+}
+
+// filterRepresentations removes the representations of the given content type
+// for which remove returns true, along with the adaptation sets left empty
+func filterRepresentations(filter ContentType, manifest *mpd.MPD, remove func(codecs string) bool) {
 	for _, period := range manifest.Periods {
 		var filteredAdaptationSets []*mpd.AdaptationSet
 		for _, as := range period.AdaptationSets {
@@ -150,7 +239,7 @@ func filterContentType(filter ContentType, supportedContentTypes map[string]stru
 						continue
 					}
 
-					if matchCodec(*r.Codecs, filter, supportedContentTypes) {
+					if remove(*r.Codecs) {
 						continue
 					}
 
@@ -171,7 +260,7 @@ func filterContentType(filter ContentType, supportedContentTypes map[string]stru
 	}
 }
 
-func (d *DASHFilter) filterAdaptationSetLanguage(filters *parsers.MediaFilters, manifest *mpd.MPD) {
+func (d *DASHFilter) filterAdaptationSetLanguage(filters *parsers.MediaFilters, manifest *mpd.MPD) error {
 	for _, period := range manifest.Periods {
 		var filteredAdaptationSets []*mpd.AdaptationSet
 		for _, as := range period.AdaptationSets {
@@ -180,18 +269,18 @@ func (d *DASHFilter) filterAdaptationSetLanguage(filters *parsers.MediaFilters,
 				continue
 			}
 
-			var langs []string
+			var nf parsers.NestedFilters
 			switch ContentType(*as.ContentType) {
 			case audioContentType:
-				langs = filters.Audios.Language
+				nf = filters.Audios
 			case captionContentType:
-				langs = filters.Captions.Language
+				nf = filters.Captions
 			default:
 				filteredAdaptationSets = append(filteredAdaptationSets, as)
 				continue
 			}
 
-			if !(matchLang(*as.Lang, langs)) {
+			if as.Lang == nil || !matchLanguage(*as.Lang, nf.Language, nf.ExactLanguage) {
 				filteredAdaptationSets = append(filteredAdaptationSets, as)
 			}
 		}
@@ -201,9 +290,202 @@ func (d *DASHFilter) filterAdaptationSetLanguage(filters *parsers.MediaFilters,
 		}
 		period.AdaptationSets = filteredAdaptationSets
 	}
+
+	return nil
+}
+
+// filterDVR shortens the time shift buffer of a live manifest to the dvr window.
+// Buffers that are already shorter are left as they are.
+func (d *DASHFilter) filterDVR(filters *parsers.MediaFilters, manifest *mpd.MPD) error {
+	if manifest.Type == nil || *manifest.Type != "dynamic" {
+		return nil
+	}
+
+	window := time.Duration(filters.DVR * float64(time.Second))
+	if manifest.TimeShiftBufferDepth != nil {
+		depth, err := mpd.ParseDuration(*manifest.TimeShiftBufferDepth)
+		if err == nil && depth <= window {
+			return nil
+		}
+	}
+
+	depth := mpd.Duration(window)
+	manifest.TimeShiftBufferDepth = strptr(depth.String())
+
+	return nil
 }
 
-func (d *DASHFilter) filterAdaptationSetContentType(filters *parsers.MediaFilters, manifest *mpd.MPD) {
+// filterPlaylistType converts a dynamic manifest to a static one. The presentation
+// starts with the first segment still in the window and ends with the last one, so
+// the periods are moved back by the time that left the window. Converting a manifest
+// with a period that has neither a segment timeline nor a duration fails.
+func (d *DASHFilter) filterPlaylistType(filters *parsers.MediaFilters, manifest *mpd.MPD) error {
+	if manifest.Type == nil || *manifest.Type != "dynamic" {
+		return nil
+	}
+
+	if len(manifest.Periods) == 0 {
+		return fmt.Errorf("converting to vod: manifest has no periods")
+	}
+
+	var windowStart, presentationEnd time.Duration
+	for i, period := range manifest.Periods {
+		first, end, err := periodWindow(period)
+		if err != nil {
+			return fmt.Errorf("converting to vod: %w", err)
+		}
+
+		start := presentationEnd
+		if period.Start != nil {
+			start = time.Duration(*period.Start)
+		}
+
+		presentationEnd = start + end
+
+		// the first period starts the presentation once its segments are shifted
+		if i == 0 {
+			windowStart = start + first
+			shiftPeriod(period, first)
+			start = windowStart
+		}
+
+		if period.Start != nil {
+			periodStart := mpd.Duration(start - windowStart)
+			period.Start = &periodStart
+		}
+	}
+
+	mediaPresentationDuration := mpd.Duration(presentationEnd - windowStart)
+	manifest.Type = strptr("static")
+	manifest.MediaPresentationDuration = strptr(mediaPresentationDuration.String())
+	manifest.MinimumUpdatePeriod = nil
+	manifest.TimeShiftBufferDepth = nil
+	manifest.SuggestedPresentationDelay = nil
+
+	return nil
+}
+
+// periodWindow returns when the first segment of the period starts and when its
+// last segment ends, from the start of the period. The segments are read from the
+// segment timelines of the period, and its duration, when set, marks its end
+func periodWindow(period *mpd.Period) (time.Duration, time.Duration, error) {
+	var first, end time.Duration
+	var found bool
+	for _, t := range periodTemplates(period) {
+		if t.SegmentTimeline == nil || len(t.SegmentTimeline.Segments) == 0 {
+			continue
+		}
+
+		start, stop := timelineWindow(t)
+		if !found || start < first {
+			first = start
+		}
+		if stop > end {
+			end = stop
+		}
+		found = true
+	}
+
+	if first < 0 {
+		first = 0
+	}
+
+	if period.Duration > 0 {
+		return first, time.Duration(period.Duration), nil
+	}
+
+	if !found {
+		return 0, 0, fmt.Errorf("no segment timeline found in period %q", period.ID)
+	}
+
+	return first, end, nil
+}
+
+// shiftPeriod moves the segments of the period back by offset, so the segment
+// starting at offset starts the period
+func shiftPeriod(period *mpd.Period, offset time.Duration) {
+	if offset <= 0 {
+		return
+	}
+
+	for _, t := range periodTemplates(period) {
+		var pto uint64
+		if t.PresentationTimeOffset != nil {
+			pto = *t.PresentationTimeOffset
+		}
+		pto += uint64(math.Round(offset.Seconds() * float64(templateTimescale(t))))
+		t.PresentationTimeOffset = &pto
+	}
+
+	if period.Duration > 0 {
+		period.Duration -= mpd.Duration(offset)
+	}
+}
+
+// periodTemplates returns the segment templates of the period, its adaptation sets
+// and their representations
+func periodTemplates(period *mpd.Period) []*mpd.SegmentTemplate {
+	var templates []*mpd.SegmentTemplate
+	if period.SegmentTemplate != nil {
+		templates = append(templates, period.SegmentTemplate)
+	}
+
+	for _, as := range period.AdaptationSets {
+		if as.SegmentTemplate != nil {
+			templates = append(templates, as.SegmentTemplate)
+		}
+		for _, r := range as.Representations {
+			if r.SegmentTemplate != nil {
+				templates = append(templates, r.SegmentTemplate)
+			}
+		}
+	}
+
+	return templates
+}
+
+// timelineWindow returns when the first segment of the template's timeline starts
+// and when its last segment ends, from the presentation time offset
+func timelineWindow(t *mpd.SegmentTemplate) (time.Duration, time.Duration) {
+	var first, end uint64
+	for i, s := range t.SegmentTimeline.Segments {
+		if s.StartTime != nil {
+			end = *s.StartTime
+		}
+		if i == 0 {
+			first = end
+		}
+
+		repeat := 0
+		if s.RepeatCount != nil && *s.RepeatCount > 0 {
+			repeat = *s.RepeatCount
+		}
+		end += s.Duration * uint64(repeat+1)
+	}
+
+	var pto uint64
+	if t.PresentationTimeOffset != nil {
+		pto = *t.PresentationTimeOffset
+	}
+
+	timescale := float64(templateTimescale(t))
+	toDuration := func(ticks uint64) time.Duration {
+		return time.Duration((float64(ticks) - float64(pto)) / timescale * float64(time.Second))
+	}
+
+	return toDuration(first), toDuration(end)
+}
+
+// templateTimescale returns the timescale of the template, 1 when it isn't set
+func templateTimescale(t *mpd.SegmentTemplate) int64 {
+	if t.Timescale != nil && *t.Timescale > 0 {
+		return *t.Timescale
+	}
+
+	return 1
+}
+
+func (d *DASHFilter) filterAdaptationSetContentType(filters *parsers.MediaFilters, manifest *mpd.MPD) error {
 	filteredAdaptationSetTypes := map[string]struct{}{}
 	for _, streamType := range filters.ContentTypes {
 		filteredAdaptationSetTypes[streamType] = struct{}{}
@@ -239,9 +521,11 @@ func (d *DASHFilter) filterAdaptationSetContentType(filters *parsers.MediaFilter
 	}
 
 	manifest.Periods = filteredPeriods
+
+	return nil
 }
 
-func (d *DASHFilter) filterFrameRate(filters *parsers.MediaFilters, manifest *mpd.MPD) {
+func (d *DASHFilter) filterFrameRate(filters *parsers.MediaFilters, manifest *mpd.MPD) error {
 	for _, period := range manifest.Periods {
 		var filteredAdaptationSets []*mpd.AdaptationSet
 		for _, as := range period.AdaptationSets {
@@ -277,9 +561,11 @@ func (d *DASHFilter) filterFrameRate(filters *parsers.MediaFilters, manifest *mp
 		}
 		period.AdaptationSets = filteredAdaptationSets
 	}
+
+	return nil
 }
 
-func (d *DASHFilter) filterBandwidth(filters *parsers.MediaFilters, manifest *mpd.MPD) {
+func (d *DASHFilter) filterBandwidth(filters *parsers.MediaFilters, manifest *mpd.MPD) error {
 	for _, period := range manifest.Periods {
 		var filteredAdaptationSets []*mpd.AdaptationSet
 		for _, as := range period.AdaptationSets {
@@ -349,16 +635,236 @@ func (d *DASHFilter) filterBandwidth(filters *parsers.MediaFilters, manifest *mp
 			as.ID = strptr(strconv.Itoa(index))
 		}
 	}
+
+	return nil
 }
 
-func matchLang(l string, langs []string) bool {
-	for _, lang := range langs {
-		if string(lang) == l {
-			return true
+func (d *DASHFilter) filterResolution(filters *parsers.MediaFilters, manifest *mpd.MPD) error {
+	height, width := filters.Videos.Height, filters.Videos.Width
+
+	for _, period := range manifest.Periods {
+		var filteredAdaptationSets []*mpd.AdaptationSet
+		for _, as := range period.AdaptationSets {
+			if as.ContentType == nil || ContentType(*as.ContentType) != videoContentType {
+				filteredAdaptationSets = append(filteredAdaptationSets, as)
+				continue
+			}
+
+			var filteredRepresentations []*mpd.Representation
+			for _, r := range as.Representations {
+				if r.Height != nil && height != nil && !inRange(height.Min, height.Max, int(*r.Height)) {
+					continue
+				}
+
+				if r.Width != nil && width != nil && !inRange(width.Min, width.Max, int(*r.Width)) {
+					continue
+				}
+
+				filteredRepresentations = append(filteredRepresentations, r)
+			}
+
+			as.Representations = filteredRepresentations
+			updateMaxResolution(as)
+
+			if len(as.Representations) != 0 {
+				filteredAdaptationSets = append(filteredAdaptationSets, as)
+			}
+		}
+
+		period.AdaptationSets = filteredAdaptationSets
+
+		// Recalculate AdaptationSet id numbers
+		for index, as := range period.AdaptationSets {
+			as.ID = strptr(strconv.Itoa(index))
 		}
 	}
 
-	return false
+	return nil
+}
+
+func (d *DASHFilter) filterChannels(filters *parsers.MediaFilters, manifest *mpd.MPD) error {
+	channels := filters.Audios.Channels
+
+	for _, period := range manifest.Periods {
+		var filteredAdaptationSets []*mpd.AdaptationSet
+		for _, as := range period.AdaptationSets {
+			if as.ContentType == nil || ContentType(*as.ContentType) != audioContentType {
+				filteredAdaptationSets = append(filteredAdaptationSets, as)
+				continue
+			}
+
+			var filteredRepresentations []*mpd.Representation
+			for _, r := range as.Representations {
+				count, ok := representationChannels(as, r)
+				if ok && !inRange(channels.Min, channels.Max, count) {
+					continue
+				}
+
+				filteredRepresentations = append(filteredRepresentations, r)
+			}
+
+			as.Representations = filteredRepresentations
+
+			if len(as.Representations) != 0 {
+				filteredAdaptationSets = append(filteredAdaptationSets, as)
+			}
+		}
+
+		period.AdaptationSets = filteredAdaptationSets
+
+		// Recalculate AdaptationSet id numbers
+		for index, as := range period.AdaptationSets {
+			as.ID = strptr(strconv.Itoa(index))
+		}
+	}
+
+	return nil
+}
+
+// representationChannels returns the number of channels advertised by the
+// AudioChannelConfiguration of the representation, or of its adaptation set
+func representationChannels(as *mpd.AdaptationSet, r *mpd.Representation) (int, bool) {
+	if c := r.AudioChannelConfiguration; c != nil && c.SchemeIDURI != nil && c.Value != nil {
+		return parseAudioChannelConfiguration(*c.SchemeIDURI, *c.Value)
+	}
+
+	for _, c := range as.AudioChannelConfiguration {
+		if c.SchemeIDURI != nil && c.Value != nil {
+			return parseAudioChannelConfiguration(*c.SchemeIDURI, *c.Value)
+		}
+	}
+
+	return 0, false
+}
+
+const cicpChannelConfiguration = "urn:mpeg:mpegB:cicp:ChannelConfiguration"
+
+// cicpChannels maps the ChannelConfiguration values of ISO/IEC 23001-8
+// to their number of channels
+var cicpChannels = map[int]int{
+	1: 1, 2: 2, 3: 3, 4: 4, 5: 5, 6: 6, 7: 8, 9: 3, 10: 4, 11: 7,
+	12: 8, 13: 24, 14: 8, 15: 12, 16: 10, 17: 12, 18: 14, 19: 12, 20: 14,
+}
+
+// dolbyChannelPairs is the mask of the bits of a Dolby audio channel
+// configuration that stand for a pair of channels
+const dolbyChannelPairs = 1<<10 | 1<<9 | 1<<6 | 1<<5 | 1<<4 | 1<<2
+
+// parseAudioChannelConfiguration returns the number of channels of an
+// AudioChannelConfiguration value according to its scheme
+func parseAudioChannelConfiguration(scheme, value string) (int, bool) {
+	switch scheme {
+	case string(mpd.AUDIO_CHANNEL_CONFIGURATION_MPEG_DASH):
+		count, err := strconv.Atoi(value)
+		if err != nil {
+			return 0, false
+		}
+		return count, true
+	case string(mpd.AUDIO_CHANNEL_CONFIGURATION_MPEG_DOLBY):
+		mask, err := strconv.ParseUint(value, 16, 16)
+		if err != nil {
+			return 0, false
+		}
+		return bits.OnesCount16(uint16(mask)) + bits.OnesCount16(uint16(mask)&dolbyChannelPairs), true
+	case cicpChannelConfiguration:
+		config, err := strconv.Atoi(value)
+		if err != nil {
+			return 0, false
+		}
+		count, found := cicpChannels[config]
+		return count, found
+	}
+
+	return 0, false
+}
+
+// filterRenditions keeps the video representations chosen by the renditions
+// filter in each video adaptation set
+func (d *DASHFilter) filterRenditions(filters *parsers.MediaFilters, manifest *mpd.MPD) error {
+	for _, period := range manifest.Periods {
+		for _, as := range period.AdaptationSets {
+			if as.ContentType == nil || ContentType(*as.ContentType) != videoContentType {
+				continue
+			}
+
+			var bandwidths []int
+			for _, r := range as.Representations {
+				var bw int
+				if r.Bandwidth != nil {
+					bw = int(*r.Bandwidth)
+				}
+				bandwidths = append(bandwidths, bw)
+			}
+
+			kept := selectRenditions(filters.Renditions, bandwidths)
+			var filteredRepresentations []*mpd.Representation
+			for i, r := range as.Representations {
+				if _, found := kept[i]; found {
+					filteredRepresentations = append(filteredRepresentations, r)
+				}
+			}
+
+			as.Representations = filteredRepresentations
+			updateMaxResolution(as)
+		}
+	}
+
+	return nil
+}
+
+// sortRepresentations orders the representations of each adaptation set following
+// the sort filter. Only video representations are promoted to the first position.
+func (d *DASHFilter) sortRepresentations(filters *parsers.MediaFilters, manifest *mpd.MPD) error {
+	for _, period := range manifest.Periods {
+		for _, as := range period.AdaptationSets {
+			video := as.ContentType != nil && ContentType(*as.ContentType) == videoContentType
+
+			var bandwidths []int
+			for _, r := range as.Representations {
+				var bw int
+				if r.Bandwidth != nil {
+					bw = int(*r.Bandwidth)
+				}
+				bandwidths = append(bandwidths, bw)
+			}
+
+			var sorted []*mpd.Representation
+			for _, index := range sortRenditions(filters.Sort, bandwidths, func(int) bool { return video }) {
+				sorted = append(sorted, as.Representations[index])
+			}
+
+			as.Representations = sorted
+		}
+	}
+
+	return nil
+}
+
+// updateMaxResolution sets the maxHeight and maxWidth of the adaptation set
+// to the largest resolution among its representations
+func updateMaxResolution(as *mpd.AdaptationSet) {
+	var maxHeight, maxWidth int
+	for _, r := range as.Representations {
+		if r.Height != nil {
+			if h := int(*r.Height); maxHeight < h {
+				maxHeight = h
+			}
+		}
+		if r.Width != nil {
+			if w := int(*r.Width); maxWidth < w {
+				maxWidth = w
+			}
+		}
+	}
+
+	if maxHeight > 0 {
+		maxHeightStr := strconv.Itoa(maxHeight)
+		as.MaxHeight = &maxHeightStr
+	}
+	if maxWidth > 0 {
+		maxWidthStr := strconv.Itoa(maxWidth)
+		as.MaxWidth = &maxWidthStr
+	}
 }
 
 func matchCodec(codec string, ct ContentType, supportedCodecs map[string]struct{}) bool {
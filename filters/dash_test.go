@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"math"
+	"net/url"
 	"testing"
 
 	"github.com/cbsinteractive/bakery/config"
@@ -475,6 +476,117 @@ func TestDASHFilter_FilterContent_audioCodecs(t *testing.T) {
 	}
 }
 
+func TestDASHFilter_FilterContent_allowedCodecs(t *testing.T) {
+	manifestWithMultiCodecs := `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-on-demand:2011" type="static" mediaPresentationDuration="PT6M16S" minBufferTime="PT1.97S">
+  <BaseURL>http://existing.base/url/</BaseURL>
+  <Period>
+    <AdaptationSet id="0" lang="en" contentType="video">
+      <Representation bandwidth="256" codecs="hvc1.2.4.L93.90" id="0"></Representation>
+      <Representation bandwidth="256" codecs="avc1.640028" id="1"></Representation>
+    </AdaptationSet>
+    <AdaptationSet id="1" lang="en" contentType="video">
+      <Representation bandwidth="256" codecs="dvh1.05.01" id="0"></Representation>
+    </AdaptationSet>
+    <AdaptationSet id="2" lang="en" contentType="audio">
+      <Representation bandwidth="256" codecs="mp4a.40.2" id="0"></Representation>
+      <Representation bandwidth="256" codecs="ec-3" id="1"></Representation>
+    </AdaptationSet>
+    <AdaptationSet id="3" lang="en" contentType="text">
+      <Representation bandwidth="256" codecs="wvtt" id="0"></Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>
+`
+
+	manifestOnlyAVC := `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-on-demand:2011" type="static" mediaPresentationDuration="PT6M16S" minBufferTime="PT1.97S">
+  <BaseURL>http://existing.base/url/</BaseURL>
+  <Period>
+    <AdaptationSet id="0" lang="en" contentType="video">
+      <Representation bandwidth="256" codecs="avc1.640028" id="1"></Representation>
+    </AdaptationSet>
+    <AdaptationSet id="1" lang="en" contentType="audio">
+      <Representation bandwidth="256" codecs="mp4a.40.2" id="0"></Representation>
+      <Representation bandwidth="256" codecs="ec-3" id="1"></Representation>
+    </AdaptationSet>
+    <AdaptationSet id="2" lang="en" contentType="text">
+      <Representation bandwidth="256" codecs="wvtt" id="0"></Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>
+`
+
+	manifestOnlyAVCAndMP4A := `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-on-demand:2011" type="static" mediaPresentationDuration="PT6M16S" minBufferTime="PT1.97S">
+  <BaseURL>http://existing.base/url/</BaseURL>
+  <Period>
+    <AdaptationSet id="0" lang="en" contentType="video">
+      <Representation bandwidth="256" codecs="avc1.640028" id="1"></Representation>
+    </AdaptationSet>
+    <AdaptationSet id="1" lang="en" contentType="audio">
+      <Representation bandwidth="256" codecs="mp4a.40.2" id="0"></Representation>
+    </AdaptationSet>
+    <AdaptationSet id="2" lang="en" contentType="text">
+      <Representation bandwidth="256" codecs="wvtt" id="0"></Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>
+`
+
+	tests := []struct {
+		name                  string
+		filters               *parsers.MediaFilters
+		manifestContent       string
+		expectManifestContent string
+		expectErr             bool
+	}{
+		{
+			name: "when only avc is allowed, expect other video representations to be removed",
+			filters: &parsers.MediaFilters{
+				Videos: parsers.NestedFilters{
+					Only: []string{"avc"},
+				},
+			},
+			manifestContent:       manifestWithMultiCodecs,
+			expectManifestContent: manifestOnlyAVC,
+		},
+		{
+			name: "when only avc and mp4a are allowed, expect other video and audio representations to be removed",
+			filters: &parsers.MediaFilters{
+				Videos: parsers.NestedFilters{
+					Only: []string{"avc"},
+				},
+				Audios: parsers.NestedFilters{
+					Only: []string{"mp4a"},
+				},
+			},
+			manifestContent:       manifestWithMultiCodecs,
+			expectManifestContent: manifestOnlyAVCAndMP4A,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			filter := NewDASHFilter("", tt.manifestContent, config.Config{})
+
+			manifest, err := filter.FilterContent(context.Background(), tt.filters)
+			if err != nil && !tt.expectErr {
+				t.Errorf("FilterContent(context.Background(), ) didn't expect error to be returned, got: %v", err)
+				return
+			} else if err == nil && tt.expectErr {
+				t.Error("FilterContent(context.Background(), ) expected an error, got nil")
+				return
+			}
+
+			if g, e := manifest, tt.expectManifestContent; g != e {
+				t.Fatalf("FilterContent(context.Background(), ) returned wrong manifest\ngot %v\nexpected %v\ndiff: %v", g, e, cmp.Diff(g, e))
+			}
+		})
+	}
+}
+
 func TestDASHFilter_FilterContent_captionTypes(t *testing.T) {
 	manifestWithWVTTAndSTPPCaptions := `<?xml version="1.0" encoding="UTF-8"?>
 <MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-on-demand:2011" type="static" mediaPresentationDuration="PT6M16S" minBufferTime="PT1.97S">
@@ -958,95 +1070,59 @@ func TestDASHFilter_FilterContent_bitrate(t *testing.T) {
 	}
 }
 
-func TestDASHFilter_FilterContent_LanguageFilter(t *testing.T) {
-	manifestWithMultiLanguages := `<?xml version="1.0" encoding="UTF-8"?>
+func TestDASHFilter_FilterContent_resolution(t *testing.T) {
+	baseManifest := `<?xml version="1.0" encoding="UTF-8"?>
 <MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-on-demand:2011" type="static" mediaPresentationDuration="PT6M16S" minBufferTime="PT1.97S">
   <BaseURL>http://existing.base/url/</BaseURL>
   <Period>
-    <AdaptationSet id="0" lang="en" contentType="video">
-      <Representation bandwidth="256" codecs="hvc1.2.4.L93.90" id="0"></Representation>
-      <Representation bandwidth="256" codecs="hvc1.2.4.L90.90" id="1"></Representation>
+    <AdaptationSet id="0" lang="en" maxWidth="1920" maxHeight="1080" contentType="video">
+      <Representation bandwidth="2048" codecs="avc" height="360" id="0" width="640"></Representation>
+      <Representation bandwidth="4096" codecs="avc" height="720" id="1" width="1280"></Representation>
+      <Representation bandwidth="8192" codecs="avc" height="1080" id="2" width="1920"></Representation>
     </AdaptationSet>
     <AdaptationSet id="1" lang="en" contentType="audio">
-      <Representation bandwidth="256" codecs="mp4a.40.2" id="0"></Representation>
-    </AdaptationSet>
-    <AdaptationSet id="2" lang="en" contentType="text">
-      <Representation bandwidth="256" codecs="wvtt" id="0"></Representation>
-    </AdaptationSet>
-    <AdaptationSet id="3" lang="es" contentType="audio">
-      <Representation bandwidth="256" codecs="mp4a.40.2" id="0"></Representation>
-    </AdaptationSet>
-    <AdaptationSet id="4" lang="es" contentType="text">
-      <Representation bandwidth="256" codecs="wvtt" id="0"></Representation>
-    </AdaptationSet>
-    <AdaptationSet id="5" lang="pt" contentType="audio">
-      <Representation bandwidth="256" codecs="mp4a.40.2" id="0"></Representation>
-    </AdaptationSet>
-    <AdaptationSet id="6" lang="pt" contentType="text">
-      <Representation bandwidth="256" codecs="wvtt" id="0"></Representation>
+      <Representation bandwidth="256" codecs="ac-3" id="0"></Representation>
     </AdaptationSet>
   </Period>
 </MPD>
 `
 
-	manifestWithNoSpanish := `<?xml version="1.0" encoding="UTF-8"?>
+	manifestUpTo720p := `<?xml version="1.0" encoding="UTF-8"?>
 <MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-on-demand:2011" type="static" mediaPresentationDuration="PT6M16S" minBufferTime="PT1.97S">
   <BaseURL>http://existing.base/url/</BaseURL>
   <Period>
-    <AdaptationSet id="0" lang="en" contentType="video">
-      <Representation bandwidth="256" codecs="hvc1.2.4.L93.90" id="0"></Representation>
-      <Representation bandwidth="256" codecs="hvc1.2.4.L90.90" id="1"></Representation>
+    <AdaptationSet id="0" lang="en" maxWidth="1280" maxHeight="720" contentType="video">
+      <Representation bandwidth="2048" codecs="avc" height="360" id="0" width="640"></Representation>
+      <Representation bandwidth="4096" codecs="avc" height="720" id="1" width="1280"></Representation>
     </AdaptationSet>
     <AdaptationSet id="1" lang="en" contentType="audio">
-      <Representation bandwidth="256" codecs="mp4a.40.2" id="0"></Representation>
-    </AdaptationSet>
-    <AdaptationSet id="2" lang="en" contentType="text">
-      <Representation bandwidth="256" codecs="wvtt" id="0"></Representation>
-    </AdaptationSet>
-    <AdaptationSet id="3" lang="pt" contentType="audio">
-      <Representation bandwidth="256" codecs="mp4a.40.2" id="0"></Representation>
-    </AdaptationSet>
-    <AdaptationSet id="4" lang="pt" contentType="text">
-      <Representation bandwidth="256" codecs="wvtt" id="0"></Representation>
+      <Representation bandwidth="256" codecs="ac-3" id="0"></Representation>
     </AdaptationSet>
   </Period>
 </MPD>
 `
 
-	manifestWithNoSpanishAndPortugese := `<?xml version="1.0" encoding="UTF-8"?>
+	manifestWidthFrom1280 := `<?xml version="1.0" encoding="UTF-8"?>
 <MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-on-demand:2011" type="static" mediaPresentationDuration="PT6M16S" minBufferTime="PT1.97S">
   <BaseURL>http://existing.base/url/</BaseURL>
   <Period>
-    <AdaptationSet id="0" lang="en" contentType="video">
-      <Representation bandwidth="256" codecs="hvc1.2.4.L93.90" id="0"></Representation>
-      <Representation bandwidth="256" codecs="hvc1.2.4.L90.90" id="1"></Representation>
+    <AdaptationSet id="0" lang="en" maxWidth="1920" maxHeight="1080" contentType="video">
+      <Representation bandwidth="4096" codecs="avc" height="720" id="1" width="1280"></Representation>
+      <Representation bandwidth="8192" codecs="avc" height="1080" id="2" width="1920"></Representation>
     </AdaptationSet>
     <AdaptationSet id="1" lang="en" contentType="audio">
-      <Representation bandwidth="256" codecs="mp4a.40.2" id="0"></Representation>
-    </AdaptationSet>
-    <AdaptationSet id="2" lang="en" contentType="text">
-      <Representation bandwidth="256" codecs="wvtt" id="0"></Representation>
+      <Representation bandwidth="256" codecs="ac-3" id="0"></Representation>
     </AdaptationSet>
   </Period>
 </MPD>
 `
 
-	manifestWithNoCaptions := `<?xml version="1.0" encoding="UTF-8"?>
+	manifestWithoutVideo := `<?xml version="1.0" encoding="UTF-8"?>
 <MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-on-demand:2011" type="static" mediaPresentationDuration="PT6M16S" minBufferTime="PT1.97S">
   <BaseURL>http://existing.base/url/</BaseURL>
   <Period>
-    <AdaptationSet id="0" lang="en" contentType="video">
-      <Representation bandwidth="256" codecs="hvc1.2.4.L93.90" id="0"></Representation>
-      <Representation bandwidth="256" codecs="hvc1.2.4.L90.90" id="1"></Representation>
-    </AdaptationSet>
-    <AdaptationSet id="1" lang="en" contentType="audio">
-      <Representation bandwidth="256" codecs="mp4a.40.2" id="0"></Representation>
-    </AdaptationSet>
-    <AdaptationSet id="2" lang="es" contentType="audio">
-      <Representation bandwidth="256" codecs="mp4a.40.2" id="0"></Representation>
-    </AdaptationSet>
-    <AdaptationSet id="3" lang="pt" contentType="audio">
-      <Representation bandwidth="256" codecs="mp4a.40.2" id="0"></Representation>
+    <AdaptationSet id="0" lang="en" contentType="audio">
+      <Representation bandwidth="256" codecs="ac-3" id="0"></Representation>
     </AdaptationSet>
   </Period>
 </MPD>
@@ -1060,46 +1136,40 @@ func TestDASHFilter_FilterContent_LanguageFilter(t *testing.T) {
 		expectErr             bool
 	}{
 		{
-			name:                  "when no filters are set, nothing is stripped from manifest",
+			name:                  "when no filters are given, nothing is stripped from manifest",
 			filters:               &parsers.MediaFilters{},
-			manifestContent:       manifestWithMultiLanguages,
-			expectManifestContent: manifestWithMultiLanguages,
+			manifestContent:       baseManifest,
+			expectManifestContent: baseManifest,
 		},
 		{
-			name: "when es lang is set, adaptation sets with es are stripped from manifest",
+			name: "when a max height is set, expect representations above it removed and max dimensions updated",
 			filters: &parsers.MediaFilters{
-				Audios: parsers.NestedFilters{
-					Language: []string{"es"},
-				},
-				Captions: parsers.NestedFilters{
-					Language: []string{"es"},
+				Videos: parsers.NestedFilters{
+					Height: &parsers.Resolution{Min: 0, Max: 720},
 				},
 			},
-			manifestContent:       manifestWithMultiLanguages,
-			expectManifestContent: manifestWithNoSpanish,
+			manifestContent:       baseManifest,
+			expectManifestContent: manifestUpTo720p,
 		},
 		{
-			name: "when es and pt lang is set, adaptation sets with es and pt are stripped from manifest",
+			name: "when a min width is set, expect representations below it removed",
 			filters: &parsers.MediaFilters{
-				Audios: parsers.NestedFilters{
-					Language: []string{"es", "pt"},
-				},
-				Captions: parsers.NestedFilters{
-					Language: []string{"es", "pt"},
+				Videos: parsers.NestedFilters{
+					Width: &parsers.Resolution{Min: 1280, Max: math.MaxInt32},
 				},
 			},
-			manifestContent:       manifestWithMultiLanguages,
-			expectManifestContent: manifestWithNoSpanishAndPortugese,
+			manifestContent:       baseManifest,
+			expectManifestContent: manifestWidthFrom1280,
 		},
 		{
-			name: "when es, pt, and en caption filters are set, expect those captions to be removed",
+			name: "when no representation is in range, expect the video adaptation set to be removed",
 			filters: &parsers.MediaFilters{
-				Captions: parsers.NestedFilters{
-					Language: []string{"es", "pt", "en"},
+				Videos: parsers.NestedFilters{
+					Height: &parsers.Resolution{Min: 2160, Max: math.MaxInt32},
 				},
 			},
-			manifestContent:       manifestWithMultiLanguages,
-			expectManifestContent: manifestWithNoCaptions,
+			manifestContent:       baseManifest,
+			expectManifestContent: manifestWithoutVideo,
 		},
 	}
 
@@ -1110,7 +1180,7 @@ func TestDASHFilter_FilterContent_LanguageFilter(t *testing.T) {
 
 			manifest, err := filter.FilterContent(context.Background(), tt.filters)
 			if err != nil && !tt.expectErr {
-				t.Errorf("FilterContent(context.Background(), ) didnt expect an error to be returned, got: %v", err)
+				t.Errorf("FilterContent(context.Background(), ) didn't expect error to be returned, got: %v", err)
 				return
 			} else if err == nil && tt.expectErr {
 				t.Error("FilterContent(context.Background(), ) expected an error, got nil")
@@ -1118,67 +1188,72 @@ func TestDASHFilter_FilterContent_LanguageFilter(t *testing.T) {
 			}
 
 			if g, e := manifest, tt.expectManifestContent; g != e {
-				t.Errorf("FilterContent(context.Background(), ) wrong manifest returned\ngot %v\nexpected: %v\ndiff: %v", g, e,
-					cmp.Diff(g, e))
+				t.Fatalf("FilterContent(context.Background(), ) returned wrong manifest\ngot %v\nexpected %v\ndiff: %v", g, e, cmp.Diff(g, e))
 			}
 		})
 	}
-
 }
 
-func TestDASHFilter_FilterFrameRate(t *testing.T) {
-	manifestWithFrameRates := `<?xml version="1.0" encoding="UTF-8"?>
+func TestDASHFilter_FilterContent_channels(t *testing.T) {
+	baseManifest := `<?xml version="1.0" encoding="UTF-8"?>
 <MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-on-demand:2011" type="static" mediaPresentationDuration="PT6M16S" minBufferTime="PT1.97S">
   <BaseURL>http://existing.base/url/</BaseURL>
   <Period>
     <AdaptationSet id="0" lang="en" contentType="video">
-      <Representation bandwidth="2048" codecs="avc" frameRate="30000/1001" id="0"></Representation>
-      <Representation bandwidth="4096" codecs="avc" frameRate="30000/1001" id="1"></Representation>
-    </AdaptationSet>
-    <AdaptationSet frameRate="24000/1001" id="1" lang="en" contentType="video">
       <Representation bandwidth="2048" codecs="avc" id="0"></Representation>
-      <Representation bandwidth="4096" codecs="avc" id="1"></Representation>
     </AdaptationSet>
-    <AdaptationSet id="2" lang="en" contentType="video">
-      <Representation bandwidth="2048" codecs="avc" frameRate="30" id="0"></Representation>
-      <Representation bandwidth="4096" codecs="avc" frameRate="60" id="1"></Representation>
+    <AdaptationSet id="1" lang="en" contentType="audio">
+      <Representation bandwidth="128" codecs="mp4a.40.2" id="0">
+        <AudioChannelConfiguration schemeIdUri="urn:mpeg:dash:23003:3:audio_channel_configuration:2011" value="2"></AudioChannelConfiguration>
+      </Representation>
+      <Representation bandwidth="384" codecs="mp4a.40.2" id="1">
+        <AudioChannelConfiguration schemeIdUri="urn:mpeg:mpegB:cicp:ChannelConfiguration" value="6"></AudioChannelConfiguration>
+      </Representation>
+    </AdaptationSet>
+    <AdaptationSet id="2" lang="en" contentType="audio">
+      <Representation bandwidth="640" codecs="ec-3" id="0">
+        <AudioChannelConfiguration schemeIdUri="tag:dolby.com,2014:dash:audio_channel_configuration:2011" value="F801"></AudioChannelConfiguration>
+      </Representation>
     </AdaptationSet>
   </Period>
 </MPD>
 `
 
-	manifestWithNo30000FractionFPS := `<?xml version="1.0" encoding="UTF-8"?>
+	manifestStereoOnly := `<?xml version="1.0" encoding="UTF-8"?>
 <MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-on-demand:2011" type="static" mediaPresentationDuration="PT6M16S" minBufferTime="PT1.97S">
   <BaseURL>http://existing.base/url/</BaseURL>
   <Period>
-    <AdaptationSet frameRate="24000/1001" id="0" lang="en" contentType="video">
+    <AdaptationSet id="0" lang="en" contentType="video">
       <Representation bandwidth="2048" codecs="avc" id="0"></Representation>
-      <Representation bandwidth="4096" codecs="avc" id="1"></Representation>
     </AdaptationSet>
-    <AdaptationSet id="1" lang="en" contentType="video">
-      <Representation bandwidth="2048" codecs="avc" frameRate="30" id="0"></Representation>
-      <Representation bandwidth="4096" codecs="avc" frameRate="60" id="1"></Representation>
+    <AdaptationSet id="1" lang="en" contentType="audio">
+      <Representation bandwidth="128" codecs="mp4a.40.2" id="0">
+        <AudioChannelConfiguration schemeIdUri="urn:mpeg:dash:23003:3:audio_channel_configuration:2011" value="2"></AudioChannelConfiguration>
+      </Representation>
     </AdaptationSet>
   </Period>
 </MPD>
 `
 
-	manifestWithOnly60FPS := `<?xml version="1.0" encoding="UTF-8"?>
+	manifestSurroundOnly := `<?xml version="1.0" encoding="UTF-8"?>
 <MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-on-demand:2011" type="static" mediaPresentationDuration="PT6M16S" minBufferTime="PT1.97S">
   <BaseURL>http://existing.base/url/</BaseURL>
   <Period>
     <AdaptationSet id="0" lang="en" contentType="video">
-      <Representation bandwidth="4096" codecs="avc" frameRate="60" id="1"></Representation>
+      <Representation bandwidth="2048" codecs="avc" id="0"></Representation>
+    </AdaptationSet>
+    <AdaptationSet id="1" lang="en" contentType="audio">
+      <Representation bandwidth="384" codecs="mp4a.40.2" id="1">
+        <AudioChannelConfiguration schemeIdUri="urn:mpeg:mpegB:cicp:ChannelConfiguration" value="6"></AudioChannelConfiguration>
+      </Representation>
+    </AdaptationSet>
+    <AdaptationSet id="2" lang="en" contentType="audio">
+      <Representation bandwidth="640" codecs="ec-3" id="0">
+        <AudioChannelConfiguration schemeIdUri="tag:dolby.com,2014:dash:audio_channel_configuration:2011" value="F801"></AudioChannelConfiguration>
+      </Representation>
     </AdaptationSet>
   </Period>
 </MPD>
-`
-
-	manifestWithNoFrameRates := `<?xml version="1.0" encoding="UTF-8"?>
-<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-on-demand:2011" type="static" mediaPresentationDuration="PT6M16S" minBufferTime="PT1.97S">
-  <BaseURL>http://existing.base/url/</BaseURL>
-  <Period></Period>
-</MPD>
 `
 
 	tests := []struct {
@@ -1189,29 +1264,927 @@ func TestDASHFilter_FilterFrameRate(t *testing.T) {
 		expectErr             bool
 	}{
 		{
-			name:                  "when no filters are passed in, nothing is removed from manifest",
+			name:                  "when no filters are given, nothing is stripped from manifest",
 			filters:               &parsers.MediaFilters{},
-			manifestContent:       manifestWithFrameRates,
-			expectManifestContent: manifestWithFrameRates,
+			manifestContent:       baseManifest,
+			expectManifestContent: baseManifest,
 		},
 		{
-			name: "when multiple framerates are set, it removes all the representations associated",
+			name: "when a max number of channels is set, expect surround representations and empty adaptation sets removed",
 			filters: &parsers.MediaFilters{
-				FrameRate: []string{"30000/1001", "24000/1001", "30"},
+				Audios: parsers.NestedFilters{
+					Channels: &parsers.Channels{Min: 0, Max: 2},
+				},
 			},
-			manifestContent:       manifestWithFrameRates,
-			expectManifestContent: manifestWithOnly60FPS,
+			manifestContent:       baseManifest,
+			expectManifestContent: manifestStereoOnly,
 		},
 		{
-			name: "when framerate is set with fraction representation, it removes its associated representations",
+			name: "when a min number of channels is set, expect stereo representations removed",
 			filters: &parsers.MediaFilters{
-				FrameRate: []string{"30000/1001"},
+				Audios: parsers.NestedFilters{
+					Channels: &parsers.Channels{Min: 6, Max: math.MaxInt32},
+				},
 			},
-			manifestContent:       manifestWithFrameRates,
-			expectManifestContent: manifestWithNo30000FractionFPS,
+			manifestContent:       baseManifest,
+			expectManifestContent: manifestSurroundOnly,
 		},
-		{
-			name: "when all framerates are sent, return an empty manifest",
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			filter := NewDASHFilter("", tt.manifestContent, config.Config{})
+
+			manifest, err := filter.FilterContent(context.Background(), tt.filters)
+			if err != nil && !tt.expectErr {
+				t.Errorf("FilterContent(context.Background(), ) didn't expect error to be returned, got: %v", err)
+				return
+			} else if err == nil && tt.expectErr {
+				t.Error("FilterContent(context.Background(), ) expected an error, got nil")
+				return
+			}
+
+			if g, e := manifest, tt.expectManifestContent; g != e {
+				t.Fatalf("FilterContent(context.Background(), ) returned wrong manifest\ngot %v\nexpected %v\ndiff: %v", g, e, cmp.Diff(g, e))
+			}
+		})
+	}
+}
+
+func TestDASHFilter_FilterContent_sort(t *testing.T) {
+	baseManifest := `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-on-demand:2011" type="static" mediaPresentationDuration="PT6M16S" minBufferTime="PT1.97S">
+  <BaseURL>http://existing.base/url/</BaseURL>
+  <Period>
+    <AdaptationSet id="0" lang="en" maxWidth="1920" maxHeight="1080" contentType="video">
+      <Representation bandwidth="2048" codecs="avc" height="360" id="0" width="640"></Representation>
+      <Representation bandwidth="8192" codecs="avc" height="1080" id="1" width="1920"></Representation>
+      <Representation bandwidth="4096" codecs="avc" height="720" id="2" width="1280"></Representation>
+    </AdaptationSet>
+    <AdaptationSet id="1" lang="en" contentType="audio">
+      <Representation bandwidth="256" codecs="mp4a.40.2" id="1"></Representation>
+      <Representation bandwidth="128" codecs="mp4a.40.2" id="0"></Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>
+`
+
+	manifestAscending := `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-on-demand:2011" type="static" mediaPresentationDuration="PT6M16S" minBufferTime="PT1.97S">
+  <BaseURL>http://existing.base/url/</BaseURL>
+  <Period>
+    <AdaptationSet id="0" lang="en" maxWidth="1920" maxHeight="1080" contentType="video">
+      <Representation bandwidth="2048" codecs="avc" height="360" id="0" width="640"></Representation>
+      <Representation bandwidth="4096" codecs="avc" height="720" id="2" width="1280"></Representation>
+      <Representation bandwidth="8192" codecs="avc" height="1080" id="1" width="1920"></Representation>
+    </AdaptationSet>
+    <AdaptationSet id="1" lang="en" contentType="audio">
+      <Representation bandwidth="128" codecs="mp4a.40.2" id="0"></Representation>
+      <Representation bandwidth="256" codecs="mp4a.40.2" id="1"></Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>
+`
+
+	manifestDescending := `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-on-demand:2011" type="static" mediaPresentationDuration="PT6M16S" minBufferTime="PT1.97S">
+  <BaseURL>http://existing.base/url/</BaseURL>
+  <Period>
+    <AdaptationSet id="0" lang="en" maxWidth="1920" maxHeight="1080" contentType="video">
+      <Representation bandwidth="8192" codecs="avc" height="1080" id="1" width="1920"></Representation>
+      <Representation bandwidth="4096" codecs="avc" height="720" id="2" width="1280"></Representation>
+      <Representation bandwidth="2048" codecs="avc" height="360" id="0" width="640"></Representation>
+    </AdaptationSet>
+    <AdaptationSet id="1" lang="en" contentType="audio">
+      <Representation bandwidth="256" codecs="mp4a.40.2" id="1"></Representation>
+      <Representation bandwidth="128" codecs="mp4a.40.2" id="0"></Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>
+`
+
+	manifestAscendingWithTargetFirst := `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-on-demand:2011" type="static" mediaPresentationDuration="PT6M16S" minBufferTime="PT1.97S">
+  <BaseURL>http://existing.base/url/</BaseURL>
+  <Period>
+    <AdaptationSet id="0" lang="en" maxWidth="1920" maxHeight="1080" contentType="video">
+      <Representation bandwidth="4096" codecs="avc" height="720" id="2" width="1280"></Representation>
+      <Representation bandwidth="2048" codecs="avc" height="360" id="0" width="640"></Representation>
+      <Representation bandwidth="8192" codecs="avc" height="1080" id="1" width="1920"></Representation>
+    </AdaptationSet>
+    <AdaptationSet id="1" lang="en" contentType="audio">
+      <Representation bandwidth="128" codecs="mp4a.40.2" id="0"></Representation>
+      <Representation bandwidth="256" codecs="mp4a.40.2" id="1"></Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>
+`
+
+	tests := []struct {
+		name                  string
+		filters               *parsers.MediaFilters
+		manifestContent       string
+		expectManifestContent string
+		expectErr             bool
+	}{
+		{
+			name: "when ascending order is set, expect representations of every adaptation set from the lowest bitrate",
+			filters: &parsers.MediaFilters{
+				Sort: &parsers.Sort{Order: parsers.SortAscending},
+			},
+			manifestContent:       baseManifest,
+			expectManifestContent: manifestAscending,
+		},
+		{
+			name: "when descending order is set, expect representations of every adaptation set from the highest bitrate",
+			filters: &parsers.MediaFilters{
+				Sort: &parsers.Sort{Order: parsers.SortDescending},
+			},
+			manifestContent:       baseManifest,
+			expectManifestContent: manifestDescending,
+		},
+		{
+			name: "when a target bitrate is set, expect the closest video representation first and audio untouched by the target",
+			filters: &parsers.MediaFilters{
+				Sort: &parsers.Sort{Order: parsers.SortAscending, Target: 4000},
+			},
+			manifestContent:       baseManifest,
+			expectManifestContent: manifestAscendingWithTargetFirst,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			filter := NewDASHFilter("", tt.manifestContent, config.Config{})
+
+			manifest, err := filter.FilterContent(context.Background(), tt.filters)
+			if err != nil && !tt.expectErr {
+				t.Errorf("FilterContent(context.Background(), ) didn't expect error to be returned, got: %v", err)
+				return
+			} else if err == nil && tt.expectErr {
+				t.Error("FilterContent(context.Background(), ) expected an error, got nil")
+				return
+			}
+
+			if g, e := manifest, tt.expectManifestContent; g != e {
+				t.Fatalf("FilterContent(context.Background(), ) returned wrong manifest\ngot %v\nexpected %v\ndiff: %v", g, e, cmp.Diff(g, e))
+			}
+		})
+	}
+}
+
+func TestDASHFilter_FilterContent_renditions(t *testing.T) {
+	baseManifest := `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-on-demand:2011" type="static" mediaPresentationDuration="PT6M16S" minBufferTime="PT1.97S">
+  <BaseURL>http://existing.base/url/</BaseURL>
+  <Period>
+    <AdaptationSet id="0" lang="en" maxWidth="1920" maxHeight="1080" contentType="video">
+      <Representation bandwidth="1024" codecs="avc" height="234" id="0" width="416"></Representation>
+      <Representation bandwidth="2048" codecs="avc" height="360" id="1" width="640"></Representation>
+      <Representation bandwidth="4096" codecs="avc" height="720" id="2" width="1280"></Representation>
+      <Representation bandwidth="8192" codecs="avc" height="1080" id="3" width="1920"></Representation>
+    </AdaptationSet>
+    <AdaptationSet id="1" lang="en" contentType="audio">
+      <Representation bandwidth="256" codecs="ac-3" id="0"></Representation>
+      <Representation bandwidth="128" codecs="ac-3" id="1"></Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>
+`
+
+	manifestHighest := `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-on-demand:2011" type="static" mediaPresentationDuration="PT6M16S" minBufferTime="PT1.97S">
+  <BaseURL>http://existing.base/url/</BaseURL>
+  <Period>
+    <AdaptationSet id="0" lang="en" maxWidth="1920" maxHeight="1080" contentType="video">
+      <Representation bandwidth="4096" codecs="avc" height="720" id="2" width="1280"></Representation>
+      <Representation bandwidth="8192" codecs="avc" height="1080" id="3" width="1920"></Representation>
+    </AdaptationSet>
+    <AdaptationSet id="1" lang="en" contentType="audio">
+      <Representation bandwidth="256" codecs="ac-3" id="0"></Representation>
+      <Representation bandwidth="128" codecs="ac-3" id="1"></Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>
+`
+
+	manifestLowest := `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-on-demand:2011" type="static" mediaPresentationDuration="PT6M16S" minBufferTime="PT1.97S">
+  <BaseURL>http://existing.base/url/</BaseURL>
+  <Period>
+    <AdaptationSet id="0" lang="en" maxWidth="640" maxHeight="360" contentType="video">
+      <Representation bandwidth="1024" codecs="avc" height="234" id="0" width="416"></Representation>
+      <Representation bandwidth="2048" codecs="avc" height="360" id="1" width="640"></Representation>
+    </AdaptationSet>
+    <AdaptationSet id="1" lang="en" contentType="audio">
+      <Representation bandwidth="256" codecs="ac-3" id="0"></Representation>
+      <Representation bandwidth="128" codecs="ac-3" id="1"></Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>
+`
+
+	manifestSpread := `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-on-demand:2011" type="static" mediaPresentationDuration="PT6M16S" minBufferTime="PT1.97S">
+  <BaseURL>http://existing.base/url/</BaseURL>
+  <Period>
+    <AdaptationSet id="0" lang="en" maxWidth="1920" maxHeight="1080" contentType="video">
+      <Representation bandwidth="1024" codecs="avc" height="234" id="0" width="416"></Representation>
+      <Representation bandwidth="8192" codecs="avc" height="1080" id="3" width="1920"></Representation>
+    </AdaptationSet>
+    <AdaptationSet id="1" lang="en" contentType="audio">
+      <Representation bandwidth="256" codecs="ac-3" id="0"></Representation>
+      <Representation bandwidth="128" codecs="ac-3" id="1"></Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>
+`
+
+	tests := []struct {
+		name                  string
+		filters               *parsers.MediaFilters
+		manifestContent       string
+		expectManifestContent string
+		expectErr             bool
+	}{
+		{
+			name: "when the highest renditions are kept, expect lower video representations removed",
+			filters: &parsers.MediaFilters{
+				Renditions: &parsers.Renditions{Count: 2, Strategy: parsers.RenditionsHighest},
+			},
+			manifestContent:       baseManifest,
+			expectManifestContent: manifestHighest,
+		},
+		{
+			name: "when the lowest renditions are kept, expect higher video representations removed and max dimensions updated",
+			filters: &parsers.MediaFilters{
+				Renditions: &parsers.Renditions{Count: 2, Strategy: parsers.RenditionsLowest},
+			},
+			manifestContent:       baseManifest,
+			expectManifestContent: manifestLowest,
+		},
+		{
+			name: "when renditions are spread, expect both ends of the ladder to be kept",
+			filters: &parsers.MediaFilters{
+				Renditions: &parsers.Renditions{Count: 2, Strategy: parsers.RenditionsSpread},
+			},
+			manifestContent:       baseManifest,
+			expectManifestContent: manifestSpread,
+		},
+		{
+			name: "when the count is larger than the ladder, nothing is stripped from manifest",
+			filters: &parsers.MediaFilters{
+				Renditions: &parsers.Renditions{Count: 5, Strategy: parsers.RenditionsLowest},
+			},
+			manifestContent:       baseManifest,
+			expectManifestContent: baseManifest,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			filter := NewDASHFilter("", tt.manifestContent, config.Config{})
+
+			manifest, err := filter.FilterContent(context.Background(), tt.filters)
+			if err != nil && !tt.expectErr {
+				t.Errorf("FilterContent(context.Background(), ) didn't expect error to be returned, got: %v", err)
+				return
+			} else if err == nil && tt.expectErr {
+				t.Error("FilterContent(context.Background(), ) expected an error, got nil")
+				return
+			}
+
+			if g, e := manifest, tt.expectManifestContent; g != e {
+				t.Fatalf("FilterContent(context.Background(), ) returned wrong manifest\ngot %v\nexpected %v\ndiff: %v", g, e, cmp.Diff(g, e))
+			}
+		})
+	}
+}
+
+func TestDASHFilter_FilterContent_dvr(t *testing.T) {
+	liveManifest := `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-live:2011" type="dynamic" minBufferTime="PT2S" availabilityStartTime="2020-03-11T00:00:00Z" minimumUpdatePeriod="PT6S" publishTime="2020-03-11T04:00:00Z" timeShiftBufferDepth="PT4H">
+  <BaseURL>http://existing.base/url/</BaseURL>
+  <Period id="0" start="PT0S">
+    <AdaptationSet id="0" contentType="video">
+      <Representation bandwidth="2048" codecs="avc" height="360" id="0" width="640"></Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>
+`
+
+	liveManifestWithDVRWindow := `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-live:2011" type="dynamic" minBufferTime="PT2S" availabilityStartTime="2020-03-11T00:00:00Z" minimumUpdatePeriod="PT6S" publishTime="2020-03-11T04:00:00Z" timeShiftBufferDepth="PT30M0S">
+  <BaseURL>http://existing.base/url/</BaseURL>
+  <Period id="0" start="PT0S">
+    <AdaptationSet id="0" contentType="video">
+      <Representation bandwidth="2048" codecs="avc" height="360" id="0" width="640"></Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>
+`
+
+	vodManifest := `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-on-demand:2011" type="static" mediaPresentationDuration="PT6M16S" minBufferTime="PT1.97S">
+  <BaseURL>http://existing.base/url/</BaseURL>
+  <Period>
+    <AdaptationSet id="0" contentType="video">
+      <Representation bandwidth="2048" codecs="avc" height="360" id="0" width="640"></Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>
+`
+
+	tests := []struct {
+		name                  string
+		filters               *parsers.MediaFilters
+		manifestContent       string
+		expectManifestContent string
+		expectErr             bool
+	}{
+		{
+			name:                  "when a dvr window is shorter than the time shift buffer, expect the buffer to be shortened",
+			filters:               &parsers.MediaFilters{DVR: 1800},
+			manifestContent:       liveManifest,
+			expectManifestContent: liveManifestWithDVRWindow,
+		},
+		{
+			name:                  "when a dvr window is longer than the time shift buffer, expect the buffer to be kept",
+			filters:               &parsers.MediaFilters{DVR: 28800},
+			manifestContent:       liveManifest,
+			expectManifestContent: liveManifest,
+		},
+		{
+			name:                  "when a dvr window is set on a static manifest, expect no changes",
+			filters:               &parsers.MediaFilters{DVR: 1800},
+			manifestContent:       vodManifest,
+			expectManifestContent: vodManifest,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			filter := NewDASHFilter("", tt.manifestContent, config.Config{})
+
+			manifest, err := filter.FilterContent(context.Background(), tt.filters)
+			if err != nil && !tt.expectErr {
+				t.Errorf("FilterContent(context.Background(), ) didn't expect error to be returned, got: %v", err)
+				return
+			} else if err == nil && tt.expectErr {
+				t.Error("FilterContent(context.Background(), ) expected an error, got nil")
+				return
+			}
+
+			if g, e := manifest, tt.expectManifestContent; g != e {
+				t.Fatalf("FilterContent(context.Background(), ) returned wrong manifest\ngot %v\nexpected %v\ndiff: %v", g, e, cmp.Diff(g, e))
+			}
+		})
+	}
+}
+
+func TestDASHFilter_FilterContent_playlistType(t *testing.T) {
+	liveManifest := `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-live:2011" type="dynamic" minBufferTime="PT2S" availabilityStartTime="2020-03-11T00:00:00Z" minimumUpdatePeriod="PT6S" publishTime="2020-03-11T04:00:00Z" timeShiftBufferDepth="PT4H">
+  <BaseURL>http://existing.base/url/</BaseURL>
+  <Period id="0" start="PT10S">
+    <AdaptationSet id="0" contentType="video">
+      <SegmentTemplate presentationTimeOffset="90000" initialization="video_init.mp4" media="video_$Time$.mp4" timescale="90000">
+        <SegmentTimeline>
+          <S t="90000" d="540000" r="2"></S>
+          <S d="270000"></S>
+        </SegmentTimeline>
+      </SegmentTemplate>
+      <Representation bandwidth="2048" codecs="avc" height="360" id="0" width="640"></Representation>
+    </AdaptationSet>
+    <AdaptationSet id="1" contentType="audio">
+      <SegmentTemplate initialization="audio_init.mp4" media="audio_$Time$.mp4" timescale="48000">
+        <SegmentTimeline>
+          <S t="0" d="288000" r="3"></S>
+        </SegmentTimeline>
+      </SegmentTemplate>
+      <Representation bandwidth="128" codecs="mp4a.40.2" id="1"></Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>
+`
+
+	vodManifest := `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-live:2011" type="static" mediaPresentationDuration="PT24S" minBufferTime="PT2S" availabilityStartTime="2020-03-11T00:00:00Z" publishTime="2020-03-11T04:00:00Z">
+  <BaseURL>http://existing.base/url/</BaseURL>
+  <Period id="0" start="PT0S">
+    <AdaptationSet id="0" contentType="video">
+      <SegmentTemplate presentationTimeOffset="90000" initialization="video_init.mp4" media="video_$Time$.mp4" timescale="90000">
+        <SegmentTimeline>
+          <S t="90000" d="540000" r="2"></S>
+          <S d="270000"></S>
+        </SegmentTimeline>
+      </SegmentTemplate>
+      <Representation bandwidth="2048" codecs="avc" height="360" id="0" width="640"></Representation>
+    </AdaptationSet>
+    <AdaptationSet id="1" contentType="audio">
+      <SegmentTemplate initialization="audio_init.mp4" media="audio_$Time$.mp4" timescale="48000">
+        <SegmentTimeline>
+          <S t="0" d="288000" r="3"></S>
+        </SegmentTimeline>
+      </SegmentTemplate>
+      <Representation bandwidth="128" codecs="mp4a.40.2" id="1"></Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>
+`
+
+	slidingWindowManifest := `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-live:2011" type="dynamic" minBufferTime="PT2S" availabilityStartTime="2020-03-11T00:00:00Z" minimumUpdatePeriod="PT6S" publishTime="2020-03-11T04:00:00Z" timeShiftBufferDepth="PT30S">
+  <BaseURL>http://existing.base/url/</BaseURL>
+  <Period id="0" start="PT0S">
+    <AdaptationSet id="0" contentType="video">
+      <SegmentTemplate initialization="video_init.mp4" media="video_$Time$.mp4" timescale="90000">
+        <SegmentTimeline>
+          <S t="10800000" d="540000" r="4"></S>
+        </SegmentTimeline>
+      </SegmentTemplate>
+      <Representation bandwidth="2048" codecs="avc" height="360" id="0" width="640"></Representation>
+    </AdaptationSet>
+    <AdaptationSet id="1" contentType="audio">
+      <SegmentTemplate initialization="audio_init.mp4" media="audio_$Time$.mp4" timescale="48000">
+        <SegmentTimeline>
+          <S t="5760000" d="288000" r="4"></S>
+        </SegmentTimeline>
+      </SegmentTemplate>
+      <Representation bandwidth="128" codecs="mp4a.40.2" id="1"></Representation>
+    </AdaptationSet>
+  </Period>
+  <Period id="1" start="PT150S">
+    <AdaptationSet id="0" contentType="video">
+      <SegmentTemplate initialization="video_init.mp4" media="video_$Time$.mp4" timescale="90000">
+        <SegmentTimeline>
+          <S t="0" d="540000" r="1"></S>
+        </SegmentTimeline>
+      </SegmentTemplate>
+      <Representation bandwidth="2048" codecs="avc" height="360" id="0" width="640"></Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>
+`
+
+	slidingWindowVODManifest := `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-live:2011" type="static" mediaPresentationDuration="PT42S" minBufferTime="PT2S" availabilityStartTime="2020-03-11T00:00:00Z" publishTime="2020-03-11T04:00:00Z">
+  <BaseURL>http://existing.base/url/</BaseURL>
+  <Period id="0" start="PT0S">
+    <AdaptationSet id="0" contentType="video">
+      <SegmentTemplate presentationTimeOffset="10800000" initialization="video_init.mp4" media="video_$Time$.mp4" timescale="90000">
+        <SegmentTimeline>
+          <S t="10800000" d="540000" r="4"></S>
+        </SegmentTimeline>
+      </SegmentTemplate>
+      <Representation bandwidth="2048" codecs="avc" height="360" id="0" width="640"></Representation>
+    </AdaptationSet>
+    <AdaptationSet id="1" contentType="audio">
+      <SegmentTemplate presentationTimeOffset="5760000" initialization="audio_init.mp4" media="audio_$Time$.mp4" timescale="48000">
+        <SegmentTimeline>
+          <S t="5760000" d="288000" r="4"></S>
+        </SegmentTimeline>
+      </SegmentTemplate>
+      <Representation bandwidth="128" codecs="mp4a.40.2" id="1"></Representation>
+    </AdaptationSet>
+  </Period>
+  <Period id="1" start="PT30S">
+    <AdaptationSet id="0" contentType="video">
+      <SegmentTemplate initialization="video_init.mp4" media="video_$Time$.mp4" timescale="90000">
+        <SegmentTimeline>
+          <S t="0" d="540000" r="1"></S>
+        </SegmentTimeline>
+      </SegmentTemplate>
+      <Representation bandwidth="2048" codecs="avc" height="360" id="0" width="640"></Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>
+`
+
+	liveManifestWithoutTimeline := `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-live:2011" type="dynamic" minBufferTime="PT2S" availabilityStartTime="2020-03-11T00:00:00Z" minimumUpdatePeriod="PT6S" publishTime="2020-03-11T04:00:00Z" timeShiftBufferDepth="PT4H">
+  <BaseURL>http://existing.base/url/</BaseURL>
+  <Period id="0" start="PT0S">
+    <AdaptationSet id="0" contentType="video">
+      <SegmentTemplate duration="6" initialization="video_init.mp4" media="video_$Number$.mp4" startNumber="1" timescale="1"></SegmentTemplate>
+      <Representation bandwidth="2048" codecs="avc" height="360" id="0" width="640"></Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>
+`
+
+	tests := []struct {
+		name                  string
+		filters               *parsers.MediaFilters
+		manifestContent       string
+		expectManifestContent string
+		expectErr             bool
+	}{
+		{
+			name:                  "when vod type is given for a dynamic manifest, expect a static manifest ending with the longest timeline",
+			filters:               &parsers.MediaFilters{PlaylistType: parsers.PlaylistTypeVOD},
+			manifestContent:       liveManifest,
+			expectManifestContent: vodManifest,
+		},
+		{
+			name: "when vod type is given for a sliding window, expect a static manifest starting with the first " +
+				"segment left in the window",
+			filters:               &parsers.MediaFilters{PlaylistType: parsers.PlaylistTypeVOD},
+			manifestContent:       slidingWindowManifest,
+			expectManifestContent: slidingWindowVODManifest,
+		},
+		{
+			name:            "when vod type is given for a manifest without segment timelines, expect an error",
+			filters:         &parsers.MediaFilters{PlaylistType: parsers.PlaylistTypeVOD},
+			manifestContent: liveManifestWithoutTimeline,
+			expectErr:       true,
+		},
+		{
+			name:                  "when live type is given for a dynamic manifest, expect no changes",
+			filters:               &parsers.MediaFilters{PlaylistType: parsers.PlaylistTypeLive},
+			manifestContent:       liveManifest,
+			expectManifestContent: liveManifest,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			filter := NewDASHFilter("", tt.manifestContent, config.Config{})
+
+			manifest, err := filter.FilterContent(context.Background(), tt.filters)
+			if err != nil && !tt.expectErr {
+				t.Errorf("FilterContent(context.Background(), ) didn't expect error to be returned, got: %v", err)
+				return
+			} else if err == nil && tt.expectErr {
+				t.Error("FilterContent(context.Background(), ) expected an error, got nil")
+				return
+			}
+
+			if g, e := manifest, tt.expectManifestContent; g != e {
+				t.Fatalf("FilterContent(context.Background(), ) returned wrong manifest\ngot %v\nexpected %v\ndiff: %v", g, e, cmp.Diff(g, e))
+			}
+		})
+	}
+}
+
+func TestDASHFilter_FilterContent_LanguageFilter(t *testing.T) {
+	manifestWithMultiLanguages := `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-on-demand:2011" type="static" mediaPresentationDuration="PT6M16S" minBufferTime="PT1.97S">
+  <BaseURL>http://existing.base/url/</BaseURL>
+  <Period>
+    <AdaptationSet id="0" lang="en" contentType="video">
+      <Representation bandwidth="256" codecs="hvc1.2.4.L93.90" id="0"></Representation>
+      <Representation bandwidth="256" codecs="hvc1.2.4.L90.90" id="1"></Representation>
+    </AdaptationSet>
+    <AdaptationSet id="1" lang="en" contentType="audio">
+      <Representation bandwidth="256" codecs="mp4a.40.2" id="0"></Representation>
+    </AdaptationSet>
+    <AdaptationSet id="2" lang="en" contentType="text">
+      <Representation bandwidth="256" codecs="wvtt" id="0"></Representation>
+    </AdaptationSet>
+    <AdaptationSet id="3" lang="es" contentType="audio">
+      <Representation bandwidth="256" codecs="mp4a.40.2" id="0"></Representation>
+    </AdaptationSet>
+    <AdaptationSet id="4" lang="es" contentType="text">
+      <Representation bandwidth="256" codecs="wvtt" id="0"></Representation>
+    </AdaptationSet>
+    <AdaptationSet id="5" lang="pt" contentType="audio">
+      <Representation bandwidth="256" codecs="mp4a.40.2" id="0"></Representation>
+    </AdaptationSet>
+    <AdaptationSet id="6" lang="pt" contentType="text">
+      <Representation bandwidth="256" codecs="wvtt" id="0"></Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>
+`
+
+	manifestWithNoSpanish := `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-on-demand:2011" type="static" mediaPresentationDuration="PT6M16S" minBufferTime="PT1.97S">
+  <BaseURL>http://existing.base/url/</BaseURL>
+  <Period>
+    <AdaptationSet id="0" lang="en" contentType="video">
+      <Representation bandwidth="256" codecs="hvc1.2.4.L93.90" id="0"></Representation>
+      <Representation bandwidth="256" codecs="hvc1.2.4.L90.90" id="1"></Representation>
+    </AdaptationSet>
+    <AdaptationSet id="1" lang="en" contentType="audio">
+      <Representation bandwidth="256" codecs="mp4a.40.2" id="0"></Representation>
+    </AdaptationSet>
+    <AdaptationSet id="2" lang="en" contentType="text">
+      <Representation bandwidth="256" codecs="wvtt" id="0"></Representation>
+    </AdaptationSet>
+    <AdaptationSet id="3" lang="pt" contentType="audio">
+      <Representation bandwidth="256" codecs="mp4a.40.2" id="0"></Representation>
+    </AdaptationSet>
+    <AdaptationSet id="4" lang="pt" contentType="text">
+      <Representation bandwidth="256" codecs="wvtt" id="0"></Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>
+`
+
+	manifestWithNoSpanishAndPortugese := `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-on-demand:2011" type="static" mediaPresentationDuration="PT6M16S" minBufferTime="PT1.97S">
+  <BaseURL>http://existing.base/url/</BaseURL>
+  <Period>
+    <AdaptationSet id="0" lang="en" contentType="video">
+      <Representation bandwidth="256" codecs="hvc1.2.4.L93.90" id="0"></Representation>
+      <Representation bandwidth="256" codecs="hvc1.2.4.L90.90" id="1"></Representation>
+    </AdaptationSet>
+    <AdaptationSet id="1" lang="en" contentType="audio">
+      <Representation bandwidth="256" codecs="mp4a.40.2" id="0"></Representation>
+    </AdaptationSet>
+    <AdaptationSet id="2" lang="en" contentType="text">
+      <Representation bandwidth="256" codecs="wvtt" id="0"></Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>
+`
+
+	manifestWithNoCaptions := `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-on-demand:2011" type="static" mediaPresentationDuration="PT6M16S" minBufferTime="PT1.97S">
+  <BaseURL>http://existing.base/url/</BaseURL>
+  <Period>
+    <AdaptationSet id="0" lang="en" contentType="video">
+      <Representation bandwidth="256" codecs="hvc1.2.4.L93.90" id="0"></Representation>
+      <Representation bandwidth="256" codecs="hvc1.2.4.L90.90" id="1"></Representation>
+    </AdaptationSet>
+    <AdaptationSet id="1" lang="en" contentType="audio">
+      <Representation bandwidth="256" codecs="mp4a.40.2" id="0"></Representation>
+    </AdaptationSet>
+    <AdaptationSet id="2" lang="es" contentType="audio">
+      <Representation bandwidth="256" codecs="mp4a.40.2" id="0"></Representation>
+    </AdaptationSet>
+    <AdaptationSet id="3" lang="pt" contentType="audio">
+      <Representation bandwidth="256" codecs="mp4a.40.2" id="0"></Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>
+`
+
+	manifestWithRegionalLanguages := `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-on-demand:2011" type="static" mediaPresentationDuration="PT6M16S" minBufferTime="PT1.97S">
+  <BaseURL>http://existing.base/url/</BaseURL>
+  <Period>
+    <AdaptationSet id="0" lang="en-US" contentType="audio">
+      <Representation bandwidth="256" codecs="mp4a.40.2" id="0"></Representation>
+    </AdaptationSet>
+    <AdaptationSet id="1" lang="eng" contentType="audio">
+      <Representation bandwidth="256" codecs="mp4a.40.2" id="0"></Representation>
+    </AdaptationSet>
+    <AdaptationSet id="2" lang="pt-BR" contentType="audio">
+      <Representation bandwidth="256" codecs="mp4a.40.2" id="0"></Representation>
+    </AdaptationSet>
+    <AdaptationSet id="3" lang="por" contentType="audio">
+      <Representation bandwidth="256" codecs="mp4a.40.2" id="0"></Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>
+`
+
+	manifestWithPortugueseOnly := `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-on-demand:2011" type="static" mediaPresentationDuration="PT6M16S" minBufferTime="PT1.97S">
+  <BaseURL>http://existing.base/url/</BaseURL>
+  <Period>
+    <AdaptationSet id="0" lang="pt-BR" contentType="audio">
+      <Representation bandwidth="256" codecs="mp4a.40.2" id="0"></Representation>
+    </AdaptationSet>
+    <AdaptationSet id="1" lang="por" contentType="audio">
+      <Representation bandwidth="256" codecs="mp4a.40.2" id="0"></Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>
+`
+
+	manifestWithNoBrazilianPortuguese := `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-on-demand:2011" type="static" mediaPresentationDuration="PT6M16S" minBufferTime="PT1.97S">
+  <BaseURL>http://existing.base/url/</BaseURL>
+  <Period>
+    <AdaptationSet id="0" lang="en-US" contentType="audio">
+      <Representation bandwidth="256" codecs="mp4a.40.2" id="0"></Representation>
+    </AdaptationSet>
+    <AdaptationSet id="1" lang="eng" contentType="audio">
+      <Representation bandwidth="256" codecs="mp4a.40.2" id="0"></Representation>
+    </AdaptationSet>
+    <AdaptationSet id="2" lang="por" contentType="audio">
+      <Representation bandwidth="256" codecs="mp4a.40.2" id="0"></Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>
+`
+
+	manifestWithNoAmericanEnglish := `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-on-demand:2011" type="static" mediaPresentationDuration="PT6M16S" minBufferTime="PT1.97S">
+  <BaseURL>http://existing.base/url/</BaseURL>
+  <Period>
+    <AdaptationSet id="0" lang="eng" contentType="audio">
+      <Representation bandwidth="256" codecs="mp4a.40.2" id="0"></Representation>
+    </AdaptationSet>
+    <AdaptationSet id="1" lang="pt-BR" contentType="audio">
+      <Representation bandwidth="256" codecs="mp4a.40.2" id="0"></Representation>
+    </AdaptationSet>
+    <AdaptationSet id="2" lang="por" contentType="audio">
+      <Representation bandwidth="256" codecs="mp4a.40.2" id="0"></Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>
+`
+
+	tests := []struct {
+		name                  string
+		filters               *parsers.MediaFilters
+		manifestContent       string
+		expectManifestContent string
+		expectErr             bool
+	}{
+		{
+			name:                  "when no filters are set, nothing is stripped from manifest",
+			filters:               &parsers.MediaFilters{},
+			manifestContent:       manifestWithMultiLanguages,
+			expectManifestContent: manifestWithMultiLanguages,
+		},
+		{
+			name: "when es lang is set, adaptation sets with es are stripped from manifest",
+			filters: &parsers.MediaFilters{
+				Audios: parsers.NestedFilters{
+					Language: []string{"es"},
+				},
+				Captions: parsers.NestedFilters{
+					Language: []string{"es"},
+				},
+			},
+			manifestContent:       manifestWithMultiLanguages,
+			expectManifestContent: manifestWithNoSpanish,
+		},
+		{
+			name: "when es and pt lang is set, adaptation sets with es and pt are stripped from manifest",
+			filters: &parsers.MediaFilters{
+				Audios: parsers.NestedFilters{
+					Language: []string{"es", "pt"},
+				},
+				Captions: parsers.NestedFilters{
+					Language: []string{"es", "pt"},
+				},
+			},
+			manifestContent:       manifestWithMultiLanguages,
+			expectManifestContent: manifestWithNoSpanishAndPortugese,
+		},
+		{
+			name: "when es, pt, and en caption filters are set, expect those captions to be removed",
+			filters: &parsers.MediaFilters{
+				Captions: parsers.NestedFilters{
+					Language: []string{"es", "pt", "en"},
+				},
+			},
+			manifestContent:       manifestWithMultiLanguages,
+			expectManifestContent: manifestWithNoCaptions,
+		},
+		{
+			name: "when a primary lang is set, adaptation sets with its regional variants and three letter code are stripped",
+			filters: &parsers.MediaFilters{
+				Audios: parsers.NestedFilters{
+					Language: []string{"EN"},
+				},
+			},
+			manifestContent:       manifestWithRegionalLanguages,
+			expectManifestContent: manifestWithPortugueseOnly,
+		},
+		{
+			name: "when a regional lang is set, only adaptation sets with that regional variant are stripped",
+			filters: &parsers.MediaFilters{
+				Audios: parsers.NestedFilters{
+					Language: []string{"pt_br"},
+				},
+			},
+			manifestContent:       manifestWithRegionalLanguages,
+			expectManifestContent: manifestWithNoBrazilianPortuguese,
+		},
+		{
+			name: "when exact matching is set, only adaptation sets with the same lang are stripped",
+			filters: &parsers.MediaFilters{
+				Audios: parsers.NestedFilters{
+					Language:      []string{"en-us", "pt"},
+					ExactLanguage: true,
+				},
+			},
+			manifestContent:       manifestWithRegionalLanguages,
+			expectManifestContent: manifestWithNoAmericanEnglish,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			filter := NewDASHFilter("", tt.manifestContent, config.Config{})
+
+			manifest, err := filter.FilterContent(context.Background(), tt.filters)
+			if err != nil && !tt.expectErr {
+				t.Errorf("FilterContent(context.Background(), ) didnt expect an error to be returned, got: %v", err)
+				return
+			} else if err == nil && tt.expectErr {
+				t.Error("FilterContent(context.Background(), ) expected an error, got nil")
+				return
+			}
+
+			if g, e := manifest, tt.expectManifestContent; g != e {
+				t.Errorf("FilterContent(context.Background(), ) wrong manifest returned\ngot %v\nexpected: %v\ndiff: %v", g, e,
+					cmp.Diff(g, e))
+			}
+		})
+	}
+
+}
+
+func TestDASHFilter_FilterFrameRate(t *testing.T) {
+	manifestWithFrameRates := `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-on-demand:2011" type="static" mediaPresentationDuration="PT6M16S" minBufferTime="PT1.97S">
+  <BaseURL>http://existing.base/url/</BaseURL>
+  <Period>
+    <AdaptationSet id="0" lang="en" contentType="video">
+      <Representation bandwidth="2048" codecs="avc" frameRate="30000/1001" id="0"></Representation>
+      <Representation bandwidth="4096" codecs="avc" frameRate="30000/1001" id="1"></Representation>
+    </AdaptationSet>
+    <AdaptationSet frameRate="24000/1001" id="1" lang="en" contentType="video">
+      <Representation bandwidth="2048" codecs="avc" id="0"></Representation>
+      <Representation bandwidth="4096" codecs="avc" id="1"></Representation>
+    </AdaptationSet>
+    <AdaptationSet id="2" lang="en" contentType="video">
+      <Representation bandwidth="2048" codecs="avc" frameRate="30" id="0"></Representation>
+      <Representation bandwidth="4096" codecs="avc" frameRate="60" id="1"></Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>
+`
+
+	manifestWithNo30000FractionFPS := `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-on-demand:2011" type="static" mediaPresentationDuration="PT6M16S" minBufferTime="PT1.97S">
+  <BaseURL>http://existing.base/url/</BaseURL>
+  <Period>
+    <AdaptationSet frameRate="24000/1001" id="0" lang="en" contentType="video">
+      <Representation bandwidth="2048" codecs="avc" id="0"></Representation>
+      <Representation bandwidth="4096" codecs="avc" id="1"></Representation>
+    </AdaptationSet>
+    <AdaptationSet id="1" lang="en" contentType="video">
+      <Representation bandwidth="2048" codecs="avc" frameRate="30" id="0"></Representation>
+      <Representation bandwidth="4096" codecs="avc" frameRate="60" id="1"></Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>
+`
+
+	manifestWithOnly60FPS := `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-on-demand:2011" type="static" mediaPresentationDuration="PT6M16S" minBufferTime="PT1.97S">
+  <BaseURL>http://existing.base/url/</BaseURL>
+  <Period>
+    <AdaptationSet id="0" lang="en" contentType="video">
+      <Representation bandwidth="4096" codecs="avc" frameRate="60" id="1"></Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>
+`
+
+	manifestWithNoFrameRates := `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-on-demand:2011" type="static" mediaPresentationDuration="PT6M16S" minBufferTime="PT1.97S">
+  <BaseURL>http://existing.base/url/</BaseURL>
+  <Period></Period>
+</MPD>
+`
+
+	tests := []struct {
+		name                  string
+		filters               *parsers.MediaFilters
+		manifestContent       string
+		expectManifestContent string
+		expectErr             bool
+	}{
+		{
+			name:                  "when no filters are passed in, nothing is removed from manifest",
+			filters:               &parsers.MediaFilters{},
+			manifestContent:       manifestWithFrameRates,
+			expectManifestContent: manifestWithFrameRates,
+		},
+		{
+			name: "when multiple framerates are set, it removes all the representations associated",
+			filters: &parsers.MediaFilters{
+				FrameRate: []string{"30000/1001", "24000/1001", "30"},
+			},
+			manifestContent:       manifestWithFrameRates,
+			expectManifestContent: manifestWithOnly60FPS,
+		},
+		{
+			name: "when framerate is set with fraction representation, it removes its associated representations",
+			filters: &parsers.MediaFilters{
+				FrameRate: []string{"30000/1001"},
+			},
+			manifestContent:       manifestWithFrameRates,
+			expectManifestContent: manifestWithNo30000FractionFPS,
+		},
+		{
+			name: "when all framerates are sent, return an empty manifest",
 			filters: &parsers.MediaFilters{
 				FrameRate: []string{"30000/1001", "24000/1001", "60", "30"},
 			},
@@ -1346,3 +2319,474 @@ func TestDASHFilter_GetMaxAge(t *testing.T) {
 		}
 	})
 }
+
+func TestDASHFilter_FilterContent_cdn(t *testing.T) {
+	manifest := `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-on-demand:2011" type="static" mediaPresentationDuration="PT6M16S" minBufferTime="PT1.97S">
+  <BaseURL>http://existing.base/url/</BaseURL>
+  <Period>
+    <AdaptationSet id="0" contentType="video">
+      <Representation bandwidth="2048" codecs="avc" height="360" id="0" width="640">
+        <BaseURL>http://other.base/url/video_360.mp4</BaseURL>
+      </Representation>
+      <Representation bandwidth="4096" codecs="avc" height="720" id="1" width="1280">
+        <BaseURL>video_720.mp4</BaseURL>
+      </Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>
+`
+
+	manifestWithCDN := `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-on-demand:2011" type="static" mediaPresentationDuration="PT6M16S" minBufferTime="PT1.97S">
+  <BaseURL>https://cbsi.akamaized.net/url/</BaseURL>
+  <Period>
+    <AdaptationSet id="0" contentType="video">
+      <Representation bandwidth="2048" codecs="avc" height="360" id="0" width="640">
+        <BaseURL>https://cbsi.akamaized.net/url/video_360.mp4</BaseURL>
+      </Representation>
+      <Representation bandwidth="4096" codecs="avc" height="720" id="1" width="1280">
+        <BaseURL>video_720.mp4</BaseURL>
+      </Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>
+`
+
+	tests := []struct {
+		name                  string
+		filters               *parsers.MediaFilters
+		manifestContent       string
+		expectManifestContent string
+		expectErr             bool
+	}{
+		{
+			name:                  "when a cdn is selected, expect absolute base urls to point to the cdn",
+			filters:               &parsers.MediaFilters{CDN: "akamai"},
+			manifestContent:       manifest,
+			expectManifestContent: manifestWithCDN,
+		},
+		{
+			name:            "when the selected cdn isn't configured, expect an error",
+			filters:         &parsers.MediaFilters{CDN: "fastly"},
+			manifestContent: manifest,
+			expectErr:       true,
+		},
+	}
+
+	cdns := config.CDNs{
+		Hosts: map[string]*url.URL{
+			"akamai": {Scheme: "https", Host: "cbsi.akamaized.net"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			filter := NewDASHFilter("", tt.manifestContent, config.Config{CDNs: cdns})
+
+			manifest, err := filter.FilterContent(context.Background(), tt.filters)
+			if err != nil && !tt.expectErr {
+				t.Errorf("FilterContent(context.Background(), ) didn't expect error to be returned, got: %v", err)
+				return
+			} else if err == nil && tt.expectErr {
+				t.Error("FilterContent(context.Background(), ) expected an error, got nil")
+				return
+			}
+
+			if g, e := manifest, tt.expectManifestContent; g != e {
+				t.Fatalf("FilterContent(context.Background(), ) returned wrong manifest\ngot %v\nexpected %v\ndiff: %v", g, e, cmp.Diff(g, e))
+			}
+		})
+	}
+}
+
+func TestDASHFilter_FilterContent_drm(t *testing.T) {
+	manifest := `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" xmlns:cenc="urn:mpeg:cenc:2013" profiles="urn:mpeg:dash:profile:isoff-on-demand:2011" type="static" mediaPresentationDuration="PT6M16S" minBufferTime="PT1.97S">
+  <BaseURL>http://existing.base/url/</BaseURL>
+  <Period>
+    <AdaptationSet id="0" contentType="video">
+      <ContentProtection schemeIdUri="urn:mpeg:dash:mp4protection:2011" cenc:default_KID="00000000-0000-0000-0000-000000000000" value="cbcs"></ContentProtection>
+      <ContentProtection schemeIdUri="urn:uuid:edef8ba9-79d6-4ace-a3c8-27dcd51d21ed">
+        <cenc:pssh>AAAA</cenc:pssh>
+      </ContentProtection>
+      <ContentProtection schemeIdUri="urn:uuid:94CE86FB-07FF-4F43-ADB8-93D2FA968CA2"></ContentProtection>
+      <Representation bandwidth="2048" codecs="avc" height="360" id="0" width="640">
+        <BaseURL>video_360.mp4</BaseURL>
+      </Representation>
+    </AdaptationSet>
+    <AdaptationSet id="1" contentType="audio">
+      <Representation bandwidth="128" codecs="mp4a.40.2" id="1">
+        <ContentProtection schemeIdUri="urn:mpeg:dash:mp4protection:2011" value="cenc"></ContentProtection>
+        <ContentProtection schemeIdUri="urn:uuid:9a04f079-9840-4286-ab92-e65be0885f95"></ContentProtection>
+        <BaseURL>audio_en.mp4</BaseURL>
+      </Representation>
+      <Representation bandwidth="128" codecs="mp4a.40.2" id="2">
+        <ContentProtection schemeIdUri="urn:mpeg:dash:mp4protection:2011" value="cbcs"></ContentProtection>
+        <ContentProtection schemeIdUri="urn:uuid:94ce86fb-07ff-4f43-adb8-93d2fa968ca2"></ContentProtection>
+        <BaseURL>audio_en_cbcs.mp4</BaseURL>
+      </Representation>
+    </AdaptationSet>
+    <AdaptationSet id="2" contentType="text">
+      <Representation bandwidth="256" codecs="wvtt" id="3">
+        <BaseURL>subtitles_en.mp4</BaseURL>
+      </Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>
+`
+
+	manifestWithWidevineDescriptors := `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" xmlns:cenc="urn:mpeg:cenc:2013" profiles="urn:mpeg:dash:profile:isoff-on-demand:2011" type="static" mediaPresentationDuration="PT6M16S" minBufferTime="PT1.97S">
+  <BaseURL>http://existing.base/url/</BaseURL>
+  <Period>
+    <AdaptationSet id="0" contentType="video">
+      <ContentProtection schemeIdUri="urn:mpeg:dash:mp4protection:2011" cenc:default_KID="00000000-0000-0000-0000-000000000000" value="cbcs"></ContentProtection>
+      <ContentProtection schemeIdUri="urn:uuid:edef8ba9-79d6-4ace-a3c8-27dcd51d21ed">
+        <cenc:pssh>AAAA</cenc:pssh>
+      </ContentProtection>
+      <Representation bandwidth="2048" codecs="avc" height="360" id="0" width="640">
+        <BaseURL>video_360.mp4</BaseURL>
+      </Representation>
+    </AdaptationSet>
+    <AdaptationSet id="1" contentType="audio">
+      <Representation bandwidth="128" codecs="mp4a.40.2" id="1">
+        <ContentProtection schemeIdUri="urn:mpeg:dash:mp4protection:2011" value="cenc"></ContentProtection>
+        <BaseURL>audio_en.mp4</BaseURL>
+      </Representation>
+      <Representation bandwidth="128" codecs="mp4a.40.2" id="2">
+        <ContentProtection schemeIdUri="urn:mpeg:dash:mp4protection:2011" value="cbcs"></ContentProtection>
+        <BaseURL>audio_en_cbcs.mp4</BaseURL>
+      </Representation>
+    </AdaptationSet>
+    <AdaptationSet id="2" contentType="text">
+      <Representation bandwidth="256" codecs="wvtt" id="3">
+        <BaseURL>subtitles_en.mp4</BaseURL>
+      </Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>
+`
+
+	manifestWithWidevine := `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" xmlns:cenc="urn:mpeg:cenc:2013" profiles="urn:mpeg:dash:profile:isoff-on-demand:2011" type="static" mediaPresentationDuration="PT6M16S" minBufferTime="PT1.97S">
+  <BaseURL>http://existing.base/url/</BaseURL>
+  <Period>
+    <AdaptationSet id="0" contentType="video">
+      <ContentProtection schemeIdUri="urn:mpeg:dash:mp4protection:2011" cenc:default_KID="00000000-0000-0000-0000-000000000000" value="cbcs"></ContentProtection>
+      <ContentProtection schemeIdUri="urn:uuid:edef8ba9-79d6-4ace-a3c8-27dcd51d21ed">
+        <cenc:pssh>AAAA</cenc:pssh>
+      </ContentProtection>
+      <Representation bandwidth="2048" codecs="avc" height="360" id="0" width="640">
+        <BaseURL>video_360.mp4</BaseURL>
+      </Representation>
+    </AdaptationSet>
+    <AdaptationSet id="1" contentType="text">
+      <Representation bandwidth="256" codecs="wvtt" id="3">
+        <BaseURL>subtitles_en.mp4</BaseURL>
+      </Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>
+`
+
+	manifestWithPlayReady := `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" xmlns:cenc="urn:mpeg:cenc:2013" profiles="urn:mpeg:dash:profile:isoff-on-demand:2011" type="static" mediaPresentationDuration="PT6M16S" minBufferTime="PT1.97S">
+  <BaseURL>http://existing.base/url/</BaseURL>
+  <Period>
+    <AdaptationSet id="0" contentType="audio">
+      <Representation bandwidth="128" codecs="mp4a.40.2" id="1">
+        <ContentProtection schemeIdUri="urn:mpeg:dash:mp4protection:2011" value="cenc"></ContentProtection>
+        <ContentProtection schemeIdUri="urn:uuid:9a04f079-9840-4286-ab92-e65be0885f95"></ContentProtection>
+        <BaseURL>audio_en.mp4</BaseURL>
+      </Representation>
+    </AdaptationSet>
+    <AdaptationSet id="1" contentType="text">
+      <Representation bandwidth="256" codecs="wvtt" id="3">
+        <BaseURL>subtitles_en.mp4</BaseURL>
+      </Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>
+`
+
+	manifestWithFairPlay := `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" xmlns:cenc="urn:mpeg:cenc:2013" profiles="urn:mpeg:dash:profile:isoff-on-demand:2011" type="static" mediaPresentationDuration="PT6M16S" minBufferTime="PT1.97S">
+  <BaseURL>http://existing.base/url/</BaseURL>
+  <Period>
+    <AdaptationSet id="0" contentType="video">
+      <ContentProtection schemeIdUri="urn:mpeg:dash:mp4protection:2011" cenc:default_KID="00000000-0000-0000-0000-000000000000" value="cbcs"></ContentProtection>
+      <ContentProtection schemeIdUri="urn:uuid:94CE86FB-07FF-4F43-ADB8-93D2FA968CA2"></ContentProtection>
+      <Representation bandwidth="2048" codecs="avc" height="360" id="0" width="640">
+        <BaseURL>video_360.mp4</BaseURL>
+      </Representation>
+    </AdaptationSet>
+    <AdaptationSet id="1" contentType="audio">
+      <Representation bandwidth="128" codecs="mp4a.40.2" id="2">
+        <ContentProtection schemeIdUri="urn:mpeg:dash:mp4protection:2011" value="cbcs"></ContentProtection>
+        <ContentProtection schemeIdUri="urn:uuid:94ce86fb-07ff-4f43-adb8-93d2fa968ca2"></ContentProtection>
+        <BaseURL>audio_en_cbcs.mp4</BaseURL>
+      </Representation>
+    </AdaptationSet>
+    <AdaptationSet id="2" contentType="text">
+      <Representation bandwidth="256" codecs="wvtt" id="3">
+        <BaseURL>subtitles_en.mp4</BaseURL>
+      </Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>
+`
+
+	tests := []struct {
+		name                  string
+		filters               *parsers.MediaFilters
+		manifestContent       string
+		expectManifestContent string
+	}{
+		{
+			name:                  "when widevine is given, expect other descriptors to be removed and every representation to be kept",
+			filters:               &parsers.MediaFilters{DRM: []string{parsers.DRMWidevine}},
+			manifestContent:       manifest,
+			expectManifestContent: manifestWithWidevineDescriptors,
+		},
+		{
+			name:                  "when widevine is given with drmstrict, expect other descriptors and sets left without widevine to be removed",
+			filters:               &parsers.MediaFilters{DRM: []string{parsers.DRMWidevine}, DRMStrict: true},
+			manifestContent:       manifest,
+			expectManifestContent: manifestWithWidevine,
+		},
+		{
+			name:                  "when playready is given with drmstrict, expect representations without playready to be removed",
+			filters:               &parsers.MediaFilters{DRM: []string{parsers.DRMPlayReady}, DRMStrict: true},
+			manifestContent:       manifest,
+			expectManifestContent: manifestWithPlayReady,
+		},
+		{
+			name:                  "when fairplay is given with drmstrict, expect descriptors to match regardless of the case of their scheme",
+			filters:               &parsers.MediaFilters{DRM: []string{parsers.DRMFairPlay}, DRMStrict: true},
+			manifestContent:       manifest,
+			expectManifestContent: manifestWithFairPlay,
+		},
+		{
+			name:                  "when no drm system is given, expect every descriptor to be kept",
+			filters:               &parsers.MediaFilters{},
+			manifestContent:       manifest,
+			expectManifestContent: manifest,
+		},
+		{
+			name:                  "when drmstrict is given without a drm system, expect every representation to be kept",
+			filters:               &parsers.MediaFilters{DRMStrict: true},
+			manifestContent:       manifest,
+			expectManifestContent: manifest,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			filter := NewDASHFilter("", tt.manifestContent, config.Config{})
+
+			manifest, err := filter.FilterContent(context.Background(), tt.filters)
+			if err != nil {
+				t.Fatalf("FilterContent(context.Background(), ) didn't expect error to be returned, got: %v", err)
+			}
+
+			if g, e := manifest, tt.expectManifestContent; g != e {
+				t.Fatalf("FilterContent(context.Background(), ) returned wrong manifest\ngot %v\nexpected %v\ndiff: %v", g, e, cmp.Diff(g, e))
+			}
+		})
+	}
+}
+
+func TestDASHFilter_FilterContent_videoRange(t *testing.T) {
+	manifest := `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-on-demand:2011" type="static" mediaPresentationDuration="PT6M16S" minBufferTime="PT1.97S">
+  <BaseURL>http://existing.base/url/</BaseURL>
+  <Period>
+    <AdaptationSet id="0" maxWidth="3840" maxHeight="2160" contentType="video">
+      <Representation bandwidth="2048" codecs="avc1.64001f" height="720" id="0" width="1280">
+        <BaseURL>video_avc_720.mp4</BaseURL>
+      </Representation>
+      <Representation bandwidth="8192" codecs="hvc1.2.4.L153.B0" height="2160" id="1" width="3840">
+        <SupplementalProperty schemeIdUri="urn:mpeg:mpegB:cicp:TransferCharacteristics" value="1"></SupplementalProperty>
+        <BaseURL>video_hevc_sdr_2160.mp4</BaseURL>
+      </Representation>
+      <Representation bandwidth="8192" codecs="hvc1.2.4.L153.B0" height="2160" id="2" width="3840">
+        <SupplementalProperty schemeIdUri="urn:mpeg:mpegB:cicp:TransferCharacteristics" value="16"></SupplementalProperty>
+        <BaseURL>video_hevc_hdr10_2160.mp4</BaseURL>
+      </Representation>
+      <Representation bandwidth="4096" codecs="hvc1.2.4.L120.B0" height="1080" id="5" width="1920">
+        <BaseURL>video_hevc_main10_1080.mp4</BaseURL>
+      </Representation>
+    </AdaptationSet>
+    <AdaptationSet codecs="hvc1.2.4.L153.B0" id="1" maxWidth="3840" maxHeight="2160" contentType="video">
+      <EssentialProperty schemeIdUri="urn:mpeg:mpegB:cicp:TransferCharacteristics" value="18"></EssentialProperty>
+      <Representation bandwidth="8192" height="2160" id="3" width="3840">
+        <BaseURL>video_hevc_hlg_2160.mp4</BaseURL>
+      </Representation>
+    </AdaptationSet>
+    <AdaptationSet id="2" contentType="audio">
+      <Representation bandwidth="128" codecs="mp4a.40.2" id="4">
+        <BaseURL>audio_en.mp4</BaseURL>
+      </Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>
+`
+
+	manifestWithoutHDR := `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-on-demand:2011" type="static" mediaPresentationDuration="PT6M16S" minBufferTime="PT1.97S">
+  <BaseURL>http://existing.base/url/</BaseURL>
+  <Period>
+    <AdaptationSet id="0" maxWidth="3840" maxHeight="2160" contentType="video">
+      <Representation bandwidth="2048" codecs="avc1.64001f" height="720" id="0" width="1280">
+        <BaseURL>video_avc_720.mp4</BaseURL>
+      </Representation>
+      <Representation bandwidth="8192" codecs="hvc1.2.4.L153.B0" height="2160" id="1" width="3840">
+        <SupplementalProperty schemeIdUri="urn:mpeg:mpegB:cicp:TransferCharacteristics" value="1"></SupplementalProperty>
+        <BaseURL>video_hevc_sdr_2160.mp4</BaseURL>
+      </Representation>
+      <Representation bandwidth="4096" codecs="hvc1.2.4.L120.B0" height="1080" id="5" width="1920">
+        <BaseURL>video_hevc_main10_1080.mp4</BaseURL>
+      </Representation>
+    </AdaptationSet>
+    <AdaptationSet id="1" contentType="audio">
+      <Representation bandwidth="128" codecs="mp4a.40.2" id="4">
+        <BaseURL>audio_en.mp4</BaseURL>
+      </Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>
+`
+
+	manifestWithoutSDR := `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-on-demand:2011" type="static" mediaPresentationDuration="PT6M16S" minBufferTime="PT1.97S">
+  <BaseURL>http://existing.base/url/</BaseURL>
+  <Period>
+    <AdaptationSet id="0" maxWidth="3840" maxHeight="2160" contentType="video">
+      <Representation bandwidth="8192" codecs="hvc1.2.4.L153.B0" height="2160" id="2" width="3840">
+        <SupplementalProperty schemeIdUri="urn:mpeg:mpegB:cicp:TransferCharacteristics" value="16"></SupplementalProperty>
+        <BaseURL>video_hevc_hdr10_2160.mp4</BaseURL>
+      </Representation>
+    </AdaptationSet>
+    <AdaptationSet codecs="hvc1.2.4.L153.B0" id="1" maxWidth="3840" maxHeight="2160" contentType="video">
+      <EssentialProperty schemeIdUri="urn:mpeg:mpegB:cicp:TransferCharacteristics" value="18"></EssentialProperty>
+      <Representation bandwidth="8192" height="2160" id="3" width="3840">
+        <BaseURL>video_hevc_hlg_2160.mp4</BaseURL>
+      </Representation>
+    </AdaptationSet>
+    <AdaptationSet id="2" contentType="audio">
+      <Representation bandwidth="128" codecs="mp4a.40.2" id="4">
+        <BaseURL>audio_en.mp4</BaseURL>
+      </Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>
+`
+
+	manifestWithCombinedCodecs := `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-on-demand:2011" type="static" mediaPresentationDuration="PT6M16S" minBufferTime="PT1.97S">
+  <BaseURL>http://existing.base/url/</BaseURL>
+  <Period>
+    <AdaptationSet id="0" maxWidth="3840" maxHeight="2160" contentType="video">
+      <Representation bandwidth="8192" codecs="hvc1.2.4.L150,dvh1.08.07" height="2160" id="0" width="3840">
+        <BaseURL>video_dolby_vision_2160.mp4</BaseURL>
+      </Representation>
+      <Representation bandwidth="8192" codecs="avc1.64001f,hvc1.2.4.L150" height="2160" id="1" width="3840">
+        <BaseURL>video_sdr_2160.mp4</BaseURL>
+      </Representation>
+    </AdaptationSet>
+    <AdaptationSet id="1" contentType="audio">
+      <Representation bandwidth="128" codecs="mp4a.40.2" id="2">
+        <BaseURL>audio_en.mp4</BaseURL>
+      </Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>
+`
+
+	manifestWithCombinedCodecsWithoutHDR := `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-on-demand:2011" type="static" mediaPresentationDuration="PT6M16S" minBufferTime="PT1.97S">
+  <BaseURL>http://existing.base/url/</BaseURL>
+  <Period>
+    <AdaptationSet id="0" maxWidth="3840" maxHeight="2160" contentType="video">
+      <Representation bandwidth="8192" codecs="avc1.64001f,hvc1.2.4.L150" height="2160" id="1" width="3840">
+        <BaseURL>video_sdr_2160.mp4</BaseURL>
+      </Representation>
+    </AdaptationSet>
+    <AdaptationSet id="1" contentType="audio">
+      <Representation bandwidth="128" codecs="mp4a.40.2" id="2">
+        <BaseURL>audio_en.mp4</BaseURL>
+      </Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>
+`
+
+	manifestWithCombinedCodecsWithoutSDR := `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-on-demand:2011" type="static" mediaPresentationDuration="PT6M16S" minBufferTime="PT1.97S">
+  <BaseURL>http://existing.base/url/</BaseURL>
+  <Period>
+    <AdaptationSet id="0" maxWidth="3840" maxHeight="2160" contentType="video">
+      <Representation bandwidth="8192" codecs="hvc1.2.4.L150,dvh1.08.07" height="2160" id="0" width="3840">
+        <BaseURL>video_dolby_vision_2160.mp4</BaseURL>
+      </Representation>
+    </AdaptationSet>
+    <AdaptationSet id="1" contentType="audio">
+      <Representation bandwidth="128" codecs="mp4a.40.2" id="2">
+        <BaseURL>audio_en.mp4</BaseURL>
+      </Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>
+`
+
+	tests := []struct {
+		name                  string
+		filters               *parsers.MediaFilters
+		manifestContent       string
+		expectManifestContent string
+	}{
+		{
+			name:                  "when pq and hlg are given, expect hdr representations to be removed along with empty adaptation sets and hevc main 10 representations without transfer characteristics to be kept",
+			filters:               &parsers.MediaFilters{VideoRange: []string{parsers.VideoRangePQ, parsers.VideoRangeHLG}},
+			manifestContent:       manifest,
+			expectManifestContent: manifestWithoutHDR,
+		},
+		{
+			name:                  "when sdr is given, expect transfer characteristics to take precedence over codecs and hevc main 10 representations without them to be removed",
+			filters:               &parsers.MediaFilters{VideoRange: []string{parsers.VideoRangeSDR}},
+			manifestContent:       manifest,
+			expectManifestContent: manifestWithoutSDR,
+		},
+		{
+			name:                  "when pq is given, expect representations with a dolby vision codec alongside their sdr base layer codec to be removed",
+			filters:               &parsers.MediaFilters{VideoRange: []string{parsers.VideoRangePQ}},
+			manifestContent:       manifestWithCombinedCodecs,
+			expectManifestContent: manifestWithCombinedCodecsWithoutHDR,
+		},
+		{
+			name:                  "when sdr is given, expect representations with a dolby vision codec alongside their sdr base layer codec to be kept",
+			filters:               &parsers.MediaFilters{VideoRange: []string{parsers.VideoRangeSDR}},
+			manifestContent:       manifestWithCombinedCodecs,
+			expectManifestContent: manifestWithCombinedCodecsWithoutSDR,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			filter := NewDASHFilter("", tt.manifestContent, config.Config{})
+
+			manifest, err := filter.FilterContent(context.Background(), tt.filters)
+			if err != nil {
+				t.Fatalf("FilterContent(context.Background(), ) didn't expect error to be returned, got: %v", err)
+			}
+
+			if g, e := manifest, tt.expectManifestContent; g != e {
+				t.Fatalf("FilterContent(context.Background(), ) returned wrong manifest\ngot %v\nexpected %v\ndiff: %v", g, e, cmp.Diff(g, e))
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package filters
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafov/m3u8"
+	"github.com/rs/zerolog"
+)
+
+// anyPipeline is the pipeline of variants without redundant copies, which
+// are kept whichever pipeline is chosen
+const anyPipeline = -1
+
+// defaultPathway is the pathway of variants without a PATHWAY-ID
+const defaultPathway = "."
+
+// variantPipelines assigns each variant of a redundant master playlist to a
+// pipeline, returning the pipeline of each variant and the number of pipelines.
+// Variants are grouped by their PATHWAY-ID when set. Otherwise copies of the
+// same variant, sharing a STABLE-VARIANT-ID or, lacking one, their stream
+// attributes, are assigned to pipelines in the order they're listed.
+func variantPipelines(manifest string, variants []*m3u8.Variant) ([]int, int) {
+	pathways, stableIDs := variantIDs(manifest, len(variants))
+	pipelines := make([]int, len(variants))
+
+	ids := make(map[string]int)
+	for i, pathway := range pathways {
+		if pathway == "" {
+			pathway = defaultPathway
+		}
+
+		p, found := ids[pathway]
+		if !found {
+			p = len(ids)
+			ids[pathway] = p
+		}
+		pipelines[i] = p
+	}
+
+	if len(ids) > 1 {
+		return pipelines, len(ids)
+	}
+
+	keys := make([]string, len(variants))
+	copies := make(map[string]int)
+	for i, v := range variants {
+		keys[i] = "attributes:" + streamKey(v)
+		if stableIDs[i] != "" {
+			keys[i] = "id:" + stableIDs[i]
+		}
+
+		pipelines[i] = copies[keys[i]]
+		copies[keys[i]]++
+	}
+
+	var count int
+	for i, key := range keys {
+		if copies[key] == 1 {
+			pipelines[i] = anyPipeline
+		}
+
+		if copies[key] > count {
+			count = copies[key]
+		}
+	}
+
+	return pipelines, count
+}
+
+// variantIDs reads the PATHWAY-ID and STABLE-VARIANT-ID attributes of the
+// variants, which the playlist decoder drops, in the order they're listed
+func variantIDs(manifest string, count int) ([]string, []string) {
+	pathways := make([]string, count)
+	stableIDs := make([]string, count)
+
+	var i int
+	for _, line := range strings.Split(manifest, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "#EXT-X-STREAM-INF:") && !strings.HasPrefix(line, "#EXT-X-I-FRAME-STREAM-INF:") {
+			continue
+		}
+
+		if i == count {
+			break
+		}
+
+		_, attributes := splitAttributes(line)
+		for _, attribute := range attributes {
+			switch {
+			case strings.HasPrefix(attribute, "PATHWAY-ID="):
+				pathways[i] = strings.Trim(strings.TrimPrefix(attribute, "PATHWAY-ID="), `"`)
+			case strings.HasPrefix(attribute, "STABLE-VARIANT-ID="):
+				stableIDs[i] = strings.Trim(strings.TrimPrefix(attribute, "STABLE-VARIANT-ID="), `"`)
+			}
+		}
+		i++
+	}
+
+	return pathways, stableIDs
+}
+
+// streamKey identifies a variant by the attributes its redundant copies share
+func streamKey(v *m3u8.Variant) string {
+	return fmt.Sprintf("%v,%v,%v,%v,%v,%v,%v", v.Iframe, v.Bandwidth, v.AverageBandwidth,
+		v.Codecs, v.Resolution, v.FrameRate, v.VideoRange)
+}
+
+// filterPipeline health checks a variant of each pipeline concurrently,
+// returning the pipeline whose variant was modified last among the healthy
+// ones. The last pipeline is returned when none is healthy, unless checking
+// them failed.
+func (h *HLSFilter) filterPipeline(ctx context.Context, variants []*m3u8.Variant, pipelines []int, count int) (int, error) {
+	absolute, err := getAbsoluteURL(h.originURL)
+	if err != nil {
+		return 0, fmt.Errorf("formatting segment URLs: %w", err)
+	}
+
+	uris := make([]string, count)
+	for p := range uris {
+		uri := pipelineVariant(variants, pipelines, p)
+		if uri == "" {
+			continue
+		}
+
+		uris[p], err = combinedIfRelative(uri, *absolute)
+		if err != nil {
+			return 0, fmt.Errorf("formatting segment URLs: %w", err)
+		}
+	}
+
+	checks := make([]pipelineCheck, count)
+	var wg sync.WaitGroup
+	for p, uri := range uris {
+		if uri == "" {
+			continue
+		}
+
+		wg.Add(1)
+		go func(p int, uri string) {
+			defer wg.Done()
+			checks[p] = pipelineHealth.check(ctx, uri, h.config.Client)
+		}(p, uri)
+	}
+	wg.Wait()
+
+	// the pipelines are remembered for a few of the longest target durations
+	var targetDuration time.Duration
+	for _, c := range checks {
+		if c.health.targetDuration > targetDuration {
+			targetDuration = c.health.targetDuration
+		}
+	}
+	pipelineHealth.remember(h.originURL, uris, targetDuration)
+
+	log := zerolog.Ctx(ctx)
+	selected := anyPipeline
+	var freshest time.Time
+	var checkErr error
+	for p, c := range checks {
+		if uris[p] == "" {
+			continue
+		}
+
+		log.Debug().
+			Int("pipeline", p).
+			Str("variant", uris[p]).
+			Bool("healthy", c.health.healthy).
+			Bool("cached", c.cached).
+			Time("lastModified", c.health.lastModified).
+			Dur("age", c.health.age).
+			Dur("staleAfter", c.health.staleAfter).
+			Err(c.err).
+			Msg("pipeline health checked")
+
+		if c.err != nil {
+			if checkErr == nil {
+				checkErr = c.err
+			}
+			continue
+		}
+
+		if c.health.healthy && (selected == anyPipeline || c.health.lastModified.After(freshest)) {
+			selected, freshest = p, c.health.lastModified
+		}
+	}
+
+	if selected == anyPipeline && checkErr != nil {
+		return 0, checkErr
+	}
+
+	if selected == anyPipeline {
+		selected = count - 1
+	}
+
+	log.Info().
+		Str("manifest", h.originURL).
+		Int("pipeline", selected).
+		Int("pipelines", count).
+		Msg("pipeline selected")
+
+	return selected, nil
+}
+
+// pipelineVariant returns the uri of the first variant of the pipeline,
+// preferring variants over I-frame playlists
+func pipelineVariant(variants []*m3u8.Variant, pipelines []int, pipeline int) string {
+	var uri string
+	for i, v := range variants {
+		if pipelines[i] != pipeline {
+			continue
+		}
+
+		if !v.Iframe {
+			return v.URI
+		}
+
+		if uri == "" {
+			uri = v.URI
+		}
+	}
+
+	return uri
+}
@@ -0,0 +1,387 @@
+package filters
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cbsinteractive/bakery/cache"
+	"github.com/cbsinteractive/bakery/config"
+	"github.com/cbsinteractive/bakery/origin"
+	"github.com/cbsinteractive/bakery/parsers"
+	"github.com/grafov/m3u8"
+	"github.com/rs/zerolog"
+	"github.com/zencoder/go-dash/v3/mpd"
+)
+
+const (
+	tagKey        = "#EXT-X-KEY:"
+	tagSessionKey = "#EXT-X-SESSION-KEY:"
+)
+
+// identityKeyFormat is the key format of keys without a KEYFORMAT attribute
+const identityKeyFormat = "identity"
+
+// keyFormats maps the drm systems to the KEYFORMAT of their keys
+var keyFormats = map[string][]string{
+	parsers.DRMFairPlay:  {"com.apple.streamingkeydelivery"},
+	parsers.DRMWidevine:  {"urn:uuid:edef8ba9-79d6-4ace-a3c8-27dcd51d21ed"},
+	parsers.DRMPlayReady: {"com.microsoft.playready", "urn:uuid:9a04f079-9840-4286-ab92-e65be0885f95"},
+	parsers.DRMClear:     {identityKeyFormat},
+}
+
+// contentProtectionSchemes maps the drm systems to the schemeIdUri of their
+// ContentProtection descriptors
+var contentProtectionSchemes = map[string][]string{
+	parsers.DRMFairPlay:  {"urn:uuid:94ce86fb-07ff-4f43-adb8-93d2fa968ca2"},
+	parsers.DRMWidevine:  {mpd.CONTENT_PROTECTION_WIDEVINE_SCHEME_ID},
+	parsers.DRMPlayReady: {mpd.CONTENT_PROTECTION_PLAYREADY_SCHEME_ID, mpd.CONTENT_PROTECTION_PLAYREADY_SCHEME_V10_ID},
+	parsers.DRMClear:     {"urn:uuid:e2719d58-a985-b3c9-781a-b030af78d30e", "urn:uuid:1077efec-c0b2-4d02-ace3-3c1e52e2fb4b"},
+}
+
+// filterKeyTags removes the EXT-X-KEY and EXT-X-SESSION-KEY tags whose
+// KEYFORMAT isn't one of the drm systems. Keys with METHOD=NONE are kept. It
+// returns false when every key of encrypted segments was removed.
+func filterKeyTags(manifest string, systems []string) (string, bool) {
+	formats := make(map[string]struct{})
+	for _, system := range systems {
+		for _, format := range keyFormats[system] {
+			formats[format] = struct{}{}
+		}
+	}
+
+	playable := true
+	// keys applying to the next segment, which replace the previous ones
+	var keys, encrypted, kept bool
+	lines := strings.Split(manifest, "\n")
+	filtered := lines[:0]
+	for _, line := range lines {
+		tag := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(tag, tagKey), strings.HasPrefix(tag, tagSessionKey):
+			if !keys {
+				keys, encrypted, kept = true, false, false
+			}
+
+			attributes := tagAttributes(tag)
+			if attributes["METHOD"] != "NONE" {
+				encrypted = true
+				if _, found := formats[keyFormat(attributes)]; !found {
+					continue
+				}
+			}
+			kept = true
+		case tag != "" && !strings.HasPrefix(tag, "#"):
+			if keys && encrypted && !kept {
+				playable = false
+			}
+			keys = false
+		}
+
+		filtered = append(filtered, line)
+	}
+
+	return strings.Join(filtered, "\n"), playable
+}
+
+// keyFormat returns the KEYFORMAT of a key, which defaults to identity
+func keyFormat(attributes map[string]string) string {
+	if format := attributes["KEYFORMAT"]; format != "" {
+		return format
+	}
+
+	return identityKeyFormat
+}
+
+// extraKeyTags returns the EXT-X-KEY tags of a media playlist that the
+// playlist decoder drops, as it only keeps the last key of those applying
+// to a segment, keyed by the index of the segment they precede
+func extraKeyTags(manifest string) map[int][]string {
+	extras := make(map[int][]string)
+	var keys []string
+	var segments int
+	for _, line := range strings.Split(manifest, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, tagKey):
+			keys = append(keys, line)
+		case line != "" && !strings.HasPrefix(line, "#"):
+			if len(keys) > 1 {
+				extras[segments] = keys[:len(keys)-1]
+			}
+			keys = nil
+			segments++
+		}
+	}
+
+	return extras
+}
+
+// sessionKeys returns the EXT-X-SESSION-KEY tags of a master playlist, which
+// the playlist decoder drops, with absolute URIs pointing to the cdn if set
+func sessionKeys(manifest string, absolute url.URL, cdn *url.URL) ([]string, error) {
+	var keys []string
+	for _, line := range strings.Split(manifest, "\n") {
+		if line = strings.TrimSpace(line); strings.HasPrefix(line, tagSessionKey) {
+			keys = append(keys, line)
+		}
+	}
+
+	return rewriteURIAttributes(keys, func(uri string) (string, error) {
+		return segmentURL(uri, absolute, cdn)
+	})
+}
+
+// maxKeyFormatChecks bounds the media playlists a request checks at once
+const maxKeyFormatChecks = 8
+
+// filterVariantKeyFormats drops the variants and audio alternatives whose media
+// playlists are encrypted without a key of the drm systems, along with variants
+// left without the audio renditions of their group. The media playlists are
+// fetched from their origin uri, up to maxKeyFormatChecks at a time, and the
+// ones that can't be fetched are kept.
+func (h *HLSFilter) filterVariantKeyFormats(ctx context.Context, systems []string, variants []*m3u8.Variant,
+	origins map[string]string) []*m3u8.Variant {
+	var uris []string
+	checked := make(map[string]bool)
+	for _, v := range variants {
+		for _, uri := range mediaURIs(v) {
+			if !checked[uri] {
+				checked[uri] = true
+				uris = append(uris, uri)
+			}
+		}
+	}
+
+	playable := make(map[string]bool)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, maxKeyFormatChecks)
+	for _, uri := range uris {
+		originURL := uri
+		if o, found := origins[uri]; found {
+			originURL = o
+		}
+
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(uri, originURL string) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+
+			p, err := variantKeyFormats.check(ctx, originURL, systems, h.config.Client)
+			if err != nil {
+				zerolog.Ctx(ctx).Warn().
+					Str("variant", originURL).
+					Err(err).
+					Msg("variant key formats unknown")
+				p = true
+			}
+
+			mu.Lock()
+			playable[uri] = p
+			mu.Unlock()
+		}(uri, originURL)
+	}
+	wg.Wait()
+
+	var filtered []*m3u8.Variant
+	for _, v := range variants {
+		if !playable[v.URI] {
+			continue
+		}
+
+		var alternatives []*m3u8.Alternative
+		var grouped, audio bool
+		for _, alt := range v.Alternatives {
+			if alt.Type == "AUDIO" && alt.GroupId == v.Audio {
+				grouped = true
+			}
+			if alt.Type == "AUDIO" && alt.URI != "" && !playable[alt.URI] {
+				continue
+			}
+			if alt.Type == "AUDIO" && alt.GroupId == v.Audio {
+				audio = true
+			}
+			alternatives = append(alternatives, alt)
+		}
+
+		if grouped && !audio {
+			continue
+		}
+
+		v.Alternatives = alternatives
+		filtered = append(filtered, v)
+	}
+
+	return filtered
+}
+
+// mediaURIs returns the uris of the media playlists of the variant and of its
+// audio alternatives, which are checked for their keys
+func mediaURIs(v *m3u8.Variant) []string {
+	uris := []string{v.URI}
+	for _, alt := range v.Alternatives {
+		if alt.Type == "AUDIO" && alt.URI != "" {
+			uris = append(uris, alt.URI)
+		}
+	}
+
+	return uris
+}
+
+// keyFormatsTTL is how long the verdict on the keys of a media playlist is cached
+const keyFormatsTTL = time.Minute
+
+// variantKeyFormats caches whether media playlists have keys of drm systems
+var variantKeyFormats = newKeyFormatChecks()
+
+// keyFormatChecks caches whether media playlists have a key of the drm systems
+// for all of their encrypted segments, collapsing concurrent checks of the same
+// media playlist into one
+type keyFormatChecks struct {
+	verdicts *cache.Cache
+}
+
+func newKeyFormatChecks() *keyFormatChecks {
+	return &keyFormatChecks{verdicts: cache.New()}
+}
+
+// check returns the cached verdict on the media playlist, checking it when the
+// verdict expired. Errors aren't cached.
+func (kc *keyFormatChecks) check(ctx context.Context, playlistURL string, systems []string, client config.Client) (bool, error) {
+	sorted := append([]string{}, systems...)
+	sort.Strings(sorted)
+	key := strings.Join(sorted, ",") + " " + playlistURL
+
+	v, _, err := kc.verdicts.Load(ctx, key, func(ctx context.Context) (interface{}, time.Duration, error) {
+		playable, err := playableVariant(ctx, playlistURL, systems, client)
+		return playable, keyFormatsTTL, err
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return v.(bool), nil
+}
+
+// playableVariant returns true if the media playlist of the variant has a
+// key of the drm systems for all of its encrypted segments
+func playableVariant(ctx context.Context, variantURL string, systems []string, client config.Client) (bool, error) {
+	o, err := origin.NewDefaultOrigin("", variantURL)
+	if err != nil {
+		return false, fmt.Errorf("fetching variant: %w", err)
+	}
+
+	manifestInfo, err := o.FetchOriginContent(ctx, client)
+	if err != nil {
+		return false, fmt.Errorf("fetching variant: %w", err)
+	}
+
+	if sc := manifestInfo.Status; sc/100 > 3 {
+		return false, fmt.Errorf("fetching variant: returning http status of %v", sc)
+	}
+
+	_, playable := filterKeyTags(manifestInfo.Payload, systems)
+	return playable, nil
+}
+
+// filterContentProtection removes the ContentProtection descriptors of adaptation
+// sets and representations whose scheme isn't one of the drm systems. The
+// mp4protection descriptor signaling the encryption scheme is kept. With
+// drmstrict, representations left without a descriptor of the drm systems are
+// removed along with adaptation sets left without representations.
+func (d *DASHFilter) filterContentProtection(filters *parsers.MediaFilters, manifest *mpd.MPD) error {
+	schemes := make(map[string]struct{})
+	for _, system := range filters.DRM {
+		for _, scheme := range contentProtectionSchemes[system] {
+			schemes[scheme] = struct{}{}
+		}
+	}
+
+	for _, period := range manifest.Periods {
+		var filteredAdaptationSets []*mpd.AdaptationSet
+		for _, as := range period.AdaptationSets {
+			var asProtected, asSupported bool
+			as.ContentProtection, asProtected, asSupported = filterContentProtections(as.ContentProtection, schemes)
+
+			var filteredRepresentations []*mpd.Representation
+			for _, r := range as.Representations {
+				var protected, supported bool
+				r.ContentProtection, protected, supported = filterContentProtections(r.ContentProtection, schemes)
+				if filters.DRMStrict && (asProtected || protected) && !asSupported && !supported {
+					continue
+				}
+
+				filteredRepresentations = append(filteredRepresentations, r)
+			}
+
+			as.Representations = filteredRepresentations
+
+			if len(as.Representations) != 0 {
+				filteredAdaptationSets = append(filteredAdaptationSets, as)
+			}
+		}
+
+		period.AdaptationSets = filteredAdaptationSets
+
+		// Recalculate AdaptationSet id numbers
+		for index, as := range period.AdaptationSets {
+			as.ID = strptr(strconv.Itoa(index))
+		}
+	}
+
+	return nil
+}
+
+// filterContentProtections keeps the descriptors whose scheme is one of the
+// schemes, along with the mp4protection descriptor. It returns whether any
+// descriptor of a drm system was found, and whether one of them was kept.
+func filterContentProtections(descriptors []mpd.ContentProtectioner, schemes map[string]struct{}) ([]mpd.ContentProtectioner, bool, bool) {
+	var filtered []mpd.ContentProtectioner
+	var protected, supported bool
+	for _, cp := range descriptors {
+		scheme := strings.ToLower(contentProtectionScheme(cp))
+		if scheme == mpd.CONTENT_PROTECTION_ROOT_SCHEME_ID_URI {
+			filtered = append(filtered, cp)
+			continue
+		}
+
+		protected = true
+		if _, found := schemes[scheme]; found {
+			supported = true
+			filtered = append(filtered, cp)
+		}
+	}
+
+	return filtered, protected, supported
+}
+
+// contentProtectionScheme returns the schemeIdUri of a ContentProtection
+// descriptor, whichever type it was decoded into
+func contentProtectionScheme(cp mpd.ContentProtectioner) string {
+	var scheme *string
+	switch p := cp.(type) {
+	case *mpd.ContentProtection:
+		scheme = p.SchemeIDURI
+	case *mpd.CENCContentProtection:
+		scheme = p.SchemeIDURI
+	case *mpd.PlayreadyContentProtection:
+		scheme = p.SchemeIDURI
+	case *mpd.WidevineContentProtection:
+		scheme = p.SchemeIDURI
+	}
+
+	if scheme == nil {
+		return ""
+	}
+
+	return *scheme
+}
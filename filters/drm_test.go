@@ -0,0 +1,154 @@
+package filters
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cbsinteractive/bakery/config"
+	"github.com/cbsinteractive/bakery/parsers"
+	test "github.com/cbsinteractive/bakery/tests"
+	"github.com/cbsinteractive/pkg/tracing"
+)
+
+func TestKeyFormatChecks_Check(t *testing.T) {
+	systems := []string{parsers.DRMWidevine, parsers.DRMFairPlay}
+
+	t.Run("when a media playlist is checked concurrently, expect a single request", func(t *testing.T) {
+		var requests int32
+		release := make(chan struct{})
+		client := healthCheckClient(200, &requests, release)
+		kc := newKeyFormatChecks()
+
+		var wg sync.WaitGroup
+		playable := make([]bool, 5)
+		for i := range playable {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				playable[i], _ = kc.check(context.Background(), healthCheckedVariantURL, systems, client)
+			}(i)
+		}
+		close(release)
+		wg.Wait()
+
+		if requests != 1 {
+			t.Errorf("Wrong number of requests: expect: 1, got %v", requests)
+		}
+
+		for _, p := range playable {
+			if !p {
+				t.Error("Expected an unencrypted media playlist to be playable")
+			}
+		}
+	})
+
+	t.Run("when a verdict is cached, expect it to be reused for the same drm systems in any order", func(t *testing.T) {
+		var requests int32
+		client := healthCheckClient(200, &requests, nil)
+		kc := newKeyFormatChecks()
+
+		kc.check(context.Background(), healthCheckedVariantURL, systems, client)
+		kc.check(context.Background(), healthCheckedVariantURL, []string{parsers.DRMFairPlay, parsers.DRMWidevine}, client)
+		if requests != 1 {
+			t.Errorf("Wrong number of requests: expect: 1, got %v", requests)
+		}
+
+		kc.check(context.Background(), healthCheckedVariantURL, []string{parsers.DRMPlayReady}, client)
+		if requests != 2 {
+			t.Errorf("Expected other drm systems to be checked again, got %v requests", requests)
+		}
+	})
+
+	t.Run("when a check fails, expect the error not to be cached", func(t *testing.T) {
+		var requests int32
+		client := healthCheckClient(500, &requests, nil)
+		kc := newKeyFormatChecks()
+
+		for i := 0; i < 2; i++ {
+			if _, err := kc.check(context.Background(), healthCheckedVariantURL, systems, client); err == nil {
+				t.Error("Expected an error, got nil")
+			}
+		}
+
+		if requests != 2 {
+			t.Errorf("Wrong number of requests: expect: 2, got %v", requests)
+		}
+	})
+}
+
+func TestHLSFilter_FilterContent_DRMVariantRequests(t *testing.T) {
+	const variants = 2*maxKeyFormatChecks + 1
+
+	var master strings.Builder
+	master.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n")
+	for i := 0; i < variants; i++ {
+		fmt.Fprintf(&master, "#EXT-X-STREAM-INF:BANDWIDTH=%d,CODECS=\"avc1.64001e,mp4a.40.2\"\nvideo_%d.m3u8\n",
+			(i+1)*100000, i)
+	}
+
+	var requests, inFlight, maxInFlight int32
+	release := make(chan struct{})
+	cfg := config.Config{
+		Hostname: "bakery.cbsi.video",
+		Client: config.Client{
+			Timeout: 5 * time.Second,
+			Tracer:  tracing.NoopTracer{},
+			HTTPClient: test.MockClient(func(*http.Request) (*http.Response, error) {
+				atomic.AddInt32(&requests, 1)
+				n := atomic.AddInt32(&inFlight, 1)
+				defer atomic.AddInt32(&inFlight, -1)
+				for {
+					max := atomic.LoadInt32(&maxInFlight)
+					if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+						break
+					}
+				}
+				<-release
+
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       ioutil.NopCloser(bytes.NewBufferString("#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXTINF:6.000,\nsegment_0.ts\n")),
+					Header:     http.Header{},
+				}, nil
+			}),
+		},
+	}
+	variantKeyFormats = newKeyFormatChecks()
+
+	done := make(chan error)
+	go func() {
+		filter := NewHLSFilter("https://existing.base/path/master.m3u8", master.String(), cfg)
+		_, err := filter.FilterContent(context.Background(), &parsers.MediaFilters{DRM: []string{parsers.DRMWidevine}})
+		done <- err
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&requests) < maxKeyFormatChecks && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if r := atomic.LoadInt32(&requests); r != maxKeyFormatChecks {
+		t.Errorf("Expected the media playlists to be fetched %v at a time, got %v requests", maxKeyFormatChecks, r)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("FilterContent(context.Background(), ) didnt expect an error to be returned, got: %v", err)
+	}
+
+	if requests != variants {
+		t.Errorf("Expected every media playlist to be fetched from the origin, got %v requests", requests)
+	}
+
+	if maxInFlight != maxKeyFormatChecks {
+		t.Errorf("Wrong number of requests in flight: expect: %v, got %v", maxKeyFormatChecks, maxInFlight)
+	}
+}
@@ -2,6 +2,8 @@ package filters
 
 import (
 	"context"
+	"math"
+	"sort"
 	"strings"
 
 	"github.com/cbsinteractive/bakery/parsers"
@@ -62,6 +64,99 @@ func isCaptionCodec(codec string) bool {
 		ValidCodecs(codec, wvttCodec))
 }
 
+// selectRenditions returns the indexes of the renditions to keep, given
+// their bandwidths, following the count and strategy of the filter
+func selectRenditions(renditions *parsers.Renditions, bandwidths []int) map[int]struct{} {
+	ladder := make([]int, len(bandwidths))
+	for i := range ladder {
+		ladder[i] = i
+	}
+	sort.SliceStable(ladder, func(i, j int) bool {
+		return bandwidths[ladder[i]] < bandwidths[ladder[j]]
+	})
+
+	count := renditions.Count
+	if count > len(ladder) {
+		count = len(ladder)
+	}
+
+	var selected []int
+	switch renditions.Strategy {
+	case parsers.RenditionsLowest:
+		selected = ladder[:count]
+	case parsers.RenditionsSpread:
+		for i := 0; i < count; i++ {
+			// a single rendition is taken from the top of the ladder
+			position := len(ladder) - 1
+			if count > 1 {
+				position = int(math.Round(float64(i*(len(ladder)-1)) / float64(count-1)))
+			}
+			selected = append(selected, ladder[position])
+		}
+	default:
+		selected = ladder[len(ladder)-count:]
+	}
+
+	kept := map[int]struct{}{}
+	for _, index := range selected {
+		kept[index] = struct{}{}
+	}
+
+	return kept
+}
+
+// sortRenditions returns the indexes of the renditions in the order set by the
+// sort filter, given their bandwidths. When a target bitrate is set, the
+// promotable rendition closest to it is moved to the first position.
+func sortRenditions(s *parsers.Sort, bandwidths []int, promotable func(index int) bool) []int {
+	order := make([]int, len(bandwidths))
+	for i := range order {
+		order[i] = i
+	}
+
+	switch s.Order {
+	case parsers.SortAscending:
+		sort.SliceStable(order, func(i, j int) bool {
+			return bandwidths[order[i]] < bandwidths[order[j]]
+		})
+	case parsers.SortDescending:
+		sort.SliceStable(order, func(i, j int) bool {
+			return bandwidths[order[i]] > bandwidths[order[j]]
+		})
+	}
+
+	if s.Target == 0 {
+		return order
+	}
+
+	closest := -1
+	for position, index := range order {
+		if !promotable(index) {
+			continue
+		}
+
+		if closest == -1 || distance(bandwidths[index], s.Target) < distance(bandwidths[order[closest]], s.Target) {
+			closest = position
+		}
+	}
+
+	if closest > 0 {
+		promoted := order[closest]
+		copy(order[1:closest+1], order[:closest])
+		order[0] = promoted
+	}
+
+	return order
+}
+
+func distance(x, y int) int {
+	if x > y {
+		return x - y
+	}
+
+	return y - x
+}
+
 func inRange(start int, end int, value int) bool {
 	return (start <= value) && (value <= end)
 }
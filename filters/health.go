@@ -0,0 +1,113 @@
+package filters
+
+import (
+	"context"
+	"time"
+
+	"github.com/cbsinteractive/bakery/cache"
+	"github.com/cbsinteractive/bakery/config"
+)
+
+// defaultHealthTTL is how long the health of variants without a target
+// duration, e.g. missing ones, is cached
+const defaultHealthTTL = 2 * time.Second
+
+// pipelinesTTL is how many target durations the pipelines of a manifest are
+// remembered for after it was last deweaved
+const pipelinesTTL = 3
+
+// pipelineHealth caches the health of the pipelines of redundant manifests
+var pipelineHealth = newHealthChecks()
+
+// variantHealth is the verdict of a variant health check along with the
+// numbers it was based on
+type variantHealth struct {
+	healthy        bool
+	lastModified   time.Time
+	age            time.Duration
+	staleAfter     time.Duration
+	targetDuration time.Duration
+}
+
+// ttl returns how long the verdict is valid for, a target duration
+func (v variantHealth) ttl() time.Duration {
+	if v.targetDuration > 0 {
+		return v.targetDuration
+	}
+
+	return defaultHealthTTL
+}
+
+// pipelineCheck is the result of checking the variant of a pipeline
+type pipelineCheck struct {
+	health variantHealth
+	cached bool
+	err    error
+}
+
+// healthChecks caches the health of variants for a target duration,
+// collapsing concurrent checks of the same variant into one. The variants
+// checked for each manifest are remembered for a few target durations, so
+// they can be checked again while the manifest is fetched.
+type healthChecks struct {
+	verdicts  *cache.Cache
+	manifests *cache.Cache
+}
+
+func newHealthChecks() *healthChecks {
+	return &healthChecks{
+		verdicts:  cache.New(),
+		manifests: cache.New(),
+	}
+}
+
+// PrefetchPipelineHealth starts health checking the pipelines of a redundant
+// manifest that were checked by previous requests, so their health is known
+// by the time the manifest is fetched and filtered. Nothing is checked for
+// manifests that weren't deweaved within three target durations, as their
+// pipelines are only known once the manifest is fetched.
+func PrefetchPipelineHealth(manifestURL string, client config.Client) {
+	pipelineHealth.prefetch(manifestURL, client)
+}
+
+// check returns the cached health of the variant, checking it when the
+// verdict expired. Errors aren't cached.
+func (hc *healthChecks) check(ctx context.Context, variantURL string, client config.Client) pipelineCheck {
+	v, cached, err := hc.verdicts.Load(ctx, variantURL, loadHealth(variantURL, client))
+	if err != nil {
+		return pipelineCheck{err: err}
+	}
+
+	return pipelineCheck{health: v.(variantHealth), cached: cached}
+}
+
+// prefetch checks the variants remembered for the manifest in the background,
+// unless their verdicts are still cached
+func (hc *healthChecks) prefetch(manifestURL string, client config.Client) {
+	variantURLs, found := hc.manifests.Get(manifestURL)
+	if !found {
+		return
+	}
+
+	for _, variantURL := range variantURLs.([]string) {
+		if variantURL != "" {
+			hc.verdicts.Prefetch(variantURL, loadHealth(variantURL, client))
+		}
+	}
+}
+
+// remember keeps the variants checked for the manifest for a few target
+// durations, or default TTLs when it isn't known
+func (hc *healthChecks) remember(manifestURL string, variantURLs []string, targetDuration time.Duration) {
+	ttl := variantHealth{targetDuration: targetDuration}.ttl()
+	hc.manifests.Set(manifestURL, variantURLs, pipelinesTTL*ttl)
+}
+
+// loadHealth returns a load of the health of the variant, cached for the
+// ttl of the verdict
+func loadHealth(variantURL string, client config.Client) cache.LoadFunc {
+	return func(ctx context.Context) (interface{}, time.Duration, error) {
+		health, err := healthCheckVariant(ctx, variantURL, client)
+		return health, health.ttl(), err
+	}
+}
@@ -0,0 +1,182 @@
+package filters
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cbsinteractive/bakery/config"
+	test "github.com/cbsinteractive/bakery/tests"
+	"github.com/cbsinteractive/pkg/tracing"
+)
+
+const healthCheckedVariantURL = "https://existing.base/path/a/rendition_1.m3u8"
+
+// healthCheckClient returns a client serving a fresh variant with the given
+// status, counting the requests made
+func healthCheckClient(status int, requests *int32, release <-chan struct{}) config.Client {
+	return config.Client{
+		Timeout: 5 * time.Second,
+		Tracer:  tracing.NoopTracer{},
+		HTTPClient: test.MockClient(func(*http.Request) (*http.Response, error) {
+			atomic.AddInt32(requests, 1)
+			if release != nil {
+				<-release
+			}
+
+			resp := &http.Response{
+				StatusCode: status,
+				Body:       ioutil.NopCloser(bytes.NewBufferString("#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:8\n")),
+				Header:     http.Header{},
+			}
+			resp.Header.Add("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+
+			return resp, nil
+		}),
+	}
+}
+
+func TestHealthChecks_Check(t *testing.T) {
+	t.Run("when a variant is checked concurrently, expect a single request", func(t *testing.T) {
+		var requests int32
+		release := make(chan struct{})
+		client := healthCheckClient(200, &requests, release)
+		hc := newHealthChecks()
+
+		var wg sync.WaitGroup
+		checks := make([]pipelineCheck, 5)
+		for i := range checks {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				checks[i] = hc.check(context.Background(), healthCheckedVariantURL, client)
+			}(i)
+		}
+		close(release)
+		wg.Wait()
+
+		if requests != 1 {
+			t.Errorf("Wrong number of requests: expect: 1, got %v", requests)
+		}
+
+		for _, c := range checks {
+			if c.err != nil || !c.health.healthy {
+				t.Errorf("Expected a healthy variant, got %+v", c)
+			}
+		}
+	})
+
+	t.Run("when a verdict is cached, expect it to be reused until it expires", func(t *testing.T) {
+		var requests int32
+		client := healthCheckClient(200, &requests, nil)
+		hc := newHealthChecks()
+
+		hc.check(context.Background(), healthCheckedVariantURL, client)
+		c := hc.check(context.Background(), healthCheckedVariantURL, client)
+		if !c.cached {
+			t.Error("Expected the verdict to be cached")
+		}
+
+		if ttl := c.health.ttl(); ttl != 8*time.Second {
+			t.Errorf("Expected the verdict to be cached for a target duration, cached for %v", ttl)
+		}
+
+		hc.verdicts.Set(healthCheckedVariantURL, c.health, -time.Second)
+		if c := hc.check(context.Background(), healthCheckedVariantURL, client); c.cached {
+			t.Error("Expected an expired verdict to be checked again")
+		}
+
+		if requests != 2 {
+			t.Errorf("Wrong number of requests: expect: 2, got %v", requests)
+		}
+	})
+
+	t.Run("when a check fails, expect the error not to be cached", func(t *testing.T) {
+		var requests int32
+		client := healthCheckClient(500, &requests, nil)
+		hc := newHealthChecks()
+
+		for i := 0; i < 2; i++ {
+			if c := hc.check(context.Background(), healthCheckedVariantURL, client); c.err == nil {
+				t.Error("Expected an error, got nil")
+			}
+		}
+
+		if requests != 2 {
+			t.Errorf("Wrong number of requests: expect: 2, got %v", requests)
+		}
+	})
+
+	t.Run("when the variants of a manifest are prefetched, expect the check to join them", func(t *testing.T) {
+		var requests int32
+		client := healthCheckClient(200, &requests, nil)
+		hc := newHealthChecks()
+
+		hc.prefetch("https://existing.base/path/master.m3u8", client)
+		if requests != 0 {
+			t.Errorf("Expected unknown manifests not to be prefetched, got %v requests", requests)
+		}
+
+		hc.remember("https://existing.base/path/master.m3u8", []string{healthCheckedVariantURL}, 8*time.Second)
+		hc.prefetch("https://existing.base/path/master.m3u8", client)
+		c := hc.check(context.Background(), healthCheckedVariantURL, client)
+		if c.err != nil || !c.health.healthy {
+			t.Errorf("Expected a healthy variant, got %+v", c)
+		}
+
+		if requests != 1 {
+			t.Errorf("Wrong number of requests: expect: 1, got %v", requests)
+		}
+	})
+
+	t.Run("when the verdicts of a manifest expired and were pruned, expect its variants to be prefetched", func(t *testing.T) {
+		var requests, prefetched int32
+		client := healthCheckClient(200, &requests, nil)
+		hc := newHealthChecks()
+
+		c := hc.check(context.Background(), healthCheckedVariantURL, client)
+		hc.remember("https://existing.base/path/master.m3u8", []string{healthCheckedVariantURL}, 8*time.Second)
+		hc.verdicts.Set(healthCheckedVariantURL, c.health, -time.Second)
+
+		// checking an unrelated variant prunes the expired verdict
+		hc.check(context.Background(), "https://existing.base/path/b/rendition_1.m3u8", client)
+		if _, remembered := hc.manifests.Get("https://existing.base/path/master.m3u8"); !remembered {
+			t.Fatal("Expected the manifest to be remembered after its verdicts were pruned")
+		}
+
+		release := make(chan struct{})
+		hc.prefetch("https://existing.base/path/master.m3u8", healthCheckClient(200, &prefetched, release))
+
+		close(release)
+		if c := hc.check(context.Background(), healthCheckedVariantURL, client); c.err != nil || !c.health.healthy {
+			t.Errorf("Expected a healthy variant, got %+v", c)
+		}
+
+		if requests != 2 || prefetched != 1 {
+			t.Errorf("Expected the check to join the prefetched one, got %v requests and %v prefetched", requests, prefetched)
+		}
+	})
+
+	t.Run("when the pipelines of a manifest expired, expect them not to be prefetched", func(t *testing.T) {
+		var requests int32
+		client := healthCheckClient(200, &requests, nil)
+		hc := newHealthChecks()
+
+		hc.remember("https://existing.base/path/master.m3u8", []string{healthCheckedVariantURL}, 50*time.Millisecond)
+		time.Sleep(75 * time.Millisecond)
+		if _, remembered := hc.manifests.Get("https://existing.base/path/master.m3u8"); !remembered {
+			t.Error("Expected the pipelines to be remembered for three target durations")
+		}
+
+		time.Sleep(100 * time.Millisecond)
+		hc.prefetch("https://existing.base/path/master.m3u8", client)
+		if requests != 0 {
+			t.Errorf("Expected expired pipelines not to be prefetched, got %v requests", requests)
+		}
+	})
+}
@@ -1,12 +1,15 @@
 package filters
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"math"
 	"net/url"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -35,13 +38,6 @@ var matchFunctions = map[ContentType]func(string) bool{
 	captionContentType: isCaptionCodec,
 }
 
-type pipelineType string
-
-const (
-	primaryPipeline pipelineType = "primary"
-	backupPipeline               = "backup"
-)
-
 // NewHLSFilter is the HLS filter constructor
 func NewHLSFilter(originURL, originContent string, c config.Config) *HLSFilter {
 	return &HLSFilter{
@@ -59,39 +55,75 @@ func (h *HLSFilter) GetMaxAge() string {
 // FilterContent will be responsible for filtering the manifest
 // according  to the MediaFilters
 func (h *HLSFilter) FilterContent(ctx context.Context, filters *parsers.MediaFilters) (string, error) {
-	m, manifestType, err := m3u8.DecodeFrom(strings.NewReader(h.originContent), true)
+	content, lowLatency := splitLowLatencyTags(removeTags(h.originContent, filters.SuppressTags()))
+	playable := true
+	if len(filters.DRM) > 0 {
+		content, playable = filterKeyTags(content, filters.DRM)
+	}
+
+	m, manifestType, err := m3u8.DecodeFrom(strings.NewReader(content), true)
 	if err != nil {
 		return "", err
 	}
 
 	if manifestType != m3u8.MASTER {
-		if filters.Trim != nil {
-			return h.trimRenditionManifest(filters, m.(*m3u8.MediaPlaylist))
+		// low latency playlists are still filtered, so rendition reports point
+		// to bakery and delta updates that can't be forwarded aren't advertised
+		if mediaPlaylistFilters(filters).Path() == "" && lowLatency == nil {
+			return h.passThroughRenditionManifest(filters, m.(*m3u8.MediaPlaylist))
+		}
+
+		if !playable {
+			return "", fmt.Errorf("no keys with the key formats of drm(%v)", strings.Join(filters.DRM, ","))
 		}
-		return isEmpty(h.originContent)
+
+		return h.filterRenditionManifest(filters, m.(*m3u8.MediaPlaylist), lowLatency,
+			dateRangeTags(content), extraKeyTags(content), unknownTags(content))
+	}
+
+	cdn, err := cdnHost(h.config, filters)
+	if err != nil {
+		return "", err
 	}
 
 	// convert into the master playlist type
 	manifest := m.(*m3u8.MasterPlaylist)
 	filteredManifest := copyPlaylistDefaults(manifest)
 
+	absolute, err := getAbsoluteURL(h.originURL)
+	if err != nil {
+		return "", err
+	}
+
+	keys, err := sessionKeys(content, *absolute, cdn)
+	if err != nil {
+		return "", fmt.Errorf("formatting session key URLs: %w", err)
+	}
+
+	if len(keys) > 0 {
+		filteredManifest.SetCustomTag(&tagLines{name: tagSessionKey, lines: keys})
+	}
+
 	//evaluate pipeline if DeWeaved filter is set
-	var pipeline pipelineType
-	if filters.DeWeave && pipeline == "" {
-		p, err := h.filterPipeline(ctx, manifest.Variants[0].URI)
-		if err != nil {
-			return "", fmt.Errorf("filtering pipeline: %w", err)
+	pipeline := anyPipeline
+	var pipelines []int
+	if filters.DeWeave {
+		var count int
+		pipelines, count = variantPipelines(content, manifest.Variants)
+		if count > 1 {
+			pipeline, err = h.filterPipeline(ctx, manifest.Variants, pipelines, count)
+			if err != nil {
+				return "", fmt.Errorf("filtering pipeline: %w", err)
+			}
 		}
-
-		pipeline = p
 	}
 
-	//When parsed, Media Alternatives are held at the root of the object
-	//with each variant refrencing it. We hold a slice of trimmed
-	//alternatives to avoid processing a media alternative twice
-	trimmedAlternatives := make(map[string]struct{})
+	// candidates are the variants of the pipeline, before the filters remove any
+	var variants, candidates []*m3u8.Variant
+	// origin uris of the variants and alternatives, by the uri they're served with
+	origins := make(map[string]string)
 	for i, v := range manifest.Variants {
-		if !isValidPipeline(pipeline, i) {
+		if pipeline != anyPipeline && pipelines[i] != pipeline && pipelines[i] != anyPipeline {
 			continue
 		}
 
@@ -109,6 +141,19 @@ func (h *HLSFilter) FilterContent(ctx context.Context, filters *parsers.MediaFil
 			return "", err
 		}
 
+		// media playlists checked for their keys are fetched from the origin
+		originURIs := mediaURIs(normalizedVariant)
+		if err := rewriteVariantHosts(normalizedVariant, cdn); err != nil {
+			return "", err
+		}
+		for i, uri := range mediaURIs(normalizedVariant) {
+			if _, found := origins[uri]; !found {
+				origins[uri] = originURIs[i]
+			}
+		}
+
+		candidates = append(candidates, normalizedVariant)
+
 		filteredVariant, err := h.filterVariant(filters, normalizedVariant)
 		if err != nil {
 			return "", err
@@ -118,8 +163,33 @@ func (h *HLSFilter) FilterContent(ctx context.Context, filters *parsers.MediaFil
 			continue
 		}
 
-		uri := normalizedVariant.URI
-		if filters.Trim != nil {
+		variants = append(variants, normalizedVariant)
+	}
+
+	if len(filters.DRM) > 0 {
+		variants = h.filterVariantKeyFormats(ctx, filters.DRM, variants, origins)
+	}
+
+	if filters.Renditions != nil {
+		variants = filterVariantRenditions(filters.Renditions, variants)
+	}
+
+	variants = filterOrphanIFrameVariants(candidates, variants)
+
+	if filters.Sort != nil {
+		variants = sortVariants(filters.Sort, variants)
+	}
+
+	// variants point to bakery whenever their media playlists are filtered
+	filterMediaPlaylists := mediaPlaylistFilters(filters).Path() != ""
+
+	//When parsed, Media Alternatives are held at the root of the object
+	//with each variant refrencing it. We hold a slice of trimmed
+	//alternatives to avoid processing a media alternative twice
+	trimmedAlternatives := make(map[*m3u8.Alternative]struct{})
+	for _, v := range variants {
+		uri := v.URI
+		if filterMediaPlaylists {
 			uri, err = h.normalizeTrimmedVariant(filters, uri)
 			if err != nil {
 				return "", err
@@ -130,7 +200,7 @@ func (h *HLSFilter) FilterContent(ctx context.Context, filters *parsers.MediaFil
 			}
 		}
 
-		filteredManifest.Append(uri, normalizedVariant.Chunklist, normalizedVariant.VariantParams)
+		filteredManifest.Append(uri, v.Chunklist, v.VariantParams)
 	}
 
 	return filteredManifest.String(), nil
@@ -147,37 +217,13 @@ func copyPlaylistDefaults(manifest *m3u8.MasterPlaylist) *m3u8.MasterPlaylist {
 	return filteredManifest
 }
 
-func (h *HLSFilter) filterPipeline(ctx context.Context, uri string) (pipelineType, error) {
-	absolute, err := getAbsoluteURL(h.originURL)
-	if err != nil {
-		return "", fmt.Errorf("formatting segment URLs: %w", err)
-	}
-
-	uri, err = combinedIfRelative(uri, *absolute)
-	if err != nil {
-		return "", fmt.Errorf("formatting segment URLs: %w", err)
-	}
-
-	healthy, err := healthCheckVariant(ctx, uri, h.config.Client)
-	if err != nil {
-		return "", err
-	}
-
-	if healthy {
-		return primaryPipeline, nil
-	}
-
-	return backupPipeline, nil
-}
-
 // Returns true if specified variant should be removed from filter
 func (h *HLSFilter) filterVariant(filters *parsers.MediaFilters, v *m3u8.Variant) (bool, error) {
 	variantCodecs := strings.Split(v.Codecs, ",")
 
-	// we do not apply filters to iframe playlists. we need to create a nested filter.
-	// for now, iframe playlists are explicitly removed via tags(iframe) filter
+	// iframe playlists are only filtered by the i() nested filter
 	if v.Iframe {
-		return false, nil
+		return h.filterIFrameVariant(filters.IFrames, v)
 	}
 
 	if filters.Videos.Bitrate != nil || filters.Audios.Bitrate != nil {
@@ -223,21 +269,182 @@ func (h *HLSFilter) filterVariant(filters *parsers.MediaFilters, v *m3u8.Variant
 		}
 	}
 
+	for filterType, only := range map[ContentType][]string{
+		videoContentType:   filters.Videos.Only,
+		audioContentType:   filters.Audios.Only,
+		captionContentType: filters.Captions.Only,
+	} {
+		if only == nil {
+			continue
+		}
+
+		allowedTypes := map[string]struct{}{}
+		for _, c := range only {
+			allowedTypes[c] = struct{}{}
+		}
+		res, err := filterVariantAllowedCodecs(filterType, variantCodecs, allowedTypes, matchFunctions)
+		if res {
+			return true, err
+		}
+	}
+
 	if filters.FrameRate != nil {
 		if filterVariantFrameRate(v.FrameRate, filters.FrameRate) {
 			return true, nil
 		}
 	}
 
-	// This filter should run last as it is not removing variants, rather updating the alternatives attached to
-	// the variant. This function will only execute if no matches have been found
+	if filters.VideoRange != nil {
+		if filterVariantVideoRange(v, filters.VideoRange) {
+			return true, nil
+		}
+	}
+
+	if filters.Videos.Height != nil || filters.Videos.Width != nil {
+		if filterVariantResolution(v.Resolution, filters.Videos) {
+			return true, nil
+		}
+	}
+
+	// These filters should run last as they are not removing variants, rather updating the alternatives attached to
+	// the variant. These functions will only execute if no matches have been found
 	if filters.Audios.Language != nil || filters.Captions.Language != nil {
 		h.filterVariantLanguage(v, filters)
 	}
 
+	if filters.Audios.Channels != nil {
+		h.filterVariantChannels(v, filters.Audios.Channels)
+	}
+
 	return false, nil
 }
 
+// Returns true if specified i-frame variant should be removed from filter
+func (h *HLSFilter) filterIFrameVariant(filters parsers.NestedFilters, v *m3u8.Variant) (bool, error) {
+	variantCodecs := strings.Split(v.Codecs, ",")
+
+	if filters.Bitrate != nil {
+		bw := v.AverageBandwidth
+		if bw == 0 {
+			bw = v.Bandwidth
+		}
+		if !inRange(filters.Bitrate.Min, filters.Bitrate.Max, int(bw)) {
+			return true, nil
+		}
+	}
+
+	if filters.Codecs != nil {
+		supportedVideoTypes := map[string]struct{}{}
+		for _, vt := range filters.Codecs {
+			supportedVideoTypes[vt] = struct{}{}
+		}
+		res, err := filterVariantCodecs(videoContentType, variantCodecs, supportedVideoTypes, matchFunctions)
+		if res {
+			return true, err
+		}
+	}
+
+	if filters.Only != nil {
+		allowedTypes := map[string]struct{}{}
+		for _, c := range filters.Only {
+			allowedTypes[c] = struct{}{}
+		}
+		res, err := filterVariantAllowedCodecs(videoContentType, variantCodecs, allowedTypes, matchFunctions)
+		if res {
+			return true, err
+		}
+	}
+
+	if filters.Height != nil || filters.Width != nil {
+		if filterVariantResolution(v.Resolution, filters) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// filterOrphanIFrameVariants removes the i-frame variants whose matching variants
+// in the original playlist were all filtered out. Variants are returned as they
+// are when the filters didn't remove any variant that isn't an i-frame variant.
+func filterOrphanIFrameVariants(original, variants []*m3u8.Variant) []*m3u8.Variant {
+	kept := map[*m3u8.Variant]struct{}{}
+	for _, v := range variants {
+		kept[v] = struct{}{}
+	}
+
+	var removed bool
+	for _, o := range original {
+		if _, found := kept[o]; !found && !o.Iframe {
+			removed = true
+			break
+		}
+	}
+
+	if !removed {
+		return variants
+	}
+
+	var filtered []*m3u8.Variant
+	for _, v := range variants {
+		if !v.Iframe {
+			filtered = append(filtered, v)
+			continue
+		}
+
+		var matched, orphan = false, true
+		for _, o := range original {
+			if o.Iframe || !matchIFrameVariant(v, o) {
+				continue
+			}
+
+			matched = true
+			if _, found := kept[o]; found {
+				orphan = false
+				break
+			}
+		}
+
+		if !matched || !orphan {
+			filtered = append(filtered, v)
+		}
+	}
+
+	return filtered
+}
+
+// matchIFrameVariant returns true if the i-frame variant shares the resolution
+// and the video codecs of the variant. Codecs are compared by their sample entry,
+// e.g. `avc1`, as i-frame playlists are often encoded with a different profile.
+func matchIFrameVariant(iframe, v *m3u8.Variant) bool {
+	if iframe.Resolution != "" && v.Resolution != "" && iframe.Resolution != v.Resolution {
+		return false
+	}
+
+	variantCodecs := map[string]struct{}{}
+	for _, codec := range strings.Split(v.Codecs, ",") {
+		variantCodecs[codecSampleEntry(codec)] = struct{}{}
+	}
+
+	for _, codec := range strings.Split(iframe.Codecs, ",") {
+		if _, found := variantCodecs[codecSampleEntry(codec)]; isVideoCodec(codec) && !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// codecSampleEntry returns the sample entry of a codec, e.g. `avc1` for `avc1.64001f`
+func codecSampleEntry(codec string) string {
+	codec = strings.TrimSpace(codec)
+	if i := strings.Index(codec, "."); i != -1 {
+		return codec[:i]
+	}
+
+	return codec
+}
+
 // Returns true if the provided variant is out of range since filters are removed when true.
 func (h *HLSFilter) filterVariantBandwidth(b int, variantCodecs []string, filters *parsers.MediaFilters) bool {
 	for _, codec := range variantCodecs {
@@ -295,6 +502,35 @@ func filterVariantCodecs(filterType ContentType, variantCodecs []string, support
 	return variantFound, nil
 }
 
+// Returns true if the given variant (variantCodecs) carries a codec of filterType
+// that is not part of the allowed codecs
+func filterVariantAllowedCodecs(filterType ContentType, variantCodecs []string, allowedCodecs map[string]struct{}, supportedFilterTypes map[ContentType]func(string) bool) (bool, error) {
+	matchFilterType, found := supportedFilterTypes[filterType]
+	if !found {
+		return false, errors.New("filter type is unsupported")
+	}
+
+	for _, codec := range variantCodecs {
+		if !matchFilterType(codec) {
+			continue
+		}
+
+		allowed := false
+		for ac := range allowedCodecs {
+			if ValidCodecs(codec, CodecFilterID(ac)) {
+				allowed = true
+				break
+			}
+		}
+
+		if !allowed {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
 func filterVariantFrameRate(floatFPS float64, frameRates []string) bool {
 	strFPS := fmt.Sprintf("%.3f", floatFPS)
 
@@ -307,24 +543,129 @@ func filterVariantFrameRate(floatFPS float64, frameRates []string) bool {
 	return false
 }
 
-// Returns true if a given variant matches the provided language filter
-func (h *HLSFilter) filterVariantLanguage(v *m3u8.Variant, filters *parsers.MediaFilters) {
-	if v.Alternatives == nil {
-		return
+// Returns true if the variant resolution is out of the height or width range.
+// Variants without a resolution, such as audio only variants, are kept
+func filterVariantResolution(resolution string, filters parsers.NestedFilters) bool {
+	width, height, ok := parseResolution(resolution)
+	if !ok {
+		return false
 	}
 
-	match := func(alt *m3u8.Alternative, langs []string) bool {
-		if langs == nil {
-			return false
+	if filters.Height != nil && !inRange(filters.Height.Min, filters.Height.Max, height) {
+		return true
+	}
+
+	if filters.Width != nil && !inRange(filters.Width.Min, filters.Width.Max, width) {
+		return true
+	}
+
+	return false
+}
+
+// parseResolution returns the width and height of a WIDTHxHEIGHT resolution
+func parseResolution(resolution string) (int, int, bool) {
+	dimensions := strings.Split(resolution, "x")
+	if len(dimensions) != 2 {
+		return 0, 0, false
+	}
+
+	width, err := strconv.Atoi(dimensions[0])
+	if err != nil {
+		return 0, 0, false
+	}
+
+	height, err := strconv.Atoi(dimensions[1])
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return width, height, true
+}
+
+// filterVariantRenditions keeps the video variants chosen by the renditions
+// filter. Audio only and I-frame variants are always kept.
+func filterVariantRenditions(renditions *parsers.Renditions, variants []*m3u8.Variant) []*m3u8.Variant {
+	var videoVariants []int
+	var bandwidths []int
+	for i, v := range variants {
+		if v.Iframe || !isVideoVariant(v) {
+			continue
 		}
 
-		for _, lang := range langs {
-			if strings.EqualFold(string(lang), alt.Language) {
-				return true
-			}
+		videoVariants = append(videoVariants, i)
+		bandwidths = append(bandwidths, int(v.Bandwidth))
+	}
+
+	removed := map[int]struct{}{}
+	kept := selectRenditions(renditions, bandwidths)
+	for i, index := range videoVariants {
+		if _, found := kept[i]; !found {
+			removed[index] = struct{}{}
 		}
+	}
 
-		return false
+	var filtered []*m3u8.Variant
+	for i, v := range variants {
+		if _, found := removed[i]; !found {
+			filtered = append(filtered, v)
+		}
+	}
+
+	return filtered
+}
+
+// sortVariants orders the variants following the sort filter. I-frame variants
+// keep their position in the playlist.
+func sortVariants(s *parsers.Sort, variants []*m3u8.Variant) []*m3u8.Variant {
+	var positions []int
+	var streams []*m3u8.Variant
+	var bandwidths []int
+	for i, v := range variants {
+		if v.Iframe {
+			continue
+		}
+
+		positions = append(positions, i)
+		streams = append(streams, v)
+		bandwidths = append(bandwidths, int(v.Bandwidth))
+	}
+
+	sorted := make([]*m3u8.Variant, len(variants))
+	copy(sorted, variants)
+	order := sortRenditions(s, bandwidths, func(i int) bool {
+		return isVideoVariant(streams[i])
+	})
+	for i, index := range order {
+		sorted[positions[i]] = streams[index]
+	}
+
+	return sorted
+}
+
+// isVideoVariant returns true if the variant advertises a video codec or,
+// when codecs are not set, a resolution
+func isVideoVariant(v *m3u8.Variant) bool {
+	if v.Codecs == "" {
+		return v.Resolution != ""
+	}
+
+	for _, codec := range strings.Split(v.Codecs, ",") {
+		if isVideoCodec(codec) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns true if a given variant matches the provided language filter
+func (h *HLSFilter) filterVariantLanguage(v *m3u8.Variant, filters *parsers.MediaFilters) {
+	if v.Alternatives == nil {
+		return
+	}
+
+	match := func(alt *m3u8.Alternative, nf parsers.NestedFilters) bool {
+		return matchLanguage(alt.Language, nf.Language, nf.ExactLanguage)
 	}
 
 	var alts []*m3u8.Alternative
@@ -333,11 +674,11 @@ func (h *HLSFilter) filterVariantLanguage(v *m3u8.Variant, filters *parsers.Medi
 		remove := true
 		switch alt.Type {
 		case "AUDIO":
-			remove = match(alt, filters.Audios.Language)
+			remove = match(alt, filters.Audios)
 		case "SUBTITLES":
-			remove = match(alt, filters.Captions.Language)
+			remove = match(alt, filters.Captions)
 		case "CLOSED-CAPTIONS":
-			remove = match(alt, filters.Captions.Language)
+			remove = match(alt, filters.Captions)
 		}
 
 		if !remove {
@@ -359,6 +700,46 @@ func (h *HLSFilter) filterVariantLanguage(v *m3u8.Variant, filters *parsers.Medi
 	}
 }
 
+// Removes the audio alternatives of the variant with a number of channels out of range
+func (h *HLSFilter) filterVariantChannels(v *m3u8.Variant, channels *parsers.Channels) {
+	if v.Alternatives == nil {
+		return
+	}
+
+	var alts []*m3u8.Alternative
+	var audioGroupIDs = map[string]struct{}{}
+	for _, alt := range v.Alternatives {
+		if alt.Type == "AUDIO" {
+			if count, ok := parseChannels(alt.Channels); ok && !inRange(channels.Min, channels.Max, count) {
+				continue
+			}
+			audioGroupIDs[alt.GroupId] = struct{}{}
+		}
+
+		alts = append(alts, alt)
+	}
+
+	v.Alternatives = alts
+	if _, audio := audioGroupIDs[v.Audio]; !audio {
+		v.Audio = ""
+	}
+}
+
+// parseChannels returns the number of audio channels in the CHANNELS
+// attribute, e.g. `6` or `16/JOC`
+func parseChannels(channels string) (int, bool) {
+	if i := strings.Index(channels, "/"); i != -1 {
+		channels = channels[:i]
+	}
+
+	count, err := strconv.Atoi(channels)
+	if err != nil {
+		return 0, false
+	}
+
+	return count, true
+}
+
 func (h *HLSFilter) normalizeVariant(v *m3u8.Variant, absolute url.URL) (*m3u8.Variant, error) {
 	for _, a := range v.VariantParams.Alternatives {
 		aURL, aErr := combinedIfRelative(a.URI, absolute)
@@ -378,23 +759,45 @@ func (h *HLSFilter) normalizeVariant(v *m3u8.Variant, absolute url.URL) (*m3u8.V
 
 func (h *HLSFilter) normalizeTrimmedVariant(filters *parsers.MediaFilters, uri string) (string, error) {
 	encoded := base64.RawURLEncoding.EncodeToString([]byte(uri))
-	start := filters.Trim.Start
-	end := filters.Trim.End
+	// the cdn carries over to rewrite absolute segment urls, yet it doesn't
+	// route variants to bakery on its own as they already point to the cdn
+	mf := mediaPlaylistFilters(filters)
+	mf.CDN = filters.CDN
+	variantFilters := mf.Path()
 	u, err := url.Parse(uri)
 	if err != nil {
 		return "", err
 	}
 
-	switch {
-	case h.config.IsLocalHost() && filters.SuppressAds():
-		return fmt.Sprintf("http://%v%v/t(%v,%v)/tags(ads)/%v.m3u8", h.config.Hostname, h.config.Listen, start, end, encoded), nil
-	case h.config.IsLocalHost():
-		return fmt.Sprintf("http://%v%v/t(%v,%v)/%v.m3u8", h.config.Hostname, h.config.Listen, start, end, encoded), nil
-	case filters.SuppressAds():
-		return fmt.Sprintf("%v://%v/t(%v,%v)/tags(ads)/%v.m3u8", u.Scheme, h.config.Hostname, start, end, encoded), nil
+	if h.config.IsLocalHost() {
+		return fmt.Sprintf("http://%v%v%v/%v.m3u8", h.config.Hostname, h.config.Listen, variantFilters, encoded), nil
 	}
 
-	return fmt.Sprintf("%v://%v/t(%v,%v)/%v.m3u8", u.Scheme, h.config.Hostname, start, end, encoded), nil
+	return fmt.Sprintf("%v://%v%v/%v.m3u8", u.Scheme, h.config.Hostname, variantFilters, encoded), nil
+}
+
+// mediaPlaylistFilters returns the subset of filters that must be carried
+// over to the variant urls as they apply to media playlists
+func mediaPlaylistFilters(filters *parsers.MediaFilters) *parsers.MediaFilters {
+	mf := &parsers.MediaFilters{
+		Trim:         filters.Trim,
+		MediaTime:    filters.MediaTime,
+		Sequence:     filters.Sequence,
+		DVR:          filters.DVR,
+		PlaylistType: filters.PlaylistType,
+		SCTE:         filters.SCTE,
+		AdSkip:       filters.AdSkip,
+		DRM:          filters.DRM,
+	}
+
+	if filters.SuppressAds() || len(filters.SuppressTags()) > 0 {
+		mf.Tags = &parsers.Tags{
+			Ads:    filters.SuppressAds(),
+			Custom: filters.SuppressTags(),
+		}
+	}
+
+	return mf
 }
 
 func combinedIfRelative(uri string, absolute url.URL) (string, error) {
@@ -423,62 +826,316 @@ func isRelative(urlStr string) (bool, error) {
 	return !u.IsAbs(), nil
 }
 
-// FilterRenditionManifest will be responsible for filtering the manifest
-// according  to the MediaFilters
-func (h *HLSFilter) trimRenditionManifest(filters *parsers.MediaFilters, m *m3u8.MediaPlaylist) (string, error) {
+// filterRenditionManifest will be responsible for filtering the manifest
+// according  to the MediaFilters. Segments are trimmed by program date time,
+// media time from the start of the playlist and media sequence, whichever are set.
+// Ad tags are suppressed and segment urls are made absolute, trimmed or not.
+// Ad cues are translated into the scte dialect when set, unless suppressed,
+// and ad breaks are spliced out with adskip. Every key of the segments is
+// kept, along with LL-HLS tags while the playlist stays open.
+func (h *HLSFilter) filterRenditionManifest(filters *parsers.MediaFilters, m *m3u8.MediaPlaylist,
+	lowLatency *lowLatencyTags, dateRanges map[int][]string, extraKeys map[int][]string, unknown *playlistTags) (string, error) {
 	filteredPlaylist, err := m3u8.NewMediaPlaylist(m.Count(), m.Count())
 	if err != nil {
 		return "", fmt.Errorf("filtering Rendition Manifest: %w", err)
 	}
 
-	// timestamps in milliseconds
-	startFilter := filters.Trim.Start * 1000
-	endFilter := filters.Trim.End * 1000
+	// the version of the origin is kept, the encoder raising it if needed
+	filteredPlaylist.SetVersion(m.Version())
+	appendPlaylistTags(filteredPlaylist, unknown.header...)
+
+	cdn, err := cdnHost(h.config, filters)
+	if err != nil {
+		return "", err
+	}
+
+	absolute, err := getAbsoluteURL(h.originURL)
+	if err != nil {
+		return "", fmt.Errorf("formatting segment URLs: %w", err)
+	}
 
-	// Append mode will be set to true when first segment is encountered in range.
+	// the keys dropped by the decoder are written along with the key it kept,
+	// which carries over to the segments where it's written again
+	keys := make(map[*m3u8.Key][]string)
+	for i, lines := range extraKeys {
+		if i < len(m.Segments) && m.Segments[i] != nil && m.Segments[i].Key != nil {
+			keys[m.Segments[i].Key] = lines
+		}
+	}
+
+	// trimmed playlists are closed and start over from the first sequence,
+	// any other playlist keeps its sequence and type so live playlists stay live
+	filteredPlaylist.Iframe = m.Iframe
+	if !filters.Trimmed() {
+		filteredPlaylist.SeqNo = m.SeqNo
+		filteredPlaylist.DiscontinuitySeq = m.DiscontinuitySeq
+		filteredPlaylist.MediaType = m.MediaType
+		filteredPlaylist.TargetDuration = m.TargetDuration
+	}
+
+	// live playlists only keep the segments within the dvr window, event
+	// playlists become live ones as their segments can't be removed
+	var window int
+	if filters.DVR > 0 && !filters.Trimmed() && !m.Closed {
+		window = dvrWindow(m.Segments, filters.DVR)
+		filteredPlaylist.SeqNo += uint64(window)
+		if filteredPlaylist.MediaType == m3u8.EVENT {
+			filteredPlaylist.MediaType = 0
+		}
+	}
+
+	// Appending will be set to true when first segment is encountered in range.
 	// Once true, we can append segments with tags that don't normally carry PDT
 	// EX: #EXT-X-ASSET, #EXT-OATCLS-SCTE35, or any other custom tags advertised in playlist
-	var append bool
+	var appending bool
 	var maxSize float64
-	for _, segment := range m.Segments {
+	var mediaTime int // milliseconds from the start of the playlist
+	var key *m3u8.Key
+	var segmentMap *m3u8.Map
+	var sequences []uint64 // media sequence numbers of the appended segments
+
+	// ad cues are translated on every segment, filtered out or not, so
+	// ad breaks are followed from their start
+	var cues *cueTranslator
+	if filters.SCTE != "" && !filters.SuppressAds() && !filters.AdSkip {
+		cues = newCueTranslator(filters.SCTE, m.Segments, dateRanges)
+	}
+
+	// ad breaks are spliced out, the segments following them starting
+	// with a discontinuity. The segments following a break would be numbered
+	// again once it leaves the window, so playlists losing segments as they're
+	// reloaded can't have their ads skipped.
+	var ads []bool
+	var spliced bool
+	if filters.AdSkip {
+		if slidingWindow(filters, m) {
+			return "", fmt.Errorf("skipping ads: only vod and event playlists are supported, got a live playlist")
+		}
+		ads = adBreakSegments(m.Segments, dateRanges)
+	}
+
+	clock, hasPDT := segmentClock(m.Segments)
+
+	for i, segment := range m.Segments {
 		if segment == nil {
 			continue
 		}
 
-		if filters.SuppressAds() && segment.SCTE != nil {
-			segment.SCTE = nil
+		if cues != nil {
+			if err := cues.translate(i, segment); err != nil {
+				return "", fmt.Errorf("translating ad cues: %w", err)
+			}
 		}
 
-		if segment.ProgramDateTime == (time.Time{}) && append {
-			if err := appendSegment(h.originURL, segment, filteredPlaylist); err != nil {
-				return "", fmt.Errorf("trimming segments: %w", err)
+		if i < window {
+			// removed discontinuities are counted by the discontinuity sequence,
+			// while the key and map still apply to the first segment in the window
+			if segment.Discontinuity {
+				filteredPlaylist.DiscontinuitySeq++
+			}
+			if segment.Key != nil {
+				key = segment.Key
+			}
+			if segment.Map != nil {
+				segmentMap = segment.Map
 			}
 			continue
 		}
 
-		// timestamp in milliseconds
-		segmentTimestamp := int(segment.ProgramDateTime.UnixNano() / 1000000)
-		if append = inRange(startFilter, endFilter, segmentTimestamp); !append {
-			// check for a segment whos start isnt in the range, but the end is in the range
-			currentSegmentEnd := segmentTimestamp + (int(segment.Duration) * 1000) //milliseconds
-			append = inRange(startFilter, endFilter, currentSegmentEnd) && currentSegmentEnd != startFilter
+		if i == window {
+			if segment.Key == nil {
+				segment.Key = key
+			}
+			if segment.Map == nil {
+				segment.Map = segmentMap
+			}
 		}
 
-		if append {
-			if err := appendSegment(h.originURL, segment, filteredPlaylist); err != nil {
-				return "", fmt.Errorf("trimming segments: %w", err)
+		if ads != nil && ads[i] {
+			// only the key and map changed within the break are carried
+			// over to the segment following it
+			if !spliced {
+				key, segmentMap = nil, nil
+				spliced = true
+			}
+			if segment.Key != nil {
+				key = segment.Key
+			}
+			if segment.Map != nil {
+				segmentMap = segment.Map
 			}
+			continue
 		}
 
-		if maxSize < segment.Duration && append {
+		if spliced {
+			spliced = false
+			segment.Discontinuity = true
+			if segment.Key == nil {
+				segment.Key = key
+			}
+			if segment.Map == nil {
+				segment.Map = segmentMap
+			}
+		}
+
+		if (filters.SuppressAds() || filters.AdSkip) && segment.SCTE != nil {
+			segment.SCTE = nil
+		}
+
+		segmentStart := mediaTime
+		mediaTime += int(math.Round(segment.Duration * 1000))
+
+		if filters.MediaTime != nil && !overlapsRange(filters.MediaTime, segmentStart, mediaTime) {
+			continue
+		}
+
+		if s := filters.Sequence; s != nil && !inRange(s.Start, s.End, int(m.SeqNo)+i) {
+			continue
+		}
+
+		if filters.Trim != nil {
+			// segments without PDT follow the segment before them
+			if segment.ProgramDateTime != (time.Time{}) {
+				// timestamp in milliseconds
+				segmentTimestamp := int(segment.ProgramDateTime.UnixNano() / 1000000)
+				appending = overlapsRange(filters.Trim, segmentTimestamp, segmentTimestamp+int(math.Round(segment.Duration*1000)))
+			}
+
+			if !appending {
+				continue
+			}
+		}
+
+		// date ranges are written by the cue translator when it's set, ad
+		// breaks being removed along with their date ranges otherwise. They're
+		// placed on the timeline by the program date time of the segment.
+		if cues == nil && len(dateRanges[i]) > 0 {
+			for _, line := range dateRanges[i] {
+				if !isSCTEDateRange(line) || !filters.SuppressAds() && !filters.AdSkip {
+					appendSegmentTags(segment, line)
+				}
+			}
+
+			if hasPDT && segment.ProgramDateTime.IsZero() {
+				segment.ProgramDateTime = clock[i]
+			}
+		}
+		appendSegmentTags(segment, unknown.segments[i]...)
+
+		if err := appendSegment(h.originURL, cdn, segment, filteredPlaylist); err != nil {
+			return "", fmt.Errorf("trimming segments: %w", err)
+		}
+		sequences = append(sequences, m.SeqNo+uint64(i))
+
+		if segment.Key != nil {
+			lines, err := rewriteURIAttributes(keys[segment.Key], func(uri string) (string, error) {
+				return segmentURL(uri, *absolute, cdn)
+			})
+			if err != nil {
+				return "", fmt.Errorf("formatting key URLs: %w", err)
+			}
+			appendSegmentTags(segment, lines...)
+		}
+
+		if maxSize < segment.Duration {
 			maxSize = segment.Duration
 		}
 	}
 
 	h.maxSegmentSize = maxSize
-	filteredPlaylist.Close()
+	closed := filters.Trimmed() || m.Closed
+	if filters.PlaylistType != "" {
+		closed = setPlaylistType(filteredPlaylist, filters.PlaylistType, maxSize)
+	}
+
+	if closed {
+		filteredPlaylist.Close()
+	}
 
-	return isEmpty(filteredPlaylist.Encode().String())
+	if filters.Trimmed() && filters.Trim == nil && filteredPlaylist.Count() == 0 {
+		return "", fmt.Errorf("No segments found in range")
+	}
+
+	var trailer string
+	if lowLatency != nil && !closed {
+		trailer, err = h.setLowLatencyTags(filters, filteredPlaylist, lowLatency, sequences, cdn)
+		if err != nil {
+			return "", fmt.Errorf("filtering low latency tags: %w", err)
+		}
+	}
+
+	playlist, err := isEmpty(filteredPlaylist.Encode().String())
+	return playlist + trailer, err
+}
+
+// setPlaylistType converts the playlist to the given type, fitting its target
+// duration to the longest segment. It returns true if the playlist is to be closed.
+func setPlaylistType(p *m3u8.MediaPlaylist, playlistType string, maxSize float64) bool {
+	switch playlistType {
+	case parsers.PlaylistTypeVOD:
+		p.MediaType = m3u8.VOD
+	case parsers.PlaylistTypeEvent:
+		p.MediaType = m3u8.EVENT
+	case parsers.PlaylistTypeLive:
+		p.MediaType = 0
+	default:
+		return false
+	}
+
+	if maxSize > 0 {
+		p.TargetDuration = math.Ceil(maxSize)
+	}
+
+	return playlistType == parsers.PlaylistTypeVOD
+}
+
+// slidingWindow returns true if segments leave the filtered playlist as it's
+// reloaded, which is the case of live playlists and of event playlists kept
+// within a dvr window, unless the filtered playlist is closed
+func slidingWindow(filters *parsers.MediaFilters, m *m3u8.MediaPlaylist) bool {
+	if filters.Trimmed() || filters.PlaylistType == parsers.PlaylistTypeVOD {
+		return false
+	}
+
+	if m.Closed || m.MediaType == m3u8.VOD {
+		return false
+	}
+
+	return m.MediaType != m3u8.EVENT || filters.DVR > 0
+}
+
+// dvrWindow returns the index of the first segment within the last given seconds
+// of the playlist. The last segment is always kept.
+func dvrWindow(segments []*m3u8.MediaSegment, seconds float64) int {
+	limit := int(math.Round(seconds * 1000))
+	first := len(segments)
+
+	var duration int // milliseconds from the end of the playlist
+	for i := len(segments) - 1; i >= 0; i-- {
+		if segments[i] == nil {
+			continue
+		}
+
+		duration += int(math.Round(segments[i].Duration * 1000))
+		if duration > limit && first < len(segments) {
+			break
+		}
+
+		first = i
+	}
+
+	return first
+}
+
+// overlapsRange returns true if a segment starting and ending at the given times,
+// in milliseconds, has any content in the trim range
+func overlapsRange(t *parsers.Trim, segmentStart, segmentEnd int) bool {
+	start := int(math.Round(t.Start * 1000))
+	end := int(math.Round(t.End * 1000))
+
+	// check for a segment whos start isnt in the range, but the end is in the range
+	return inRange(start, end, segmentStart) ||
+		(inRange(start, end, segmentEnd) && segmentEnd != start)
 }
 
 func isEmpty(p string) (string, error) {
@@ -499,18 +1156,167 @@ func isEmpty(p string) (string, error) {
 
 }
 
-//appends segment to provided media playlist with absolute urls
-func appendSegment(manifest string, s *m3u8.MediaSegment, p *m3u8.MediaPlaylist) error {
+// passThroughRenditionManifest returns the media playlist as served by the
+// origin when none of its filters are set, only making its urls absolute and
+// pointing them to the cdn if set
+func (h *HLSFilter) passThroughRenditionManifest(filters *parsers.MediaFilters, m *m3u8.MediaPlaylist) (string, error) {
+	cdn, err := cdnHost(h.config, filters)
+	if err != nil {
+		return "", err
+	}
+
+	absolute, err := getAbsoluteURL(h.originURL)
+	if err != nil {
+		return "", fmt.Errorf("formatting segment URLs: %w", err)
+	}
+
+	for _, segment := range m.Segments {
+		if segment != nil && h.maxSegmentSize < segment.Duration {
+			h.maxSegmentSize = segment.Duration
+		}
+	}
+
+	rewrite := func(uri string) (string, error) {
+		return segmentURL(uri, *absolute, cdn)
+	}
+
+	lines := strings.Split(h.originContent, "\n")
+	for i, line := range lines {
+		tag := strings.TrimSpace(line)
+		switch {
+		case tag == "":
+		case !strings.HasPrefix(tag, "#"):
+			lines[i], err = rewrite(tag)
+		case strings.Contains(tag, `URI="`):
+			var rewritten []string
+			rewritten, err = rewriteURIAttributes([]string{tag}, rewrite)
+			if err == nil {
+				lines[i] = rewritten[0]
+			}
+		}
+
+		if err != nil {
+			return "", fmt.Errorf("formatting URLs: %w", err)
+		}
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// decodedTags are the prefixes of the media playlist tags read by the playlist
+// decoder, or set aside before decoding and written back once filtered
+var decodedTags = []string{
+	"#EXTM3U", "#EXTINF:", "#EXT-X-VERSION:", "#EXT-X-TARGETDURATION:", tagMediaSequence,
+	"#EXT-X-PLAYLIST-TYPE:", "#EXT-X-DISCONTINUITY", "#EXT-X-ENDLIST", "#EXT-X-I-FRAMES-ONLY",
+	"#EXT-X-PROGRAM-DATE-TIME:", "#EXT-X-BYTERANGE:", tagKey, "#EXT-X-MAP:", tagDateRange,
+	"#EXT-SCTE35:", "#EXT-OATCLS-SCTE35:", "#EXT-X-CUE-OUT", "#EXT-X-CUE-IN", "#EXT-X-ASSET:",
+	"#EXT-X-ALLOW-CACHE:", "#WV-",
+}
+
+// headerTags are the prefixes of the media playlist tags dropped by the
+// playlist decoder that apply to the whole playlist
+var headerTags = []string{"#EXT-X-INDEPENDENT-SEGMENTS", "#EXT-X-START:", "#EXT-X-DEFINE:"}
+
+// playlistTags holds the tags of a media playlist the playlist decoder drops,
+// such as EXT-X-GAP or EXT-X-BITRATE, which are written back as they are
+type playlistTags struct {
+	header []string
+	// tags keyed by the index of the segment they precede
+	segments map[int][]string
+}
+
+// unknownTags returns the tags of a media playlist the playlist decoder drops.
+// Tags following the last segment are kept with it.
+func unknownTags(manifest string) *playlistTags {
+	tags := &playlistTags{segments: make(map[int][]string)}
+	var segments int
+	for _, line := range strings.Split(manifest, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+		case !strings.HasPrefix(line, "#"):
+			segments++
+		case !strings.HasPrefix(line, "#EXT") || hasAnyPrefix(line, decodedTags):
+		case hasAnyPrefix(line, headerTags):
+			tags.header = append(tags.header, line)
+		default:
+			tags.segments[segments] = append(tags.segments[segments], line)
+		}
+	}
+
+	if trailing, found := tags.segments[segments]; found && segments > 0 {
+		delete(tags.segments, segments)
+		tags.segments[segments-1] = append(tags.segments[segments-1], trailing...)
+	}
+
+	return tags
+}
+
+// hasAnyPrefix returns true if the line starts with any of the prefixes
+func hasAnyPrefix(line string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// removeTags drops the lines of the given hls tags, named without the
+// leading #, from the manifest
+func removeTags(manifest string, tags []string) string {
+	if len(tags) == 0 {
+		return manifest
+	}
+
+	removed := make(map[string]struct{}, len(tags))
+	for _, tag := range tags {
+		removed["#"+tag] = struct{}{}
+	}
+
+	lines := strings.Split(manifest, "\n")
+	filtered := lines[:0]
+	for _, line := range lines {
+		name := strings.TrimSpace(line)
+		if i := strings.Index(name, ":"); i != -1 {
+			name = name[:i]
+		}
+
+		if _, found := removed[name]; found {
+			continue
+		}
+
+		filtered = append(filtered, line)
+	}
+
+	return strings.Join(filtered, "\n")
+}
+
+//appends segment to provided media playlist with absolute urls, pointing to the cdn if set
+func appendSegment(manifest string, cdn *url.URL, s *m3u8.MediaSegment, p *m3u8.MediaPlaylist) error {
 	absolute, err := getAbsoluteURL(manifest)
 	if err != nil {
 		return fmt.Errorf("formatting segment URLs: %w", err)
 	}
 
-	s.URI, err = combinedIfRelative(s.URI, *absolute)
+	s.URI, err = segmentURL(s.URI, *absolute, cdn)
 	if err != nil {
 		return fmt.Errorf("formatting segment URLs: %w", err)
 	}
 
+	if s.Key != nil {
+		if s.Key.URI, err = segmentURL(s.Key.URI, *absolute, cdn); err != nil {
+			return fmt.Errorf("formatting key URLs: %w", err)
+		}
+	}
+
+	if s.Map != nil {
+		if s.Map.URI, err = segmentURL(s.Map.URI, *absolute, cdn); err != nil {
+			return fmt.Errorf("formatting map URLs: %w", err)
+		}
+	}
+
 	err = p.AppendSegment(s)
 	if err != nil {
 		return fmt.Errorf("trimming segments: %w", err)
@@ -519,78 +1325,139 @@ func appendSegment(manifest string, s *m3u8.MediaSegment, p *m3u8.MediaPlaylist)
 	return nil
 }
 
+// segmentTags is the name of the custom tag holding the tags of a segment
+// the playlist decoder drops
+const segmentTags = "segment"
+
+// tagLines writes tags the playlist decoder drops through the playlist
+// encoder, which keys custom tags by name, so all lines of a kind are
+// written by a single tag
+type tagLines struct {
+	name  string
+	lines []string
+}
+
+// TagName returns the name of the tags
+func (t *tagLines) TagName() string {
+	return t.name
+}
+
+// Encode returns the tag lines
+func (t *tagLines) Encode() *bytes.Buffer {
+	return bytes.NewBufferString(t.String())
+}
+
+// String returns the tag lines joined by new lines
+func (t *tagLines) String() string {
+	return strings.Join(t.lines, "\n")
+}
+
+// playlistHeaderTags is the name of the custom tag holding the tags of the
+// playlist header the playlist decoder drops
+const playlistHeaderTags = "header"
+
+// appendPlaylistTags adds tag lines to be written in the playlist header,
+// after the ones already added
+func appendPlaylistTags(p *m3u8.MediaPlaylist, lines ...string) {
+	if len(lines) == 0 {
+		return
+	}
+
+	if t, found := p.Custom[playlistHeaderTags].(*tagLines); found {
+		t.lines = append(t.lines, lines...)
+		return
+	}
+
+	p.SetCustomTag(&tagLines{name: playlistHeaderTags, lines: lines})
+}
+
+// appendSegmentTags adds tag lines to be written before the segment, after
+// the ones already added, as custom tags are written in no particular order
+func appendSegmentTags(s *m3u8.MediaSegment, lines ...string) {
+	if len(lines) == 0 {
+		return
+	}
+
+	if t, found := s.Custom[segmentTags].(*tagLines); found {
+		t.lines = append(t.lines, lines...)
+		return
+	}
+
+	if s.Custom == nil {
+		s.Custom = make(map[string]m3u8.CustomTag)
+	}
+	s.Custom[segmentTags] = &tagLines{name: segmentTags, lines: lines}
+}
+
+// segmentURL returns the absolute url of a segment, key or map, pointing to the cdn if set
+func segmentURL(uri string, absolute url.URL, cdn *url.URL) (string, error) {
+	combined, err := combinedIfRelative(uri, absolute)
+	if err != nil {
+		return uri, err
+	}
+
+	return rewriteHost(combined, cdn)
+}
+
 //Returns absolute url of given manifest as a string
 func getAbsoluteURL(path string) (*url.URL, error) {
 	absoluteURL, _ := filepath.Split(path)
 	return url.Parse(absoluteURL)
 }
 
-// Replaces the variant's subtitle alternative uris if they have not been trimmed already.
-// Returns a list of group ids that have already been trimmed (so they only get trimmed once)
-func (h *HLSFilter) normalizeTrimmedVariantAlternatives(filters *parsers.MediaFilters, v *m3u8.Variant, trimmedAlternatives map[string]struct{}) (map[string]struct{}, error) {
+// Replaces the uris of the variant's alternatives if they have not been trimmed already.
+// Alternatives are shared by the variants of their group, the ones already trimmed are
+// returned so they only get trimmed once. Closed captions have no uri to replace
+func (h *HLSFilter) normalizeTrimmedVariantAlternatives(filters *parsers.MediaFilters, v *m3u8.Variant, trimmedAlternatives map[*m3u8.Alternative]struct{}) (map[*m3u8.Alternative]struct{}, error) {
 	for _, alt := range v.Alternatives {
-		if _, found := trimmedAlternatives[alt.GroupId]; found {
+		if _, found := trimmedAlternatives[alt]; found {
 			continue
 		}
-		if alt.Type == "SUBTITLES" {
+		if alt.URI != "" && alt.Type != "CLOSED-CAPTIONS" {
 			auri, err := h.normalizeTrimmedVariant(filters, alt.URI)
 			if err != nil {
 				return trimmedAlternatives, err
 			}
 			alt.URI = auri
-			trimmedAlternatives[alt.GroupId] = struct{}{}
+			trimmedAlternatives[alt] = struct{}{}
 		}
 	}
 	return trimmedAlternatives, nil
 }
 
-func isPrimaryPipeline(index int) bool {
-	return index%2 == 0
-}
-
-func isValidPipeline(pipeline pipelineType, index int) bool {
-	if pipeline == primaryPipeline && !isPrimaryPipeline(index) {
-		return false
-	}
-
-	if pipeline == backupPipeline && isPrimaryPipeline(index) {
-		return false
-	}
-
-	return true
-}
-
 //Health check variant of redundant manifest
-func healthCheckVariant(ctx context.Context, variantURL string, client config.Client) (bool, error) {
+func healthCheckVariant(ctx context.Context, variantURL string, client config.Client) (variantHealth, error) {
 	o, err := origin.NewDefaultOrigin("", variantURL)
 	if err != nil {
-		return false, fmt.Errorf("health checking variant: %w", err)
+		return variantHealth{}, fmt.Errorf("health checking variant: %w", err)
 	}
 
 	manifestInfo, err := o.FetchOriginContent(ctx, client)
 	if err != nil {
-		return false, fmt.Errorf("health checking variant: %w", err)
+		return variantHealth{}, fmt.Errorf("health checking variant: %w", err)
 	}
 
 	if sc := manifestInfo.Status; sc/100 > 3 {
 		if sc == 404 {
-			return false, nil
+			return variantHealth{}, nil
 		}
 
-		return false, fmt.Errorf("checking variant: returning http status of %v", sc)
+		return variantHealth{}, fmt.Errorf("checking variant: returning http status of %v", sc)
 	}
 
 	if manifestInfo.LastModified.IsZero() {
-		return false, nil
+		return variantHealth{}, nil
 	}
 
 	return evaluateStaleness(manifestInfo.Payload, manifestInfo.LastModified)
 }
 
-func evaluateStaleness(variant string, lastModified time.Time) (bool, error) {
+// evaluateStaleness returns the variant as healthy when it was modified
+// within twice its target duration
+func evaluateStaleness(variant string, lastModified time.Time) (variantHealth, error) {
 	v, _, err := m3u8.DecodeFrom(strings.NewReader(variant), true)
 	if err != nil {
-		return false, err
+		return variantHealth{}, err
 	}
 
 	playlist := v.(*m3u8.MediaPlaylist)
@@ -598,5 +1465,11 @@ func evaluateStaleness(variant string, lastModified time.Time) (bool, error) {
 	segDurationX2 := time.Second * time.Duration(playlist.TargetDuration*2)
 	diff := time.Now().Sub(lastModified)
 
-	return segDurationX2 > diff, nil
+	return variantHealth{
+		healthy:        segDurationX2 > diff,
+		lastModified:   lastModified,
+		age:            diff,
+		staleAfter:     segDurationX2,
+		targetDuration: time.Second * time.Duration(playlist.TargetDuration),
+	}, nil
 }
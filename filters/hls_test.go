@@ -3,9 +3,12 @@ package filters
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"io/ioutil"
 	"math"
 	"net/http"
+	"net/url"
+	"path"
 	"testing"
 	"time"
 
@@ -1508,6 +1511,20 @@ https://bakery.cbsi.video/t(10000,100000)/tags(ads)/aHR0cHM6Ly9leGlzdGluZy5iYXNl
 https://bakery.cbsi.video/t(10000,100000)/tags(ads)/aHR0cHM6Ly9leGlzdGluZy5iYXNlL3BhdGgvbGlua181Lm0zdTg.m3u8
 #EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4500,AVERAGE-BANDWIDTH=4500,CODECS="avc1.64001f,mp4a.40.2"
 https://bakery.cbsi.video/t(10000,100000)/tags(ads)/aHR0cHM6Ly9leGlzdGluZy5iYXNlL3BhdGgvbGlua182Lm0zdTg.m3u8
+`
+
+	manifestWithAdsAndBase64EncodedVariantURLS := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000,AVERAGE-BANDWIDTH=1000,CODECS="avc1.64001f,mp4a.40.2"
+https://bakery.cbsi.video/tags(ads)/aHR0cHM6Ly9leGlzdGluZy5iYXNlL3BhdGgvbGlua18xLm0zdTg.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4200,AVERAGE-BANDWIDTH=4200,CODECS="avc1.64001f,mp4a.40.2"
+https://bakery.cbsi.video/tags(ads)/aHR0cHM6Ly9leGlzdGluZy5iYXNlL3BhdGgvbGlua18yLm0zdTg.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4000,AVERAGE-BANDWIDTH=4000,CODECS="avc1.64001f,mp4a.40.2"
+https://bakery.cbsi.video/tags(ads)/aHR0cHM6Ly9leGlzdGluZy5iYXNlL3BhdGgvbGlua180Lm0zdTg.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4100,AVERAGE-BANDWIDTH=4100,CODECS="avc1.64001f,mp4a.40.2"
+https://bakery.cbsi.video/tags(ads)/aHR0cHM6Ly9leGlzdGluZy5iYXNlL3BhdGgvbGlua181Lm0zdTg.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4500,AVERAGE-BANDWIDTH=4500,CODECS="avc1.64001f,mp4a.40.2"
+https://bakery.cbsi.video/tags(ads)/aHR0cHM6Ly9leGlzdGluZy5iYXNlL3BhdGgvbGlua182Lm0zdTg.m3u8
 `
 
 	manifestWithBase64EncodedVariantURLSAndLocalHost := `#EXTM3U
@@ -1574,6 +1591,30 @@ https://bakery.cbsi.video/t(10000,100000)/aHR0cHM6Ly9jYnNzNjRlYi1jYnNzNjRlYi1tcy
 https://bakery.cbsi.video/t(10000,100000)/aHR0cHM6Ly9jYnNzNjRlYi1jYnNzNjRlYi1tcy1kZXYuZ2xvYmFsLnNzbC5mYXN0bHkubmV0L2Nic3NjMGE3L21hc3Rlci9jYnNzYzBhN182Lm0zdTg.m3u8
 #EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=5859480,AVERAGE-BANDWIDTH=5640800,CODECS="avc1.640028,mp4a.40.2",RESOLUTION=1920x1080,SUBTITLES="subs",FRAME-RATE=29.970
 https://bakery.cbsi.video/t(10000,100000)/aHR0cHM6Ly9jYnNzNjRlYi1jYnNzNjRlYi1tcy1kZXYuZ2xvYmFsLnNzbC5mYXN0bHkubmV0L2Nic3NjMGE3L21hc3Rlci9jYnNzYzBhN183Lm0zdTg.m3u8
+`
+
+	masterManifestWithAlternatives := `#EXTM3U
+#EXT-X-VERSION:4
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aac",NAME="English",DEFAULT=YES,AUTOSELECT=YES,LANGUAGE="en",URI="audio/en.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aac",NAME="Spanish",DEFAULT=NO,AUTOSELECT=YES,LANGUAGE="es",URI="audio/es.m3u8"
+#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",NAME="English",DEFAULT=YES,AUTOSELECT=YES,LANGUAGE="en",URI="subs/en.m3u8"
+#EXT-X-MEDIA:TYPE=CLOSED-CAPTIONS,GROUP-ID="cc",NAME="English",DEFAULT=YES,AUTOSELECT=YES,LANGUAGE="en",INSTREAM-ID="CC1"
+#EXT-X-STREAM-INF:BANDWIDTH=1000,AVERAGE-BANDWIDTH=1000,CODECS="avc1.64001f,mp4a.40.2",AUDIO="aac",SUBTITLES="subs",CLOSED-CAPTIONS="cc"
+link_1.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=4000,AVERAGE-BANDWIDTH=4000,CODECS="avc1.64001f,mp4a.40.2",AUDIO="aac",SUBTITLES="subs",CLOSED-CAPTIONS="cc"
+link_2.m3u8
+`
+
+	masterManifestFilteredWithAlternativesAndBase64EncodedURIs := `#EXTM3U
+#EXT-X-VERSION:4
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aac",NAME="English",DEFAULT=YES,AUTOSELECT=YES,LANGUAGE="en",URI="https://bakery.cbsi.video/t(10000,100000)/aHR0cHM6Ly9leGlzdGluZy5iYXNlL3BhdGgvYXVkaW8vZW4ubTN1OA.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aac",NAME="Spanish",DEFAULT=NO,AUTOSELECT=YES,LANGUAGE="es",URI="https://bakery.cbsi.video/t(10000,100000)/aHR0cHM6Ly9leGlzdGluZy5iYXNlL3BhdGgvYXVkaW8vZXMubTN1OA.m3u8"
+#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",NAME="English",DEFAULT=YES,AUTOSELECT=YES,LANGUAGE="en",URI="https://bakery.cbsi.video/t(10000,100000)/aHR0cHM6Ly9leGlzdGluZy5iYXNlL3BhdGgvc3Vicy9lbi5tM3U4.m3u8"
+#EXT-X-MEDIA:TYPE=CLOSED-CAPTIONS,GROUP-ID="cc",NAME="English",DEFAULT=YES,AUTOSELECT=YES,LANGUAGE="en",INSTREAM-ID="CC1"
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000,AVERAGE-BANDWIDTH=1000,CODECS="avc1.64001f,mp4a.40.2",AUDIO="aac",CLOSED-CAPTIONS="cc",SUBTITLES="subs"
+https://bakery.cbsi.video/t(10000,100000)/aHR0cHM6Ly9leGlzdGluZy5iYXNlL3BhdGgvbGlua18xLm0zdTg.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4000,AVERAGE-BANDWIDTH=4000,CODECS="avc1.64001f,mp4a.40.2",AUDIO="aac",CLOSED-CAPTIONS="cc",SUBTITLES="subs"
+https://bakery.cbsi.video/t(10000,100000)/aHR0cHM6Ly9leGlzdGluZy5iYXNlL3BhdGgvbGlua18yLm0zdTg.m3u8
 `
 
 	trim := &parsers.Trim{
@@ -1623,16 +1664,15 @@ https://bakery.cbsi.video/t(10000,100000)/aHR0cHM6Ly9jYnNzNjRlYi1jYnNzNjRlYi1tcy
 			config:                config.Config{Hostname: "bakery.cbsi.video"},
 		},
 		{
-			name: "when trim and ads filter is given and master has absolute urls, variant level manifest will point to " +
-				"bakery with trim and ads filter and base64 encoding string in the manifest",
+			name: "when only the ads filter is given, variant level manifest will point to " +
+				"bakery with the ads filter and base64 encoding string in the manifest",
 			filters: &parsers.MediaFilters{
-				Trim: trim,
 				Tags: &parsers.Tags{
 					Ads: true,
 				},
 			},
 			manifestContent:       masterManifestWithAbsoluteURLs,
-			expectManifestContent: manifestWithFilteredAdsAndBase64EncodedVariantURLS,
+			expectManifestContent: manifestWithAdsAndBase64EncodedVariantURLS,
 			config:                config.Config{Hostname: "bakery.cbsi.video"},
 		},
 		{
@@ -1696,6 +1736,16 @@ https://bakery.cbsi.video/t(10000,100000)/aHR0cHM6Ly9jYnNzNjRlYi1jYnNzNjRlYi1tcy
 			expectManifestContent: masterManifestFilteredWithMediaTagAndBase64EncodedMediaURI,
 			config:                config.Config{Hostname: "bakery.cbsi.video"},
 		},
+		{
+			name: "when audio, subtitles and closed captions alternatives are present, audio and subtitles " +
+				"urls point to bakery with the trim filter and closed captions are kept",
+			filters: &parsers.MediaFilters{
+				Trim: trim,
+			},
+			manifestContent:       masterManifestWithAlternatives,
+			expectManifestContent: masterManifestFilteredWithAlternativesAndBase64EncodedURIs,
+			config:                config.Config{Hostname: "bakery.cbsi.video"},
+		},
 	}
 
 	for _, tt := range tests {
@@ -1924,6 +1974,53 @@ https://existing.base/path/chan_1/chan_1_20200311T202813_1_00024.ts
 #EXTINF:6.000,
 https://existing.base/path/chan_1/chan_1_20200311T202818_1_00025.ts
 #EXT-X-ENDLIST
+`
+
+	variantManifestWithFractionalDurations := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-MEDIA-SEQUENCE:10
+#EXT-X-TARGETDURATION:6
+#EXT-X-PROGRAM-DATE-TIME:2020-03-11T00:51:54Z
+#EXTINF:6.006,
+https://existing.base/path/chan_1/chan_1_20200311T202748_1_00020.ts
+#EXT-X-PROGRAM-DATE-TIME:2020-03-11T00:52:00.006Z
+#EXTINF:0.500,
+https://existing.base/path/chan_1/chan_1_20200311T202754_1_00021.ts
+#EXT-X-PROGRAM-DATE-TIME:2020-03-11T00:52:00.506Z
+#EXTINF:6.006,
+https://existing.base/path/chan_1/chan_1_20200311T202801_1_00022.ts
+#EXT-X-PROGRAM-DATE-TIME:2020-03-11T00:52:06.512Z
+#EXTINF:6.006,
+https://existing.base/path/chan_1/chan_1_20200311T202806_1_00023.ts
+`
+
+	variantManifestTrimmedWithFractionalDurations := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-TARGETDURATION:7
+#EXT-X-PROGRAM-DATE-TIME:2020-03-11T00:51:54Z
+#EXTINF:6.006,
+https://existing.base/path/chan_1/chan_1_20200311T202748_1_00020.ts
+#EXT-X-PROGRAM-DATE-TIME:2020-03-11T00:52:00.006Z
+#EXTINF:0.500,
+https://existing.base/path/chan_1/chan_1_20200311T202754_1_00021.ts
+#EXT-X-PROGRAM-DATE-TIME:2020-03-11T00:52:00.506Z
+#EXTINF:6.006,
+https://existing.base/path/chan_1/chan_1_20200311T202801_1_00022.ts
+#EXT-X-ENDLIST
+`
+
+	variantManifestTrimmedWithSubSecondSegment := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-TARGETDURATION:7
+#EXT-X-PROGRAM-DATE-TIME:2020-03-11T00:52:00.006Z
+#EXTINF:0.500,
+https://existing.base/path/chan_1/chan_1_20200311T202754_1_00021.ts
+#EXT-X-PROGRAM-DATE-TIME:2020-03-11T00:52:00.506Z
+#EXTINF:6.006,
+https://existing.base/path/chan_1/chan_1_20200311T202801_1_00022.ts
+#EXT-X-ENDLIST
 `
 
 	emptyVariantManifest := `#EXTM3U
@@ -1943,6 +2040,16 @@ https://existing.base/path/chan_1/chan_1_20200311T202818_1_00025.ts
 		End:   1583887944, //2020-03-11T00:52:24
 	}
 
+	fractionalTrim := &parsers.Trim{
+		Start: 1583887920.003, //2020-03-11T00:52:00.003
+		End:   1583887926,     //2020-03-11T00:52:06
+	}
+
+	subSecondTrim := &parsers.Trim{
+		Start: 1583887920.1, //2020-03-11T00:52:00.100
+		End:   1583887920.9, //2020-03-11T00:52:00.900
+	}
+
 	tests := []struct {
 		name                  string
 		filters               *parsers.MediaFilters
@@ -1981,6 +2088,20 @@ https://existing.base/path/chan_1/chan_1_20200311T202818_1_00025.ts
 			expectManifestContent: variantManifestTrimmed,
 			expectAge:             "3",
 		},
+		{
+			name:                  "when segments have fractional durations, expect them to end with their exact duration at the trim start",
+			filters:               &parsers.MediaFilters{Trim: fractionalTrim},
+			manifestContent:       variantManifestWithFractionalDurations,
+			expectManifestContent: variantManifestTrimmedWithFractionalDurations,
+			expectAge:             "3",
+		},
+		{
+			name:                  "when a sub-second segment ends in the trim range, expect it to be kept",
+			filters:               &parsers.MediaFilters{Trim: subSecondTrim},
+			manifestContent:       variantManifestWithFractionalDurations,
+			expectManifestContent: variantManifestTrimmedWithSubSecondSegment,
+			expectAge:             "3",
+		},
 		{
 			name:                  "when no pdt present for segment, empty manifest is returned",
 			filters:               &parsers.MediaFilters{Trim: trim},
@@ -2019,6 +2140,133 @@ https://existing.base/path/chan_1/chan_1_20200311T202818_1_00025.ts
 	}
 }
 
+func TestHLSFilter_FilterContent_MediaTimeFilter_VariantManifest(t *testing.T) {
+	variantManifestWithNoPDT := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-PLAYLIST-TYPE:VOD
+#EXT-X-MEDIA-SEQUENCE:10
+#EXT-X-TARGETDURATION:6
+#EXTINF:6.000,
+segment_10.ts
+#EXTINF:6.000,
+segment_11.ts
+#EXTINF:6.000,
+segment_12.ts
+#EXTINF:6.000,
+segment_13.ts
+#EXTINF:4.500,
+segment_14.ts
+#EXT-X-ENDLIST
+`
+
+	variantManifestFrom12To23Seconds := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-TARGETDURATION:6
+#EXTINF:6.000,
+https://existing.base/path/segment_12.ts
+#EXTINF:6.000,
+https://existing.base/path/segment_13.ts
+#EXT-X-ENDLIST
+`
+
+	variantManifestUnalignedMediaTime := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-TARGETDURATION:6
+#EXTINF:6.000,
+https://existing.base/path/segment_11.ts
+#EXTINF:6.000,
+https://existing.base/path/segment_12.ts
+#EXTINF:6.000,
+https://existing.base/path/segment_13.ts
+#EXTINF:4.500,
+https://existing.base/path/segment_14.ts
+#EXT-X-ENDLIST
+`
+
+	variantManifestSequence11To12 := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-TARGETDURATION:6
+#EXTINF:6.000,
+https://existing.base/path/segment_11.ts
+#EXTINF:6.000,
+https://existing.base/path/segment_12.ts
+#EXT-X-ENDLIST
+`
+
+	tests := []struct {
+		name                  string
+		filters               *parsers.MediaFilters
+		manifestContent       string
+		expectManifestContent string
+		expectErr             bool
+	}{
+		{
+			name:                  "when media time filter is aligned to segments, expect segments within the range",
+			filters:               &parsers.MediaFilters{MediaTime: &parsers.Trim{Start: 12, End: 23}},
+			manifestContent:       variantManifestWithNoPDT,
+			expectManifestContent: variantManifestFrom12To23Seconds,
+		},
+		{
+			name:                  "when media time filter is not aligned to segments, expect segments with any content in the range",
+			filters:               &parsers.MediaFilters{MediaTime: &parsers.Trim{Start: 11.5, End: math.MaxInt32}},
+			manifestContent:       variantManifestWithNoPDT,
+			expectManifestContent: variantManifestUnalignedMediaTime,
+		},
+		{
+			name:                  "when media sequence filter is set, expect segments within the sequence range",
+			filters:               &parsers.MediaFilters{Sequence: &parsers.Sequence{Start: 11, End: 12}},
+			manifestContent:       variantManifestWithNoPDT,
+			expectManifestContent: variantManifestSequence11To12,
+		},
+		{
+			name: "when media time and sequence filters are set, expect segments matching both",
+			filters: &parsers.MediaFilters{
+				MediaTime: &parsers.Trim{Start: 12, End: 30},
+				Sequence:  &parsers.Sequence{Start: 0, End: 12},
+			},
+			manifestContent: variantManifestWithNoPDT,
+			expectManifestContent: `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-TARGETDURATION:6
+#EXTINF:6.000,
+https://existing.base/path/segment_12.ts
+#EXT-X-ENDLIST
+`,
+		},
+		{
+			name:            "when no segment is in the media sequence range, expect error",
+			filters:         &parsers.MediaFilters{Sequence: &parsers.Sequence{Start: 20, End: 30}},
+			manifestContent: variantManifestWithNoPDT,
+			expectErr:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			filter := NewHLSFilter("https://existing.base/path/master.m3u8", tt.manifestContent, config.Config{Hostname: "bakery.cbsi.video"})
+			manifest, err := filter.FilterContent(context.Background(), tt.filters)
+
+			if err != nil && !tt.expectErr {
+				t.Errorf("FilterContent(context.Background(), ) didnt expect an error to be returned, got: %v", err)
+				return
+			} else if err == nil && tt.expectErr {
+				t.Error("FilterContent(context.Background(), ) expected an error, got nil")
+				return
+			}
+
+			if g, e := manifest, tt.expectManifestContent; g != e {
+				t.Errorf("FilterContent(context.Background(), ) wrong manifest returned)\ngot %v\nexpected: %v\ndiff: %v", g, e,
+					cmp.Diff(g, e))
+			}
+		})
+	}
+}
+
 func TestHLSFilter_FilterContent_TrimFilter_VariantManifest_AdSuppression(t *testing.T) {
 
 	variantManifestWithAds := `#EXTM3U
@@ -2390,92 +2638,291 @@ https://existing.base/path/chan_1/chan_1_20200311T202818_1_00029.ts
 	}
 }
 
-func TestHLSFilter_FilterContent_PreventHTTPError(t *testing.T) {
-	variantManifestContent := `#EXTM3U
+func TestHLSFilter_FilterContent_VariantManifest(t *testing.T) {
+
+	liveVariantManifest := `#EXTM3U
 #EXT-X-VERSION:3
-#EXT-X-MEDIA-SEQUENCE:17738
-#EXT-X-TARGETDURATION:8
-#EXT-X-PROGRAM-DATE-TIME:2021-02-23T05:59:55.633Z
+#EXT-X-MEDIA-SEQUENCE:10
+#EXT-X-TARGETDURATION:6
+#EXT-X-PROGRAM-DATE-TIME:2020-03-11T00:51:48Z
 #EXTINF:6.000,
-http://bakery.cbsi.video/phe(true)/aHR0cHM6Ly9kaWN0YTU0ODczYTIuYWlyc3BhY2UtY2RuLmNic2l2aWRlby5jb20va2VpdGhoOTdoLXRlc3QvZW4vY2FwdGlvbnNfMTc3MzgudnR0.vtt
-#EXT-X-PROGRAM-DATE-TIME:2021-02-23T06:00:01.633Z
+chan_1/chan_1_20200311T202743_1_00019.ts
+#EXT-OATCLS-SCTE35:/DAuAAAAAAAAAP/wBQb/Ldjb7wAYAhZDVUVJCiuBsH/DAADN/lIMAgEANAAADbYGAw==
+#EXT-X-ASSET:CAID=0x0100
+#EXT-X-CUE-OUT:12
 #EXTINF:6.000,
-http://bakery.cbsi.video/phe(true)/aHR0cHM6Ly9kaWN0YTU0ODczYTIuYWlyc3BhY2UtY2RuLmNic2l2aWRlby5jb20va2VpdGhoOTdoLXRlc3QvZW4vY2FwdGlvbnNfMTc3MzkudnR0.vtt
-#EXT-X-PROGRAM-DATE-TIME:2021-02-23T06:00:07.633Z
+chan_1/chan_1_20200311T202748_1_00020.ts
+#EXT-X-CUE-OUT-CONT:CAID=0x0100,ElapsedTime=6.00,Duration=12,SCTE35=/DAuAAAAAAAAAP/wBQb/Ldjb7wAYAhZDVUVJCiuBsH/DAADN/lIMAgEANAAADbYGAw==
 #EXTINF:6.000,
-http://bakery.cbsi.video/phe(true)/aHR0cHM6Ly9kaWN0YTU0ODczYTIuYWlyc3BhY2UtY2RuLmNic2l2aWRlby5jb20va2VpdGhoOTdoLXRlc3QvZW4vY2FwdGlvbnNfMTc3NDAudnR0.vtt
-#EXT-X-PROGRAM-DATE-TIME:2021-02-23T06:00:13.633Z
+https://other.base/path/chan_1_20200311T202801_1_00021.ts
+#EXT-X-CUE-IN
+#EXTINF:4.000,
+chan_1/chan_1_20200311T202806_1_00022.ts
+`
+
+	liveVariantManifestWithAbsoluteURLs := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-MEDIA-SEQUENCE:10
+#EXT-X-TARGETDURATION:6
+#EXT-X-PROGRAM-DATE-TIME:2020-03-11T00:51:48Z
 #EXTINF:6.000,
-http://bakery.cbsi.video/phe(true)/aHR0cHM6Ly9kaWN0YTU0ODczYTIuYWlyc3BhY2UtY2RuLmNic2l2aWRlby5jb20va2VpdGhoOTdoLXRlc3QvZW4vY2FwdGlvbnNfMTc3NDEudnR0.vtt
-#EXT-X-PROGRAM-DATE-TIME:2021-02-23T06:00:19.633Z
+https://existing.base/path/chan_1/chan_1_20200311T202743_1_00019.ts
+#EXT-OATCLS-SCTE35:/DAuAAAAAAAAAP/wBQb/Ldjb7wAYAhZDVUVJCiuBsH/DAADN/lIMAgEANAAADbYGAw==
+#EXT-X-ASSET:CAID=0x0100
+#EXT-X-CUE-OUT:12
 #EXTINF:6.000,
-http://bakery.cbsi.video/phe(true)/aHR0cHM6Ly9kaWN0YTU0ODczYTIuYWlyc3BhY2UtY2RuLmNic2l2aWRlby5jb20va2VpdGhoOTdoLXRlc3QvZW4vY2FwdGlvbnNfMTc3NDIudnR0.vtt
-#EXT-X-PROGRAM-DATE-TIME:2021-02-23T06:00:25.633Z
+https://existing.base/path/chan_1/chan_1_20200311T202748_1_00020.ts
+#EXT-X-CUE-OUT-CONT:CAID=0x0100,ElapsedTime=6.00,Duration=12,SCTE35=/DAuAAAAAAAAAP/wBQb/Ldjb7wAYAhZDVUVJCiuBsH/DAADN/lIMAgEANAAADbYGAw==
 #EXTINF:6.000,
-http://bakery.cbsi.video/phe(true)/aHR0cHM6Ly9kaWN0YTU0ODczYTIuYWlyc3BhY2UtY2RuLmNic2l2aWRlby5jb20va2VpdGhoOTdoLXRlc3QvZW4vY2FwdGlvbnNfMTc3NDMudnR0.vtt
+https://other.base/path/chan_1_20200311T202801_1_00021.ts
+#EXT-X-CUE-IN
+#EXTINF:4.000,
+https://existing.base/path/chan_1/chan_1_20200311T202806_1_00022.ts
 `
 
-	expectedFilteredManifestWithTrimFilter := `#EXTM3U
+	liveVariantManifestWithNoAds := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-MEDIA-SEQUENCE:10
+#EXT-X-TARGETDURATION:6
+#EXT-X-PROGRAM-DATE-TIME:2020-03-11T00:51:48Z
+#EXTINF:6.000,
+https://existing.base/path/chan_1/chan_1_20200311T202743_1_00019.ts
+#EXTINF:6.000,
+https://existing.base/path/chan_1/chan_1_20200311T202748_1_00020.ts
+#EXTINF:6.000,
+https://other.base/path/chan_1_20200311T202801_1_00021.ts
+#EXTINF:4.000,
+https://existing.base/path/chan_1/chan_1_20200311T202806_1_00022.ts
+`
+
+	vodVariantManifest := `#EXTM3U
 #EXT-X-VERSION:3
+#EXT-X-PLAYLIST-TYPE:VOD
 #EXT-X-MEDIA-SEQUENCE:0
 #EXT-X-TARGETDURATION:6
-#EXT-X-PROGRAM-DATE-TIME:2021-02-23T06:00:07.633Z
+#EXT-X-PROGRAM-DATE-TIME:2020-03-11T00:51:48Z
 #EXTINF:6.000,
-http://bakery.cbsi.video/phe(true)/aHR0cHM6Ly9kaWN0YTU0ODczYTIuYWlyc3BhY2UtY2RuLmNic2l2aWRlby5jb20va2VpdGhoOTdoLXRlc3QvZW4vY2FwdGlvbnNfMTc3NDAudnR0.vtt
-#EXT-X-PROGRAM-DATE-TIME:2021-02-23T06:00:13.633Z
+segment_1.ts
+#EXT-X-DISCONTINUITY
+#EXT-X-PROGRAM-DATE-TIME:2020-03-11T00:52:48Z
 #EXTINF:6.000,
-http://bakery.cbsi.video/phe(true)/aHR0cHM6Ly9kaWN0YTU0ODczYTIuYWlyc3BhY2UtY2RuLmNic2l2aWRlby5jb20va2VpdGhoOTdoLXRlc3QvZW4vY2FwdGlvbnNfMTc3NDEudnR0.vtt
-#EXT-X-PROGRAM-DATE-TIME:2021-02-23T06:00:19.633Z
+segment_2.ts
+#EXT-X-ENDLIST
+`
+
+	vodVariantManifestWithAbsoluteURLs := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-PLAYLIST-TYPE:VOD
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-TARGETDURATION:6
+#EXT-X-PROGRAM-DATE-TIME:2020-03-11T00:51:48Z
 #EXTINF:6.000,
-http://bakery.cbsi.video/phe(true)/aHR0cHM6Ly9kaWN0YTU0ODczYTIuYWlyc3BhY2UtY2RuLmNic2l2aWRlby5jb20va2VpdGhoOTdoLXRlc3QvZW4vY2FwdGlvbnNfMTc3NDIudnR0.vtt
+https://existing.base/path/segment_1.ts
+#EXT-X-DISCONTINUITY
+#EXT-X-PROGRAM-DATE-TIME:2020-03-11T00:52:48Z
+#EXTINF:6.000,
+https://existing.base/path/segment_2.ts
 #EXT-X-ENDLIST
 `
 
-	longWebvttManifest, err := ioutil.ReadFile("../tests/long_vtt_manifest.m3u8")
-	if err != nil {
-		panic(err)
-	}
-	longWebvttManifestContent := string(longWebvttManifest)
+	liveVariantManifestWithLongTargetDuration := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-MEDIA-SEQUENCE:10
+#EXT-X-TARGETDURATION:10
+#EXTINF:6.000,
+segment_10.ts
+#EXTINF:5.500,
+segment_11.ts
+`
 
-	tests := []struct {
-		name                  string
-		filters               *parsers.MediaFilters
-		manifestContent       string
-		expectManifestContent string
-	}{
-		{
-			name:                  "when preventing http error on rendition manifest, should not apply trim filter if not present",
-			filters:               &parsers.MediaFilters{},
-			manifestContent:       variantManifestContent,
-			expectManifestContent: variantManifestContent,
-		},
-		{
-			name:                  "when preventing http error on rendition manifest, should be able to return long manifest",
-			filters:               &parsers.MediaFilters{},
-			manifestContent:       longWebvttManifestContent,
-			expectManifestContent: longWebvttManifestContent,
-		},
-		{
-			name: "when preventing http error on rendition manifest, should apply trim filter",
-			filters: &parsers.MediaFilters{
-				Trim: &parsers.Trim{
-					Start: 1614060008, // 2021-02-23T06:00:08
-					End:   1614060020, // 2021-02-23T06:00:20
-				},
+	vodVariantManifestFromLive := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-PLAYLIST-TYPE:VOD
+#EXT-X-MEDIA-SEQUENCE:10
+#EXT-X-TARGETDURATION:6
+#EXTINF:6.000,
+https://existing.base/path/segment_10.ts
+#EXTINF:5.500,
+https://existing.base/path/segment_11.ts
+#EXT-X-ENDLIST
+`
+
+	eventVariantManifestFromVOD := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-PLAYLIST-TYPE:EVENT
+#EXT-X-ALLOW-CACHE:NO
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-TARGETDURATION:6
+#EXT-X-PROGRAM-DATE-TIME:2020-03-11T00:51:48Z
+#EXTINF:6.000,
+https://existing.base/path/segment_1.ts
+#EXT-X-DISCONTINUITY
+#EXT-X-PROGRAM-DATE-TIME:2020-03-11T00:52:48Z
+#EXTINF:6.000,
+https://existing.base/path/segment_2.ts
+`
+
+	liveVariantManifestFromVOD := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-TARGETDURATION:6
+#EXT-X-PROGRAM-DATE-TIME:2020-03-11T00:51:48Z
+#EXTINF:6.000,
+https://existing.base/path/segment_1.ts
+#EXT-X-DISCONTINUITY
+#EXT-X-PROGRAM-DATE-TIME:2020-03-11T00:52:48Z
+#EXTINF:6.000,
+https://existing.base/path/segment_2.ts
+`
+
+	vodVariantManifestWithNoPDTs := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-PLAYLIST-TYPE:VOD
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-TARGETDURATION:6
+#EXTINF:6.000,
+https://existing.base/path/segment_1.ts
+#EXT-X-DISCONTINUITY
+#EXTINF:6.000,
+https://existing.base/path/segment_2.ts
+#EXT-X-ENDLIST
+`
+
+	liveVariantManifestWithDiscontinuities := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-MEDIA-SEQUENCE:100
+#EXT-X-TARGETDURATION:6
+#EXT-X-DISCONTINUITY-SEQUENCE:2
+#EXT-X-KEY:METHOD=AES-128,URI="https://existing.base/key"
+#EXTINF:6.000,
+segment_100.ts
+#EXT-X-DISCONTINUITY
+#EXTINF:6.000,
+segment_101.ts
+#EXTINF:6.000,
+segment_102.ts
+#EXT-X-DISCONTINUITY
+#EXTINF:6.000,
+segment_103.ts
+#EXTINF:6.000,
+segment_104.ts
+`
+
+	liveVariantManifestWithDVRWindow := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-MEDIA-SEQUENCE:103
+#EXT-X-TARGETDURATION:6
+#EXT-X-DISCONTINUITY-SEQUENCE:3
+#EXT-X-KEY:METHOD=AES-128,URI="https://existing.base/key"
+#EXT-X-DISCONTINUITY
+#EXTINF:6.000,
+https://existing.base/path/segment_103.ts
+#EXTINF:6.000,
+https://existing.base/path/segment_104.ts
+`
+
+	liveVariantManifestWithShortDVRWindow := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-MEDIA-SEQUENCE:104
+#EXT-X-TARGETDURATION:6
+#EXT-X-DISCONTINUITY-SEQUENCE:4
+#EXT-X-KEY:METHOD=AES-128,URI="https://existing.base/key"
+#EXTINF:6.000,
+https://existing.base/path/segment_104.ts
+`
+
+	tests := []struct {
+		name                  string
+		filters               *parsers.MediaFilters
+		manifestContent       string
+		expectManifestContent string
+		expectAge             string
+		expectErr             bool
+	}{
+		{
+			name:                  "when a dvr window is given, only the segments in the window are kept and sequences are adjusted",
+			filters:               &parsers.MediaFilters{DVR: 12},
+			manifestContent:       liveVariantManifestWithDiscontinuities,
+			expectManifestContent: liveVariantManifestWithDVRWindow,
+			expectAge:             "3",
+		},
+		{
+			name:                  "when a dvr window is shorter than a segment, the last segment is kept",
+			filters:               &parsers.MediaFilters{DVR: 2},
+			manifestContent:       liveVariantManifestWithDiscontinuities,
+			expectManifestContent: liveVariantManifestWithShortDVRWindow,
+			expectAge:             "3",
+		},
+		{
+			name:                  "when a dvr window is given for a vod playlist, all segments are kept",
+			filters:               &parsers.MediaFilters{DVR: 6},
+			manifestContent:       vodVariantManifest,
+			expectManifestContent: vodVariantManifestWithAbsoluteURLs,
+			expectAge:             "3",
+		},
+		{
+			name:                  "when vod type is given for a live playlist, the playlist is closed with a fitted target duration",
+			filters:               &parsers.MediaFilters{PlaylistType: parsers.PlaylistTypeVOD},
+			manifestContent:       liveVariantManifestWithLongTargetDuration,
+			expectManifestContent: vodVariantManifestFromLive,
+			expectAge:             "3",
+		},
+		{
+			name:                  "when event type is given for a vod playlist, the playlist is opened as an event",
+			filters:               &parsers.MediaFilters{PlaylistType: parsers.PlaylistTypeEvent},
+			manifestContent:       vodVariantManifest,
+			expectManifestContent: eventVariantManifestFromVOD,
+			expectAge:             "3",
+		},
+		{
+			name:                  "when live type is given for a vod playlist, the playlist is opened without a playlist type",
+			filters:               &parsers.MediaFilters{PlaylistType: parsers.PlaylistTypeLive},
+			manifestContent:       vodVariantManifest,
+			expectManifestContent: liveVariantManifestFromVOD,
+			expectAge:             "3",
+		},
+		{
+			name:                  "when no filters are given, segment urls are absolute and a live playlist stays live",
+			filters:               &parsers.MediaFilters{},
+			manifestContent:       liveVariantManifest,
+			expectManifestContent: liveVariantManifestWithAbsoluteURLs,
+			expectAge:             "3",
+		},
+		{
+			name: "when ads tag is enabled without trim, ads are suppressed and a live playlist stays live",
+			filters: &parsers.MediaFilters{
+				Tags: &parsers.Tags{
+					Ads: true,
+				},
 			},
-			manifestContent:       variantManifestContent,
-			expectManifestContent: expectedFilteredManifestWithTrimFilter,
+			manifestContent:       liveVariantManifest,
+			expectManifestContent: liveVariantManifestWithNoAds,
+			expectAge:             "3",
+		},
+		{
+			name: "when custom tags are given, the tags are removed and a vod playlist stays closed",
+			filters: &parsers.MediaFilters{
+				Tags: &parsers.Tags{
+					Custom: []string{"EXT-X-PROGRAM-DATE-TIME"},
+				},
+			},
+			manifestContent:       vodVariantManifest,
+			expectManifestContent: vodVariantManifestWithNoPDTs,
+			expectAge:             "3",
 		},
 	}
 
 	for _, tt := range tests {
 		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
-			filter := NewHLSFilter("https://existing.base/path/variant.m3u8", tt.manifestContent, config.Config{Hostname: "bakery.cbsi.video"})
+			filter := NewHLSFilter("https://existing.base/path/master.m3u8", tt.manifestContent, config.Config{Hostname: "bakery.cbsi.video"})
 			manifest, err := filter.FilterContent(context.Background(), tt.filters)
 
-			if err != nil {
-				t.Errorf("FilterContent(context.Background(), ) got an error: %v", err)
+			if err != nil && !tt.expectErr {
+				t.Errorf("FilterContent(context.Background(), ) didnt expect an error to be returned, got: %v", err)
+				return
+			} else if err == nil && tt.expectErr {
+				t.Error("FilterContent(context.Background(), ) expected an error, got nil")
 				return
 			}
 
@@ -2483,112 +2930,2148 @@ http://bakery.cbsi.video/phe(true)/aHR0cHM6Ly9kaWN0YTU0ODczYTIuYWlyc3BhY2UtY2RuL
 				t.Errorf("FilterContent(context.Background(), ) wrong manifest returned)\ngot %v\nexpected: %v\ndiff: %v", g, e,
 					cmp.Diff(g, e))
 			}
+
+			if g := filter.GetMaxAge(); g != tt.expectAge {
+				t.Errorf("Wrong max age returned\ngot %v\nexpected: %v\ndiff: %v", g, tt.expectAge,
+					cmp.Diff(g, tt.expectAge))
+			}
 		})
 	}
 }
 
-func TestHLSFilter_FilterContent_LanguageFilter(t *testing.T) {
+func TestHLSFilter_FilterContent_UnknownTags(t *testing.T) {
+	manifest := `#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-INDEPENDENT-SEGMENTS
+#EXT-X-START:TIME-OFFSET=-12,PRECISE=YES
+#EXT-X-TARGETDURATION:6
+#EXT-X-MEDIA-SEQUENCE:10
+#EXT-X-PROGRAM-DATE-TIME:2020-03-11T00:51:48Z
+#EXTINF:6.000,
+segment_10.ts
+#EXT-X-DATERANGE:ID="chapter-1",CLASS="com.example.chapter",START-DATE="2020-03-11T00:51:54Z"
+#EXTINF:6.000,
+segment_11.ts
+#EXT-X-BITRATE:1200
+#EXTINF:6.000,
+segment_12.ts
+#EXT-X-GAP
+#EXTINF:6.000,
+segment_13.ts
+`
 
-	masterManifestWithMultipleLangs := `#EXTM3U
-#EXT-X-VERSION:4
-#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio0",NAME="English",DEFAULT=YES,AUTOSELECT=YES,LANGUAGE="en",URI="https://existing.base/path/index-f8-a1.m3u8"
-#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio0",NAME="Dansk",DEFAULT=NO,AUTOSELECT=NO,LANGUAGE="da",URI="https://existing.base/path/index-f10-a1.m3u8"
-#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio0",NAME="Deutsch",DEFAULT=NO,AUTOSELECT=NO,LANGUAGE="de",URI="https://existing.base/path/index-f11-a1.m3u8"
-#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio0",NAME="Spanish (Latin America)",DEFAULT=NO,AUTOSELECT=NO,LANGUAGE="es",URI="https://existing.base/path/index-f12-a1.m3u8"
-#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio0",NAME="Brazilian Portuguese",DEFAULT=NO,AUTOSELECT=NO,LANGUAGE="pt",URI="https://existing.base/path/index-f16-a1.m3u8"
-#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs0",NAME="English",DEFAULT=YES,AUTOSELECT=YES,LANGUAGE="en",URI="https://existing.base/path/index-f19.m3u8"
-#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=277965,CODECS="avc1.4d401e,mp4a.40.2",RESOLUTION=384x216,AUDIO="audio0",SUBTITLES="subs0",FRAME-RATE=25.000,VIDEO-RANGE=SDR
-https://existing.base/path/index-f1-v1.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=479857,CODECS="avc1.4d401e,mp4a.40.2",RESOLUTION=512x288,AUDIO="audio0",SUBTITLES="subs0",FRAME-RATE=25.000,VIDEO-RANGE=SDR
-https://existing.base/path/index-f2-v1.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=786893,CODECS="avc1.4d401e,mp4a.40.2",RESOLUTION=640x360,AUDIO="audio0",SUBTITLES="subs0",FRAME-RATE=25.000,VIDEO-RANGE=SDR
-https://existing.base/path/index-f3-v1.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=1015563,CODECS="avc1.4d401e,mp4a.40.2",RESOLUTION=768x432,AUDIO="audio0",SUBTITLES="subs0",FRAME-RATE=25.000,VIDEO-RANGE=SDR
-https://existing.base/path/index-f4-v1.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=1421115,CODECS="avc1.4d401e,mp4a.40.2",RESOLUTION=960x540,AUDIO="audio0",SUBTITLES="subs0",FRAME-RATE=25.000,VIDEO-RANGE=SDR
-https://existing.base/path/index-f5-v1.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=1956642,CODECS="avc1.640028,mp4a.40.2",RESOLUTION=1280x720,AUDIO="audio0",SUBTITLES="subs0",FRAME-RATE=25.000,VIDEO-RANGE=SDR
-https://existing.base/path/index-f6-v1.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=3712583,CODECS="avc1.640028,mp4a.40.2",RESOLUTION=1920x1080,AUDIO="audio0",SUBTITLES="subs0",FRAME-RATE=25.000,VIDEO-RANGE=SDR
-https://existing.base/path/index-f7-v1.m3u8
+	manifestWithAbsoluteURLs := `#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-INDEPENDENT-SEGMENTS
+#EXT-X-START:TIME-OFFSET=-12,PRECISE=YES
+#EXT-X-TARGETDURATION:6
+#EXT-X-MEDIA-SEQUENCE:10
+#EXT-X-PROGRAM-DATE-TIME:2020-03-11T00:51:48Z
+#EXTINF:6.000,
+https://existing.base/path/segment_10.ts
+#EXT-X-DATERANGE:ID="chapter-1",CLASS="com.example.chapter",START-DATE="2020-03-11T00:51:54Z"
+#EXTINF:6.000,
+https://existing.base/path/segment_11.ts
+#EXT-X-BITRATE:1200
+#EXTINF:6.000,
+https://existing.base/path/segment_12.ts
+#EXT-X-GAP
+#EXTINF:6.000,
+https://existing.base/path/segment_13.ts
 `
 
-	masterManifestWithEnglishOnly := `#EXTM3U
+	tests := []struct {
+		name                  string
+		filters               *parsers.MediaFilters
+		expectManifestContent string
+	}{
+		{
+			name:                  "when no filters are given, expect the playlist as served by the origin with absolute urls",
+			filters:               &parsers.MediaFilters{},
+			expectManifestContent: manifestWithAbsoluteURLs,
+		},
+		{
+			name:                  "when the playlist is filtered, expect the tags the decoder drops to be kept",
+			filters:               &parsers.MediaFilters{DVR: 18},
+			expectManifestContent: `#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-INDEPENDENT-SEGMENTS
+#EXT-X-START:TIME-OFFSET=-12,PRECISE=YES
+#EXT-X-MEDIA-SEQUENCE:11
+#EXT-X-TARGETDURATION:6
+#EXT-X-PROGRAM-DATE-TIME:2020-03-11T00:51:54Z
+#EXT-X-DATERANGE:ID="chapter-1",CLASS="com.example.chapter",START-DATE="2020-03-11T00:51:54Z"
+#EXTINF:6.000,
+https://existing.base/path/segment_11.ts
+#EXT-X-BITRATE:1200
+#EXTINF:6.000,
+https://existing.base/path/segment_12.ts
+#EXT-X-GAP
+#EXTINF:6.000,
+https://existing.base/path/segment_13.ts
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			filter := NewHLSFilter("https://existing.base/path/master.m3u8", manifest, config.Config{Hostname: "bakery.cbsi.video"})
+			manifest, err := filter.FilterContent(context.Background(), tt.filters)
+			if err != nil {
+				t.Fatalf("FilterContent(context.Background(), ) didnt expect an error to be returned, got: %v", err)
+			}
+
+			if g, e := manifest, tt.expectManifestContent; g != e {
+				t.Errorf("FilterContent(context.Background(), ) wrong manifest returned)\ngot %v\nexpected: %v\ndiff: %v", g, e,
+					cmp.Diff(g, e))
+			}
+		})
+	}
+}
+
+func TestHLSFilter_FilterContent_CDN(t *testing.T) {
+	masterManifest := `#EXTM3U
 #EXT-X-VERSION:4
-#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio0",NAME="English",DEFAULT=YES,AUTOSELECT=YES,LANGUAGE="en",URI="https://existing.base/path/index-f8-a1.m3u8"
-#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs0",NAME="English",DEFAULT=YES,AUTOSELECT=YES,LANGUAGE="en",URI="https://existing.base/path/index-f19.m3u8"
-#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=277965,CODECS="avc1.4d401e,mp4a.40.2",RESOLUTION=384x216,AUDIO="audio0",SUBTITLES="subs0",FRAME-RATE=25.000,VIDEO-RANGE=SDR
-https://existing.base/path/index-f1-v1.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=479857,CODECS="avc1.4d401e,mp4a.40.2",RESOLUTION=512x288,AUDIO="audio0",SUBTITLES="subs0",FRAME-RATE=25.000,VIDEO-RANGE=SDR
-https://existing.base/path/index-f2-v1.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=786893,CODECS="avc1.4d401e,mp4a.40.2",RESOLUTION=640x360,AUDIO="audio0",SUBTITLES="subs0",FRAME-RATE=25.000,VIDEO-RANGE=SDR
-https://existing.base/path/index-f3-v1.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=1015563,CODECS="avc1.4d401e,mp4a.40.2",RESOLUTION=768x432,AUDIO="audio0",SUBTITLES="subs0",FRAME-RATE=25.000,VIDEO-RANGE=SDR
-https://existing.base/path/index-f4-v1.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=1421115,CODECS="avc1.4d401e,mp4a.40.2",RESOLUTION=960x540,AUDIO="audio0",SUBTITLES="subs0",FRAME-RATE=25.000,VIDEO-RANGE=SDR
-https://existing.base/path/index-f5-v1.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=1956642,CODECS="avc1.640028,mp4a.40.2",RESOLUTION=1280x720,AUDIO="audio0",SUBTITLES="subs0",FRAME-RATE=25.000,VIDEO-RANGE=SDR
-https://existing.base/path/index-f6-v1.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=3712583,CODECS="avc1.640028,mp4a.40.2",RESOLUTION=1920x1080,AUDIO="audio0",SUBTITLES="subs0",FRAME-RATE=25.000,VIDEO-RANGE=SDR
-https://existing.base/path/index-f7-v1.m3u8
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aac",NAME="English",DEFAULT=YES,AUTOSELECT=YES,LANGUAGE="en",URI="audio/en.m3u8"
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000,AVERAGE-BANDWIDTH=1000,CODECS="avc1.64001f,mp4a.40.2",AUDIO="aac"
+link_1.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4000,AVERAGE-BANDWIDTH=4000,CODECS="avc1.64001f,mp4a.40.2",AUDIO="aac"
+https://other.base/path/link_2.m3u8
 `
 
-	masterManifestWithSpanishAndPortugeseAndNoSubtitles := `#EXTM3U
+	masterManifestWithCDN := `#EXTM3U
 #EXT-X-VERSION:4
-#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio0",NAME="Spanish (Latin America)",DEFAULT=NO,AUTOSELECT=NO,LANGUAGE="es",URI="https://existing.base/path/index-f12-a1.m3u8"
-#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio0",NAME="Brazilian Portuguese",DEFAULT=NO,AUTOSELECT=NO,LANGUAGE="pt",URI="https://existing.base/path/index-f16-a1.m3u8"
-#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=277965,CODECS="avc1.4d401e,mp4a.40.2",RESOLUTION=384x216,AUDIO="audio0",FRAME-RATE=25.000,VIDEO-RANGE=SDR
-https://existing.base/path/index-f1-v1.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=479857,CODECS="avc1.4d401e,mp4a.40.2",RESOLUTION=512x288,AUDIO="audio0",FRAME-RATE=25.000,VIDEO-RANGE=SDR
-https://existing.base/path/index-f2-v1.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=786893,CODECS="avc1.4d401e,mp4a.40.2",RESOLUTION=640x360,AUDIO="audio0",FRAME-RATE=25.000,VIDEO-RANGE=SDR
-https://existing.base/path/index-f3-v1.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=1015563,CODECS="avc1.4d401e,mp4a.40.2",RESOLUTION=768x432,AUDIO="audio0",FRAME-RATE=25.000,VIDEO-RANGE=SDR
-https://existing.base/path/index-f4-v1.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=1421115,CODECS="avc1.4d401e,mp4a.40.2",RESOLUTION=960x540,AUDIO="audio0",FRAME-RATE=25.000,VIDEO-RANGE=SDR
-https://existing.base/path/index-f5-v1.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=1956642,CODECS="avc1.640028,mp4a.40.2",RESOLUTION=1280x720,AUDIO="audio0",FRAME-RATE=25.000,VIDEO-RANGE=SDR
-https://existing.base/path/index-f6-v1.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=3712583,CODECS="avc1.640028,mp4a.40.2",RESOLUTION=1920x1080,AUDIO="audio0",FRAME-RATE=25.000,VIDEO-RANGE=SDR
-https://existing.base/path/index-f7-v1.m3u8
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aac",NAME="English",DEFAULT=YES,AUTOSELECT=YES,LANGUAGE="en",URI="https://cbsi.akamaized.net/path/audio/en.m3u8"
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000,AVERAGE-BANDWIDTH=1000,CODECS="avc1.64001f,mp4a.40.2",AUDIO="aac"
+https://cbsi.akamaized.net/path/link_1.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4000,AVERAGE-BANDWIDTH=4000,CODECS="avc1.64001f,mp4a.40.2",AUDIO="aac"
+https://cbsi.akamaized.net/path/link_2.m3u8
 `
 
-	masterManifestWithEnglishSubsAndCaptions := `#EXTM3U
+	masterManifestWithCDNAndBase64EncodedVariantURLs := `#EXTM3U
 #EXT-X-VERSION:4
-#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",NAME="English",DEFAULT=YES,AUTOSELECT=YES,LANGUAGE="eng",FORCED="NO",URI="https://devcarrier-hls.amlg.io/captions/737e9315-fec6-4809-bd5e-3f2e427ec3a1/en.m3u8"
-#EXT-X-MEDIA:TYPE=CLOSED-CAPTIONS,GROUP-ID="CC",NAME="ENGLISH",DEFAULT=NO,LANGUAGE="ENG"
-#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=356400,AVERAGE-BANDWIDTH=345400,CODECS="avc1.640015,mp4a.40.2",RESOLUTION=400x244,CLOSED-CAPTIONS="CC",SUBTITLES="subs",FRAME-RATE=29.000
-https://cbss64eb-cbss64eb-ms-dev.global.ssl.fastly.net/cbssc0a7/master/cbssc0a7_1.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=642400,AVERAGE-BANDWIDTH=620400,CODECS="avc1.640015,mp4a.40.2",RESOLUTION=512x288,CLOSED-CAPTIONS="CC",SUBTITLES="subs",FRAME-RATE=29.000
-https://cbss64eb-cbss64eb-ms-dev.global.ssl.fastly.net/cbssc0a7/master/cbssc0a7_2.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=985600,AVERAGE-BANDWIDTH=950400,CODECS="avc1.64001e,mp4a.40.2",RESOLUTION=640x360,CLOSED-CAPTIONS="CC",SUBTITLES="subs",FRAME-RATE=29.000
-https://cbss64eb-cbss64eb-ms-dev.global.ssl.fastly.net/cbssc0a7/master/cbssc0a7_3.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1443200,AVERAGE-BANDWIDTH=1390400,CODECS="avc1.64001e,mp4a.40.2",RESOLUTION=768x432,CLOSED-CAPTIONS="CC",SUBTITLES="subs",FRAME-RATE=29.000
-https://cbss64eb-cbss64eb-ms-dev.global.ssl.fastly.net/cbssc0a7/master/cbssc0a7_4.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=2129600,AVERAGE-BANDWIDTH=2050400,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=960x540,CLOSED-CAPTIONS="CC",SUBTITLES="subs",FRAME-RATE=29.000
-https://cbss64eb-cbss64eb-ms-dev.global.ssl.fastly.net/cbssc0a7/master/cbssc0a7_5.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4074400,AVERAGE-BANDWIDTH=3920400,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=1280x720,CLOSED-CAPTIONS="CC",SUBTITLES="subs",FRAME-RATE=29.000
-https://cbss64eb-cbss64eb-ms-dev.global.ssl.fastly.net/cbssc0a7/master/cbssc0a7_6.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=5790400,AVERAGE-BANDWIDTH=5570400,CODECS="avc1.640028,mp4a.40.2",RESOLUTION=1920x1080,CLOSED-CAPTIONS="CC",SUBTITLES="subs",FRAME-RATE=29.000
-https://cbss64eb-cbss64eb-ms-dev.global.ssl.fastly.net/cbssc0a7/master/cbssc0a7_7.m3u8
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aac",NAME="English",DEFAULT=YES,AUTOSELECT=YES,LANGUAGE="en",URI="https://bakery.cbsi.video/cdn(akamai)/tags(ads)/aHR0cHM6Ly9jYnNpLmFrYW1haXplZC5uZXQvcGF0aC9hdWRpby9lbi5tM3U4.m3u8"
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000,AVERAGE-BANDWIDTH=1000,CODECS="avc1.64001f,mp4a.40.2",AUDIO="aac"
+https://bakery.cbsi.video/cdn(akamai)/tags(ads)/aHR0cHM6Ly9jYnNpLmFrYW1haXplZC5uZXQvcGF0aC9saW5rXzEubTN1OA.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4000,AVERAGE-BANDWIDTH=4000,CODECS="avc1.64001f,mp4a.40.2",AUDIO="aac"
+https://bakery.cbsi.video/cdn(akamai)/tags(ads)/aHR0cHM6Ly9jYnNpLmFrYW1haXplZC5uZXQvcGF0aC9saW5rXzIubTN1OA.m3u8
+`
+
+	variantManifest := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-MEDIA-SEQUENCE:10
+#EXT-X-TARGETDURATION:6
+#EXT-X-KEY:METHOD=AES-128,URI="keys/key_1"
+#EXT-X-MAP:URI="init.mp4"
+#EXTINF:6.000,
+segment_10.mp4
+#EXTINF:6.000,
+https://other.base/path/segment_11.mp4
+`
+
+	variantManifestWithCDN := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-MEDIA-SEQUENCE:10
+#EXT-X-TARGETDURATION:6
+#EXT-X-KEY:METHOD=AES-128,URI="https://cbsi.akamaized.net/path/keys/key_1"
+#EXT-X-MAP:URI="https://cbsi.akamaized.net/path/init.mp4"
+#EXTINF:6.000,
+https://cbsi.akamaized.net/path/segment_10.mp4
+#EXTINF:6.000,
+https://cbsi.akamaized.net/path/segment_11.mp4
+`
+
+	tests := []struct {
+		name                  string
+		filters               *parsers.MediaFilters
+		manifestContent       string
+		expectManifestContent string
+		expectErr             bool
+	}{
+		{
+			name:                  "when a cdn is selected, variant and alternative urls point to the cdn",
+			filters:               &parsers.MediaFilters{CDN: "akamai"},
+			manifestContent:       masterManifest,
+			expectManifestContent: masterManifestWithCDN,
+		},
+		{
+			name: "when a cdn is selected with media playlist filters, variants and alternatives point to bakery carrying the cdn",
+			filters: &parsers.MediaFilters{
+				CDN:  "akamai",
+				Tags: &parsers.Tags{Ads: true},
+			},
+			manifestContent:       masterManifest,
+			expectManifestContent: masterManifestWithCDNAndBase64EncodedVariantURLs,
+		},
+		{
+			name:                  "when a cdn is selected for a media playlist, segment, key and map urls point to the cdn",
+			filters:               &parsers.MediaFilters{CDN: "akamai"},
+			manifestContent:       variantManifest,
+			expectManifestContent: variantManifestWithCDN,
+		},
+		{
+			name:            "when the selected cdn isn't configured, expect an error",
+			filters:         &parsers.MediaFilters{CDN: "fastly"},
+			manifestContent: masterManifest,
+			expectErr:       true,
+		},
+	}
+
+	cdns := config.CDNs{
+		Hosts: map[string]*url.URL{
+			"akamai": {Scheme: "https", Host: "cbsi.akamaized.net"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			filter := NewHLSFilter("https://existing.base/path/master.m3u8", tt.manifestContent, config.Config{Hostname: "bakery.cbsi.video", CDNs: cdns})
+			manifest, err := filter.FilterContent(context.Background(), tt.filters)
+
+			if err != nil && !tt.expectErr {
+				t.Errorf("FilterContent(context.Background(), ) didnt expect an error to be returned, got: %v", err)
+				return
+			} else if err == nil && tt.expectErr {
+				t.Error("FilterContent(context.Background(), ) expected an error, got nil")
+				return
+			}
+
+			if g, e := manifest, tt.expectManifestContent; g != e {
+				t.Errorf("FilterContent(context.Background(), ) wrong manifest returned)\ngot %v\nexpected: %v\ndiff: %v", g, e,
+					cmp.Diff(g, e))
+			}
+		})
+	}
+}
+
+func TestHLSFilter_FilterContent_LowLatency(t *testing.T) {
+	lowLatencyManifest := `#EXTM3U
+#EXT-X-VERSION:6
+#EXT-X-TARGETDURATION:4
+#EXT-X-SERVER-CONTROL:CAN-BLOCK-RELOAD=YES,CAN-SKIP-UNTIL=24.0,PART-HOLD-BACK=3.0
+#EXT-X-PART-INF:PART-TARGET=2.0
+#EXT-X-MEDIA-SEQUENCE:270
+#EXT-X-MAP:URI="init.mp4"
+#EXTINF:4.000,
+segment_270.mp4
+#EXTINF:4.000,
+segment_271.mp4
+#EXT-X-PART:DURATION=2.000,URI="segment_272.part0.mp4",INDEPENDENT=YES
+#EXT-X-PART:DURATION=2.000,URI="segment_272.part1.mp4"
+#EXTINF:4.000,
+segment_272.mp4
+#EXT-X-PART:DURATION=2.000,URI="segment_273.part0.mp4",INDEPENDENT=YES
+#EXT-X-PRELOAD-HINT:TYPE=PART,URI="segment_273.part1.mp4"
+#EXT-X-RENDITION-REPORT:URI="../1M/playlist.m3u8",LAST-MSN=273,LAST-PART=0
+`
+
+	lowLatencyManifestWithAbsoluteURLs := `#EXTM3U
+#EXT-X-VERSION:6
+#EXT-X-SERVER-CONTROL:CAN-BLOCK-RELOAD=YES,PART-HOLD-BACK=3.0
+#EXT-X-PART-INF:PART-TARGET=2.0
+#EXT-X-MEDIA-SEQUENCE:270
+#EXT-X-TARGETDURATION:4
+#EXT-X-MAP:URI="https://existing.base/path/init.mp4"
+#EXTINF:4.000,
+https://existing.base/path/segment_270.mp4
+#EXTINF:4.000,
+https://existing.base/path/segment_271.mp4
+#EXT-X-PART:DURATION=2.000,URI="https://existing.base/path/segment_272.part0.mp4",INDEPENDENT=YES
+#EXT-X-PART:DURATION=2.000,URI="https://existing.base/path/segment_272.part1.mp4"
+#EXTINF:4.000,
+https://existing.base/path/segment_272.mp4
+#EXT-X-PART:DURATION=2.000,URI="https://existing.base/path/segment_273.part0.mp4",INDEPENDENT=YES
+#EXT-X-PRELOAD-HINT:TYPE=PART,URI="https://existing.base/path/segment_273.part1.mp4"
+#EXT-X-RENDITION-REPORT:URI="https://bakery.cbsi.video/aHR0cHM6Ly9leGlzdGluZy5iYXNlLzFNL3BsYXlsaXN0Lm0zdTg.m3u8",LAST-MSN=273,LAST-PART=0
+`
+
+	lowLatencyManifestWithDVRWindow := `#EXTM3U
+#EXT-X-VERSION:6
+#EXT-X-SERVER-CONTROL:CAN-BLOCK-RELOAD=YES,PART-HOLD-BACK=3.0
+#EXT-X-PART-INF:PART-TARGET=2.0
+#EXT-X-MEDIA-SEQUENCE:272
+#EXT-X-TARGETDURATION:4
+#EXT-X-MAP:URI="https://existing.base/path/init.mp4"
+#EXT-X-PART:DURATION=2.000,URI="https://existing.base/path/segment_272.part0.mp4",INDEPENDENT=YES
+#EXT-X-PART:DURATION=2.000,URI="https://existing.base/path/segment_272.part1.mp4"
+#EXTINF:4.000,
+https://existing.base/path/segment_272.mp4
+#EXT-X-PART:DURATION=2.000,URI="https://existing.base/path/segment_273.part0.mp4",INDEPENDENT=YES
+#EXT-X-PRELOAD-HINT:TYPE=PART,URI="https://existing.base/path/segment_273.part1.mp4"
+#EXT-X-RENDITION-REPORT:URI="https://bakery.cbsi.video/dvr(4)/aHR0cHM6Ly9leGlzdGluZy5iYXNlLzFNL3BsYXlsaXN0Lm0zdTg.m3u8",LAST-MSN=273,LAST-PART=0
+`
+
+	lowLatencyManifestWithCDN := `#EXTM3U
+#EXT-X-VERSION:6
+#EXT-X-SERVER-CONTROL:CAN-BLOCK-RELOAD=YES,PART-HOLD-BACK=3.0
+#EXT-X-PART-INF:PART-TARGET=2.0
+#EXT-X-MEDIA-SEQUENCE:270
+#EXT-X-TARGETDURATION:4
+#EXT-X-MAP:URI="https://cbsi.akamaized.net/path/init.mp4"
+#EXTINF:4.000,
+https://cbsi.akamaized.net/path/segment_270.mp4
+#EXTINF:4.000,
+https://cbsi.akamaized.net/path/segment_271.mp4
+#EXT-X-PART:DURATION=2.000,URI="https://cbsi.akamaized.net/path/segment_272.part0.mp4",INDEPENDENT=YES
+#EXT-X-PART:DURATION=2.000,URI="https://cbsi.akamaized.net/path/segment_272.part1.mp4"
+#EXTINF:4.000,
+https://cbsi.akamaized.net/path/segment_272.mp4
+#EXT-X-PART:DURATION=2.000,URI="https://cbsi.akamaized.net/path/segment_273.part0.mp4",INDEPENDENT=YES
+#EXT-X-PRELOAD-HINT:TYPE=PART,URI="https://cbsi.akamaized.net/path/segment_273.part1.mp4"
+#EXT-X-RENDITION-REPORT:URI="https://bakery.cbsi.video/cdn(akamai)/aHR0cHM6Ly9jYnNpLmFrYW1haXplZC5uZXQvMU0vcGxheWxpc3QubTN1OA.m3u8",LAST-MSN=273,LAST-PART=0
+`
+
+	lowLatencyManifestAsVOD := `#EXTM3U
+#EXT-X-VERSION:6
+#EXT-X-PLAYLIST-TYPE:VOD
+#EXT-X-MEDIA-SEQUENCE:270
+#EXT-X-TARGETDURATION:4
+#EXT-X-MAP:URI="https://existing.base/path/init.mp4"
+#EXTINF:4.000,
+https://existing.base/path/segment_270.mp4
+#EXTINF:4.000,
+https://existing.base/path/segment_271.mp4
+#EXTINF:4.000,
+https://existing.base/path/segment_272.mp4
+#EXT-X-ENDLIST
+`
+
+	tests := []struct {
+		name                  string
+		filters               *parsers.MediaFilters
+		manifestContent       string
+		expectManifestContent string
+	}{
+		{
+			name:                  "when no filters are set, expect partial segments, hints and reports to be kept",
+			filters:               &parsers.MediaFilters{},
+			manifestContent:       lowLatencyManifest,
+			expectManifestContent: lowLatencyManifestWithAbsoluteURLs,
+		},
+		{
+			name:                  "when a dvr window is set, expect the partial segments of removed segments to be removed",
+			filters:               &parsers.MediaFilters{DVR: 4},
+			manifestContent:       lowLatencyManifest,
+			expectManifestContent: lowLatencyManifestWithDVRWindow,
+		},
+		{
+			name:                  "when a cdn is selected, expect partial segments and hints to point to the cdn",
+			filters:               &parsers.MediaFilters{CDN: "akamai"},
+			manifestContent:       lowLatencyManifest,
+			expectManifestContent: lowLatencyManifestWithCDN,
+		},
+		{
+			name:                  "when the playlist is closed, expect low latency tags to be removed",
+			filters:               &parsers.MediaFilters{PlaylistType: parsers.PlaylistTypeVOD},
+			manifestContent:       lowLatencyManifest,
+			expectManifestContent: lowLatencyManifestAsVOD,
+		},
+	}
+
+	c := config.Config{
+		Hostname: "bakery.cbsi.video",
+		CDNs: config.CDNs{
+			Hosts: map[string]*url.URL{
+				"akamai": {Scheme: "https", Host: "cbsi.akamaized.net"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			filter := NewHLSFilter("https://existing.base/path/720p.m3u8", tt.manifestContent, c)
+			manifest, err := filter.FilterContent(context.Background(), tt.filters)
+			if err != nil {
+				t.Fatalf("FilterContent(context.Background(), ) didnt expect an error to be returned, got: %v", err)
+			}
+
+			if g, e := manifest, tt.expectManifestContent; g != e {
+				t.Errorf("FilterContent(context.Background(), ) wrong manifest returned)\ngot %v\nexpected: %v\ndiff: %v", g, e,
+					cmp.Diff(g, e))
+			}
+		})
+	}
+}
+
+func TestHLSFilter_FilterContent_SCTE(t *testing.T) {
+	oatclsManifest := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:6
+#EXT-X-MEDIA-SEQUENCE:100
+#EXT-X-PROGRAM-DATE-TIME:2020-06-01T10:00:00Z
+#EXTINF:6.000,
+segment_100.ts
+#EXT-OATCLS-SCTE35:/DAlAAAAAAAAAP/wFAUAAAABf+/+LRQrAP4BI9MIAAEBAQAAfxV6SQ==
+#EXT-X-ASSET:CAID=0x0000000020FB6501
+#EXT-X-CUE-OUT:12
+#EXTINF:6.000,
+segment_101.ts
+#EXT-X-CUE-OUT-CONT:CAID=0x0000000020FB6501,ElapsedTime=6,Duration=12,SCTE35=/DAlAAAAAAAAAP/wFAUAAAABf+/+LRQrAP4BI9MIAAEBAQAAfxV6SQ==
+#EXTINF:6.000,
+segment_102.ts
+#EXT-X-CUE-IN
+#EXTINF:6.000,
+segment_103.ts
+`
+
+	oatclsManifestAsDateRanges := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-MEDIA-SEQUENCE:100
+#EXT-X-TARGETDURATION:6
+#EXT-X-PROGRAM-DATE-TIME:2020-06-01T10:00:00Z
+#EXTINF:6.000,
+https://existing.base/path/segment_100.ts
+#EXT-X-PROGRAM-DATE-TIME:2020-06-01T10:00:06Z
+#EXT-X-DATERANGE:ID="splice-1",START-DATE="2020-06-01T10:00:06.000Z",PLANNED-DURATION=12,SCTE35-OUT=0xFC302500000000000000FFF01405000000017FEFFE2D142B00FE0123D3080001010100007F157A49
+#EXTINF:6.000,
+https://existing.base/path/segment_101.ts
+#EXTINF:6.000,
+https://existing.base/path/segment_102.ts
+#EXT-X-PROGRAM-DATE-TIME:2020-06-01T10:00:18Z
+#EXT-X-DATERANGE:ID="splice-1",START-DATE="2020-06-01T10:00:06.000Z",DURATION=12,SCTE35-IN=0xFC301B00000000000000FFF00A05000000017F5F000000000000D8AAE913
+#EXTINF:6.000,
+https://existing.base/path/segment_103.ts
+`
+
+	oatclsManifestAsCues := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-MEDIA-SEQUENCE:100
+#EXT-X-TARGETDURATION:6
+#EXT-X-PROGRAM-DATE-TIME:2020-06-01T10:00:00Z
+#EXTINF:6.000,
+https://existing.base/path/segment_100.ts
+#EXT-X-CUE-OUT:DURATION=12
+#EXTINF:6.000,
+https://existing.base/path/segment_101.ts
+#EXTINF:6.000,
+https://existing.base/path/segment_102.ts
+#EXT-X-CUE-IN
+#EXTINF:6.000,
+https://existing.base/path/segment_103.ts
+`
+
+	oatclsManifestWithDVRWindow := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-MEDIA-SEQUENCE:102
+#EXT-X-TARGETDURATION:6
+#EXT-X-CUE-OUT-CONT:CAID=0x0000000020FB6501,ElapsedTime=6,Duration=12,SCTE35=/DAlAAAAAAAAAP/wFAUAAAABf+/+LRQrAP4BI9MIAAEBAQAAfxV6SQ==
+#EXTINF:6.000,
+https://existing.base/path/segment_102.ts
+#EXT-X-CUE-IN
+#EXTINF:6.000,
+https://existing.base/path/segment_103.ts
+`
+
+	oatclsManifestWithoutAds := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-MEDIA-SEQUENCE:100
+#EXT-X-TARGETDURATION:6
+#EXT-X-PROGRAM-DATE-TIME:2020-06-01T10:00:00Z
+#EXTINF:6.000,
+https://existing.base/path/segment_100.ts
+#EXTINF:6.000,
+https://existing.base/path/segment_101.ts
+#EXTINF:6.000,
+https://existing.base/path/segment_102.ts
+#EXTINF:6.000,
+https://existing.base/path/segment_103.ts
+`
+
+	cueManifest := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:6
+#EXT-X-MEDIA-SEQUENCE:100
+#EXTINF:6.000,
+segment_100.ts
+#EXT-X-CUE-OUT:DURATION=12
+#EXTINF:6.000,
+segment_101.ts
+#EXTINF:6.000,
+segment_102.ts
+#EXT-X-CUE-IN
+#EXTINF:6.000,
+segment_103.ts
+`
+
+	cueManifestAsOATCLS := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-MEDIA-SEQUENCE:100
+#EXT-X-TARGETDURATION:6
+#EXTINF:6.000,
+https://existing.base/path/segment_100.ts
+#EXT-OATCLS-SCTE35:/DAgAAAAAAAAAP/wDwUAABdwf/9+ABB6wAAAAAAAAEqfyLw=
+#EXT-X-CUE-OUT:12
+#EXTINF:6.000,
+https://existing.base/path/segment_101.ts
+#EXT-X-CUE-OUT-CONT:ElapsedTime=6,Duration=12,SCTE35=/DAgAAAAAAAAAP/wDwUAABdwf/9+ABB6wAAAAAAAAEqfyLw=
+#EXTINF:6.000,
+https://existing.base/path/segment_102.ts
+#EXT-X-CUE-IN
+#EXTINF:6.000,
+https://existing.base/path/segment_103.ts
+`
+
+	dateRangeManifest := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:6
+#EXT-X-MEDIA-SEQUENCE:100
+#EXT-X-PROGRAM-DATE-TIME:2020-06-01T10:00:00Z
+#EXTINF:6.000,
+segment_100.ts
+#EXT-X-DATERANGE:ID="splice-1",START-DATE="2020-06-01T10:00:06.000Z",PLANNED-DURATION=12,SCTE35-OUT=0xFC302500000000000000FFF01405000000017FEFFE2D142B00FE0123D3080001010100007F157A49
+#EXT-X-DATERANGE:ID="chapter-2",CLASS="com.example.chapter",START-DATE="2020-06-01T10:00:06.000Z"
+#EXTINF:6.000,
+segment_101.ts
+#EXTINF:6.000,
+segment_102.ts
+#EXTINF:6.000,
+segment_103.ts
+`
+
+	dateRangeManifestAsCues := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-MEDIA-SEQUENCE:100
+#EXT-X-TARGETDURATION:6
+#EXT-X-PROGRAM-DATE-TIME:2020-06-01T10:00:00Z
+#EXTINF:6.000,
+https://existing.base/path/segment_100.ts
+#EXT-X-PROGRAM-DATE-TIME:2020-06-01T10:00:06Z
+#EXT-X-DATERANGE:ID="chapter-2",CLASS="com.example.chapter",START-DATE="2020-06-01T10:00:06.000Z"
+#EXT-X-CUE-OUT:DURATION=12
+#EXTINF:6.000,
+https://existing.base/path/segment_101.ts
+#EXTINF:6.000,
+https://existing.base/path/segment_102.ts
+#EXT-X-CUE-IN
+#EXTINF:6.000,
+https://existing.base/path/segment_103.ts
+`
+
+	tests := []struct {
+		name                  string
+		filters               *parsers.MediaFilters
+		manifestContent       string
+		expectManifestContent string
+		expectErr             bool
+	}{
+		{
+			name:                  "when oatcls cues are translated into date ranges, expect the splice to be kept",
+			filters:               &parsers.MediaFilters{SCTE: parsers.SCTEDateRange},
+			manifestContent:       oatclsManifest,
+			expectManifestContent: oatclsManifestAsDateRanges,
+		},
+		{
+			name:                  "when oatcls cues are translated into cues, expect cue out and cue in tags",
+			filters:               &parsers.MediaFilters{SCTE: parsers.SCTECue},
+			manifestContent:       oatclsManifest,
+			expectManifestContent: oatclsManifestAsCues,
+		},
+		{
+			name:                  "when the dvr window starts within an ad break, expect the break to be continued",
+			filters:               &parsers.MediaFilters{SCTE: parsers.SCTEOATCLS, DVR: 12},
+			manifestContent:       oatclsManifest,
+			expectManifestContent: oatclsManifestWithDVRWindow,
+		},
+		{
+			name:                  "when ads are suppressed, expect the scte filter to be ignored",
+			filters:               &parsers.MediaFilters{SCTE: parsers.SCTEDateRange, Tags: &parsers.Tags{Ads: true}},
+			manifestContent:       oatclsManifest,
+			expectManifestContent: oatclsManifestWithoutAds,
+		},
+		{
+			name:                  "when cues are translated into oatcls cues, expect a splice to be encoded",
+			filters:               &parsers.MediaFilters{SCTE: parsers.SCTEOATCLS},
+			manifestContent:       cueManifest,
+			expectManifestContent: cueManifestAsOATCLS,
+		},
+		{
+			name:                  "when date ranges are translated into cues, expect other date ranges to be kept",
+			filters:               &parsers.MediaFilters{SCTE: parsers.SCTECue},
+			manifestContent:       dateRangeManifest,
+			expectManifestContent: dateRangeManifestAsCues,
+		},
+		{
+			name:            "when cues are translated into date ranges without program date times, expect an error",
+			filters:         &parsers.MediaFilters{SCTE: parsers.SCTEDateRange},
+			manifestContent: cueManifest,
+			expectErr:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			filter := NewHLSFilter("https://existing.base/path/720p.m3u8", tt.manifestContent, config.Config{})
+			manifest, err := filter.FilterContent(context.Background(), tt.filters)
+			if err != nil && !tt.expectErr {
+				t.Fatalf("FilterContent(context.Background(), ) didnt expect an error to be returned, got: %v", err)
+			} else if err == nil && tt.expectErr {
+				t.Fatal("FilterContent(context.Background(), ) expected an error, got nil")
+			}
+
+			if g, e := manifest, tt.expectManifestContent; g != e {
+				t.Errorf("FilterContent(context.Background(), ) wrong manifest returned)\ngot %v\nexpected: %v\ndiff: %v", g, e,
+					cmp.Diff(g, e))
+			}
+		})
+	}
+}
+
+func TestHLSFilter_FilterContent_AdSkip(t *testing.T) {
+	vodManifest := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-PLAYLIST-TYPE:VOD
+#EXT-X-TARGETDURATION:6
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-KEY:METHOD=AES-128,URI="key_0.key"
+#EXTINF:6.000,
+segment_0.ts
+#EXT-OATCLS-SCTE35:/DAlAAAAAAAAAP/wFAUAAAABf+/+LRQrAP4BI9MIAAEBAQAAfxV6SQ==
+#EXT-X-CUE-OUT:12
+#EXT-X-DISCONTINUITY
+#EXTINF:6.000,
+ad_0.ts
+#EXT-X-CUE-OUT-CONT:ElapsedTime=6,Duration=12,SCTE35=/DAlAAAAAAAAAP/wFAUAAAABf+/+LRQrAP4BI9MIAAEBAQAAfxV6SQ==
+#EXT-X-KEY:METHOD=AES-128,URI="key_1.key"
+#EXTINF:6.000,
+ad_1.ts
+#EXT-X-CUE-IN
+#EXT-X-DISCONTINUITY
+#EXTINF:6.000,
+segment_1.ts
+#EXTINF:6.000,
+segment_2.ts
+#EXT-X-ENDLIST
+`
+
+	vodManifestWithoutAds := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-PLAYLIST-TYPE:VOD
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-TARGETDURATION:6
+#EXT-X-KEY:METHOD=AES-128,URI="https://existing.base/path/key_0.key"
+#EXTINF:6.000,
+https://existing.base/path/segment_0.ts
+#EXT-X-KEY:METHOD=AES-128,URI="https://existing.base/path/key_1.key"
+#EXT-X-DISCONTINUITY
+#EXTINF:6.000,
+https://existing.base/path/segment_1.ts
+#EXTINF:6.000,
+https://existing.base/path/segment_2.ts
+#EXT-X-ENDLIST
+`
+
+	liveManifest := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:6
+#EXT-X-MEDIA-SEQUENCE:200
+#EXT-X-DISCONTINUITY-SEQUENCE:4
+#EXT-X-PROGRAM-DATE-TIME:2020-06-01T10:00:00Z
+#EXT-X-CUE-OUT-CONT:ElapsedTime=12,Duration=18
+#EXTINF:6.000,
+ad_2.ts
+#EXT-X-CUE-IN
+#EXTINF:6.000,
+segment_201.ts
+#EXT-X-CUE-OUT:DURATION=12
+#EXTINF:6.000,
+ad_3.ts
+#EXTINF:6.000,
+ad_4.ts
+#EXTINF:6.000,
+segment_204.ts
+`
+
+	liveManifestAsVODWithoutAds := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-PLAYLIST-TYPE:VOD
+#EXT-X-MEDIA-SEQUENCE:200
+#EXT-X-TARGETDURATION:6
+#EXT-X-DISCONTINUITY-SEQUENCE:4
+#EXT-X-DISCONTINUITY
+#EXT-X-PROGRAM-DATE-TIME:2020-06-01T10:00:06Z
+#EXTINF:6.000,
+https://existing.base/path/segment_201.ts
+#EXT-X-DISCONTINUITY
+#EXT-X-PROGRAM-DATE-TIME:2020-06-01T10:00:24Z
+#EXTINF:6.000,
+https://existing.base/path/segment_204.ts
+#EXT-X-ENDLIST
+`
+
+	dateRangeManifest := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-PLAYLIST-TYPE:EVENT
+#EXT-X-TARGETDURATION:6
+#EXT-X-MEDIA-SEQUENCE:200
+#EXT-X-PROGRAM-DATE-TIME:2020-06-01T10:00:00Z
+#EXTINF:6.000,
+segment_200.ts
+#EXT-X-DATERANGE:ID="splice-1",START-DATE="2020-06-01T10:00:06.000Z",PLANNED-DURATION=12,SCTE35-OUT=0xFC302500000000000000FFF01405000000017FEFFE2D142B00FE0123D3080001010100007F157A49
+#EXTINF:6.000,
+ad_0.ts
+#EXTINF:6.000,
+ad_1.ts
+#EXTINF:6.000,
+segment_203.ts
+`
+
+	dateRangeManifestWithoutAds := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-PLAYLIST-TYPE:EVENT
+#EXT-X-ALLOW-CACHE:NO
+#EXT-X-MEDIA-SEQUENCE:200
+#EXT-X-TARGETDURATION:6
+#EXT-X-PROGRAM-DATE-TIME:2020-06-01T10:00:00Z
+#EXTINF:6.000,
+https://existing.base/path/segment_200.ts
+#EXT-X-DISCONTINUITY
+#EXT-X-PROGRAM-DATE-TIME:2020-06-01T10:00:18Z
+#EXTINF:6.000,
+https://existing.base/path/segment_203.ts
+`
+
+	tests := []struct {
+		name                  string
+		filters               *parsers.MediaFilters
+		manifestContent       string
+		expectManifestContent string
+		expectErr             bool
+	}{
+		{
+			name:                  "when a vod playlist has an ad break, expect its segments to be removed and the key to carry over",
+			filters:               &parsers.MediaFilters{AdSkip: true},
+			manifestContent:       vodManifest,
+			expectManifestContent: vodManifestWithoutAds,
+		},
+		{
+			name: "when a live playlist converted to vod starts within an ad break, expect the segments of every " +
+				"break to be removed",
+			filters:               &parsers.MediaFilters{AdSkip: true, PlaylistType: parsers.PlaylistTypeVOD},
+			manifestContent:       liveManifest,
+			expectManifestContent: liveManifestAsVODWithoutAds,
+		},
+		{
+			name:            "when a playlist is live, expect an error as segments would be numbered again",
+			filters:         &parsers.MediaFilters{AdSkip: true},
+			manifestContent: liveManifest,
+			expectErr:       true,
+		},
+		{
+			name:                  "when an ad break is signaled by a date range, expect the break to end after its planned duration",
+			filters:               &parsers.MediaFilters{AdSkip: true},
+			manifestContent:       dateRangeManifest,
+			expectManifestContent: dateRangeManifestWithoutAds,
+		},
+		{
+			name:            "when an event playlist is kept within a dvr window, expect an error",
+			filters:         &parsers.MediaFilters{AdSkip: true, DVR: 12},
+			manifestContent: dateRangeManifest,
+			expectErr:       true,
+		},
+		{
+			name:                  "when ad cues are also translated, expect ad skipping to take precedence",
+			filters:               &parsers.MediaFilters{AdSkip: true, SCTE: parsers.SCTECue},
+			manifestContent:       vodManifest,
+			expectManifestContent: vodManifestWithoutAds,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			filter := NewHLSFilter("https://existing.base/path/720p.m3u8", tt.manifestContent, config.Config{})
+			manifest, err := filter.FilterContent(context.Background(), tt.filters)
+			if err != nil && !tt.expectErr {
+				t.Fatalf("FilterContent(context.Background(), ) didnt expect an error to be returned, got: %v", err)
+			} else if err == nil && tt.expectErr {
+				t.Fatal("FilterContent(context.Background(), ) expected an error, got nil")
+			}
+
+			if g, e := manifest, tt.expectManifestContent; g != e {
+				t.Errorf("FilterContent(context.Background(), ) wrong manifest returned)\ngot %v\nexpected: %v\ndiff: %v", g, e,
+					cmp.Diff(g, e))
+			}
+		})
+	}
+}
+
+func TestHLSFilter_FilterContent_DRM(t *testing.T) {
+	mediaManifest := `#EXTM3U
+#EXT-X-VERSION:5
+#EXT-X-PLAYLIST-TYPE:VOD
+#EXT-X-TARGETDURATION:6
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-KEY:METHOD=SAMPLE-AES,URI="skd://key_0",KEYFORMAT="com.apple.streamingkeydelivery",KEYFORMATVERSIONS="1"
+#EXT-X-KEY:METHOD=SAMPLE-AES,URI="data:text/plain;base64,AAAA",KEYFORMAT="urn:uuid:edef8ba9-79d6-4ace-a3c8-27dcd51d21ed",KEYFORMATVERSIONS="1"
+#EXT-X-KEY:METHOD=SAMPLE-AES,URI="playready/key_0",KEYFORMAT="com.microsoft.playready",KEYFORMATVERSIONS="1"
+#EXTINF:6.000,
+segment_0.ts
+#EXTINF:6.000,
+segment_1.ts
+#EXT-X-ENDLIST
+`
+
+	masterManifest := `#EXTM3U
+#EXT-X-VERSION:5
+#EXT-X-SESSION-KEY:METHOD=SAMPLE-AES,URI="skd://key_0",KEYFORMAT="com.apple.streamingkeydelivery",KEYFORMATVERSIONS="1"
+#EXT-X-SESSION-KEY:METHOD=SAMPLE-AES,URI="widevine/key_0",KEYFORMAT="urn:uuid:edef8ba9-79d6-4ace-a3c8-27dcd51d21ed",KEYFORMATVERSIONS="1"
+#EXT-X-STREAM-INF:BANDWIDTH=1000000,CODECS="avc1.64001e,mp4a.40.2",RESOLUTION=640x360
+fairplay.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=1000000,CODECS="avc1.64001e,mp4a.40.2",RESOLUTION=640x360
+widevine.m3u8
+`
+
+	mediaManifestWithAllKeys := `#EXTM3U
+#EXT-X-VERSION:5
+#EXT-X-PLAYLIST-TYPE:VOD
+#EXT-X-TARGETDURATION:6
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-KEY:METHOD=SAMPLE-AES,URI="skd://key_0",KEYFORMAT="com.apple.streamingkeydelivery",KEYFORMATVERSIONS="1"
+#EXT-X-KEY:METHOD=SAMPLE-AES,URI="data:text/plain;base64,AAAA",KEYFORMAT="urn:uuid:edef8ba9-79d6-4ace-a3c8-27dcd51d21ed",KEYFORMATVERSIONS="1"
+#EXT-X-KEY:METHOD=SAMPLE-AES,URI="https://existing.base/path/playready/key_0",KEYFORMAT="com.microsoft.playready",KEYFORMATVERSIONS="1"
+#EXTINF:6.000,
+https://existing.base/path/segment_0.ts
+#EXTINF:6.000,
+https://existing.base/path/segment_1.ts
+#EXT-X-ENDLIST
+`
+
+	mediaManifestWithFairPlayOnCDN := `#EXTM3U
+#EXT-X-VERSION:5
+#EXT-X-PLAYLIST-TYPE:VOD
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-TARGETDURATION:6
+#EXT-X-KEY:METHOD=SAMPLE-AES,URI="skd://key_0",KEYFORMAT="com.apple.streamingkeydelivery",KEYFORMATVERSIONS="1"
+#EXTINF:6.000,
+https://cbsi.akamaized.net/path/segment_0.ts
+#EXTINF:6.000,
+https://cbsi.akamaized.net/path/segment_1.ts
+#EXT-X-ENDLIST
+`
+
+	mediaManifestWithWidevineAndPlayReady := `#EXTM3U
+#EXT-X-VERSION:5
+#EXT-X-PLAYLIST-TYPE:VOD
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-TARGETDURATION:6
+#EXT-X-KEY:METHOD=SAMPLE-AES,URI="https://existing.base/path/playready/key_0",KEYFORMAT="com.microsoft.playready",KEYFORMATVERSIONS="1"
+#EXT-X-KEY:METHOD=SAMPLE-AES,URI="data:text/plain;base64,AAAA",KEYFORMAT="urn:uuid:edef8ba9-79d6-4ace-a3c8-27dcd51d21ed",KEYFORMATVERSIONS="1"
+#EXTINF:6.000,
+https://existing.base/path/segment_0.ts
+#EXTINF:6.000,
+https://existing.base/path/segment_1.ts
+#EXT-X-ENDLIST
+`
+
+	masterManifestWithSessionKeys := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-SESSION-KEY:METHOD=SAMPLE-AES,URI="skd://key_0",KEYFORMAT="com.apple.streamingkeydelivery",KEYFORMATVERSIONS="1"
+#EXT-X-SESSION-KEY:METHOD=SAMPLE-AES,URI="https://existing.base/path/widevine/key_0",KEYFORMAT="urn:uuid:edef8ba9-79d6-4ace-a3c8-27dcd51d21ed",KEYFORMATVERSIONS="1"
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000000,CODECS="avc1.64001e,mp4a.40.2",RESOLUTION=640x360
+https://existing.base/path/fairplay.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000000,CODECS="avc1.64001e,mp4a.40.2",RESOLUTION=640x360
+https://existing.base/path/widevine.m3u8
+`
+
+	masterManifestWithWidevine := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-SESSION-KEY:METHOD=SAMPLE-AES,URI="https://existing.base/path/widevine/key_0",KEYFORMAT="urn:uuid:edef8ba9-79d6-4ace-a3c8-27dcd51d21ed",KEYFORMATVERSIONS="1"
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000000,CODECS="avc1.64001e,mp4a.40.2",RESOLUTION=640x360
+https://bakery.cbsi.video/drm(widevine)/aHR0cHM6Ly9leGlzdGluZy5iYXNlL3BhdGgvd2lkZXZpbmUubTN1OA.m3u8
+`
+
+	masterManifestWithAudio := `#EXTM3U
+#EXT-X-VERSION:4
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aac-fp",NAME="English",LANGUAGE="en",URI="audio/fairplay.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aac-wv",NAME="English",LANGUAGE="en",URI="audio/widevine.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aac",NAME="English",LANGUAGE="en",URI="audio/en/widevine.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aac",NAME="Spanish",LANGUAGE="es",URI="audio/es/fairplay.m3u8"
+#EXT-X-STREAM-INF:BANDWIDTH=1000000,CODECS="avc1.64001e,mp4a.40.2",RESOLUTION=640x360,AUDIO="aac-fp"
+video_1.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=1000000,CODECS="avc1.64001e,mp4a.40.2",RESOLUTION=640x360,AUDIO="aac-wv"
+video_2.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=2000000,CODECS="avc1.64001e,mp4a.40.2",RESOLUTION=1280x720,AUDIO="aac"
+video_3.m3u8
+`
+
+	masterManifestWithWidevineAudio := `#EXTM3U
+#EXT-X-VERSION:4
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aac-wv",NAME="English",DEFAULT=NO,LANGUAGE="en",URI="https://bakery.cbsi.video/drm(widevine)/aHR0cHM6Ly9leGlzdGluZy5iYXNlL3BhdGgvYXVkaW8vd2lkZXZpbmUubTN1OA.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aac",NAME="English",DEFAULT=NO,LANGUAGE="en",URI="https://bakery.cbsi.video/drm(widevine)/aHR0cHM6Ly9leGlzdGluZy5iYXNlL3BhdGgvYXVkaW8vZW4vd2lkZXZpbmUubTN1OA.m3u8"
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000000,CODECS="avc1.64001e,mp4a.40.2",RESOLUTION=640x360,AUDIO="aac-wv"
+https://bakery.cbsi.video/drm(widevine)/aHR0cHM6Ly9leGlzdGluZy5iYXNlL3BhdGgvdmlkZW9fMi5tM3U4.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=2000000,CODECS="avc1.64001e,mp4a.40.2",RESOLUTION=1280x720,AUDIO="aac"
+https://bakery.cbsi.video/drm(widevine)/aHR0cHM6Ly9leGlzdGluZy5iYXNlL3BhdGgvdmlkZW9fMy5tM3U4.m3u8
+`
+
+	masterManifestWithWidevineOnCDN := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-SESSION-KEY:METHOD=SAMPLE-AES,URI="https://cbsi.akamaized.net/path/widevine/key_0",KEYFORMAT="urn:uuid:edef8ba9-79d6-4ace-a3c8-27dcd51d21ed",KEYFORMATVERSIONS="1"
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000000,CODECS="avc1.64001e,mp4a.40.2",RESOLUTION=640x360
+https://bakery.cbsi.video/cdn(akamai)/drm(widevine)/aHR0cHM6Ly9jYnNpLmFrYW1haXplZC5uZXQvcGF0aC93aWRldmluZS5tM3U4.m3u8
+`
+
+	// mockVariants responds to variant requests with a media playlist
+	// encrypted with the key of the drm system the variant is named after,
+	// or left clear. Only the origin serves variants.
+	mockVariants := func(req *http.Request) (*http.Response, error) {
+		if req.URL.Host != "existing.base" {
+			return &http.Response{
+				StatusCode: http.StatusNotFound,
+				Body:       ioutil.NopCloser(bytes.NewBufferString("")),
+				Header:     http.Header{},
+			}, nil
+		}
+
+		formats := map[string]string{
+			"fairplay.m3u8": "com.apple.streamingkeydelivery",
+			"widevine.m3u8": "urn:uuid:edef8ba9-79d6-4ace-a3c8-27dcd51d21ed",
+		}
+		var key string
+		if format, found := formats[path.Base(req.URL.Path)]; found {
+			key = fmt.Sprintf("#EXT-X-KEY:METHOD=SAMPLE-AES,URI=\"key_0\",KEYFORMAT=\"%v\",KEYFORMATVERSIONS=\"1\"\n", format)
+		}
+		variant := `#EXTM3U
+#EXT-X-VERSION:5
+#EXT-X-TARGETDURATION:6
+` + key + `#EXTINF:6.000,
+segment_0.ts
+`
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(variant)),
+			Header:     http.Header{},
+		}, nil
+	}
+
+	tests := []struct {
+		name                  string
+		filters               *parsers.MediaFilters
+		manifestURL           string
+		manifestContent       string
+		expectManifestContent string
+		expectErr             bool
+	}{
+		{
+			name:                  "when no drm system is given, expect every key to be kept",
+			filters:               &parsers.MediaFilters{},
+			manifestURL:           "https://existing.base/path/720p.m3u8",
+			manifestContent:       mediaManifest,
+			expectManifestContent: mediaManifestWithAllKeys,
+		},
+		{
+			name:                  "when fairplay is given with a cdn, expect only the fairplay key to be kept as is",
+			filters:               &parsers.MediaFilters{DRM: []string{parsers.DRMFairPlay}, CDN: "akamai"},
+			manifestURL:           "https://existing.base/path/720p.m3u8",
+			manifestContent:       mediaManifest,
+			expectManifestContent: mediaManifestWithFairPlayOnCDN,
+		},
+		{
+			name:                  "when widevine and playready are given, expect both keys to be kept",
+			filters:               &parsers.MediaFilters{DRM: []string{parsers.DRMWidevine, parsers.DRMPlayReady}},
+			manifestURL:           "https://existing.base/path/720p.m3u8",
+			manifestContent:       mediaManifest,
+			expectManifestContent: mediaManifestWithWidevineAndPlayReady,
+		},
+		{
+			name:            "when no key of an encrypted playlist is kept, expect an error",
+			filters:         &parsers.MediaFilters{DRM: []string{parsers.DRMClear}},
+			manifestURL:     "https://existing.base/path/720p.m3u8",
+			manifestContent: mediaManifest,
+			expectErr:       true,
+		},
+		{
+			name:                  "when no drm system is given, expect the session keys of a master playlist to be kept",
+			filters:               &parsers.MediaFilters{},
+			manifestURL:           "https://existing.base/path/master.m3u8",
+			manifestContent:       masterManifest,
+			expectManifestContent: masterManifestWithSessionKeys,
+		},
+		{
+			name:                  "when widevine is given, expect the fairplay session key and variant to be removed",
+			filters:               &parsers.MediaFilters{DRM: []string{parsers.DRMWidevine}},
+			manifestURL:           "https://existing.base/path/master.m3u8",
+			manifestContent:       masterManifest,
+			expectManifestContent: masterManifestWithWidevine,
+		},
+		{
+			name:                  "when widevine is given with a cdn, expect the variants to be checked at the origin",
+			filters:               &parsers.MediaFilters{DRM: []string{parsers.DRMWidevine}, CDN: "akamai"},
+			manifestURL:           "https://existing.base/path/master.m3u8",
+			manifestContent:       masterManifest,
+			expectManifestContent: masterManifestWithWidevineOnCDN,
+		},
+		{
+			name: "when widevine is given, expect fairplay audio alternatives to be removed along with the variants " +
+				"left without audio",
+			filters:               &parsers.MediaFilters{DRM: []string{parsers.DRMWidevine}},
+			manifestURL:           "https://existing.base/path/master.m3u8",
+			manifestContent:       masterManifestWithAudio,
+			expectManifestContent: masterManifestWithWidevineAudio,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Config{
+				Hostname: "bakery.cbsi.video",
+				CDNs: config.CDNs{
+					Hosts: map[string]*url.URL{
+						"akamai": {Scheme: "https", Host: "cbsi.akamaized.net"},
+					},
+				},
+				Client: config.Client{
+					Timeout:    5 * time.Second,
+					Tracer:     tracing.NoopTracer{},
+					HTTPClient: test.MockClient(mockVariants),
+				},
+			}
+			variantKeyFormats = newKeyFormatChecks()
+			filter := NewHLSFilter(tt.manifestURL, tt.manifestContent, cfg)
+			manifest, err := filter.FilterContent(context.Background(), tt.filters)
+
+			if err != nil && !tt.expectErr {
+				t.Fatalf("FilterContent(context.Background(), ) didnt expect an error to be returned, got: %v", err)
+			} else if err == nil && tt.expectErr {
+				t.Fatal("FilterContent(context.Background(), ) expected an error, got nil")
+			}
+
+			if g, e := manifest, tt.expectManifestContent; g != e {
+				t.Errorf("FilterContent(context.Background(), ) wrong manifest returned)\ngot %v\nexpected: %v\ndiff: %v", g, e,
+					cmp.Diff(g, e))
+			}
+		})
+	}
+}
+
+func TestHLSFilter_FilterContent_PreventHTTPError(t *testing.T) {
+	variantManifestContent := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-MEDIA-SEQUENCE:17738
+#EXT-X-TARGETDURATION:8
+#EXT-X-PROGRAM-DATE-TIME:2021-02-23T05:59:55.633Z
+#EXTINF:6.000,
+http://bakery.cbsi.video/phe(true)/aHR0cHM6Ly9kaWN0YTU0ODczYTIuYWlyc3BhY2UtY2RuLmNic2l2aWRlby5jb20va2VpdGhoOTdoLXRlc3QvZW4vY2FwdGlvbnNfMTc3MzgudnR0.vtt
+#EXT-X-PROGRAM-DATE-TIME:2021-02-23T06:00:01.633Z
+#EXTINF:6.000,
+http://bakery.cbsi.video/phe(true)/aHR0cHM6Ly9kaWN0YTU0ODczYTIuYWlyc3BhY2UtY2RuLmNic2l2aWRlby5jb20va2VpdGhoOTdoLXRlc3QvZW4vY2FwdGlvbnNfMTc3MzkudnR0.vtt
+#EXT-X-PROGRAM-DATE-TIME:2021-02-23T06:00:07.633Z
+#EXTINF:6.000,
+http://bakery.cbsi.video/phe(true)/aHR0cHM6Ly9kaWN0YTU0ODczYTIuYWlyc3BhY2UtY2RuLmNic2l2aWRlby5jb20va2VpdGhoOTdoLXRlc3QvZW4vY2FwdGlvbnNfMTc3NDAudnR0.vtt
+#EXT-X-PROGRAM-DATE-TIME:2021-02-23T06:00:13.633Z
+#EXTINF:6.000,
+http://bakery.cbsi.video/phe(true)/aHR0cHM6Ly9kaWN0YTU0ODczYTIuYWlyc3BhY2UtY2RuLmNic2l2aWRlby5jb20va2VpdGhoOTdoLXRlc3QvZW4vY2FwdGlvbnNfMTc3NDEudnR0.vtt
+#EXT-X-PROGRAM-DATE-TIME:2021-02-23T06:00:19.633Z
+#EXTINF:6.000,
+http://bakery.cbsi.video/phe(true)/aHR0cHM6Ly9kaWN0YTU0ODczYTIuYWlyc3BhY2UtY2RuLmNic2l2aWRlby5jb20va2VpdGhoOTdoLXRlc3QvZW4vY2FwdGlvbnNfMTc3NDIudnR0.vtt
+#EXT-X-PROGRAM-DATE-TIME:2021-02-23T06:00:25.633Z
+#EXTINF:6.000,
+http://bakery.cbsi.video/phe(true)/aHR0cHM6Ly9kaWN0YTU0ODczYTIuYWlyc3BhY2UtY2RuLmNic2l2aWRlby5jb20va2VpdGhoOTdoLXRlc3QvZW4vY2FwdGlvbnNfMTc3NDMudnR0.vtt
+`
+
+	expectedFilteredManifestWithTrimFilter := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-TARGETDURATION:6
+#EXT-X-PROGRAM-DATE-TIME:2021-02-23T06:00:07.633Z
+#EXTINF:6.000,
+http://bakery.cbsi.video/phe(true)/aHR0cHM6Ly9kaWN0YTU0ODczYTIuYWlyc3BhY2UtY2RuLmNic2l2aWRlby5jb20va2VpdGhoOTdoLXRlc3QvZW4vY2FwdGlvbnNfMTc3NDAudnR0.vtt
+#EXT-X-PROGRAM-DATE-TIME:2021-02-23T06:00:13.633Z
+#EXTINF:6.000,
+http://bakery.cbsi.video/phe(true)/aHR0cHM6Ly9kaWN0YTU0ODczYTIuYWlyc3BhY2UtY2RuLmNic2l2aWRlby5jb20va2VpdGhoOTdoLXRlc3QvZW4vY2FwdGlvbnNfMTc3NDEudnR0.vtt
+#EXT-X-PROGRAM-DATE-TIME:2021-02-23T06:00:19.633Z
+#EXTINF:6.000,
+http://bakery.cbsi.video/phe(true)/aHR0cHM6Ly9kaWN0YTU0ODczYTIuYWlyc3BhY2UtY2RuLmNic2l2aWRlby5jb20va2VpdGhoOTdoLXRlc3QvZW4vY2FwdGlvbnNfMTc3NDIudnR0.vtt
+#EXT-X-ENDLIST
+`
+
+	longWebvttManifest, err := ioutil.ReadFile("../tests/long_vtt_manifest.m3u8")
+	if err != nil {
+		panic(err)
+	}
+	longWebvttManifestContent := string(longWebvttManifest)
+
+	tests := []struct {
+		name                  string
+		filters               *parsers.MediaFilters
+		manifestContent       string
+		expectManifestContent string
+	}{
+		{
+			name:                  "when preventing http error on rendition manifest, should not apply trim filter if not present",
+			filters:               &parsers.MediaFilters{},
+			manifestContent:       variantManifestContent,
+			expectManifestContent: variantManifestContent,
+		},
+		{
+			name:                  "when preventing http error on rendition manifest, should be able to return long manifest",
+			filters:               &parsers.MediaFilters{},
+			manifestContent:       longWebvttManifestContent,
+			expectManifestContent: longWebvttManifestContent,
+		},
+		{
+			name: "when preventing http error on rendition manifest, should apply trim filter",
+			filters: &parsers.MediaFilters{
+				Trim: &parsers.Trim{
+					Start: 1614060008, // 2021-02-23T06:00:08
+					End:   1614060020, // 2021-02-23T06:00:20
+				},
+			},
+			manifestContent:       variantManifestContent,
+			expectManifestContent: expectedFilteredManifestWithTrimFilter,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			filter := NewHLSFilter("https://existing.base/path/variant.m3u8", tt.manifestContent, config.Config{Hostname: "bakery.cbsi.video"})
+			manifest, err := filter.FilterContent(context.Background(), tt.filters)
+
+			if err != nil {
+				t.Errorf("FilterContent(context.Background(), ) got an error: %v", err)
+				return
+			}
+
+			if g, e := manifest, tt.expectManifestContent; g != e {
+				t.Errorf("FilterContent(context.Background(), ) wrong manifest returned)\ngot %v\nexpected: %v\ndiff: %v", g, e,
+					cmp.Diff(g, e))
+			}
+		})
+	}
+}
+
+func TestHLSFilter_FilterContent_LanguageFilter(t *testing.T) {
+
+	masterManifestWithMultipleLangs := `#EXTM3U
+#EXT-X-VERSION:4
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio0",NAME="English",DEFAULT=YES,AUTOSELECT=YES,LANGUAGE="en",URI="https://existing.base/path/index-f8-a1.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio0",NAME="Dansk",DEFAULT=NO,AUTOSELECT=NO,LANGUAGE="da",URI="https://existing.base/path/index-f10-a1.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio0",NAME="Deutsch",DEFAULT=NO,AUTOSELECT=NO,LANGUAGE="de",URI="https://existing.base/path/index-f11-a1.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio0",NAME="Spanish (Latin America)",DEFAULT=NO,AUTOSELECT=NO,LANGUAGE="es",URI="https://existing.base/path/index-f12-a1.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio0",NAME="Brazilian Portuguese",DEFAULT=NO,AUTOSELECT=NO,LANGUAGE="pt",URI="https://existing.base/path/index-f16-a1.m3u8"
+#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs0",NAME="English",DEFAULT=YES,AUTOSELECT=YES,LANGUAGE="en",URI="https://existing.base/path/index-f19.m3u8"
+#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=277965,CODECS="avc1.4d401e,mp4a.40.2",RESOLUTION=384x216,AUDIO="audio0",SUBTITLES="subs0",FRAME-RATE=25.000,VIDEO-RANGE=SDR
+https://existing.base/path/index-f1-v1.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=479857,CODECS="avc1.4d401e,mp4a.40.2",RESOLUTION=512x288,AUDIO="audio0",SUBTITLES="subs0",FRAME-RATE=25.000,VIDEO-RANGE=SDR
+https://existing.base/path/index-f2-v1.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=786893,CODECS="avc1.4d401e,mp4a.40.2",RESOLUTION=640x360,AUDIO="audio0",SUBTITLES="subs0",FRAME-RATE=25.000,VIDEO-RANGE=SDR
+https://existing.base/path/index-f3-v1.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=1015563,CODECS="avc1.4d401e,mp4a.40.2",RESOLUTION=768x432,AUDIO="audio0",SUBTITLES="subs0",FRAME-RATE=25.000,VIDEO-RANGE=SDR
+https://existing.base/path/index-f4-v1.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=1421115,CODECS="avc1.4d401e,mp4a.40.2",RESOLUTION=960x540,AUDIO="audio0",SUBTITLES="subs0",FRAME-RATE=25.000,VIDEO-RANGE=SDR
+https://existing.base/path/index-f5-v1.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=1956642,CODECS="avc1.640028,mp4a.40.2",RESOLUTION=1280x720,AUDIO="audio0",SUBTITLES="subs0",FRAME-RATE=25.000,VIDEO-RANGE=SDR
+https://existing.base/path/index-f6-v1.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=3712583,CODECS="avc1.640028,mp4a.40.2",RESOLUTION=1920x1080,AUDIO="audio0",SUBTITLES="subs0",FRAME-RATE=25.000,VIDEO-RANGE=SDR
+https://existing.base/path/index-f7-v1.m3u8
+`
+
+	masterManifestWithEnglishOnly := `#EXTM3U
+#EXT-X-VERSION:4
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio0",NAME="English",DEFAULT=YES,AUTOSELECT=YES,LANGUAGE="en",URI="https://existing.base/path/index-f8-a1.m3u8"
+#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs0",NAME="English",DEFAULT=YES,AUTOSELECT=YES,LANGUAGE="en",URI="https://existing.base/path/index-f19.m3u8"
+#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=277965,CODECS="avc1.4d401e,mp4a.40.2",RESOLUTION=384x216,AUDIO="audio0",SUBTITLES="subs0",FRAME-RATE=25.000,VIDEO-RANGE=SDR
+https://existing.base/path/index-f1-v1.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=479857,CODECS="avc1.4d401e,mp4a.40.2",RESOLUTION=512x288,AUDIO="audio0",SUBTITLES="subs0",FRAME-RATE=25.000,VIDEO-RANGE=SDR
+https://existing.base/path/index-f2-v1.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=786893,CODECS="avc1.4d401e,mp4a.40.2",RESOLUTION=640x360,AUDIO="audio0",SUBTITLES="subs0",FRAME-RATE=25.000,VIDEO-RANGE=SDR
+https://existing.base/path/index-f3-v1.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=1015563,CODECS="avc1.4d401e,mp4a.40.2",RESOLUTION=768x432,AUDIO="audio0",SUBTITLES="subs0",FRAME-RATE=25.000,VIDEO-RANGE=SDR
+https://existing.base/path/index-f4-v1.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=1421115,CODECS="avc1.4d401e,mp4a.40.2",RESOLUTION=960x540,AUDIO="audio0",SUBTITLES="subs0",FRAME-RATE=25.000,VIDEO-RANGE=SDR
+https://existing.base/path/index-f5-v1.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=1956642,CODECS="avc1.640028,mp4a.40.2",RESOLUTION=1280x720,AUDIO="audio0",SUBTITLES="subs0",FRAME-RATE=25.000,VIDEO-RANGE=SDR
+https://existing.base/path/index-f6-v1.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=3712583,CODECS="avc1.640028,mp4a.40.2",RESOLUTION=1920x1080,AUDIO="audio0",SUBTITLES="subs0",FRAME-RATE=25.000,VIDEO-RANGE=SDR
+https://existing.base/path/index-f7-v1.m3u8
+`
+
+	masterManifestWithSpanishAndPortugeseAndNoSubtitles := `#EXTM3U
+#EXT-X-VERSION:4
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio0",NAME="Spanish (Latin America)",DEFAULT=NO,AUTOSELECT=NO,LANGUAGE="es",URI="https://existing.base/path/index-f12-a1.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio0",NAME="Brazilian Portuguese",DEFAULT=NO,AUTOSELECT=NO,LANGUAGE="pt",URI="https://existing.base/path/index-f16-a1.m3u8"
+#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=277965,CODECS="avc1.4d401e,mp4a.40.2",RESOLUTION=384x216,AUDIO="audio0",FRAME-RATE=25.000,VIDEO-RANGE=SDR
+https://existing.base/path/index-f1-v1.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=479857,CODECS="avc1.4d401e,mp4a.40.2",RESOLUTION=512x288,AUDIO="audio0",FRAME-RATE=25.000,VIDEO-RANGE=SDR
+https://existing.base/path/index-f2-v1.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=786893,CODECS="avc1.4d401e,mp4a.40.2",RESOLUTION=640x360,AUDIO="audio0",FRAME-RATE=25.000,VIDEO-RANGE=SDR
+https://existing.base/path/index-f3-v1.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=1015563,CODECS="avc1.4d401e,mp4a.40.2",RESOLUTION=768x432,AUDIO="audio0",FRAME-RATE=25.000,VIDEO-RANGE=SDR
+https://existing.base/path/index-f4-v1.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=1421115,CODECS="avc1.4d401e,mp4a.40.2",RESOLUTION=960x540,AUDIO="audio0",FRAME-RATE=25.000,VIDEO-RANGE=SDR
+https://existing.base/path/index-f5-v1.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=1956642,CODECS="avc1.640028,mp4a.40.2",RESOLUTION=1280x720,AUDIO="audio0",FRAME-RATE=25.000,VIDEO-RANGE=SDR
+https://existing.base/path/index-f6-v1.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=3712583,CODECS="avc1.640028,mp4a.40.2",RESOLUTION=1920x1080,AUDIO="audio0",FRAME-RATE=25.000,VIDEO-RANGE=SDR
+https://existing.base/path/index-f7-v1.m3u8
+`
+
+	masterManifestWithEnglishSubsAndCaptions := `#EXTM3U
+#EXT-X-VERSION:4
+#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",NAME="English",DEFAULT=YES,AUTOSELECT=YES,LANGUAGE="eng",FORCED="NO",URI="https://devcarrier-hls.amlg.io/captions/737e9315-fec6-4809-bd5e-3f2e427ec3a1/en.m3u8"
+#EXT-X-MEDIA:TYPE=CLOSED-CAPTIONS,GROUP-ID="CC",NAME="ENGLISH",DEFAULT=NO,LANGUAGE="ENG"
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=356400,AVERAGE-BANDWIDTH=345400,CODECS="avc1.640015,mp4a.40.2",RESOLUTION=400x244,CLOSED-CAPTIONS="CC",SUBTITLES="subs",FRAME-RATE=29.000
+https://cbss64eb-cbss64eb-ms-dev.global.ssl.fastly.net/cbssc0a7/master/cbssc0a7_1.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=642400,AVERAGE-BANDWIDTH=620400,CODECS="avc1.640015,mp4a.40.2",RESOLUTION=512x288,CLOSED-CAPTIONS="CC",SUBTITLES="subs",FRAME-RATE=29.000
+https://cbss64eb-cbss64eb-ms-dev.global.ssl.fastly.net/cbssc0a7/master/cbssc0a7_2.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=985600,AVERAGE-BANDWIDTH=950400,CODECS="avc1.64001e,mp4a.40.2",RESOLUTION=640x360,CLOSED-CAPTIONS="CC",SUBTITLES="subs",FRAME-RATE=29.000
+https://cbss64eb-cbss64eb-ms-dev.global.ssl.fastly.net/cbssc0a7/master/cbssc0a7_3.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1443200,AVERAGE-BANDWIDTH=1390400,CODECS="avc1.64001e,mp4a.40.2",RESOLUTION=768x432,CLOSED-CAPTIONS="CC",SUBTITLES="subs",FRAME-RATE=29.000
+https://cbss64eb-cbss64eb-ms-dev.global.ssl.fastly.net/cbssc0a7/master/cbssc0a7_4.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=2129600,AVERAGE-BANDWIDTH=2050400,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=960x540,CLOSED-CAPTIONS="CC",SUBTITLES="subs",FRAME-RATE=29.000
+https://cbss64eb-cbss64eb-ms-dev.global.ssl.fastly.net/cbssc0a7/master/cbssc0a7_5.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4074400,AVERAGE-BANDWIDTH=3920400,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=1280x720,CLOSED-CAPTIONS="CC",SUBTITLES="subs",FRAME-RATE=29.000
+https://cbss64eb-cbss64eb-ms-dev.global.ssl.fastly.net/cbssc0a7/master/cbssc0a7_6.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=5790400,AVERAGE-BANDWIDTH=5570400,CODECS="avc1.640028,mp4a.40.2",RESOLUTION=1920x1080,CLOSED-CAPTIONS="CC",SUBTITLES="subs",FRAME-RATE=29.000
+https://cbss64eb-cbss64eb-ms-dev.global.ssl.fastly.net/cbssc0a7/master/cbssc0a7_7.m3u8
+`
+
+	masterManifestWithNoEnglishSubsAndCaptions := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=356400,AVERAGE-BANDWIDTH=345400,CODECS="avc1.640015,mp4a.40.2",RESOLUTION=400x244,FRAME-RATE=29.000
+https://cbss64eb-cbss64eb-ms-dev.global.ssl.fastly.net/cbssc0a7/master/cbssc0a7_1.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=642400,AVERAGE-BANDWIDTH=620400,CODECS="avc1.640015,mp4a.40.2",RESOLUTION=512x288,FRAME-RATE=29.000
+https://cbss64eb-cbss64eb-ms-dev.global.ssl.fastly.net/cbssc0a7/master/cbssc0a7_2.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=985600,AVERAGE-BANDWIDTH=950400,CODECS="avc1.64001e,mp4a.40.2",RESOLUTION=640x360,FRAME-RATE=29.000
+https://cbss64eb-cbss64eb-ms-dev.global.ssl.fastly.net/cbssc0a7/master/cbssc0a7_3.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1443200,AVERAGE-BANDWIDTH=1390400,CODECS="avc1.64001e,mp4a.40.2",RESOLUTION=768x432,FRAME-RATE=29.000
+https://cbss64eb-cbss64eb-ms-dev.global.ssl.fastly.net/cbssc0a7/master/cbssc0a7_4.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=2129600,AVERAGE-BANDWIDTH=2050400,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=960x540,FRAME-RATE=29.000
+https://cbss64eb-cbss64eb-ms-dev.global.ssl.fastly.net/cbssc0a7/master/cbssc0a7_5.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4074400,AVERAGE-BANDWIDTH=3920400,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=1280x720,FRAME-RATE=29.000
+https://cbss64eb-cbss64eb-ms-dev.global.ssl.fastly.net/cbssc0a7/master/cbssc0a7_6.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=5790400,AVERAGE-BANDWIDTH=5570400,CODECS="avc1.640028,mp4a.40.2",RESOLUTION=1920x1080,FRAME-RATE=29.000
+https://cbss64eb-cbss64eb-ms-dev.global.ssl.fastly.net/cbssc0a7/master/cbssc0a7_7.m3u8
+`
+
+	masterManifestWithRegionalLangs := `#EXTM3U
+#EXT-X-VERSION:4
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio0",NAME="English (US)",DEFAULT=YES,AUTOSELECT=YES,LANGUAGE="en-US",URI="https://existing.base/path/index-en-us.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio0",NAME="English (UK)",DEFAULT=NO,AUTOSELECT=NO,LANGUAGE="EN-gb",URI="https://existing.base/path/index-en-gb.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio0",NAME="Brazilian Portuguese",DEFAULT=NO,AUTOSELECT=NO,LANGUAGE="pt-BR",URI="https://existing.base/path/index-pt-br.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio0",NAME="Portuguese",DEFAULT=NO,AUTOSELECT=NO,LANGUAGE="por",URI="https://existing.base/path/index-pt.m3u8"
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000,AVERAGE-BANDWIDTH=1000,CODECS="avc1.64001f,mp4a.40.2",AUDIO="audio0"
+https://existing.base/path/link_1.m3u8
+`
+
+	masterManifestWithPortugueseOnly := `#EXTM3U
+#EXT-X-VERSION:4
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio0",NAME="Brazilian Portuguese",DEFAULT=NO,AUTOSELECT=NO,LANGUAGE="pt-BR",URI="https://existing.base/path/index-pt-br.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio0",NAME="Portuguese",DEFAULT=NO,AUTOSELECT=NO,LANGUAGE="por",URI="https://existing.base/path/index-pt.m3u8"
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000,AVERAGE-BANDWIDTH=1000,CODECS="avc1.64001f,mp4a.40.2",AUDIO="audio0"
+https://existing.base/path/link_1.m3u8
+`
+
+	masterManifestWithoutBrazilianPortuguese := `#EXTM3U
+#EXT-X-VERSION:4
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio0",NAME="English (US)",DEFAULT=YES,AUTOSELECT=YES,LANGUAGE="en-US",URI="https://existing.base/path/index-en-us.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio0",NAME="English (UK)",DEFAULT=NO,AUTOSELECT=NO,LANGUAGE="EN-gb",URI="https://existing.base/path/index-en-gb.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio0",NAME="Portuguese",DEFAULT=NO,AUTOSELECT=NO,LANGUAGE="por",URI="https://existing.base/path/index-pt.m3u8"
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000,AVERAGE-BANDWIDTH=1000,CODECS="avc1.64001f,mp4a.40.2",AUDIO="audio0"
+https://existing.base/path/link_1.m3u8
+`
+
+	masterManifestWithoutAmericanEnglishAndPortuguese := `#EXTM3U
+#EXT-X-VERSION:4
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio0",NAME="English (UK)",DEFAULT=NO,AUTOSELECT=NO,LANGUAGE="EN-gb",URI="https://existing.base/path/index-en-gb.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio0",NAME="Brazilian Portuguese",DEFAULT=NO,AUTOSELECT=NO,LANGUAGE="pt-BR",URI="https://existing.base/path/index-pt-br.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio0",NAME="Portuguese",DEFAULT=NO,AUTOSELECT=NO,LANGUAGE="por",URI="https://existing.base/path/index-pt.m3u8"
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000,AVERAGE-BANDWIDTH=1000,CODECS="avc1.64001f,mp4a.40.2",AUDIO="audio0"
+https://existing.base/path/link_1.m3u8
+`
+
+	tests := []struct {
+		name                  string
+		filters               *parsers.MediaFilters
+		manifestContent       string
+		expectManifestContent string
+		expectErr             bool
+	}{
+		{
+			name:                  "when no filters are passed, nothing is removed",
+			filters:               &parsers.MediaFilters{},
+			manifestContent:       masterManifestWithMultipleLangs,
+			expectManifestContent: masterManifestWithMultipleLangs,
+		},
+		{
+			name: "when multiple audio languages are passed in, remove all those languages of type audio",
+			filters: &parsers.MediaFilters{
+				Audios: parsers.NestedFilters{
+					Language: []string{"da", "de", "es", "pt"},
+				},
+			},
+			manifestContent:       masterManifestWithMultipleLangs,
+			expectManifestContent: masterManifestWithEnglishOnly,
+		},
+		{
+			name: "when multiple audio and caption languages are passed in, remove provided audio and caption languages ",
+			filters: &parsers.MediaFilters{
+				Audios: parsers.NestedFilters{
+					Language: []string{"da", "de", "en"},
+				},
+				Captions: parsers.NestedFilters{
+					Language: []string{"en"},
+				},
+			},
+			manifestContent:       masterManifestWithMultipleLangs,
+			expectManifestContent: masterManifestWithSpanishAndPortugeseAndNoSubtitles,
+		},
+		{
+			name: "when caption languages are passed in, remove provided subtitles and caption languages ",
+			filters: &parsers.MediaFilters{
+				Captions: parsers.NestedFilters{
+					Language: []string{"eng"},
+				},
+			},
+			manifestContent:       masterManifestWithEnglishSubsAndCaptions,
+			expectManifestContent: masterManifestWithNoEnglishSubsAndCaptions,
+		},
+		{
+			name: "when a two letter caption language is passed in, remove subtitles and captions with its three letter code",
+			filters: &parsers.MediaFilters{
+				Captions: parsers.NestedFilters{
+					Language: []string{"en"},
+				},
+			},
+			manifestContent:       masterManifestWithEnglishSubsAndCaptions,
+			expectManifestContent: masterManifestWithNoEnglishSubsAndCaptions,
+		},
+		{
+			name: "when a primary language is passed in, remove all of its regional variants",
+			filters: &parsers.MediaFilters{
+				Audios: parsers.NestedFilters{
+					Language: []string{"en"},
+				},
+			},
+			manifestContent:       masterManifestWithRegionalLangs,
+			expectManifestContent: masterManifestWithPortugueseOnly,
+		},
+		{
+			name: "when a regional language is passed in, remove only that regional variant",
+			filters: &parsers.MediaFilters{
+				Audios: parsers.NestedFilters{
+					Language: []string{"pt-br"},
+				},
+			},
+			manifestContent:       masterManifestWithRegionalLangs,
+			expectManifestContent: masterManifestWithoutBrazilianPortuguese,
+		},
+		{
+			name: "when exact matching is set, remove only languages matching as they are",
+			filters: &parsers.MediaFilters{
+				Audios: parsers.NestedFilters{
+					Language:      []string{"en-us", "pt"},
+					ExactLanguage: true,
+				},
+			},
+			manifestContent:       masterManifestWithRegionalLangs,
+			expectManifestContent: masterManifestWithoutAmericanEnglishAndPortuguese,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			filter := NewHLSFilter("https://existing.base/path/master.m3u8", tt.manifestContent, config.Config{Hostname: "bakery.cbsi.video"})
+			manifest, err := filter.FilterContent(context.Background(), tt.filters)
+
+			if err != nil && !tt.expectErr {
+				t.Errorf("FilterContent(context.Background(), ) didnt expect an error to be returned, got: %v", err)
+				return
+			} else if err == nil && tt.expectErr {
+				t.Error("FilterContent(context.Background(), ) expected an error, got nil")
+				return
+			}
+
+			if g, e := manifest, tt.expectManifestContent; g != e {
+				t.Errorf("FilterContent(context.Background(), ) wrong manifest returned)\ngot %v\nexpected: %v\ndiff: %v", g, e,
+					cmp.Diff(g, e))
+			}
+
+		})
+	}
+}
+
+func TestHLSFilter_FilterContent_IFrameFilter(t *testing.T) {
+	masterManifestWithSingleIFrame := `#EXTM3U
+#EXT-X-VERSION:4
+#EXT-X-MEDIA:TYPE=CLOSED-CAPTIONS,GROUP-ID="CC",NAME="ENGLISH",DEFAULT=NO,LANGUAGE="ENG"
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000,AVERAGE-BANDWIDTH=1000,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC"
+https://existing.base/path/link_1.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4200,AVERAGE-BANDWIDTH=4200,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC"
+https://existing.base/path/link_2.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4000,AVERAGE-BANDWIDTH=4000,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC"
+https://existing.base/path/link_4.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4100,AVERAGE-BANDWIDTH=4100,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC"
+https://existing.base/path/link_5.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4500,AVERAGE-BANDWIDTH=4500,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC"
+https://existing.base/path/link_6.m3u8
+#EXT-X-I-FRAME-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=250,CODECS="avc1.4d401e",RESOLUTION=384x216,URI="https://existing.base/path/link_1.m3u8"
+`
+
+	masterManifestWithMultipleIFrame := `#EXTM3U
+#EXT-X-VERSION:4
+#EXT-X-MEDIA:TYPE=CLOSED-CAPTIONS,GROUP-ID="CC",NAME="ENGLISH",DEFAULT=NO,LANGUAGE="ENG"
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000,AVERAGE-BANDWIDTH=1000,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC"
+https://existing.base/path/link_1.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4200,AVERAGE-BANDWIDTH=4200,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC"
+https://existing.base/path/link_2.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4000,AVERAGE-BANDWIDTH=4000,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC"
+https://existing.base/path/link_4.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4100,AVERAGE-BANDWIDTH=4100,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC"
+https://existing.base/path/link_5.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4500,AVERAGE-BANDWIDTH=4500,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC"
+https://existing.base/path/link_6.m3u8
+#EXT-X-I-FRAME-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=250,CODECS="avc1.4d401e",RESOLUTION=384x216,URI="link_1.m3u8",
+#EXT-X-I-FRAME-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=550,CODECS="avc1.4d401e",RESOLUTION=512x288,URI="link_2.m3u8",
+`
+
+	masterManifestWithNoIframe := `#EXTM3U
+#EXT-X-VERSION:4
+#EXT-X-MEDIA:TYPE=CLOSED-CAPTIONS,GROUP-ID="CC",NAME="ENGLISH",DEFAULT=NO,LANGUAGE="ENG"
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000,AVERAGE-BANDWIDTH=1000,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC"
+https://existing.base/path/link_1.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4200,AVERAGE-BANDWIDTH=4200,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC"
+https://existing.base/path/link_2.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4000,AVERAGE-BANDWIDTH=4000,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC"
+https://existing.base/path/link_4.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4100,AVERAGE-BANDWIDTH=4100,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC"
+https://existing.base/path/link_5.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4500,AVERAGE-BANDWIDTH=4500,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC"
+https://existing.base/path/link_6.m3u8
+`
+	tests := []struct {
+		name                  string
+		filters               *parsers.MediaFilters
+		manifestContent       string
+		expectManifestContent string
+		expectErr             bool
+	}{
+		{
+			name:                  "when empty filter is given, expect no filtering to be done",
+			filters:               &parsers.MediaFilters{},
+			manifestContent:       masterManifestWithSingleIFrame,
+			expectManifestContent: masterManifestWithSingleIFrame,
+		},
+		{
+			name: "when iframe filter has been set, iframe is removed given a playlist with a single iframe",
+			filters: &parsers.MediaFilters{
+				Tags: &parsers.Tags{
+					IFrame: true,
+				},
+			},
+			manifestContent:       masterManifestWithSingleIFrame,
+			expectManifestContent: masterManifestWithNoIframe,
+		},
+		{
+			name: "when iframe filter has been set, iframe is removed given a playlist with mutliple iframe",
+			filters: &parsers.MediaFilters{
+				Tags: &parsers.Tags{
+					IFrame: true,
+				},
+			},
+			manifestContent:       masterManifestWithMultipleIFrame,
+			expectManifestContent: masterManifestWithNoIframe,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			filter := NewHLSFilter("https://existing.base/path/master.m3u8", tt.manifestContent, config.Config{Hostname: "bakery.cbsi.video"})
+			manifest, err := filter.FilterContent(context.Background(), tt.filters)
+
+			if err != nil && !tt.expectErr {
+				t.Errorf("FilterContent(context.Background(), ) didnt expect an error to be returned, got: %v", err)
+				return
+			} else if err == nil && tt.expectErr {
+				t.Error("FilterContent(context.Background(), ) expected an error, got nil")
+				return
+			}
+
+			if g, e := manifest, tt.expectManifestContent; g != e {
+				t.Errorf("FilterContent(context.Background(), ) wrong manifest returned)\ngot %v\nexpected: %v\ndiff: %v", g, e,
+					cmp.Diff(g, e))
+			}
+
+		})
+	}
+}
+
+func TestHLSFilter_FilterContent_NestedIFrameFilter(t *testing.T) {
+	masterManifest := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000,AVERAGE-BANDWIDTH=1000,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=640x360
+https://existing.base/path/link_1.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=2000,AVERAGE-BANDWIDTH=2000,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=1280x720
+https://existing.base/path/link_2.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4000,AVERAGE-BANDWIDTH=4000,CODECS="hvc1.2.4.L123.B0,mp4a.40.2",RESOLUTION=1920x1080
+https://existing.base/path/link_3.m3u8
+#EXT-X-I-FRAME-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=100,CODECS="avc1.4d401e",RESOLUTION=640x360,URI="https://existing.base/path/iframe_1.m3u8"
+#EXT-X-I-FRAME-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=200,CODECS="avc1.4d401e",RESOLUTION=1280x720,URI="https://existing.base/path/iframe_2.m3u8"
+#EXT-X-I-FRAME-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=400,CODECS="hvc1.2.4.L123.B0",RESOLUTION=1920x1080,URI="https://existing.base/path/iframe_3.m3u8"
+#EXT-X-I-FRAME-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=50,CODECS="avc1.4d401e",RESOLUTION=320x180,URI="https://existing.base/path/iframe_thumbnails.m3u8"
+`
+
+	masterManifestWithout1080pIFrame := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000,AVERAGE-BANDWIDTH=1000,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=640x360
+https://existing.base/path/link_1.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=2000,AVERAGE-BANDWIDTH=2000,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=1280x720
+https://existing.base/path/link_2.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4000,AVERAGE-BANDWIDTH=4000,CODECS="hvc1.2.4.L123.B0,mp4a.40.2",RESOLUTION=1920x1080
+https://existing.base/path/link_3.m3u8
+#EXT-X-I-FRAME-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=100,CODECS="avc1.4d401e",RESOLUTION=640x360,URI="https://existing.base/path/iframe_1.m3u8"
+#EXT-X-I-FRAME-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=200,CODECS="avc1.4d401e",RESOLUTION=1280x720,URI="https://existing.base/path/iframe_2.m3u8"
+#EXT-X-I-FRAME-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=50,CODECS="avc1.4d401e",RESOLUTION=320x180,URI="https://existing.base/path/iframe_thumbnails.m3u8"
+`
+
+	masterManifestUpTo720p := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000,AVERAGE-BANDWIDTH=1000,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=640x360
+https://existing.base/path/link_1.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=2000,AVERAGE-BANDWIDTH=2000,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=1280x720
+https://existing.base/path/link_2.m3u8
+#EXT-X-I-FRAME-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=100,CODECS="avc1.4d401e",RESOLUTION=640x360,URI="https://existing.base/path/iframe_1.m3u8"
+#EXT-X-I-FRAME-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=200,CODECS="avc1.4d401e",RESOLUTION=1280x720,URI="https://existing.base/path/iframe_2.m3u8"
+#EXT-X-I-FRAME-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=50,CODECS="avc1.4d401e",RESOLUTION=320x180,URI="https://existing.base/path/iframe_thumbnails.m3u8"
+`
+
+	masterManifestLowestRendition := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000,AVERAGE-BANDWIDTH=1000,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=640x360
+https://existing.base/path/link_1.m3u8
+#EXT-X-I-FRAME-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=100,CODECS="avc1.4d401e",RESOLUTION=640x360,URI="https://existing.base/path/iframe_1.m3u8"
+#EXT-X-I-FRAME-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=50,CODECS="avc1.4d401e",RESOLUTION=320x180,URI="https://existing.base/path/iframe_thumbnails.m3u8"
+`
+
+	tests := []struct {
+		name                  string
+		filters               *parsers.MediaFilters
+		manifestContent       string
+		expectManifestContent string
+		expectErr             bool
+	}{
+		{
+			name:                  "when no filter is given, expect i-frame variants to pass through unchanged",
+			filters:               &parsers.MediaFilters{},
+			manifestContent:       masterManifest,
+			expectManifestContent: masterManifest,
+		},
+		{
+			name: "when a filter keeps every variant, expect i-frame variants to pass through unchanged",
+			filters: &parsers.MediaFilters{
+				Videos: parsers.NestedFilters{
+					Codecs: []string{"dvh"},
+				},
+			},
+			manifestContent:       masterManifest,
+			expectManifestContent: masterManifest,
+		},
+		{
+			name: "when an i-frame bitrate range is set, expect i-frame variants out of range to be removed",
+			filters: &parsers.MediaFilters{
+				IFrames: parsers.NestedFilters{
+					Bitrate: &parsers.Bitrate{Min: 0, Max: 200},
+				},
+			},
+			manifestContent:       masterManifest,
+			expectManifestContent: masterManifestWithout1080pIFrame,
+		},
+		{
+			name: "when an i-frame codec is set, expect i-frame variants with the codec to be removed",
+			filters: &parsers.MediaFilters{
+				IFrames: parsers.NestedFilters{
+					Codecs: []string{"hvc"},
+				},
+			},
+			manifestContent:       masterManifest,
+			expectManifestContent: masterManifestWithout1080pIFrame,
+		},
+		{
+			name: "when an i-frame resolution range is set, expect only i-frame variants out of range to be removed",
+			filters: &parsers.MediaFilters{
+				IFrames: parsers.NestedFilters{
+					Height: &parsers.Resolution{Min: 0, Max: 720},
+				},
+			},
+			manifestContent:       masterManifest,
+			expectManifestContent: masterManifestWithout1080pIFrame,
+		},
+		{
+			name: "when variants are removed, expect their i-frame variants to be removed and unmatched i-frame variants kept",
+			filters: &parsers.MediaFilters{
+				Videos: parsers.NestedFilters{
+					Height: &parsers.Resolution{Min: 0, Max: 720},
+				},
+			},
+			manifestContent:       masterManifest,
+			expectManifestContent: masterManifestUpTo720p,
+		},
+		{
+			name: "when renditions are limited, expect the i-frame variants of removed renditions to be removed",
+			filters: &parsers.MediaFilters{
+				Renditions: &parsers.Renditions{Count: 1, Strategy: parsers.RenditionsLowest},
+			},
+			manifestContent:       masterManifest,
+			expectManifestContent: masterManifestLowestRendition,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			filter := NewHLSFilter("https://existing.base/path/master.m3u8", tt.manifestContent, config.Config{Hostname: "bakery.cbsi.video"})
+			manifest, err := filter.FilterContent(context.Background(), tt.filters)
+
+			if err != nil && !tt.expectErr {
+				t.Errorf("FilterContent(context.Background(), ) didnt expect an error to be returned, got: %v", err)
+				return
+			} else if err == nil && tt.expectErr {
+				t.Error("FilterContent(context.Background(), ) expected an error, got nil")
+				return
+			}
+
+			if g, e := manifest, tt.expectManifestContent; g != e {
+				t.Errorf("FilterContent(context.Background(), ) wrong manifest returned)\ngot %v\nexpected: %v\ndiff: %v", g, e,
+					cmp.Diff(g, e))
+			}
+		})
+	}
+}
+
+func TestHLSFilter_FilterContent_FrameRate(t *testing.T) {
+	masterManifestWithMultipleFrameRates := `#EXTM3U
+#EXT-X-VERSION:4
+#EXT-X-MEDIA:TYPE=CLOSED-CAPTIONS,GROUP-ID="CC",NAME="ENGLISH",DEFAULT=NO,LANGUAGE="ENG"
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000,AVERAGE-BANDWIDTH=1000,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC",FRAME-RATE=30.000
+https://existing.base/path/link_1.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4200,AVERAGE-BANDWIDTH=4200,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC",FRAME-RATE=30.000
+https://existing.base/path/link_2.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4000,AVERAGE-BANDWIDTH=4000,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC",FRAME-RATE=59.940
+https://existing.base/path/link_4.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4100,AVERAGE-BANDWIDTH=4100,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC",FRAME-RATE=59.940
+https://existing.base/path/link_5.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4500,AVERAGE-BANDWIDTH=4500,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC",FRAME-RATE=60.000
+https://existing.base/path/link_6.m3u8
+`
+
+	masterManifestWithout5994FrameRate := `#EXTM3U
+#EXT-X-VERSION:4
+#EXT-X-MEDIA:TYPE=CLOSED-CAPTIONS,GROUP-ID="CC",NAME="ENGLISH",DEFAULT=NO,LANGUAGE="ENG"
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000,AVERAGE-BANDWIDTH=1000,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC",FRAME-RATE=30.000
+https://existing.base/path/link_1.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4200,AVERAGE-BANDWIDTH=4200,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC",FRAME-RATE=30.000
+https://existing.base/path/link_2.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4500,AVERAGE-BANDWIDTH=4500,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC",FRAME-RATE=60.000
+https://existing.base/path/link_6.m3u8
+`
+
+	masterManifestWithOnly5994FrameRate := `#EXTM3U
+#EXT-X-VERSION:4
+#EXT-X-MEDIA:TYPE=CLOSED-CAPTIONS,GROUP-ID="CC",NAME="ENGLISH",DEFAULT=NO,LANGUAGE="ENG"
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4000,AVERAGE-BANDWIDTH=4000,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC",FRAME-RATE=59.940
+https://existing.base/path/link_4.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4100,AVERAGE-BANDWIDTH=4100,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC",FRAME-RATE=59.940
+https://existing.base/path/link_5.m3u8
+`
+
+	masterManifestWithNoVariants := `#EXTM3U
+#EXT-X-VERSION:3
+`
+
+	tests := []struct {
+		name                  string
+		filters               *parsers.MediaFilters
+		manifestContent       string
+		expectManifestContent string
+		expectErr             bool
+	}{
+		{
+			name:                  "when empty filter is given, expect no filtering to be done",
+			filters:               &parsers.MediaFilters{},
+			manifestContent:       masterManifestWithMultipleFrameRates,
+			expectManifestContent: masterManifestWithMultipleFrameRates,
+		},
+		{
+			name: "when framerate is set to 60, variant of 60fps is removed",
+			filters: &parsers.MediaFilters{
+				FrameRate: []string{"60.000", "30.000"},
+			},
+			manifestContent:       masterManifestWithMultipleFrameRates,
+			expectManifestContent: masterManifestWithOnly5994FrameRate,
+		},
+		{
+			name: "when framerate is set to 59.94, both variants of 59.94fps is removed",
+			filters: &parsers.MediaFilters{
+				FrameRate: []string{"59.940"},
+			},
+			manifestContent:       masterManifestWithMultipleFrameRates,
+			expectManifestContent: masterManifestWithout5994FrameRate,
+		},
+		{
+			name: "when all framerates are set, empty playlist is returned",
+			filters: &parsers.MediaFilters{
+				FrameRate: []string{"59.940", "60.000", "30.000"},
+			},
+			manifestContent:       masterManifestWithMultipleFrameRates,
+			expectManifestContent: masterManifestWithNoVariants,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := NewHLSFilter("https://existing.base/path/master.m3u8", tt.manifestContent, config.Config{Hostname: "bakery.cbsi.video"})
+			manifest, err := filter.FilterContent(context.Background(), tt.filters)
+
+			if err != nil && !tt.expectErr {
+				t.Errorf("FilterContent(context.Background(), ) didnt expect an error to be returned, got: %v", err)
+				return
+			} else if err == nil && tt.expectErr {
+				t.Error("FilterContent(context.Background(), ) expected an error, got nil")
+				return
+			}
+
+			if g, e := manifest, tt.expectManifestContent; g != e {
+				t.Errorf("FilterContent(context.Background(), ) wrong manifest returned)\ngot %v\nexpected: %v\ndiff: %v", g, e,
+					cmp.Diff(g, e))
+			}
+
+		})
+	}
+}
+
+func TestHLSFilter_FilterContent_VideoRange(t *testing.T) {
+	masterManifestWithMultipleVideoRanges := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000,AVERAGE-BANDWIDTH=1000,CODECS="hvc1.2.4.L123.B0,mp4a.40.2",VIDEO-RANGE=SDR
+https://existing.base/path/link_1.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=2000,AVERAGE-BANDWIDTH=2000,CODECS="hvc1.2.4.L123.B0,mp4a.40.2",VIDEO-RANGE=PQ
+https://existing.base/path/link_2.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=3000,AVERAGE-BANDWIDTH=3000,CODECS="dvh1.08.07,mp4a.40.2",VIDEO-RANGE=HLG
+https://existing.base/path/link_3.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4000,AVERAGE-BANDWIDTH=4000,CODECS="avc1.64001f,mp4a.40.2"
+https://existing.base/path/link_4.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=5000,AVERAGE-BANDWIDTH=5000,CODECS="av01.0.08M.10.0.110.09.18.09.0,mp4a.40.2"
+https://existing.base/path/link_5.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=6000,AVERAGE-BANDWIDTH=6000,CODECS="mp4a.40.2"
+https://existing.base/path/link_6.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=7000,AVERAGE-BANDWIDTH=7000,CODECS="hvc1.2.4.L153.B0,mp4a.40.2"
+https://existing.base/path/link_7.m3u8
+`
+
+	masterManifestWithoutHDR := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000,AVERAGE-BANDWIDTH=1000,CODECS="hvc1.2.4.L123.B0,mp4a.40.2",VIDEO-RANGE=SDR
+https://existing.base/path/link_1.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4000,AVERAGE-BANDWIDTH=4000,CODECS="avc1.64001f,mp4a.40.2"
+https://existing.base/path/link_4.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=6000,AVERAGE-BANDWIDTH=6000,CODECS="mp4a.40.2"
+https://existing.base/path/link_6.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=7000,AVERAGE-BANDWIDTH=7000,CODECS="hvc1.2.4.L153.B0,mp4a.40.2"
+https://existing.base/path/link_7.m3u8
+`
+
+	masterManifestWithoutSDR := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=2000,AVERAGE-BANDWIDTH=2000,CODECS="hvc1.2.4.L123.B0,mp4a.40.2",VIDEO-RANGE=PQ
+https://existing.base/path/link_2.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=3000,AVERAGE-BANDWIDTH=3000,CODECS="dvh1.08.07,mp4a.40.2",VIDEO-RANGE=HLG
+https://existing.base/path/link_3.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=5000,AVERAGE-BANDWIDTH=5000,CODECS="av01.0.08M.10.0.110.09.18.09.0,mp4a.40.2"
+https://existing.base/path/link_5.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=6000,AVERAGE-BANDWIDTH=6000,CODECS="mp4a.40.2"
+https://existing.base/path/link_6.m3u8
+`
+
+	masterManifestWithCombinedCodecs := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000,AVERAGE-BANDWIDTH=1000,CODECS="hvc1.2.4.L150,dvh1.08.07,ec-3"
+https://existing.base/path/link_1.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=2000,AVERAGE-BANDWIDTH=2000,CODECS="hvc1.2.4.L150,av01.0.08M.10.0.110.09.18.09.0,mp4a.40.2"
+https://existing.base/path/link_2.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=3000,AVERAGE-BANDWIDTH=3000,CODECS="avc1.64001f,hvc1.2.4.L150,mp4a.40.2"
+https://existing.base/path/link_3.m3u8
+`
+
+	masterManifestWithCombinedCodecsWithoutHDR := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=3000,AVERAGE-BANDWIDTH=3000,CODECS="avc1.64001f,hvc1.2.4.L150,mp4a.40.2"
+https://existing.base/path/link_3.m3u8
+`
+
+	masterManifestWithCombinedCodecsWithoutSDR := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000,AVERAGE-BANDWIDTH=1000,CODECS="hvc1.2.4.L150,dvh1.08.07,ec-3"
+https://existing.base/path/link_1.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=2000,AVERAGE-BANDWIDTH=2000,CODECS="hvc1.2.4.L150,av01.0.08M.10.0.110.09.18.09.0,mp4a.40.2"
+https://existing.base/path/link_2.m3u8
+`
+
+	tests := []struct {
+		name                  string
+		filters               *parsers.MediaFilters
+		manifestContent       string
+		expectManifestContent string
+	}{
+		{
+			name:                  "when empty filter is given, expect no filtering to be done",
+			filters:               &parsers.MediaFilters{},
+			manifestContent:       masterManifestWithMultipleVideoRanges,
+			expectManifestContent: masterManifestWithMultipleVideoRanges,
+		},
+		{
+			name: "when pq and hlg are given, expect hdr variants set or inferred from codecs to be removed and hevc main 10 variants without a video range to be kept",
+			filters: &parsers.MediaFilters{
+				VideoRange: []string{parsers.VideoRangePQ, parsers.VideoRangeHLG},
+			},
+			manifestContent:       masterManifestWithMultipleVideoRanges,
+			expectManifestContent: masterManifestWithoutHDR,
+		},
+		{
+			name: "when sdr is given, expect sdr variants, including hevc main 10 variants without a video range, to be removed and audio only variants to be kept",
+			filters: &parsers.MediaFilters{
+				VideoRange: []string{parsers.VideoRangeSDR},
+			},
+			manifestContent:       masterManifestWithMultipleVideoRanges,
+			expectManifestContent: masterManifestWithoutSDR,
+		},
+		{
+			name: "when pq and hlg are given, expect variants with an hdr codec alongside their sdr base layer codec to be removed",
+			filters: &parsers.MediaFilters{
+				VideoRange: []string{parsers.VideoRangePQ, parsers.VideoRangeHLG},
+			},
+			manifestContent:       masterManifestWithCombinedCodecs,
+			expectManifestContent: masterManifestWithCombinedCodecsWithoutHDR,
+		},
+		{
+			name: "when sdr is given, expect variants with an hdr codec alongside their sdr base layer codec to be kept",
+			filters: &parsers.MediaFilters{
+				VideoRange: []string{parsers.VideoRangeSDR},
+			},
+			manifestContent:       masterManifestWithCombinedCodecs,
+			expectManifestContent: masterManifestWithCombinedCodecsWithoutSDR,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			filter := NewHLSFilter("https://existing.base/path/master.m3u8", tt.manifestContent, config.Config{Hostname: "bakery.cbsi.video"})
+			manifest, err := filter.FilterContent(context.Background(), tt.filters)
+			if err != nil {
+				t.Fatalf("FilterContent(context.Background(), ) didnt expect an error to be returned, got: %v", err)
+			}
+
+			if g, e := manifest, tt.expectManifestContent; g != e {
+				t.Errorf("FilterContent(context.Background(), ) wrong manifest returned)\ngot %v\nexpected: %v\ndiff: %v", g, e,
+					cmp.Diff(g, e))
+			}
+		})
+	}
+}
+
+func TestHLSFilter_FilterContent_ResolutionFilter(t *testing.T) {
+	masterManifest := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000,AVERAGE-BANDWIDTH=1000,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=640x360
+https://existing.base/path/link_1.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=2000,AVERAGE-BANDWIDTH=2000,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=1280x720
+https://existing.base/path/link_2.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4000,AVERAGE-BANDWIDTH=4000,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=1920x1080
+https://existing.base/path/link_3.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=100,AVERAGE-BANDWIDTH=100,CODECS="mp4a.40.2"
+https://existing.base/path/link_4.m3u8
+`
+
+	masterManifestUpTo720p := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000,AVERAGE-BANDWIDTH=1000,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=640x360
+https://existing.base/path/link_1.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=2000,AVERAGE-BANDWIDTH=2000,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=1280x720
+https://existing.base/path/link_2.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=100,AVERAGE-BANDWIDTH=100,CODECS="mp4a.40.2"
+https://existing.base/path/link_4.m3u8
+`
+
+	masterManifestFrom720p := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=2000,AVERAGE-BANDWIDTH=2000,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=1280x720
+https://existing.base/path/link_2.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4000,AVERAGE-BANDWIDTH=4000,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=1920x1080
+https://existing.base/path/link_3.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=100,AVERAGE-BANDWIDTH=100,CODECS="mp4a.40.2"
+https://existing.base/path/link_4.m3u8
+`
+
+	masterManifestOnly720p := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=2000,AVERAGE-BANDWIDTH=2000,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=1280x720
+https://existing.base/path/link_2.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=100,AVERAGE-BANDWIDTH=100,CODECS="mp4a.40.2"
+https://existing.base/path/link_4.m3u8
+`
+
+	tests := []struct {
+		name                  string
+		filters               *parsers.MediaFilters
+		manifestContent       string
+		expectManifestContent string
+		expectErr             bool
+	}{
+		{
+			name:                  "when no filters are given, expect no filtering to be done",
+			filters:               &parsers.MediaFilters{},
+			manifestContent:       masterManifest,
+			expectManifestContent: masterManifest,
+		},
+		{
+			name: "when a max height is set, expect variants above it to be removed",
+			filters: &parsers.MediaFilters{
+				Videos: parsers.NestedFilters{
+					Height: &parsers.Resolution{Min: 0, Max: 720},
+				},
+			},
+			manifestContent:       masterManifest,
+			expectManifestContent: masterManifestUpTo720p,
+		},
+		{
+			name: "when a min height is set, expect variants below it to be removed",
+			filters: &parsers.MediaFilters{
+				Videos: parsers.NestedFilters{
+					Height: &parsers.Resolution{Min: 720, Max: math.MaxInt32},
+				},
+			},
+			manifestContent:       masterManifest,
+			expectManifestContent: masterManifestFrom720p,
+		},
+		{
+			name: "when both height and width ranges are set, expect variants matching both to remain",
+			filters: &parsers.MediaFilters{
+				Videos: parsers.NestedFilters{
+					Height: &parsers.Resolution{Min: 360, Max: 1080},
+					Width:  &parsers.Resolution{Min: 1000, Max: 1500},
+				},
+			},
+			manifestContent:       masterManifest,
+			expectManifestContent: masterManifestOnly720p,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := NewHLSFilter("https://existing.base/path/master.m3u8", tt.manifestContent, config.Config{Hostname: "bakery.cbsi.video"})
+			manifest, err := filter.FilterContent(context.Background(), tt.filters)
+
+			if err != nil && !tt.expectErr {
+				t.Errorf("FilterContent(context.Background(), ) didnt expect an error to be returned, got: %v", err)
+				return
+			} else if err == nil && tt.expectErr {
+				t.Error("FilterContent(context.Background(), ) expected an error, got nil")
+				return
+			}
+
+			if g, e := manifest, tt.expectManifestContent; g != e {
+				t.Errorf("FilterContent(context.Background(), ) wrong manifest returned)\ngot %v\nexpected: %v\ndiff: %v", g, e,
+					cmp.Diff(g, e))
+			}
+		})
+	}
+}
+
+func TestHLSFilter_FilterContent_AllowedCodecsFilter(t *testing.T) {
+	manifestWithAllCodecs := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000,AVERAGE-BANDWIDTH=1000,CODECS="mp4a.40.2"
+http://existing.base/uri/link_1.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000,AVERAGE-BANDWIDTH=1000,CODECS="ec-3"
+http://existing.base/uri/link_2.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1100,AVERAGE-BANDWIDTH=1100,CODECS="avc1.77.30,mp4a.40.2"
+http://existing.base/uri/link_3.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4000,AVERAGE-BANDWIDTH=4000,CODECS="hvc1.2.4.L93.90,mp4a.40.2"
+http://existing.base/uri/link_4.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4500,AVERAGE-BANDWIDTH=4500,CODECS="avc1.640029,ec-3"
+http://existing.base/uri/link_5.m3u8
+`
+
+	manifestOnlyAVC := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000,AVERAGE-BANDWIDTH=1000,CODECS="mp4a.40.2"
+http://existing.base/uri/link_1.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000,AVERAGE-BANDWIDTH=1000,CODECS="ec-3"
+http://existing.base/uri/link_2.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1100,AVERAGE-BANDWIDTH=1100,CODECS="avc1.77.30,mp4a.40.2"
+http://existing.base/uri/link_3.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4500,AVERAGE-BANDWIDTH=4500,CODECS="avc1.640029,ec-3"
+http://existing.base/uri/link_5.m3u8
+`
+
+	manifestOnlyAVCAndMP4A := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000,AVERAGE-BANDWIDTH=1000,CODECS="mp4a.40.2"
+http://existing.base/uri/link_1.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1100,AVERAGE-BANDWIDTH=1100,CODECS="avc1.77.30,mp4a.40.2"
+http://existing.base/uri/link_3.m3u8
+`
+
+	manifestOnlyMP4AWithoutAVC := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000,AVERAGE-BANDWIDTH=1000,CODECS="mp4a.40.2"
+http://existing.base/uri/link_1.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4000,AVERAGE-BANDWIDTH=4000,CODECS="hvc1.2.4.L93.90,mp4a.40.2"
+http://existing.base/uri/link_4.m3u8
+`
+
+	tests := []struct {
+		name                  string
+		filters               *parsers.MediaFilters
+		manifestContent       string
+		expectManifestContent string
+		expectErr             bool
+	}{
+		{
+			name: "when only avc is allowed, expect variants with other video codecs to be removed",
+			filters: &parsers.MediaFilters{
+				Videos: parsers.NestedFilters{
+					Only: []string{"avc"},
+				},
+			},
+			manifestContent:       manifestWithAllCodecs,
+			expectManifestContent: manifestOnlyAVC,
+		},
+		{
+			name: "when only avc and mp4a are allowed, expect audio only variants of mp4a to remain",
+			filters: &parsers.MediaFilters{
+				Videos: parsers.NestedFilters{
+					Only: []string{"avc"},
+				},
+				Audios: parsers.NestedFilters{
+					Only: []string{"mp4a"},
+				},
+			},
+			manifestContent:       manifestWithAllCodecs,
+			expectManifestContent: manifestOnlyAVCAndMP4A,
+		},
+		{
+			name: "when an allowlist is combined with a removal list, expect both to apply",
+			filters: &parsers.MediaFilters{
+				Videos: parsers.NestedFilters{
+					Codecs: []string{"avc"},
+				},
+				Audios: parsers.NestedFilters{
+					Only: []string{"mp4a"},
+				},
+			},
+			manifestContent:       manifestWithAllCodecs,
+			expectManifestContent: manifestOnlyMP4AWithoutAVC,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := NewHLSFilter("", tt.manifestContent, config.Config{})
+			manifest, err := filter.FilterContent(context.Background(), tt.filters)
+
+			if err != nil && !tt.expectErr {
+				t.Errorf("FilterContent(context.Background(), ) didnt expect an error to be returned, got: %v", err)
+				return
+			} else if err == nil && tt.expectErr {
+				t.Error("FilterContent(context.Background(), ) expected an error, got nil")
+				return
+			}
+
+			if g, e := manifest, tt.expectManifestContent; g != e {
+				t.Errorf("FilterContent(context.Background(), ) wrong manifest returned)\ngot %v\nexpected: %v\ndiff: %v", g, e,
+					cmp.Diff(g, e))
+			}
+		})
+	}
+}
+
+func TestHLSFilter_FilterContent_SortFilter(t *testing.T) {
+	masterManifest := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4000,AVERAGE-BANDWIDTH=4000,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=1920x1080
+https://existing.base/path/link_4.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=500,AVERAGE-BANDWIDTH=500,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=416x234
+https://existing.base/path/link_0.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=2000,AVERAGE-BANDWIDTH=2000,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=960x540
+https://existing.base/path/link_2.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=100,AVERAGE-BANDWIDTH=100,CODECS="mp4a.40.2"
+https://existing.base/path/link_audio.m3u8
+#EXT-X-I-FRAME-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=200,CODECS="avc1.4d401e",URI="https://existing.base/path/iframe.m3u8"
+`
+
+	masterManifestAscending := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=100,AVERAGE-BANDWIDTH=100,CODECS="mp4a.40.2"
+https://existing.base/path/link_audio.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=500,AVERAGE-BANDWIDTH=500,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=416x234
+https://existing.base/path/link_0.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=2000,AVERAGE-BANDWIDTH=2000,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=960x540
+https://existing.base/path/link_2.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4000,AVERAGE-BANDWIDTH=4000,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=1920x1080
+https://existing.base/path/link_4.m3u8
+#EXT-X-I-FRAME-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=200,CODECS="avc1.4d401e",URI="https://existing.base/path/iframe.m3u8"
 `
 
-	masterManifestWithNoEnglishSubsAndCaptions := `#EXTM3U
+	masterManifestDescending := `#EXTM3U
 #EXT-X-VERSION:3
-#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=356400,AVERAGE-BANDWIDTH=345400,CODECS="avc1.640015,mp4a.40.2",RESOLUTION=400x244,FRAME-RATE=29.000
-https://cbss64eb-cbss64eb-ms-dev.global.ssl.fastly.net/cbssc0a7/master/cbssc0a7_1.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=642400,AVERAGE-BANDWIDTH=620400,CODECS="avc1.640015,mp4a.40.2",RESOLUTION=512x288,FRAME-RATE=29.000
-https://cbss64eb-cbss64eb-ms-dev.global.ssl.fastly.net/cbssc0a7/master/cbssc0a7_2.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=985600,AVERAGE-BANDWIDTH=950400,CODECS="avc1.64001e,mp4a.40.2",RESOLUTION=640x360,FRAME-RATE=29.000
-https://cbss64eb-cbss64eb-ms-dev.global.ssl.fastly.net/cbssc0a7/master/cbssc0a7_3.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1443200,AVERAGE-BANDWIDTH=1390400,CODECS="avc1.64001e,mp4a.40.2",RESOLUTION=768x432,FRAME-RATE=29.000
-https://cbss64eb-cbss64eb-ms-dev.global.ssl.fastly.net/cbssc0a7/master/cbssc0a7_4.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=2129600,AVERAGE-BANDWIDTH=2050400,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=960x540,FRAME-RATE=29.000
-https://cbss64eb-cbss64eb-ms-dev.global.ssl.fastly.net/cbssc0a7/master/cbssc0a7_5.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4074400,AVERAGE-BANDWIDTH=3920400,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=1280x720,FRAME-RATE=29.000
-https://cbss64eb-cbss64eb-ms-dev.global.ssl.fastly.net/cbssc0a7/master/cbssc0a7_6.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=5790400,AVERAGE-BANDWIDTH=5570400,CODECS="avc1.640028,mp4a.40.2",RESOLUTION=1920x1080,FRAME-RATE=29.000
-https://cbss64eb-cbss64eb-ms-dev.global.ssl.fastly.net/cbssc0a7/master/cbssc0a7_7.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4000,AVERAGE-BANDWIDTH=4000,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=1920x1080
+https://existing.base/path/link_4.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=2000,AVERAGE-BANDWIDTH=2000,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=960x540
+https://existing.base/path/link_2.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=500,AVERAGE-BANDWIDTH=500,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=416x234
+https://existing.base/path/link_0.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=100,AVERAGE-BANDWIDTH=100,CODECS="mp4a.40.2"
+https://existing.base/path/link_audio.m3u8
+#EXT-X-I-FRAME-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=200,CODECS="avc1.4d401e",URI="https://existing.base/path/iframe.m3u8"
+`
+
+	masterManifestAscendingWithLowestVideoFirst := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=500,AVERAGE-BANDWIDTH=500,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=416x234
+https://existing.base/path/link_0.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=100,AVERAGE-BANDWIDTH=100,CODECS="mp4a.40.2"
+https://existing.base/path/link_audio.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=2000,AVERAGE-BANDWIDTH=2000,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=960x540
+https://existing.base/path/link_2.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4000,AVERAGE-BANDWIDTH=4000,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=1920x1080
+https://existing.base/path/link_4.m3u8
+#EXT-X-I-FRAME-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=200,CODECS="avc1.4d401e",URI="https://existing.base/path/iframe.m3u8"
+`
+
+	masterManifestWithTargetFirst := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=2000,AVERAGE-BANDWIDTH=2000,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=960x540
+https://existing.base/path/link_2.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4000,AVERAGE-BANDWIDTH=4000,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=1920x1080
+https://existing.base/path/link_4.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=500,AVERAGE-BANDWIDTH=500,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=416x234
+https://existing.base/path/link_0.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=100,AVERAGE-BANDWIDTH=100,CODECS="mp4a.40.2"
+https://existing.base/path/link_audio.m3u8
+#EXT-X-I-FRAME-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=200,CODECS="avc1.4d401e",URI="https://existing.base/path/iframe.m3u8"
+`
+
+	masterManifestAscendingWithTargetFirst := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=2000,AVERAGE-BANDWIDTH=2000,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=960x540
+https://existing.base/path/link_2.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=100,AVERAGE-BANDWIDTH=100,CODECS="mp4a.40.2"
+https://existing.base/path/link_audio.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=500,AVERAGE-BANDWIDTH=500,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=416x234
+https://existing.base/path/link_0.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4000,AVERAGE-BANDWIDTH=4000,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=1920x1080
+https://existing.base/path/link_4.m3u8
+#EXT-X-I-FRAME-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=200,CODECS="avc1.4d401e",URI="https://existing.base/path/iframe.m3u8"
 `
 
 	tests := []struct {
@@ -2599,43 +5082,44 @@ https://cbss64eb-cbss64eb-ms-dev.global.ssl.fastly.net/cbssc0a7/master/cbssc0a7_
 		expectErr             bool
 	}{
 		{
-			name:                  "when no filters are passed, nothing is removed",
-			filters:               &parsers.MediaFilters{},
-			manifestContent:       masterManifestWithMultipleLangs,
-			expectManifestContent: masterManifestWithMultipleLangs,
+			name: "when ascending order is set, expect variants from the lowest bitrate and i-frame variants in place",
+			filters: &parsers.MediaFilters{
+				Sort: &parsers.Sort{Order: parsers.SortAscending},
+			},
+			manifestContent:       masterManifest,
+			expectManifestContent: masterManifestAscending,
 		},
 		{
-			name: "when multiple audio languages are passed in, remove all those languages of type audio",
+			name: "when descending order is set, expect variants from the highest bitrate",
 			filters: &parsers.MediaFilters{
-				Audios: parsers.NestedFilters{
-					Language: []string{"da", "de", "es", "pt"},
-				},
+				Sort: &parsers.Sort{Order: parsers.SortDescending},
 			},
-			manifestContent:       masterManifestWithMultipleLangs,
-			expectManifestContent: masterManifestWithEnglishOnly,
+			manifestContent:       masterManifest,
+			expectManifestContent: masterManifestDescending,
 		},
 		{
-			name: "when multiple audio and caption languages are passed in, remove provided audio and caption languages ",
+			name: "when a target bitrate is set, expect the closest video variant first and others in playlist order",
 			filters: &parsers.MediaFilters{
-				Audios: parsers.NestedFilters{
-					Language: []string{"da", "de", "en"},
-				},
-				Captions: parsers.NestedFilters{
-					Language: []string{"en"},
-				},
+				Sort: &parsers.Sort{Target: 1500},
 			},
-			manifestContent:       masterManifestWithMultipleLangs,
-			expectManifestContent: masterManifestWithSpanishAndPortugeseAndNoSubtitles,
+			manifestContent:       masterManifest,
+			expectManifestContent: masterManifestWithTargetFirst,
 		},
 		{
-			name: "when caption languages are passed in, remove provided subtitles and caption languages ",
+			name: "when an order and a target bitrate are set, expect the closest video variant first and others ordered",
 			filters: &parsers.MediaFilters{
-				Captions: parsers.NestedFilters{
-					Language: []string{"eng"},
-				},
+				Sort: &parsers.Sort{Order: parsers.SortAscending, Target: 1500},
 			},
-			manifestContent:       masterManifestWithEnglishSubsAndCaptions,
-			expectManifestContent: masterManifestWithNoEnglishSubsAndCaptions,
+			manifestContent:       masterManifest,
+			expectManifestContent: masterManifestAscendingWithTargetFirst,
+		},
+		{
+			name: "when a low target bitrate is set, expect the lowest video variant promoted over audio only variants",
+			filters: &parsers.MediaFilters{
+				Sort: &parsers.Sort{Order: parsers.SortAscending, Target: 100},
+			},
+			manifestContent:       masterManifest,
+			expectManifestContent: masterManifestAscendingWithLowestVideoFirst,
 		},
 	}
 
@@ -2657,59 +5141,44 @@ https://cbss64eb-cbss64eb-ms-dev.global.ssl.fastly.net/cbssc0a7/master/cbssc0a7_
 				t.Errorf("FilterContent(context.Background(), ) wrong manifest returned)\ngot %v\nexpected: %v\ndiff: %v", g, e,
 					cmp.Diff(g, e))
 			}
-
 		})
 	}
 }
 
-func TestHLSFilter_FilterContent_IFrameFilter(t *testing.T) {
-	masterManifestWithSingleIFrame := `#EXTM3U
+func TestHLSFilter_FilterContent_ChannelsFilter(t *testing.T) {
+	masterManifest := `#EXTM3U
 #EXT-X-VERSION:4
-#EXT-X-MEDIA:TYPE=CLOSED-CAPTIONS,GROUP-ID="CC",NAME="ENGLISH",DEFAULT=NO,LANGUAGE="ENG"
-#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000,AVERAGE-BANDWIDTH=1000,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aac",NAME="English",DEFAULT=YES,AUTOSELECT=YES,LANGUAGE="en",CHANNELS="2",URI="https://existing.base/path/aac.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="surround",NAME="English 5.1",DEFAULT=YES,AUTOSELECT=YES,LANGUAGE="en",CHANNELS="6",URI="https://existing.base/path/ac3.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="surround",NAME="English Atmos",DEFAULT=NO,AUTOSELECT=YES,LANGUAGE="en",CHANNELS="16/JOC",URI="https://existing.base/path/atmos.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="surround",NAME="Description",DEFAULT=NO,AUTOSELECT=NO,LANGUAGE="en",URI="https://existing.base/path/description.m3u8"
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000,AVERAGE-BANDWIDTH=1000,CODECS="avc1.64001f,mp4a.40.2",AUDIO="aac"
 https://existing.base/path/link_1.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4200,AVERAGE-BANDWIDTH=4200,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC"
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=2000,AVERAGE-BANDWIDTH=2000,CODECS="avc1.64001f,ec-3",AUDIO="surround"
 https://existing.base/path/link_2.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4000,AVERAGE-BANDWIDTH=4000,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC"
-https://existing.base/path/link_4.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4100,AVERAGE-BANDWIDTH=4100,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC"
-https://existing.base/path/link_5.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4500,AVERAGE-BANDWIDTH=4500,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC"
-https://existing.base/path/link_6.m3u8
-#EXT-X-I-FRAME-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=250,CODECS="avc1.4d401e",RESOLUTION=384x216,URI="https://existing.base/path/link_1.m3u8"
 `
 
-	masterManifestWithMultipleIFrame := `#EXTM3U
+	masterManifestStereoOnly := `#EXTM3U
 #EXT-X-VERSION:4
-#EXT-X-MEDIA:TYPE=CLOSED-CAPTIONS,GROUP-ID="CC",NAME="ENGLISH",DEFAULT=NO,LANGUAGE="ENG"
-#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000,AVERAGE-BANDWIDTH=1000,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aac",NAME="English",DEFAULT=YES,AUTOSELECT=YES,LANGUAGE="en",CHANNELS="2",URI="https://existing.base/path/aac.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="surround",NAME="Description",DEFAULT=NO,AUTOSELECT=NO,LANGUAGE="en",URI="https://existing.base/path/description.m3u8"
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000,AVERAGE-BANDWIDTH=1000,CODECS="avc1.64001f,mp4a.40.2",AUDIO="aac"
 https://existing.base/path/link_1.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4200,AVERAGE-BANDWIDTH=4200,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC"
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=2000,AVERAGE-BANDWIDTH=2000,CODECS="avc1.64001f,ec-3",AUDIO="surround"
 https://existing.base/path/link_2.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4000,AVERAGE-BANDWIDTH=4000,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC"
-https://existing.base/path/link_4.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4100,AVERAGE-BANDWIDTH=4100,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC"
-https://existing.base/path/link_5.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4500,AVERAGE-BANDWIDTH=4500,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC"
-https://existing.base/path/link_6.m3u8
-#EXT-X-I-FRAME-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=250,CODECS="avc1.4d401e",RESOLUTION=384x216,URI="link_1.m3u8",
-#EXT-X-I-FRAME-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=550,CODECS="avc1.4d401e",RESOLUTION=512x288,URI="link_2.m3u8",
 `
 
-	masterManifestWithNoIframe := `#EXTM3U
+	masterManifestSurroundOnly := `#EXTM3U
 #EXT-X-VERSION:4
-#EXT-X-MEDIA:TYPE=CLOSED-CAPTIONS,GROUP-ID="CC",NAME="ENGLISH",DEFAULT=NO,LANGUAGE="ENG"
-#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000,AVERAGE-BANDWIDTH=1000,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="surround",NAME="English 5.1",DEFAULT=YES,AUTOSELECT=YES,LANGUAGE="en",CHANNELS="6",URI="https://existing.base/path/ac3.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="surround",NAME="English Atmos",DEFAULT=NO,AUTOSELECT=YES,LANGUAGE="en",CHANNELS="16/JOC",URI="https://existing.base/path/atmos.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="surround",NAME="Description",DEFAULT=NO,AUTOSELECT=NO,LANGUAGE="en",URI="https://existing.base/path/description.m3u8"
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000,AVERAGE-BANDWIDTH=1000,CODECS="avc1.64001f,mp4a.40.2"
 https://existing.base/path/link_1.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4200,AVERAGE-BANDWIDTH=4200,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC"
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=2000,AVERAGE-BANDWIDTH=2000,CODECS="avc1.64001f,ec-3",AUDIO="surround"
 https://existing.base/path/link_2.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4000,AVERAGE-BANDWIDTH=4000,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC"
-https://existing.base/path/link_4.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4100,AVERAGE-BANDWIDTH=4100,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC"
-https://existing.base/path/link_5.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4500,AVERAGE-BANDWIDTH=4500,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC"
-https://existing.base/path/link_6.m3u8
 `
+
 	tests := []struct {
 		name                  string
 		filters               *parsers.MediaFilters
@@ -2718,35 +5187,34 @@ https://existing.base/path/link_6.m3u8
 		expectErr             bool
 	}{
 		{
-			name:                  "when empty filter is given, expect no filtering to be done",
+			name:                  "when no filters are given, expect no filtering to be done",
 			filters:               &parsers.MediaFilters{},
-			manifestContent:       masterManifestWithSingleIFrame,
-			expectManifestContent: masterManifestWithSingleIFrame,
+			manifestContent:       masterManifest,
+			expectManifestContent: masterManifest,
 		},
 		{
-			name: "when iframe filter has been set, iframe is removed given a playlist with a single iframe",
+			name: "when a max number of channels is set, expect surround audio removed and group references cleaned up",
 			filters: &parsers.MediaFilters{
-				Tags: &parsers.Tags{
-					IFrame: true,
+				Audios: parsers.NestedFilters{
+					Channels: &parsers.Channels{Min: 0, Max: 2},
 				},
 			},
-			manifestContent:       masterManifestWithSingleIFrame,
-			expectManifestContent: masterManifestWithNoIframe,
+			manifestContent:       masterManifest,
+			expectManifestContent: masterManifestStereoOnly,
 		},
 		{
-			name: "when iframe filter has been set, iframe is removed given a playlist with mutliple iframe",
+			name: "when a min number of channels is set, expect stereo audio removed and audio without channels kept",
 			filters: &parsers.MediaFilters{
-				Tags: &parsers.Tags{
-					IFrame: true,
+				Audios: parsers.NestedFilters{
+					Channels: &parsers.Channels{Min: 6, Max: math.MaxInt32},
 				},
 			},
-			manifestContent:       masterManifestWithMultipleIFrame,
-			expectManifestContent: masterManifestWithNoIframe,
+			manifestContent:       masterManifest,
+			expectManifestContent: masterManifestSurroundOnly,
 		},
 	}
 
 	for _, tt := range tests {
-		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
 			filter := NewHLSFilter("https://existing.base/path/master.m3u8", tt.manifestContent, config.Config{Hostname: "bakery.cbsi.video"})
 			manifest, err := filter.FilterContent(context.Background(), tt.filters)
@@ -2763,49 +5231,67 @@ https://existing.base/path/link_6.m3u8
 				t.Errorf("FilterContent(context.Background(), ) wrong manifest returned)\ngot %v\nexpected: %v\ndiff: %v", g, e,
 					cmp.Diff(g, e))
 			}
-
 		})
 	}
 }
 
-func TestHLSFilter_FilterContent_FrameRate(t *testing.T) {
-	masterManifestWithMultipleFrameRates := `#EXTM3U
-#EXT-X-VERSION:4
-#EXT-X-MEDIA:TYPE=CLOSED-CAPTIONS,GROUP-ID="CC",NAME="ENGLISH",DEFAULT=NO,LANGUAGE="ENG"
-#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000,AVERAGE-BANDWIDTH=1000,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC",FRAME-RATE=30.000
+func TestHLSFilter_FilterContent_RenditionsFilter(t *testing.T) {
+	masterManifest := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4000,AVERAGE-BANDWIDTH=4000,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=1920x1080
+https://existing.base/path/link_4.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=500,AVERAGE-BANDWIDTH=500,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=416x234
+https://existing.base/path/link_0.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000,AVERAGE-BANDWIDTH=1000,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=640x360
 https://existing.base/path/link_1.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4200,AVERAGE-BANDWIDTH=4200,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC",FRAME-RATE=30.000
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=2000,AVERAGE-BANDWIDTH=2000,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=960x540
 https://existing.base/path/link_2.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4000,AVERAGE-BANDWIDTH=4000,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC",FRAME-RATE=59.940
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=3000,AVERAGE-BANDWIDTH=3000,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=1280x720
+https://existing.base/path/link_3.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=100,AVERAGE-BANDWIDTH=100,CODECS="mp4a.40.2"
+https://existing.base/path/link_audio.m3u8
+`
+
+	masterManifestHighest := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4000,AVERAGE-BANDWIDTH=4000,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=1920x1080
 https://existing.base/path/link_4.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4100,AVERAGE-BANDWIDTH=4100,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC",FRAME-RATE=59.940
-https://existing.base/path/link_5.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4500,AVERAGE-BANDWIDTH=4500,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC",FRAME-RATE=60.000
-https://existing.base/path/link_6.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=3000,AVERAGE-BANDWIDTH=3000,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=1280x720
+https://existing.base/path/link_3.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=100,AVERAGE-BANDWIDTH=100,CODECS="mp4a.40.2"
+https://existing.base/path/link_audio.m3u8
 `
 
-	masterManifestWithout5994FrameRate := `#EXTM3U
-#EXT-X-VERSION:4
-#EXT-X-MEDIA:TYPE=CLOSED-CAPTIONS,GROUP-ID="CC",NAME="ENGLISH",DEFAULT=NO,LANGUAGE="ENG"
-#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000,AVERAGE-BANDWIDTH=1000,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC",FRAME-RATE=30.000
+	masterManifestLowest := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=500,AVERAGE-BANDWIDTH=500,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=416x234
+https://existing.base/path/link_0.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000,AVERAGE-BANDWIDTH=1000,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=640x360
 https://existing.base/path/link_1.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4200,AVERAGE-BANDWIDTH=4200,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC",FRAME-RATE=30.000
-https://existing.base/path/link_2.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4500,AVERAGE-BANDWIDTH=4500,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC",FRAME-RATE=60.000
-https://existing.base/path/link_6.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=100,AVERAGE-BANDWIDTH=100,CODECS="mp4a.40.2"
+https://existing.base/path/link_audio.m3u8
 `
 
-	masterManifestWithOnly5994FrameRate := `#EXTM3U
-#EXT-X-VERSION:4
-#EXT-X-MEDIA:TYPE=CLOSED-CAPTIONS,GROUP-ID="CC",NAME="ENGLISH",DEFAULT=NO,LANGUAGE="ENG"
-#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4000,AVERAGE-BANDWIDTH=4000,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC",FRAME-RATE=59.940
+	masterManifestSpread := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4000,AVERAGE-BANDWIDTH=4000,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=1920x1080
 https://existing.base/path/link_4.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4100,AVERAGE-BANDWIDTH=4100,CODECS="avc1.64001f,mp4a.40.2",CLOSED-CAPTIONS="CC",FRAME-RATE=59.940
-https://existing.base/path/link_5.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=500,AVERAGE-BANDWIDTH=500,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=416x234
+https://existing.base/path/link_0.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=2000,AVERAGE-BANDWIDTH=2000,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=960x540
+https://existing.base/path/link_2.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=100,AVERAGE-BANDWIDTH=100,CODECS="mp4a.40.2"
+https://existing.base/path/link_audio.m3u8
 `
 
-	masterManifestWithNoVariants := `#EXTM3U
+	masterManifestHighestUnder3000 := `#EXTM3U
 #EXT-X-VERSION:3
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=2000,AVERAGE-BANDWIDTH=2000,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=960x540
+https://existing.base/path/link_2.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=3000,AVERAGE-BANDWIDTH=3000,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=1280x720
+https://existing.base/path/link_3.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=100,AVERAGE-BANDWIDTH=100,CODECS="mp4a.40.2"
+https://existing.base/path/link_audio.m3u8
 `
 
 	tests := []struct {
@@ -2816,34 +5302,47 @@ https://existing.base/path/link_5.m3u8
 		expectErr             bool
 	}{
 		{
-			name:                  "when empty filter is given, expect no filtering to be done",
-			filters:               &parsers.MediaFilters{},
-			manifestContent:       masterManifestWithMultipleFrameRates,
-			expectManifestContent: masterManifestWithMultipleFrameRates,
+			name: "when the highest renditions are kept, expect the top of the ladder and audio variants",
+			filters: &parsers.MediaFilters{
+				Renditions: &parsers.Renditions{Count: 2, Strategy: parsers.RenditionsHighest},
+			},
+			manifestContent:       masterManifest,
+			expectManifestContent: masterManifestHighest,
 		},
 		{
-			name: "when framerate is set to 60, variant of 60fps is removed",
+			name: "when the lowest renditions are kept, expect the bottom of the ladder and audio variants",
 			filters: &parsers.MediaFilters{
-				FrameRate: []string{"60.000", "30.000"},
+				Renditions: &parsers.Renditions{Count: 2, Strategy: parsers.RenditionsLowest},
 			},
-			manifestContent:       masterManifestWithMultipleFrameRates,
-			expectManifestContent: masterManifestWithOnly5994FrameRate,
+			manifestContent:       masterManifest,
+			expectManifestContent: masterManifestLowest,
 		},
 		{
-			name: "when framerate is set to 59.94, both variants of 59.94fps is removed",
+			name: "when renditions are spread, expect both ends of the ladder and the middle in playlist order",
 			filters: &parsers.MediaFilters{
-				FrameRate: []string{"59.940"},
+				Renditions: &parsers.Renditions{Count: 3, Strategy: parsers.RenditionsSpread},
 			},
-			manifestContent:       masterManifestWithMultipleFrameRates,
-			expectManifestContent: masterManifestWithout5994FrameRate,
+			manifestContent:       masterManifest,
+			expectManifestContent: masterManifestSpread,
 		},
 		{
-			name: "when all framerates are set, empty playlist is returned",
+			name: "when the count is larger than the ladder, expect no filtering to be done",
 			filters: &parsers.MediaFilters{
-				FrameRate: []string{"59.940", "60.000", "30.000"},
+				Renditions: &parsers.Renditions{Count: 10, Strategy: parsers.RenditionsSpread},
 			},
-			manifestContent:       masterManifestWithMultipleFrameRates,
-			expectManifestContent: masterManifestWithNoVariants,
+			manifestContent:       masterManifest,
+			expectManifestContent: masterManifest,
+		},
+		{
+			name: "when combined with a bitrate filter, expect renditions to be chosen among the remaining variants",
+			filters: &parsers.MediaFilters{
+				Videos: parsers.NestedFilters{
+					Bitrate: &parsers.Bitrate{Min: 0, Max: 3000},
+				},
+				Renditions: &parsers.Renditions{Count: 2, Strategy: parsers.RenditionsHighest},
+			},
+			manifestContent:       masterManifest,
+			expectManifestContent: masterManifestHighestUnder3000,
 		},
 	}
 
@@ -2864,7 +5363,6 @@ https://existing.base/path/link_5.m3u8
 				t.Errorf("FilterContent(context.Background(), ) wrong manifest returned)\ngot %v\nexpected: %v\ndiff: %v", g, e,
 					cmp.Diff(g, e))
 			}
-
 		})
 	}
 }
@@ -3024,6 +5522,9 @@ https://cbsi679d-cbsi679d-ms-dev.global.ssl.fastly.net/testa5fe/master/backup_te
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			// pipeline health is cached across requests
+			pipelineHealth = newHealthChecks()
+
 			cfg := config.Config{
 				Hostname: "bakery.cbsi.video",
 				Client: config.Client{
@@ -3050,3 +5551,185 @@ https://cbsi679d-cbsi679d-ms-dev.global.ssl.fastly.net/testa5fe/master/backup_te
 		})
 	}
 }
+
+func TestHLSFilter_FilterContent_RedundantPipelines(t *testing.T) {
+	threeWayRedundant := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=356400,CODECS="avc1.64000c,mp4a.40.2",RESOLUTION=400x224
+https://existing.base/path/a/rendition_1.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=356400,CODECS="avc1.64000c,mp4a.40.2",RESOLUTION=400x224
+https://existing.base/path/b/rendition_1.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=356400,CODECS="avc1.64000c,mp4a.40.2",RESOLUTION=400x224
+https://existing.base/path/c/rendition_1.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1100000,CODECS="avc1.64001e,mp4a.40.2",RESOLUTION=640x360
+https://existing.base/path/a/rendition_2.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1100000,CODECS="avc1.64001e,mp4a.40.2",RESOLUTION=640x360
+https://existing.base/path/b/rendition_2.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1100000,CODECS="avc1.64001e,mp4a.40.2",RESOLUTION=640x360
+https://existing.base/path/c/rendition_2.m3u8
+#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=80000,CODECS="avc1.64001e",RESOLUTION=640x360,URI="https://existing.base/path/a/iframe.m3u8"
+`
+
+	threeWayRedundantPipelineB := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=356400,CODECS="avc1.64000c,mp4a.40.2",RESOLUTION=400x224
+https://existing.base/path/b/rendition_1.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1100000,CODECS="avc1.64001e,mp4a.40.2",RESOLUTION=640x360
+https://existing.base/path/b/rendition_2.m3u8
+#EXT-X-I-FRAME-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=80000,CODECS="avc1.64001e",RESOLUTION=640x360,URI="https://existing.base/path/a/iframe.m3u8"
+`
+
+	threeWayRedundantPipelineC := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=356400,CODECS="avc1.64000c,mp4a.40.2",RESOLUTION=400x224
+https://existing.base/path/c/rendition_1.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1100000,CODECS="avc1.64001e,mp4a.40.2",RESOLUTION=640x360
+https://existing.base/path/c/rendition_2.m3u8
+#EXT-X-I-FRAME-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=80000,CODECS="avc1.64001e",RESOLUTION=640x360,URI="https://existing.base/path/a/iframe.m3u8"
+`
+
+	pathwayRedundant := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-STREAM-INF:BANDWIDTH=356400,CODECS="avc1.64000c,mp4a.40.2",RESOLUTION=400x224,PATHWAY-ID="CDN-A"
+https://existing.base/path/a/rendition_1.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=1100000,CODECS="avc1.64001e,mp4a.40.2",RESOLUTION=640x360,PATHWAY-ID="CDN-A"
+https://existing.base/path/a/rendition_2.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=356000,CODECS="avc1.64000c,mp4a.40.2",RESOLUTION=400x224,PATHWAY-ID="CDN-B"
+https://existing.base/path/b/rendition_1.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=1000000,CODECS="avc1.64001e,mp4a.40.2",RESOLUTION=640x360,PATHWAY-ID="CDN-B"
+https://existing.base/path/b/rendition_2.m3u8
+`
+
+	pathwayRedundantPipelineB := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=356000,CODECS="avc1.64000c,mp4a.40.2",RESOLUTION=400x224
+https://existing.base/path/b/rendition_1.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000000,CODECS="avc1.64001e,mp4a.40.2",RESOLUTION=640x360
+https://existing.base/path/b/rendition_2.m3u8
+`
+
+	stableIDRedundant := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-STREAM-INF:BANDWIDTH=356400,CODECS="avc1.64000c,mp4a.40.2",RESOLUTION=400x224,STABLE-VARIANT-ID="low"
+https://existing.base/path/a/rendition_1.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=1100000,CODECS="avc1.64001e,mp4a.40.2",RESOLUTION=640x360,STABLE-VARIANT-ID="high"
+https://existing.base/path/a/rendition_2.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=356000,CODECS="avc1.64000c,mp4a.40.2",RESOLUTION=400x224,STABLE-VARIANT-ID="low"
+https://existing.base/path/b/rendition_1.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=1000000,CODECS="avc1.64001e,mp4a.40.2",RESOLUTION=640x360,STABLE-VARIANT-ID="high"
+https://existing.base/path/b/rendition_2.m3u8
+`
+
+	variant := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:8
+`
+
+	// mockPipelines responds to variant requests of each pipeline, named by
+	// their path, with the given status and age of the variant
+	type pipelineResp struct {
+		status int
+		age    time.Duration
+	}
+	mockPipelines := func(pipelines map[string]pipelineResp) func(*http.Request) (*http.Response, error) {
+		return func(req *http.Request) (*http.Response, error) {
+			p := pipelines[path.Base(path.Dir(req.URL.Path))]
+			resp := &http.Response{
+				StatusCode: p.status,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(variant)),
+				Header:     http.Header{},
+			}
+			resp.Header.Add("Last-Modified", time.Now().UTC().Add(-p.age).Format(http.TimeFormat))
+
+			return resp, nil
+		}
+	}
+
+	tests := []struct {
+		name           string
+		manifest       string
+		mockResp       func(req *http.Request) (*http.Response, error)
+		expectManifest string
+		expectErr      bool
+	}{
+		{
+			name:     "when the first of three pipelines is missing, expect the healthy pipeline modified last",
+			manifest: threeWayRedundant,
+			mockResp: mockPipelines(map[string]pipelineResp{
+				"a": {status: 404},
+				"b": {status: 200, age: 2 * time.Second},
+				"c": {status: 200, age: 6 * time.Second},
+			}),
+			expectManifest: threeWayRedundantPipelineB,
+		},
+		{
+			name:     "when only the last of three pipelines is fresh, expect the last pipeline",
+			manifest: threeWayRedundant,
+			mockResp: mockPipelines(map[string]pipelineResp{
+				"a": {status: 200, age: time.Minute},
+				"b": {status: 404},
+				"c": {status: 200},
+			}),
+			expectManifest: threeWayRedundantPipelineC,
+		},
+		{
+			name:     "when pipelines are set by pathway, expect the variants of the healthy pathway",
+			manifest: pathwayRedundant,
+			mockResp: mockPipelines(map[string]pipelineResp{
+				"a": {status: 200, age: time.Minute},
+				"b": {status: 200},
+			}),
+			expectManifest: pathwayRedundantPipelineB,
+		},
+		{
+			name:     "when copies share a stable variant id, expect the variants of the healthy pipeline",
+			manifest: stableIDRedundant,
+			mockResp: mockPipelines(map[string]pipelineResp{
+				"a": {status: 404},
+				"b": {status: 200},
+			}),
+			expectManifest: pathwayRedundantPipelineB,
+		},
+		{
+			name:     "when a pipeline fails to be checked but another is healthy, expect the healthy pipeline",
+			manifest: threeWayRedundant,
+			mockResp: mockPipelines(map[string]pipelineResp{
+				"a": {status: 500},
+				"b": {status: 404},
+				"c": {status: 200},
+			}),
+			expectManifest: threeWayRedundantPipelineC,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// pipeline health is cached across requests
+			pipelineHealth = newHealthChecks()
+
+			cfg := config.Config{
+				Hostname: "bakery.cbsi.video",
+				Client: config.Client{
+					Timeout:    5 * time.Second,
+					Tracer:     tracing.NoopTracer{},
+					HTTPClient: test.MockClient(tt.mockResp),
+				},
+			}
+			filter := NewHLSFilter("https://existing.base/path/master.m3u8", tt.manifest, cfg)
+			manifest, err := filter.FilterContent(context.Background(), &parsers.MediaFilters{DeWeave: true})
+
+			if err != nil && !tt.expectErr {
+				t.Errorf("FilterContent(context.Background(), ) didnt expect an error to be returned, got: %v", err)
+				return
+			} else if err == nil && tt.expectErr {
+				t.Error("FilterContent(context.Background(), ) expected an error, got nil")
+				return
+			}
+
+			if g, e := manifest, tt.expectManifest; g != e {
+				t.Errorf("FilterContent(context.Background(), ) wrong manifest returned)\ngot %v\nexpected: %v\ndiff: %v", g, e,
+					cmp.Diff(g, e))
+			}
+		})
+	}
+}
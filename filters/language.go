@@ -0,0 +1,123 @@
+package filters
+
+import (
+	"strings"
+)
+
+// iso6392 maps ISO-639-2 language codes, both bibliographic and
+// terminologic, to their ISO-639-1 equivalent
+var iso6392 = map[string]string{
+	"alb": "sq", "sqi": "sq",
+	"ara": "ar",
+	"arm": "hy", "hye": "hy",
+	"baq": "eu", "eus": "eu",
+	"ben": "bn",
+	"bul": "bg",
+	"bur": "my", "mya": "my",
+	"cat": "ca",
+	"chi": "zh", "zho": "zh",
+	"cze": "cs", "ces": "cs",
+	"dan": "da",
+	"dut": "nl", "nld": "nl",
+	"eng": "en",
+	"est": "et",
+	"fin": "fi",
+	"fre": "fr", "fra": "fr",
+	"geo": "ka", "kat": "ka",
+	"ger": "de", "deu": "de",
+	"gle": "ga",
+	"glg": "gl",
+	"gre": "el", "ell": "el",
+	"guj": "gu",
+	"heb": "he",
+	"hin": "hi",
+	"hrv": "hr",
+	"hun": "hu",
+	"ice": "is", "isl": "is",
+	"ind": "id",
+	"ita": "it",
+	"jpn": "ja",
+	"kan": "kn",
+	"kaz": "kk",
+	"khm": "km",
+	"kor": "ko",
+	"lao": "lo",
+	"lav": "lv",
+	"lit": "lt",
+	"mac": "mk", "mkd": "mk",
+	"mal": "ml",
+	"mar": "mr",
+	"may": "ms", "msa": "ms",
+	"mon": "mn",
+	"nep": "ne",
+	"nob": "nb",
+	"nno": "nn",
+	"nor": "no",
+	"pan": "pa",
+	"per": "fa", "fas": "fa",
+	"pol": "pl",
+	"por": "pt",
+	"rum": "ro", "ron": "ro",
+	"rus": "ru",
+	"slo": "sk", "slk": "sk",
+	"slv": "sl",
+	"spa": "es",
+	"srp": "sr",
+	"swa": "sw",
+	"swe": "sv",
+	"tam": "ta",
+	"tel": "te",
+	"tgl": "tl",
+	"tha": "th",
+	"tur": "tr",
+	"ukr": "uk",
+	"urd": "ur",
+	"vie": "vi",
+	"wel": "cy", "cym": "cy",
+	"zul": "zu",
+}
+
+// normalizeLanguage lowercases a language tag, using hyphens to separate
+// subtags, and maps an ISO-639-2 primary subtag to its ISO-639-1 equivalent
+func normalizeLanguage(tag string) string {
+	tag = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+
+	primary, subtags := tag, ""
+	if i := strings.Index(tag, "-"); i != -1 {
+		primary, subtags = tag[:i], tag[i:]
+	}
+
+	if code, found := iso6392[primary]; found {
+		primary = code
+	}
+
+	return primary + subtags
+}
+
+// matchLanguage returns true if the language matches any of the filtered
+// languages. A filtered primary language, e.g. `en`, matches all of its
+// regional variants, while a filtered regional variant, e.g. `pt-BR`, only
+// matches itself and its subtags. With exact matching the languages are
+// compared as they are, ignoring case.
+func matchLanguage(lang string, langs []string, exact bool) bool {
+	if lang == "" {
+		return false
+	}
+
+	normalized := normalizeLanguage(lang)
+	for _, l := range langs {
+		if exact {
+			if strings.EqualFold(l, lang) {
+				return true
+			}
+			continue
+		}
+
+		l = normalizeLanguage(l)
+		if normalized == l || strings.HasPrefix(normalized, l+"-") {
+			return true
+		}
+	}
+
+	return false
+}
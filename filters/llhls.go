@@ -0,0 +1,231 @@
+package filters
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/cbsinteractive/bakery/parsers"
+	"github.com/grafov/m3u8"
+)
+
+const (
+	tagServerControl   = "#EXT-X-SERVER-CONTROL:"
+	tagPartInf         = "#EXT-X-PART-INF:"
+	tagPart            = "#EXT-X-PART:"
+	tagPreloadHint     = "#EXT-X-PRELOAD-HINT:"
+	tagRenditionReport = "#EXT-X-RENDITION-REPORT:"
+	tagMediaSequence   = "#EXT-X-MEDIA-SEQUENCE:"
+)
+
+// deltaUpdateAttributes advertise playlist delta updates, which aren't
+// forwarded to the origin as skipped segments couldn't be filtered
+var deltaUpdateAttributes = []string{"CAN-SKIP-UNTIL", "CAN-SKIP-DATERANGES"}
+
+// lowLatencyTags holds the LL-HLS tags of a media playlist, which the
+// playlist decoder drops. They're set aside before decoding and written
+// back once the playlist is filtered.
+type lowLatencyTags struct {
+	// server control and part information, written in the playlist header
+	header []string
+	// partial segments keyed by the media sequence number of their parent
+	parts map[uint64][]string
+	// media sequence number of the segment whose parts follow the last segment
+	next    uint64
+	hints   []string
+	reports []string
+}
+
+// splitLowLatencyTags removes the LL-HLS tags from the media playlist,
+// returning nil tags when the playlist has none
+func splitLowLatencyTags(manifest string) (string, *lowLatencyTags) {
+	if !strings.Contains(manifest, tagPartInf) && !strings.Contains(manifest, tagServerControl) {
+		return manifest, nil
+	}
+
+	tags := &lowLatencyTags{parts: make(map[uint64][]string)}
+	lines := strings.Split(manifest, "\n")
+	filtered := lines[:0]
+	for _, line := range lines {
+		tag := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(tag, tagServerControl), strings.HasPrefix(tag, tagPartInf):
+			tags.header = append(tags.header, tag)
+		case strings.HasPrefix(tag, tagPart):
+			tags.parts[tags.next] = append(tags.parts[tags.next], tag)
+		case strings.HasPrefix(tag, tagPreloadHint):
+			tags.hints = append(tags.hints, tag)
+		case strings.HasPrefix(tag, tagRenditionReport):
+			tags.reports = append(tags.reports, tag)
+		default:
+			if strings.HasPrefix(tag, tagMediaSequence) {
+				seq, err := strconv.ParseUint(strings.TrimPrefix(tag, tagMediaSequence), 10, 64)
+				if err == nil {
+					tags.next = seq
+				}
+			} else if tag != "" && !strings.HasPrefix(tag, "#") {
+				tags.next++
+			}
+
+			filtered = append(filtered, line)
+		}
+	}
+
+	return strings.Join(filtered, "\n"), tags
+}
+
+// setLowLatencyTags adds the LL-HLS tags back to the filtered playlist, whose
+// segments have the given media sequence numbers. Partial segments, preload
+// hints and rendition reports only point to bakery and the cdn like the
+// segments do. The tags following the last segment are returned, as the
+// playlist encoder can't write them.
+func (h *HLSFilter) setLowLatencyTags(filters *parsers.MediaFilters, p *m3u8.MediaPlaylist,
+	tags *lowLatencyTags, sequences []uint64, cdn *url.URL) (string, error) {
+	absolute, err := getAbsoluteURL(h.originURL)
+	if err != nil {
+		return "", err
+	}
+
+	segmentURI := func(uri string) (string, error) {
+		return segmentURL(uri, *absolute, cdn)
+	}
+
+	var header []string
+	for _, line := range tags.header {
+		if strings.HasPrefix(line, tagServerControl) {
+			line = removeAttributes(line, deltaUpdateAttributes)
+		}
+		header = append(header, line)
+	}
+	appendPlaylistTags(p, header...)
+
+	for i, seq := range sequences {
+		parts, err := rewriteURIAttributes(tags.parts[seq], segmentURI)
+		if err != nil {
+			return "", err
+		}
+
+		appendSegmentTags(p.Segments[i], parts...)
+	}
+
+	// parts of the segment being written only follow the last segment of
+	// the origin playlist, along with the hint of the next part
+	var trailer []string
+	if len(sequences) > 0 && sequences[len(sequences)-1]+1 == tags.next {
+		parts, err := rewriteURIAttributes(tags.parts[tags.next], segmentURI)
+		if err != nil {
+			return "", err
+		}
+
+		hints, err := rewriteURIAttributes(tags.hints, segmentURI)
+		if err != nil {
+			return "", err
+		}
+
+		trailer = append(parts, hints...)
+	}
+
+	// rendition reports point to the other renditions as served by bakery
+	reports, err := rewriteURIAttributes(tags.reports, func(uri string) (string, error) {
+		uri, err := segmentURL(uri, *absolute, cdn)
+		if err != nil {
+			return uri, err
+		}
+
+		return h.normalizeTrimmedVariant(filters, uri)
+	})
+	if err != nil {
+		return "", err
+	}
+	trailer = append(trailer, reports...)
+
+	if len(trailer) == 0 {
+		return "", nil
+	}
+
+	return strings.Join(trailer, "\n") + "\n", nil
+}
+
+// rewriteURIAttributes rewrites the URI attribute of the given tag lines
+func rewriteURIAttributes(lines []string, rewrite func(string) (string, error)) ([]string, error) {
+	var rewritten []string
+	for _, line := range lines {
+		name, attributes := splitAttributes(line)
+		for i, attribute := range attributes {
+			if !strings.HasPrefix(attribute, `URI="`) {
+				continue
+			}
+
+			uri, err := rewrite(strings.Trim(strings.TrimPrefix(attribute, "URI="), `"`))
+			if err != nil {
+				return nil, err
+			}
+			attributes[i] = `URI="` + uri + `"`
+		}
+
+		rewritten = append(rewritten, name+strings.Join(attributes, ","))
+	}
+
+	return rewritten, nil
+}
+
+// removeAttributes drops the named attributes from a tag line
+func removeAttributes(line string, names []string) string {
+	name, attributes := splitAttributes(line)
+
+	kept := attributes[:0]
+	for _, attribute := range attributes {
+		removed := false
+		for _, n := range names {
+			if strings.HasPrefix(attribute, n+"=") {
+				removed = true
+				break
+			}
+		}
+
+		if !removed {
+			kept = append(kept, attribute)
+		}
+	}
+
+	return name + strings.Join(kept, ",")
+}
+
+// splitAttributes returns the name of a tag, including the trailing colon,
+// and its attributes, keeping commas within quoted values
+func splitAttributes(line string) (string, []string) {
+	i := strings.Index(line, ":")
+	if i == -1 {
+		return line, nil
+	}
+
+	var attributes []string
+	var quoted bool
+	start := i + 1
+	for j := start; j < len(line); j++ {
+		switch line[j] {
+		case '"':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				attributes = append(attributes, line[start:j])
+				start = j + 1
+			}
+		}
+	}
+
+	return line[:i+1], append(attributes, line[start:])
+}
+
+// tagAttributes returns the attributes of a tag line by name, unquoted
+func tagAttributes(line string) map[string]string {
+	_, list := splitAttributes(line)
+	attributes := make(map[string]string, len(list))
+	for _, attribute := range list {
+		if i := strings.Index(attribute, "="); i != -1 {
+			attributes[attribute[:i]] = strings.Trim(attribute[i+1:], `"`)
+		}
+	}
+
+	return attributes
+}
@@ -0,0 +1,517 @@
+package filters
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cbsinteractive/bakery/parsers"
+	"github.com/grafov/m3u8"
+)
+
+const (
+	tagDateRange  = "#EXT-X-DATERANGE:"
+	tagOATCLS     = "#EXT-OATCLS-SCTE35:"
+	tagAsset      = "#EXT-X-ASSET:"
+	tagCueOut     = "#EXT-X-CUE-OUT:"
+	tagCueOutCont = "#EXT-X-CUE-OUT-CONT:"
+	tagCueIn      = "#EXT-X-CUE-IN"
+)
+
+// cueTolerance absorbs the rounding of segment durations when matching the
+// start and end of ad breaks to segments
+const cueTolerance = 500 * time.Millisecond
+
+// dateRangeFormat is the format of the START-DATE of date ranges
+const dateRangeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+var errMissingProgramDateTime = errors.New("date ranges require the playlist to have program date times")
+
+// adBreak is an ad break signaled by cue tags of any dialect
+type adBreak struct {
+	id    string
+	start time.Time
+	// planned duration in seconds, 0 when unknown
+	planned float64
+	// actual duration in seconds, set once the break ends
+	duration float64
+	// splice_info_sections starting and ending the break, when signaled
+	out []byte
+	in  []byte
+	// id of the ad, without the CAID= prefix of EXT-X-ASSET
+	caid string
+}
+
+// ends returns true if the break is over by the given time
+func (b *adBreak) ends(at time.Time) bool {
+	d := b.duration
+	if d == 0 {
+		d = b.planned
+	}
+
+	return d > 0 && !at.Add(cueTolerance).Before(b.start.Add(seconds(d)))
+}
+
+// eventID returns the splice event id of the break, taken from its splice_insert
+// when signaled, otherwise derived from its start so all renditions agree on it
+func (b *adBreak) eventID() uint32 {
+	if id, found := spliceEventID(b.out); found {
+		return id
+	}
+
+	return uint32(b.start.UnixNano() / int64(time.Millisecond))
+}
+
+// cueTranslator rewrites the ad cues of a media playlist into a dialect,
+// following ad breaks across segments
+type cueTranslator struct {
+	dialect string
+	// start of each segment, following the program date times of the playlist
+	clock  []time.Time
+	hasPDT bool
+	// date ranges keyed by the index of the segment they precede
+	dateRanges map[int][]string
+	// ad breaks signaled by date ranges keyed by the index of their first segment
+	breaks map[int][]*adBreak
+	// SCTE-35 date ranges are kept as they are when translating into date ranges
+	passThrough bool
+	open        *adBreak
+}
+
+// newCueTranslator returns a translator of the ad cues of the segments, set in
+// MediaSegment.SCTE by the playlist decoder, and of the given date ranges.
+// Translators without a dialect only follow the ad breaks.
+func newCueTranslator(dialect string, segments []*m3u8.MediaSegment, dateRanges map[int][]string) *cueTranslator {
+	clock, hasPDT := segmentClock(segments)
+	t := &cueTranslator{
+		dialect:    dialect,
+		clock:      clock,
+		hasPDT:     hasPDT,
+		dateRanges: dateRanges,
+		breaks:     make(map[int][]*adBreak),
+	}
+
+	breaks := dateRangeBreaks(dateRanges, len(segments))
+	if dialect == parsers.SCTEDateRange && len(breaks) > 0 {
+		t.passThrough = true
+		return t
+	}
+
+	for _, b := range breaks {
+		if b.planned == 0 {
+			b.planned = b.duration
+		}
+
+		for i, s := range segments {
+			if s == nil || t.clock[i].Add(cueTolerance).Before(b.start) {
+				continue
+			}
+
+			if !b.ends(t.clock[i]) {
+				t.breaks[i] = append(t.breaks[i], b)
+			}
+			break
+		}
+	}
+
+	return t
+}
+
+// translate rewrites the ad cues of the segment at index i of the playlist.
+// Segments are expected in order, including the ones filtered out, so ad
+// breaks are followed from their start.
+func (t *cueTranslator) translate(i int, s *m3u8.MediaSegment) error {
+	// the cues of SCTE35_67_2014 carry no ad break boundaries, they're kept as is
+	cue := s.SCTE
+	if cue != nil && cue.Syntax == m3u8.SCTE35_67_2014 {
+		cue = nil
+	} else {
+		s.SCTE = nil
+	}
+
+	var lines []string
+	for _, line := range t.dateRanges[i] {
+		if t.passThrough || !isSCTEDateRange(line) {
+			lines = append(lines, line)
+		}
+	}
+
+	var translated []string
+	if !t.passThrough {
+		ended, started := t.follow(i, cue)
+		if ended != nil {
+			translated = append(translated, t.inTags(ended)...)
+		}
+
+		if started != nil {
+			translated = append(translated, t.outTags(started, t.clock[i])...)
+		} else if t.open != nil {
+			translated = append(translated, t.continueTags(t.open, t.clock[i])...)
+		}
+	}
+
+	if t.dialect == parsers.SCTEDateRange && len(translated) > 0 && !t.hasPDT {
+		return errMissingProgramDateTime
+	}
+	lines = append(lines, translated...)
+
+	// date ranges are placed on the timeline by the program date time
+	// of the segments, which may be trimmed away
+	if t.hasPDT && s.ProgramDateTime.IsZero() {
+		for _, line := range lines {
+			if strings.HasPrefix(line, tagDateRange) {
+				s.ProgramDateTime = t.clock[i]
+				break
+			}
+		}
+	}
+
+	appendSegmentTags(s, lines...)
+
+	return nil
+}
+
+// follow updates the ad break in progress with the segment at index i and its
+// cue, returning the break ending before the segment and the one starting
+// with it, if any
+func (t *cueTranslator) follow(i int, cue *m3u8.SCTE) (*adBreak, *adBreak) {
+	at := t.clock[i]
+	var ended *adBreak
+	if b := t.open; b != nil && (b.ends(at) || cue != nil && cue.CueType == m3u8.SCTE35Cue_End) {
+		b.duration = roundSeconds(at.Sub(b.start))
+		ended = b
+		t.open = nil
+	}
+
+	if t.open != nil {
+		return ended, nil
+	}
+
+	t.open = t.nextBreak(i, cue, at)
+	return ended, t.open
+}
+
+// nextBreak returns the ad break starting at the segment, if any
+func (t *cueTranslator) nextBreak(i int, cue *m3u8.SCTE, at time.Time) *adBreak {
+	var b *adBreak
+	switch {
+	case len(t.breaks[i]) > 0:
+		b = t.breaks[i][0]
+	case cue != nil && cue.CueType != m3u8.SCTE35Cue_End:
+		b = &adBreak{start: at, planned: cue.Time, caid: strings.TrimPrefix(cue.CAID, "CAID=")}
+		if cue.CueType == m3u8.SCTE35Cue_Mid {
+			b.start = at.Add(-seconds(cue.Elapsed))
+		}
+
+		if cue.Syntax == m3u8.SCTE35_OATCLS && cue.Cue != "" {
+			if splice, err := base64.StdEncoding.DecodeString(cue.Cue); err == nil {
+				b.out = splice
+			}
+		}
+	default:
+		return nil
+	}
+
+	if b.id == "" {
+		b.id = fmt.Sprintf("splice-%v", b.eventID())
+	}
+
+	return b
+}
+
+// outTags returns the tags starting the ad break, or continuing it when the
+// segment joins the break after its start
+func (t *cueTranslator) outTags(b *adBreak, at time.Time) []string {
+	joined := at.Sub(b.start) > cueTolerance
+	switch t.dialect {
+	case parsers.SCTECue:
+		if joined {
+			return nil
+		}
+
+		return []string{tagCueOut + "DURATION=" + formatSeconds(b.planned)}
+	case parsers.SCTEOATCLS:
+		if joined {
+			return t.continueTags(b, at)
+		}
+
+		lines := []string{tagOATCLS + base64.StdEncoding.EncodeToString(b.outSplice())}
+		if b.caid != "" {
+			lines = append(lines, tagAsset+"CAID="+b.caid)
+		}
+
+		return append(lines, tagCueOut+formatSeconds(b.planned))
+	case parsers.SCTEDateRange:
+		attributes := []string{
+			fmt.Sprintf("ID=%q", b.id),
+			fmt.Sprintf("START-DATE=%q", b.start.Format(dateRangeFormat)),
+		}
+		if b.planned > 0 {
+			attributes = append(attributes, "PLANNED-DURATION="+formatSeconds(b.planned))
+		}
+		attributes = append(attributes, "SCTE35-OUT="+formatSplice(b.outSplice()))
+
+		return []string{tagDateRange + strings.Join(attributes, ",")}
+	}
+
+	return nil
+}
+
+// continueTags returns the tags of the segments within the ad break
+func (t *cueTranslator) continueTags(b *adBreak, at time.Time) []string {
+	if t.dialect != parsers.SCTEOATCLS {
+		return nil
+	}
+
+	var caid string
+	if b.caid != "" {
+		caid = "CAID=" + b.caid + ","
+	}
+
+	return []string{fmt.Sprintf("%v%vElapsedTime=%v,Duration=%v,SCTE35=%v", tagCueOutCont, caid,
+		formatSeconds(roundSeconds(at.Sub(b.start))), formatSeconds(b.planned),
+		base64.StdEncoding.EncodeToString(b.outSplice()))}
+}
+
+// inTags returns the tags ending the ad break
+func (t *cueTranslator) inTags(b *adBreak) []string {
+	if t.dialect != parsers.SCTEDateRange {
+		return []string{tagCueIn}
+	}
+
+	in := b.in
+	if in == nil {
+		in = spliceInsert(b.eventID(), false, 0)
+	}
+
+	return []string{fmt.Sprintf("%vID=%q,START-DATE=%q,DURATION=%v,SCTE35-IN=%v", tagDateRange, b.id,
+		b.start.Format(dateRangeFormat), formatSeconds(b.duration), formatSplice(in))}
+}
+
+// outSplice returns the splice starting the break, encoding one when the
+// dialect it was signaled with doesn't carry it
+func (b *adBreak) outSplice() []byte {
+	if b.out != nil {
+		return b.out
+	}
+
+	return spliceInsert(b.eventID(), true, b.planned)
+}
+
+// dateRangeTags returns the EXT-X-DATERANGE tags of a media playlist, which the
+// playlist decoder drops, keyed by the index of the segment they precede. Tags
+// following the last segment are kept with it.
+func dateRangeTags(manifest string) map[int][]string {
+	tags := make(map[int][]string)
+	var segments int
+	for _, line := range strings.Split(manifest, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, tagDateRange):
+			tags[segments] = append(tags[segments], line)
+		case line != "" && !strings.HasPrefix(line, "#"):
+			segments++
+		}
+	}
+
+	if trailing, found := tags[segments]; found && segments > 0 {
+		delete(tags, segments)
+		tags[segments-1] = append(tags[segments-1], trailing...)
+	}
+
+	return tags
+}
+
+// dateRangeBreaks returns the ad breaks signaled by SCTE-35 date ranges, merging
+// the date ranges sharing an ID, in the order they start
+func dateRangeBreaks(dateRanges map[int][]string, segments int) []*adBreak {
+	var breaks []*adBreak
+	ids := make(map[string]*adBreak)
+	for i := 0; i < segments; i++ {
+		for _, line := range dateRanges[i] {
+			if !isSCTEDateRange(line) {
+				continue
+			}
+
+			attributes := tagAttributes(line)
+			start, err := time.Parse(time.RFC3339Nano, attributes["START-DATE"])
+			if err != nil {
+				continue
+			}
+
+			id := attributes["ID"]
+			b, found := ids[id]
+			if !found {
+				b = &adBreak{id: id, start: start}
+				ids[id] = b
+				breaks = append(breaks, b)
+			}
+
+			if d, err := strconv.ParseFloat(attributes["PLANNED-DURATION"], 64); err == nil {
+				b.planned = d
+			}
+
+			if d, err := strconv.ParseFloat(attributes["DURATION"], 64); err == nil {
+				b.duration = d
+			}
+
+			if splice, err := parseSplice(attributes["SCTE35-OUT"]); err == nil {
+				b.out = splice
+			}
+
+			if splice, err := parseSplice(attributes["SCTE35-IN"]); err == nil {
+				b.in = splice
+			}
+		}
+	}
+
+	return breaks
+}
+
+// isSCTEDateRange returns true if the date range signals an ad break
+func isSCTEDateRange(line string) bool {
+	return strings.HasPrefix(line, tagDateRange) &&
+		(strings.Contains(line, "SCTE35-OUT=") || strings.Contains(line, "SCTE35-IN="))
+}
+
+// segmentClock returns the start of each segment following the program date
+// times of the playlist, or counting from the unix epoch when it has none
+func segmentClock(segments []*m3u8.MediaSegment) ([]time.Time, bool) {
+	clock := make([]time.Time, len(segments))
+	first := -1
+	at := time.Unix(0, 0).UTC()
+	for i, s := range segments {
+		if s == nil {
+			continue
+		}
+
+		if !s.ProgramDateTime.IsZero() {
+			at = s.ProgramDateTime
+			if first == -1 {
+				first = i
+			}
+		}
+		clock[i] = at
+		at = at.Add(seconds(s.Duration))
+	}
+
+	if first == -1 {
+		return clock, false
+	}
+
+	// segments before the first program date time count back from it
+	at = clock[first]
+	for i := first - 1; i >= 0; i-- {
+		if segments[i] == nil {
+			continue
+		}
+		at = at.Add(-seconds(segments[i].Duration))
+		clock[i] = at
+	}
+
+	return clock, true
+}
+
+// spliceInsert encodes a splice_info_section with an immediate splice_insert
+// command, for breaks signaled by dialects that don't carry the splice
+func spliceInsert(eventID uint32, out bool, duration float64) []byte {
+	command := make([]byte, 6)
+	binary.BigEndian.PutUint32(command, eventID)
+	// splice_event_cancel_indicator unset
+	command[4] = 0x7f
+	// program_splice_flag, splice_immediate_flag and event_id_compliance_flag
+	command[5] = 0x40 | 0x10 | 0x08 | 0x07
+	if out {
+		command[5] |= 0x80
+	}
+
+	if out && duration > 0 {
+		command[5] |= 0x20
+		// break_duration in 90kHz ticks, without auto_return
+		ticks := uint64(math.Round(duration*90000)) & (1<<33 - 1)
+		command = append(command, 0x7e|byte(ticks>>32), byte(ticks>>24), byte(ticks>>16), byte(ticks>>8), byte(ticks))
+	}
+	// unique_program_id, avail_num and avails_expected
+	command = append(command, 0, 0, 0, 0)
+
+	// table_id, sap_type not specified and section_length, protocol_version,
+	// unencrypted with no pts_adjustment, cw_index, tier and
+	// splice_command_length, followed by the splice_insert command type
+	section := []byte{0xfc, 0x30, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0xff, 0xf0 | byte(len(command)>>8), byte(len(command)), 0x05}
+	section = append(section, command...)
+	// descriptor_loop_length
+	section = append(section, 0, 0)
+
+	length := len(section) + 4 - 3
+	section[1] |= byte(length >> 8)
+	section[2] = byte(length)
+
+	crc := make([]byte, 4)
+	binary.BigEndian.PutUint32(crc, crc32MPEG2(section))
+
+	return append(section, crc...)
+}
+
+// spliceEventID returns the splice_event_id of an unencrypted splice_insert
+func spliceEventID(splice []byte) (uint32, bool) {
+	if len(splice) < 18 || splice[0] != 0xfc || splice[4]&0x80 != 0 || splice[13] != 0x05 {
+		return 0, false
+	}
+
+	return binary.BigEndian.Uint32(splice[14:18]), true
+}
+
+// crc32MPEG2 returns the CRC_32 of a splice_info_section, which unlike the
+// IEEE checksum of hash/crc32 isn't reflected
+func crc32MPEG2(data []byte) uint32 {
+	crc := uint32(0xffffffff)
+	for _, b := range data {
+		crc ^= uint32(b) << 24
+		for i := 0; i < 8; i++ {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04c11db7
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+
+	return crc
+}
+
+// parseSplice decodes the hexadecimal splice of a date range
+func parseSplice(value string) ([]byte, error) {
+	if value == "" {
+		return nil, errors.New("missing splice")
+	}
+
+	value = strings.TrimPrefix(strings.TrimPrefix(value, "0x"), "0X")
+	return hex.DecodeString(value)
+}
+
+// formatSplice encodes the splice of a date range
+func formatSplice(splice []byte) string {
+	return "0x" + strings.ToUpper(hex.EncodeToString(splice))
+}
+
+// seconds returns the duration of the given seconds
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// roundSeconds returns the duration in seconds, rounded to milliseconds
+func roundSeconds(d time.Duration) float64 {
+	return math.Round(d.Seconds()*1000) / 1000
+}
+
+// formatSeconds formats seconds as cue tags do, without trailing zeros
+func formatSeconds(s float64) string {
+	return strconv.FormatFloat(s, 'f', -1, 64)
+}
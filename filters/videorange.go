@@ -0,0 +1,188 @@
+package filters
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/cbsinteractive/bakery/parsers"
+	"github.com/grafov/m3u8"
+	"github.com/zencoder/go-dash/v3/mpd"
+)
+
+const cicpTransferCharacteristics = "urn:mpeg:mpegB:cicp:TransferCharacteristics"
+
+// transferCharacteristics maps the TransferCharacteristics of ISO/IEC 23001-8
+// that aren't standard dynamic range to their video range
+var transferCharacteristics = map[int]string{
+	16: parsers.VideoRangePQ,
+	18: parsers.VideoRangeHLG,
+}
+
+// transferVideoRange returns the video range of a TransferCharacteristics value
+func transferVideoRange(value string) (string, bool) {
+	tc, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return "", false
+	}
+
+	if videoRange, found := transferCharacteristics[tc]; found {
+		return videoRange, true
+	}
+
+	return parsers.VideoRangeSDR, true
+}
+
+// codecVideoRange infers the video range of a video codec. Dolby Vision is
+// assumed to be PQ, and VP9 and AV1 codecs carry their transfer
+// characteristics when the optional color fields are set. HEVC codecs don't
+// carry them, so HEVC Main 10 is SDR unless the range is signaled otherwise.
+// It returns false for codecs that aren't video codecs.
+func codecVideoRange(codec string) (string, bool) {
+	fields := strings.Split(strings.ToLower(strings.TrimSpace(codec)), ".")
+	// the position of the transfer characteristics in vp09 and av01 codecs
+	var tc int
+	switch fields[0] {
+	case "avc1", "avc3", "dvav", "dva1", "hvc1", "hev1":
+		return parsers.VideoRangeSDR, true
+	case "dvh1", "dvhe", "dav1":
+		return parsers.VideoRangePQ, true
+	case "vp09":
+		tc = 6
+	case "av01":
+		tc = 7
+	default:
+		return "", false
+	}
+
+	if len(fields) > tc {
+		return transferVideoRange(fields[tc])
+	}
+
+	return parsers.VideoRangeSDR, true
+}
+
+// codecsVideoRange infers the video range of a list of codecs. Dolby Vision
+// enhancement layers and HDR codecs take precedence over their SDR base layer,
+// e.g. hvc1.2.4.L150,dvh1.08.07 is PQ. It returns false for lists without a
+// video codec.
+func codecsVideoRange(codecs string) (string, bool) {
+	var videoRange string
+	for _, codec := range strings.Split(codecs, ",") {
+		r, found := codecVideoRange(codec)
+		if !found {
+			continue
+		}
+
+		if r != parsers.VideoRangeSDR {
+			return r, true
+		}
+		videoRange = r
+	}
+
+	return videoRange, videoRange != ""
+}
+
+// variantVideoRange returns the video range of a variant, as set by its
+// VIDEO-RANGE attribute or inferred from its codecs. Video variants without
+// either are SDR, and it returns false for variants without video.
+func variantVideoRange(v *m3u8.Variant) (string, bool) {
+	if v.VideoRange != "" {
+		return strings.ToLower(v.VideoRange), true
+	}
+
+	if videoRange, found := codecsVideoRange(v.Codecs); found {
+		return videoRange, true
+	}
+
+	if isVideoVariant(v) {
+		return parsers.VideoRangeSDR, true
+	}
+
+	return "", false
+}
+
+// Returns true if the variant is of one of the video ranges. Variants
+// without video are kept
+func filterVariantVideoRange(v *m3u8.Variant, videoRanges []string) bool {
+	videoRange, found := variantVideoRange(v)
+	if !found {
+		return false
+	}
+
+	for _, r := range videoRanges {
+		if videoRange == r {
+			return true
+		}
+	}
+
+	return false
+}
+
+// filterVideoRange removes the video representations of the video ranges, along
+// with adaptation sets left without representations
+func (d *DASHFilter) filterVideoRange(filters *parsers.MediaFilters, manifest *mpd.MPD) error {
+	videoRanges := make(map[string]struct{})
+	for _, r := range filters.VideoRange {
+		videoRanges[r] = struct{}{}
+	}
+
+	for _, period := range manifest.Periods {
+		var filteredAdaptationSets []*mpd.AdaptationSet
+		for _, as := range period.AdaptationSets {
+			if as.ContentType == nil || ContentType(*as.ContentType) != videoContentType {
+				filteredAdaptationSets = append(filteredAdaptationSets, as)
+				continue
+			}
+
+			var filteredRepresentations []*mpd.Representation
+			for _, r := range as.Representations {
+				if videoRange, found := representationVideoRange(as, r); found {
+					if _, remove := videoRanges[videoRange]; remove {
+						continue
+					}
+				}
+
+				filteredRepresentations = append(filteredRepresentations, r)
+			}
+
+			as.Representations = filteredRepresentations
+			updateMaxResolution(as)
+
+			if len(as.Representations) != 0 {
+				filteredAdaptationSets = append(filteredAdaptationSets, as)
+			}
+		}
+
+		period.AdaptationSets = filteredAdaptationSets
+
+		// Recalculate AdaptationSet id numbers
+		for index, as := range period.AdaptationSets {
+			as.ID = strptr(strconv.Itoa(index))
+		}
+	}
+
+	return nil
+}
+
+// representationVideoRange returns the video range advertised by the
+// TransferCharacteristics descriptors of the representation, or of its
+// adaptation set, falling back to the one inferred from their codecs
+func representationVideoRange(as *mpd.AdaptationSet, r *mpd.Representation) (string, bool) {
+	for _, common := range []*mpd.CommonAttributesAndElements{&r.CommonAttributesAndElements, &as.CommonAttributesAndElements} {
+		for _, descriptors := range [][]mpd.DescriptorType{common.EssentialProperty, common.SupplementalProperty} {
+			for _, d := range descriptors {
+				if d.SchemeIDURI != nil && *d.SchemeIDURI == cicpTransferCharacteristics && d.Value != nil {
+					return transferVideoRange(*d.Value)
+				}
+			}
+		}
+	}
+
+	for _, codecs := range []*string{r.Codecs, as.Codecs} {
+		if codecs != nil && *codecs != "" {
+			return codecsVideoRange(*codecs)
+		}
+	}
+
+	return "", false
+}
@@ -3,25 +3,41 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 
 	"github.com/cbsinteractive/bakery/logging"
+	"github.com/cbsinteractive/bakery/parsers"
 )
 
 //ErrorResponse holds the errore response message
 type ErrorResponse struct {
 	Message string              `json:"message"`
 	Errors  map[string][]string `json:"errors"`
+	Filter  *parsers.ParseError `json:"filter,omitempty"`
 	Err     error               `json:"-"`
 }
 
 //NewErrorResponse holds a formatted error response
 //Errors returned from the parser, origin, filter packages
 //Will return in a `key: err` format. Where the key signals
-//the package scope source of the error
+//the package scope source of the error. Parse errors are
+//keyed by filter and returned in full under `filter`
 func NewErrorResponse(message string, err error) ErrorResponse {
+	var pErr *parsers.ParseError
+	if errors.As(err, &pErr) {
+		return ErrorResponse{
+			Message: message,
+			Errors: map[string][]string{
+				pErr.Filter: []string{pErr.Err.Error()},
+			},
+			Filter: pErr,
+			Err:    err,
+		}
+	}
+
 	errList := strings.Split(err.Error(), ": ")
 	errMap := map[string][]string{
 		errList[0]: errList[1:],
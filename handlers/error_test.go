@@ -8,6 +8,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/cbsinteractive/bakery/parsers"
 	test "github.com/cbsinteractive/bakery/tests"
 	"github.com/google/go-cmp/cmp"
 )
@@ -55,7 +56,34 @@ func TestHandler_ErrorResponse(t *testing.T) {
 			expectErr: ErrorResponse{
 				Message: "failed parsing filters",
 				Errors: map[string][]string{
-					"Bitrate": []string{"invalid range for provided values", "( 10000, 10 )"},
+					"Bitrate": []string{"invalid range for provided values: ( 10000, 10 )"},
+				},
+				Filter: &parsers.ParseError{
+					Filter:  "Bitrate",
+					Key:     "b",
+					Segment: 0,
+					Value:   "10000,10",
+					Hint:    "did you mean `b(10,10000)`?",
+				},
+			},
+		},
+		{
+			name:         "when request is made with an unknown nested filter, expect a hint to the right filter",
+			url:          "/v(avc)/a(lang(en))/origin/some/path/to/master.mpd",
+			auth:         "authenticate-me",
+			mockResp:     default200Response("OK"),
+			expectStatus: 400,
+			expectErr: ErrorResponse{
+				Message: "failed parsing filters",
+				Errors: map[string][]string{
+					"Audio": []string{"unsupported nested filter lang"},
+				},
+				Filter: &parsers.ParseError{
+					Filter:  "Audio",
+					Key:     "a",
+					Segment: 1,
+					Value:   "lang",
+					Hint:    "did you mean `a(l(en))`?",
 				},
 			},
 		},
@@ -83,6 +111,12 @@ func TestHandler_ErrorResponse(t *testing.T) {
 				Errors: map[string][]string{
 					"Protocol": []string{"unsupported protocol"},
 				},
+				Filter: &parsers.ParseError{
+					Filter:  "Protocol",
+					Segment: -1,
+					Value:   "request",
+					Hint:    "manifest paths must end in .m3u8, .mpd or .vtt",
+				},
 			},
 		},
 		{
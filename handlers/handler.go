@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net/http"
 
 	"github.com/cbsinteractive/bakery/config"
@@ -16,14 +17,20 @@ func LoadHandler(c config.Config) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 
-		// parse all the filters from the URL
-		masterManifestPath, mediaFilters, err := parsers.URLParse(r.URL.Path)
+		// parse all the filters from the URL, query or body
+		masterManifestPath, mediaFilters, err := parseFilters(r, c.Presets.Filters)
 		if err != nil {
 			e := NewErrorResponse("failed parsing filters", err)
 			e.HandleError(r.Context(), w, http.StatusBadRequest)
 			return
 		}
 
+		if err := mediaFilters.ValidateCDN(c.CDNs.Names()); err != nil {
+			e := NewErrorResponse("failed parsing filters", err)
+			e.HandleError(r.Context(), w, http.StatusBadRequest)
+			return
+		}
+
 		//configure origin from path
 		o, err := origin.Configure(r.Context(), c, masterManifestPath)
 		if err != nil {
@@ -32,8 +39,23 @@ func LoadHandler(c config.Config) http.Handler {
 			return
 		}
 
+		// query parameters that aren't filters, e.g. tokens, are meant for the origin
+		o = origin.WithQuery(o, parsers.OriginQuery(r.URL.Query()))
+
+		// live media playlist requests are held by the origin until the
+		// requested segment or part is available
+		if mediaFilters.Protocol == parsers.ProtocolHLS {
+			o = origin.WithBlockingReload(o, r.URL.Query())
+		}
+
 		logging.UpdateCtx(r.Context(), logging.Params{"playbackURL": o.GetPlaybackURL()})
 
+		// pipelines of redundant manifests deweaved by earlier requests are
+		// health checked while the manifest is fetched
+		if mediaFilters.DeWeave && mediaFilters.Protocol == parsers.ProtocolHLS {
+			filters.PrefetchPipelineHealth(o.GetPlaybackURL(), c.Client)
+		}
+
 		// fetch manifest from origin
 		contentInfo, err := o.FetchOriginContent(r.Context(), c.Client)
 		if err != nil {
@@ -93,3 +115,18 @@ func LoadHandler(c config.Config) http.Handler {
 		fmt.Fprint(w, filteredManifest)
 	})
 }
+
+// parseFilters reads the filters set in the request path along with the
+// ones passed as query parameters, or as a JSON body for POST requests
+func parseFilters(r *http.Request, presets parsers.Presets) (string, *parsers.MediaFilters, error) {
+	if r.Method != http.MethodPost {
+		return parsers.QueryParse(r.URL.Path, r.URL.Query(), presets)
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return "", &parsers.MediaFilters{}, fmt.Errorf("Body: %w", err)
+	}
+
+	return parsers.JSONParse(r.URL.Path, body, presets)
+}
@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 	"time"
 
@@ -33,6 +34,11 @@ func testConfig(fc test.FakeClient) config.Config {
 		Propeller: config.Propeller{
 			Enabled: true,
 		},
+		CDNs: config.CDNs{
+			Hosts: map[string]*url.URL{
+				"akamai": {Scheme: "https", Host: "cbsi.akamaized.net"},
+			},
+		},
 	}
 }
 
@@ -45,6 +51,15 @@ func getRequest(url string, t *testing.T) *http.Request {
 	return req
 }
 
+func postRequest(url, body string, t *testing.T) *http.Request {
+	req, err := http.NewRequest("POST", url, bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatalf("could not create request to endpoint: %v, got error: %v", url, err)
+	}
+
+	return req
+}
+
 func getResponseRecorder() *httptest.ResponseRecorder {
 	return httptest.NewRecorder()
 }
@@ -94,6 +109,31 @@ http://existing.base/uri/link_4.m3u8
 http://existing.base/uri/link_5.m3u8
 `
 }
+
+func getFilteredManifest() string {
+	return `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4000,CODECS="avc1.77.30,mp4a"
+http://existing.base/uri/link_2.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=6000,CODECS="avc1.77.30,mp4a"
+http://existing.base/uri/link_3.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=8000,CODECS="avc1.77.30,mp4a"
+http://existing.base/uri/link_4.m3u8
+`
+}
+
+func getCDNManifest() string {
+	return `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4000,CODECS="avc1.77.30,mp4a"
+https://cbsi.akamaized.net/uri/link_2.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=6000,CODECS="avc1.77.30,mp4a"
+https://cbsi.akamaized.net/uri/link_3.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=8000,CODECS="avc1.77.30,mp4a"
+https://cbsi.akamaized.net/uri/link_4.m3u8
+`
+}
+
 func readManifestTestFixtures(fileName string) string {
 	manifest, err := ioutil.ReadFile(fmt.Sprintf("../tests/%v", fileName))
 	if err != nil {
@@ -108,6 +148,7 @@ func TestHandler(t *testing.T) {
 	tests := []struct {
 		name           string
 		url            string
+		body           string
 		auth           string
 		mockResp       func(req *http.Request) (*http.Response, error)
 		expectStatus   int
@@ -161,6 +202,40 @@ func TestHandler(t *testing.T) {
 			expectStatus:   200,
 			expectManifest: readManifestTestFixtures("default_manifest.m3u8"),
 		},
+		{
+			name:           "when filters are passed as query params, apply them",
+			url:            "origin/some/path/to/master.m3u8?b=4000,8000&token=abc",
+			auth:           "authenticate-me",
+			mockResp:       default200Response(getManifest()),
+			expectStatus:   200,
+			expectManifest: getFilteredManifest(),
+		},
+		{
+			name:           "when filters are posted as a json body, apply them",
+			url:            "origin/some/path/to/master.m3u8",
+			body:           `{"Bitrate":{"Min":4000,"Max":8000}}`,
+			auth:           "authenticate-me",
+			mockResp:       default200Response(getManifest()),
+			expectStatus:   200,
+			expectManifest: getFilteredManifest(),
+		},
+		{
+			name:           "when a configured cdn is selected, expect variants to point to it",
+			url:            "cdn(akamai)/b(4000,8000)/origin/some/path/to/master.m3u8",
+			auth:           "authenticate-me",
+			mockResp:       default200Response(getManifest()),
+			expectStatus:   200,
+			expectManifest: getCDNManifest(),
+		},
+		{
+			name:         "when the selected cdn isn't configured, expect 400 w/ a hint to the closest cdn",
+			url:          "cdn(akamia)/origin/some/path/to/master.m3u8",
+			auth:         "authenticate-me",
+			mockResp:     default200Response(getManifest()),
+			expectStatus: 400,
+			expectManifest: `{"message":"failed parsing filters","errors":{"CDN":["cdn akamia is not configured"]},` +
+				`"filter":{"filter":"CDN","key":"cdn","segment":-1,"value":"akamia","hint":"did you mean ` + "`cdn(akamai)`" + `?"}}` + "\n",
+		},
 	}
 
 	for _, tc := range tests {
@@ -168,6 +243,9 @@ func TestHandler(t *testing.T) {
 		handler := LoadHandler(c)
 		// set req + response recorder and serve it
 		req := getRequest(tc.url, t)
+		if tc.body != "" {
+			req = postRequest(tc.url, tc.body, t)
+		}
 		req.Header.Set("x-bakery-origin-token", tc.auth)
 		rec := getResponseRecorder()
 		handler.ServeHTTP(rec, req)
@@ -9,9 +9,11 @@ import (
 	"net/http"
 	"net/url"
 	"path"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/cbsinteractive/bakery/cache"
 	"github.com/cbsinteractive/bakery/config"
 )
 
@@ -85,13 +87,204 @@ func (d *DefaultOrigin) FetchOriginContent(ctx context.Context, c config.Client)
 	return fetch(ctx, c, d.GetPlaybackURL())
 }
 
+// blockingReloadParams are the LL-HLS query parameters players set to hold
+// a media playlist request until the requested segment or part is available
+var blockingReloadParams = []string{"_HLS_msn", "_HLS_part"}
+
+// llhlsParamPrefix prefixes the LL-HLS query parameters, which are only
+// forwarded when they request a blocking playlist reload
+const llhlsParamPrefix = "_HLS_"
+
+// forwardedQuery forwards query parameters of the request to an origin
+type forwardedQuery struct {
+	Origin
+	query url.Values
+}
+
+// WithQuery returns an origin that forwards the query parameters when
+// fetching its content, e.g. tokens expected by the origin. LL-HLS parameters
+// are left to WithBlockingReload. The origin is returned as it is when there
+// are none to forward.
+func WithQuery(o Origin, query url.Values) Origin {
+	forwarded := url.Values{}
+	for param, value := range query {
+		if !strings.HasPrefix(param, llhlsParamPrefix) {
+			forwarded[param] = value
+		}
+	}
+
+	return forward(o, forwarded)
+}
+
+// WithBlockingReload returns an origin that forwards the LL-HLS blocking
+// playlist reload parameters set in the query when fetching its content.
+// The origin is returned as it is when none are set.
+func WithBlockingReload(o Origin, query url.Values) Origin {
+	forwarded := url.Values{}
+	for _, param := range blockingReloadParams {
+		if value, found := query[param]; found {
+			forwarded[param] = value
+		}
+	}
+
+	return forward(o, forwarded)
+}
+
+// forward adds the query parameters to the ones forwarded by the origin
+func forward(o Origin, query url.Values) Origin {
+	if len(query) == 0 {
+		return o
+	}
+
+	f, ok := o.(*forwardedQuery)
+	if !ok {
+		f = &forwardedQuery{Origin: o, query: url.Values{}}
+	}
+
+	for param, value := range query {
+		f.query[param] = value
+	}
+
+	return f
+}
+
+// FetchOriginContent will grab the contents of the origin with the forwarded
+// query parameters. Blocking playlist reloads are given the time the origin
+// may hold them for on top of the client timeout.
+func (f *forwardedQuery) FetchOriginContent(ctx context.Context, c config.Client) (OriginContentInfo, error) {
+	u, err := url.Parse(f.GetPlaybackURL())
+	if err != nil {
+		return OriginContentInfo{}, fmt.Errorf("forwarding query: %w", err)
+	}
+
+	query := u.Query()
+	for param, value := range f.query {
+		query[param] = value
+	}
+	u.RawQuery = query.Encode()
+
+	timeout := c.Timeout
+	if f.blockingReload() {
+		timeout = targetDurations.blockingReloadTimeout(u.String(), c)
+	}
+
+	return fetchWithTimeout(ctx, c, u.String(), timeout)
+}
+
+// blockingReload returns true if the forwarded query requests a blocking
+// playlist reload
+func (f *forwardedQuery) blockingReload() bool {
+	for _, param := range blockingReloadParams {
+		if _, found := f.query[param]; found {
+			return true
+		}
+	}
+
+	return false
+}
+
+// targetDurationTTL is how long the target duration of a media playlist is
+// remembered after it was last fetched
+const targetDurationTTL = time.Minute
+
+// blockingReloadTargetDurations is the number of target durations the
+// origin may hold a blocking playlist reload for
+const blockingReloadTargetDurations = 3
+
+// targetDurations remembers the target durations of the media playlists
+// fetched, so blocking reloads of a playlist can last as long as the origin
+// may hold them
+var targetDurations = newTargetDurationCache()
+
+// targetDurationCache caches the target durations of media playlists by
+// their url, leaving the blocking reload parameters out
+type targetDurationCache struct {
+	durations *cache.Cache
+}
+
+func newTargetDurationCache() *targetDurationCache {
+	return &targetDurationCache{durations: cache.New()}
+}
+
+// remember keeps the target duration of the playlist fetched from the origin
+// url, if it has one
+func (tc *targetDurationCache) remember(originURL string, playlist string) {
+	duration, found := targetDuration(playlist)
+	if !found {
+		return
+	}
+
+	tc.durations.Set(playlistKey(originURL), duration, targetDurationTTL)
+}
+
+// blockingReloadTimeout returns how long a blocking reload of the playlist
+// at the origin url may take: the client timeout on top of three target
+// durations, or the blocking reload timeout when the target duration of the
+// playlist isn't known yet
+func (tc *targetDurationCache) blockingReloadTimeout(originURL string, c config.Client) time.Duration {
+	if d, found := tc.durations.Get(playlistKey(originURL)); found {
+		return c.Timeout + blockingReloadTargetDurations*d.(time.Duration)
+	}
+
+	if c.BlockingReloadTimeout > c.Timeout {
+		return c.BlockingReloadTimeout
+	}
+
+	return c.Timeout
+}
+
+// playlistKey returns the url without its blocking reload parameters, so
+// blocking reloads of a playlist share the target duration of the playlist
+func playlistKey(originURL string) string {
+	u, err := url.Parse(originURL)
+	if err != nil {
+		return originURL
+	}
+
+	query := u.Query()
+	for _, param := range blockingReloadParams {
+		query.Del(param)
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
+
+// targetDuration returns the target duration of a media playlist
+func targetDuration(playlist string) (time.Duration, bool) {
+	const tag = "#EXT-X-TARGETDURATION:"
+
+	i := strings.Index(playlist, tag)
+	if i < 0 {
+		return 0, false
+	}
+
+	value := playlist[i+len(tag):]
+	if end := strings.IndexAny(value, "\r\n"); end >= 0 {
+		value = value[:end]
+	}
+
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || seconds <= 0 {
+		return 0, false
+	}
+
+	return time.Duration(seconds * float64(time.Second)), true
+}
+
 func fetch(ctx context.Context, client config.Client, originURL string) (OriginContentInfo, error) {
+	return fetchWithTimeout(ctx, client, originURL, client.Timeout)
+}
+
+// fetchWithTimeout fetches the origin url, giving up after the timeout. The
+// target duration of media playlists is remembered for later blocking reloads.
+func fetchWithTimeout(ctx context.Context, client config.Client, originURL string, timeout time.Duration) (OriginContentInfo, error) {
 	req, err := http.NewRequest(http.MethodGet, originURL, nil)
 	if err != nil {
 		return OriginContentInfo{}, fmt.Errorf("generating request to fetch origin: %w", err)
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, client.Timeout)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
 	resp, err := client.Do(req.WithContext(ctx))
@@ -113,6 +306,10 @@ func fetch(ctx context.Context, client config.Client, originURL string) (OriginC
 		}
 	}
 
+	if resp.StatusCode/100 == 2 {
+		targetDurations.remember(originURL, string(origin))
+	}
+
 	return OriginContentInfo{
 		Payload:      string(origin),
 		LastModified: lastModified,
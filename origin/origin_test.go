@@ -161,6 +161,181 @@ func TestOrigin_GetPlaybackURL(t *testing.T) {
 	}
 }
 
+func TestOrigin_WithBlockingReload(t *testing.T) {
+	playbackURL, err := url.Parse("https://origin.com/path/to/manifest/720p.m3u8?token=abc")
+	if err != nil {
+		t.Errorf("Unable to make test urls")
+	}
+
+	tests := []struct {
+		name              string
+		query             url.Values
+		expectOriginURL   string
+		expectPlaybackURL string
+	}{
+		{
+			name:              "when blocking reload parameters are set, expect them forwarded to the origin",
+			query:             url.Values{"_HLS_msn": {"273"}, "_HLS_part": {"2"}, "v": {"avc"}},
+			expectOriginURL:   "https://origin.com/path/to/manifest/720p.m3u8?_HLS_msn=273&_HLS_part=2&token=abc",
+			expectPlaybackURL: "https://origin.com/path/to/manifest/720p.m3u8?token=abc",
+		},
+		{
+			name:              "when delta updates are requested, expect the full playlist to be fetched",
+			query:             url.Values{"_HLS_msn": {"273"}, "_HLS_skip": {"YES"}},
+			expectOriginURL:   "https://origin.com/path/to/manifest/720p.m3u8?_HLS_msn=273&token=abc",
+			expectPlaybackURL: "https://origin.com/path/to/manifest/720p.m3u8?token=abc",
+		},
+		{
+			name:              "when no blocking reload parameters are set, expect the playback url to be fetched",
+			query:             url.Values{"v": {"avc"}},
+			expectOriginURL:   "https://origin.com/path/to/manifest/720p.m3u8?token=abc",
+			expectPlaybackURL: "https://origin.com/path/to/manifest/720p.m3u8?token=abc",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var originURL string
+			c := testConfig(test.MockClient(func(req *http.Request) (*http.Response, error) {
+				originURL = req.URL.String()
+				return getMockResp(200, "OK")(req)
+			}))
+
+			o := WithBlockingReload(&DefaultOrigin{URL: *playbackURL}, tc.query)
+			if _, err := o.FetchOriginContent(context.Background(), c.Client); err != nil {
+				t.Fatalf("FetchOriginContent() didnt expect an error to be returned, got: %v", err)
+			}
+
+			if originURL != tc.expectOriginURL {
+				t.Errorf("Wrong origin url: expect: %q, got %q", tc.expectOriginURL, originURL)
+			}
+
+			if got := o.GetPlaybackURL(); got != tc.expectPlaybackURL {
+				t.Errorf("Wrong playback url: expect: %q, got %q", tc.expectPlaybackURL, got)
+			}
+		})
+	}
+}
+
+func TestOrigin_WithBlockingReload_Timeout(t *testing.T) {
+	playbackURL, err := url.Parse("https://origin.com/path/to/manifest/720p.m3u8?token=abc")
+	if err != nil {
+		t.Errorf("Unable to make test urls")
+	}
+
+	playlist := "#EXTM3U\n#EXT-X-VERSION:6\n#EXT-X-TARGETDURATION:4\n#EXTINF:4.000,\nsegment_1.mp4\n"
+
+	tests := []struct {
+		name          string
+		query         url.Values
+		fetchPlaylist bool
+		expectTimeout time.Duration
+	}{
+		{
+			name:          "when the playlist isn't a blocking reload, expect the client timeout",
+			query:         url.Values{"token": {"abc"}},
+			fetchPlaylist: true,
+			expectTimeout: 5 * time.Second,
+		},
+		{
+			name:          "when the target duration of the playlist is known, expect three target durations on top of the client timeout",
+			query:         url.Values{"_HLS_msn": {"273"}, "_HLS_part": {"2"}},
+			fetchPlaylist: true,
+			expectTimeout: 17 * time.Second,
+		},
+		{
+			name:          "when the target duration of the playlist isn't known, expect the blocking reload timeout",
+			query:         url.Values{"_HLS_msn": {"273"}},
+			expectTimeout: 30 * time.Second,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			targetDurations = newTargetDurationCache()
+
+			var timeout time.Duration
+			c := testConfig(test.MockClient(func(req *http.Request) (*http.Response, error) {
+				if deadline, found := req.Context().Deadline(); found {
+					timeout = time.Until(deadline).Round(time.Second)
+				}
+				return getMockResp(200, playlist)(req)
+			}))
+			c.Client.BlockingReloadTimeout = 30 * time.Second
+
+			o := WithQuery(&DefaultOrigin{URL: *playbackURL}, url.Values{"token": {"abc"}})
+			if tc.fetchPlaylist {
+				if _, err := o.FetchOriginContent(context.Background(), c.Client); err != nil {
+					t.Fatalf("FetchOriginContent() didnt expect an error to be returned, got: %v", err)
+				}
+			}
+
+			o = WithBlockingReload(o, tc.query)
+			if _, err := o.FetchOriginContent(context.Background(), c.Client); err != nil {
+				t.Fatalf("FetchOriginContent() didnt expect an error to be returned, got: %v", err)
+			}
+
+			if timeout != tc.expectTimeout {
+				t.Errorf("Wrong timeout: expect: %v, got %v", tc.expectTimeout, timeout)
+			}
+		})
+	}
+}
+
+func TestOrigin_WithQuery(t *testing.T) {
+	playbackURL, err := url.Parse("https://origin.com/path/to/manifest/720p.m3u8?token=abc")
+	if err != nil {
+		t.Errorf("Unable to make test urls")
+	}
+
+	tests := []struct {
+		name            string
+		query           url.Values
+		blockingReload  bool
+		expectOriginURL string
+	}{
+		{
+			name:            "when query parameters are set, expect them forwarded to the origin",
+			query:           url.Values{"token": {"def"}, "cb": {"1600000000"}},
+			expectOriginURL: "https://origin.com/path/to/manifest/720p.m3u8?cb=1600000000&token=def",
+		},
+		{
+			name:            "when ll-hls parameters are set, expect them left to blocking reloads",
+			query:           url.Values{"_HLS_msn": {"273"}, "_HLS_skip": {"YES"}, "cb": {"1600000000"}},
+			expectOriginURL: "https://origin.com/path/to/manifest/720p.m3u8?cb=1600000000&token=abc",
+		},
+		{
+			name:            "when combined with a blocking reload, expect both to be forwarded",
+			query:           url.Values{"_HLS_msn": {"273"}, "_HLS_skip": {"YES"}, "cb": {"1600000000"}},
+			blockingReload:  true,
+			expectOriginURL: "https://origin.com/path/to/manifest/720p.m3u8?_HLS_msn=273&cb=1600000000&token=abc",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var originURL string
+			c := testConfig(test.MockClient(func(req *http.Request) (*http.Response, error) {
+				originURL = req.URL.String()
+				return getMockResp(200, "OK")(req)
+			}))
+
+			o := WithQuery(&DefaultOrigin{URL: *playbackURL}, tc.query)
+			if tc.blockingReload {
+				o = WithBlockingReload(o, tc.query)
+			}
+
+			if _, err := o.FetchOriginContent(context.Background(), c.Client); err != nil {
+				t.Fatalf("FetchOriginContent() didnt expect an error to be returned, got: %v", err)
+			}
+
+			if originURL != tc.expectOriginURL {
+				t.Errorf("Wrong origin url: expect: %q, got %q", tc.expectOriginURL, originURL)
+			}
+		})
+	}
+}
+
 func TestOrigin_Configure(t *testing.T) {
 	absTestURL, err := url.Parse("https://stream/some/path/request/to/master.m3u8")
 	relTestURL, err := url.Parse("/some/path/request/to/master.m3u8")
@@ -0,0 +1,83 @@
+package parsers
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ParseError is returned when a filter can't be parsed or fails validation.
+// It points at the filter that needs fixing and, when possible, hints at
+// how to fix it.
+type ParseError struct {
+	// Filter is the name of the filter, e.g. Bitrate
+	Filter string `json:"filter"`
+	// Key is the filter key as used in the url, e.g. b
+	Key string `json:"key,omitempty"`
+	// Segment is the zero based index of the path segment holding
+	// the filter, or -1 when the filter wasn't set in the path
+	Segment int `json:"segment"`
+	// Value is the offending value
+	Value string `json:"value,omitempty"`
+	// Hint suggests a fix for the filter
+	Hint string `json:"hint,omitempty"`
+	Err  error  `json:"-"`
+}
+
+// Error returns the error message prefixed by the filter name
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Filter, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// nestedError is returned by nested filters. It is turned into a ParseError
+// by the filter holding them, which knows how to format the hint.
+type nestedError struct {
+	value      string
+	suggestion string
+	err        error
+}
+
+func (e *nestedError) Error() string {
+	return e.err.Error()
+}
+
+// filterError returns a ParseError for the filter set with the given key
+func filterError(key, value string, err error) *ParseError {
+	name, found := filterKeys[key]
+	if !found {
+		name = key
+	}
+
+	return &ParseError{
+		Filter:  name,
+		Key:     key,
+		Segment: -1,
+		Value:   value,
+		Err:     err,
+	}
+}
+
+// nestedFilterError returns a ParseError for an error returned by the
+// nested filters of the given key
+func nestedFilterError(key string, err error) *ParseError {
+	var nErr *nestedError
+	if !errors.As(err, &nErr) {
+		return filterError(key, "", err)
+	}
+
+	pErr := filterError(key, nErr.value, nErr.err)
+	if nErr.suggestion != "" {
+		pErr.Hint = hint(key, nErr.suggestion)
+	}
+
+	return pErr
+}
+
+// hint formats a suggestion for the filter set with the given key
+func hint(key string, values ...string) string {
+	return fmt.Sprintf("did you mean `%v`?", filterSegment(key, values...))
+}
@@ -0,0 +1,255 @@
+package parsers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestURLParse_ParseError(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		expectErr ParseError
+		expectMsg string
+	}{
+		{
+			name:  "when bitrate range is reversed, expect the swapped range as hint",
+			input: "/b(10000,10)/master.m3u8",
+			expectErr: ParseError{
+				Filter:  "Bitrate",
+				Key:     "b",
+				Segment: 0,
+				Value:   "10000,10",
+				Hint:    "did you mean `b(10,10000)`?",
+			},
+			expectMsg: "Bitrate: invalid range for provided values: ( 10000, 10 )",
+		},
+		{
+			name:  "when a language is passed as an audio codec, expect a language hint",
+			input: "/v(avc)/a(en)/master.m3u8",
+			expectErr: ParseError{
+				Filter:  "Audio",
+				Key:     "a",
+				Segment: 1,
+				Value:   "en",
+				Hint:    "did you mean `a(l(en))`?",
+			},
+			expectMsg: "Audio: Codec en is not supported",
+		},
+		{
+			name:  "when a nested filter key is unknown, expect a hint to the closest key",
+			input: "/a(mp4a,lang(en))/master.m3u8",
+			expectErr: ParseError{
+				Filter:  "Audio",
+				Key:     "a",
+				Segment: 0,
+				Value:   "lang",
+				Hint:    "did you mean `a(l(en))`?",
+			},
+			expectMsg: "Audio: unsupported nested filter lang",
+		},
+		{
+			name:  "when input is left after a nested filter, expect an error",
+			input: "/a(l(en)x)/master.m3u8",
+			expectErr: ParseError{
+				Filter:  "Audio",
+				Key:     "a",
+				Segment: 0,
+				Value:   "x",
+			},
+			expectMsg: "Audio: unexpected x after l(en)",
+		},
+		{
+			name:  "when a codec follows a nested filter without a comma, expect the separated values as hint",
+			input: "/v(b(0,100)avc)/master.m3u8",
+			expectErr: ParseError{
+				Filter:  "Video",
+				Key:     "v",
+				Segment: 0,
+				Value:   "avc",
+				Hint:    "did you mean `v(b(0,100),avc)`?",
+			},
+			expectMsg: "Video: unexpected avc after b(0,100)",
+		},
+		{
+			name:  "when a nested resolution range is reversed, expect the swapped range as hint",
+			input: "/v(avc,res(1080,720))/master.mpd",
+			expectErr: ParseError{
+				Filter:  "Video",
+				Key:     "v",
+				Segment: 0,
+				Value:   "1080,720",
+				Hint:    "did you mean `v(res(720,1080))`?",
+			},
+			expectMsg: "Video: invalid range for provided values: ( 1080, 720 )",
+		},
+		{
+			name:  "when a codec is misspelled, expect the closest codec as hint",
+			input: "/v(hevx)/master.mpd",
+			expectErr: ParseError{
+				Filter:  "Video",
+				Key:     "v",
+				Segment: 0,
+				Value:   "hevx",
+				Hint:    "did you mean `v(hevc)`?",
+			},
+			expectMsg: "Video: Codec hevx is not supported",
+		},
+		{
+			name:  "when a renditions strategy is misspelled, expect the closest strategy as hint",
+			input: "/n(4,spred)/master.mpd",
+			expectErr: ParseError{
+				Filter:  "Renditions",
+				Key:     "n",
+				Segment: 0,
+				Value:   "spred",
+				Hint:    "did you mean `n(4,spread)`?",
+			},
+			expectMsg: "Renditions: strategy spred is not supported",
+		},
+		{
+			name:  "when a sort order is misspelled, expect the closest order as hint",
+			input: "/sort(des)/master.m3u8",
+			expectErr: ParseError{
+				Filter:  "Sort",
+				Key:     "sort",
+				Segment: 0,
+				Value:   "des",
+				Hint:    "did you mean `sort(desc)`?",
+			},
+			expectMsg: "Sort: order des is not supported",
+		},
+		{
+			name:  "when a tag is neither a known value nor an hls tag, expect an error",
+			input: "/tags(ads,cue)/master.m3u8",
+			expectErr: ParseError{
+				Filter:  "Tags",
+				Key:     "tags",
+				Segment: 0,
+				Value:   "ads,cue",
+			},
+			expectMsg: "Tags: expected ads, iframe or the name of an hls tag, e.g. EXT-X-ASSET, got cue",
+		},
+		{
+			name:  "when a playlist type is misspelled, expect the closest type as hint",
+			input: "/type(evnt)/master.m3u8",
+			expectErr: ParseError{
+				Filter:  "Playlist Type",
+				Key:     "type",
+				Segment: 0,
+				Value:   "evnt",
+				Hint:    "did you mean `type(event)`?",
+			},
+			expectMsg: "Playlist Type: playlist type evnt is not supported",
+		},
+		{
+			name:  "when a playlist type other than vod is given for dash, expect vod as hint",
+			input: "/type(live)/manifest.mpd",
+			expectErr: ParseError{
+				Filter:  "Playlist Type",
+				Key:     "type",
+				Segment: 0,
+				Value:   "live",
+				Hint:    "did you mean `type(vod)`?",
+			},
+			expectMsg: "Playlist Type: playlist type live is not supported for dash",
+		},
+		{
+			name:  "when an scte-35 dialect is misspelled, expect the closest dialect as hint",
+			input: "/scte(datarange)/master.m3u8",
+			expectErr: ParseError{
+				Filter:  "SCTE-35",
+				Key:     "scte",
+				Segment: 0,
+				Value:   "datarange",
+				Hint:    "did you mean `scte(daterange)`?",
+			},
+			expectMsg: "SCTE-35: scte-35 dialect datarange is not supported",
+		},
+		{
+			name:  "when a drm system is misspelled, expect the values with the closest system as hint",
+			input: "/drm(fairplay,widevin)/master.m3u8",
+			expectErr: ParseError{
+				Filter:  "DRM",
+				Key:     "drm",
+				Segment: 0,
+				Value:   "widevin",
+				Hint:    "did you mean `drm(fairplay,widevine)`?",
+			},
+			expectMsg: "DRM: drm system widevin is not supported",
+		},
+		{
+			name:  "when a video range is misspelled, expect the values with the closest range as hint",
+			input: "/range(sdr,hgl)/master.m3u8",
+			expectErr: ParseError{
+				Filter:  "Video Range",
+				Key:     "range",
+				Segment: 0,
+				Value:   "hgl",
+				Hint:    "did you mean `range(sdr,hlg)`?",
+			},
+			expectMsg: "Video Range: video range hgl is not supported",
+		},
+		{
+			name:  "when a filter key is unknown, expect the closest key as hint",
+			input: "/v(avc)/fp(30)/master.mpd",
+			expectErr: ParseError{
+				Filter:  "fp",
+				Key:     "fp",
+				Segment: 1,
+				Value:   "30",
+				Hint:    "did you mean `fps(30)`?",
+			},
+			expectMsg: "fp: unsupported filter fp",
+		},
+		{
+			name:  "when a content type is misspelled, expect the closest content type as hint",
+			input: "/ct(vidoe)/master.mpd",
+			expectErr: ParseError{
+				Filter:  "Content Type",
+				Key:     "ct",
+				Segment: 0,
+				Value:   "vidoe",
+				Hint:    "did you mean `ct(video)`?",
+			},
+			expectMsg: "Content Type: Content Type vidoe is not supported",
+		},
+		{
+			name:  "when the protocol is unknown, expect a protocol error",
+			input: "/b(10,20)/master.txt",
+			expectErr: ParseError{
+				Filter:  "Protocol",
+				Segment: -1,
+				Value:   "master.txt",
+				Hint:    "manifest paths must end in .m3u8, .mpd or .vtt",
+			},
+			expectMsg: "Protocol: unsupported protocol",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := URLParse(tc.input)
+			if err == nil {
+				t.Fatal("Expected an error returned, got nil")
+			}
+
+			var got *ParseError
+			if !errors.As(err, &got) {
+				t.Fatalf("Expected a ParseError, got %T", err)
+			}
+
+			if !cmp.Equal(*got, tc.expectErr, cmpopts.IgnoreFields(ParseError{}, "Err")) {
+				t.Errorf("Wrong error returned\ngot %v\nexpected: %v\ndiff: %v",
+					*got, tc.expectErr, cmp.Diff(*got, tc.expectErr, cmpopts.IgnoreFields(ParseError{}, "Err")))
+			}
+
+			if err.Error() != tc.expectMsg {
+				t.Errorf("Wrong error message\ngot %v\nexpected: %v", err.Error(), tc.expectMsg)
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package parsers
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Path returns the canonical bakery path segments for the filters, e.g.
+// `/v(avc,b(0,4000))/a(mp4a)/t(100,200)`. The segments are expected to
+// prefix a manifest path and parse back into the same MediaFilters with
+// URLParse. An empty string is returned when no filters are set.
+func (mf *MediaFilters) Path() string {
+	var segments []string
+
+	if len(mf.Presets) > 0 {
+		segments = append(segments, filterSegment("p", mf.Presets...))
+	}
+
+	if len(mf.ContentTypes) > 0 {
+		segments = append(segments, filterSegment("ct", mf.ContentTypes...))
+	}
+
+	if nested := mf.Videos.values(); len(nested) > 0 {
+		segments = append(segments, filterSegment("v", nested...))
+	}
+
+	if nested := mf.Audios.values(); len(nested) > 0 {
+		segments = append(segments, filterSegment("a", nested...))
+	}
+
+	if nested := mf.Captions.values(); len(nested) > 0 {
+		segments = append(segments, filterSegment("c", nested...))
+	}
+
+	if nested := mf.IFrames.values(); len(nested) > 0 {
+		segments = append(segments, filterSegment("i", nested...))
+	}
+
+	if mf.Bitrate != nil {
+		segments = append(segments, mf.Bitrate.segment())
+	}
+
+	if r := mf.Renditions; r != nil {
+		values := []string{fmt.Sprint(r.Count)}
+		if r.Strategy != "" && r.Strategy != RenditionsHighest {
+			values = append(values, r.Strategy)
+		}
+		segments = append(segments, filterSegment("n", values...))
+	}
+
+	if s := mf.Sort; s != nil {
+		var values []string
+		if s.Order != "" {
+			values = append(values, s.Order)
+		}
+		if s.Target > 0 {
+			values = append(values, fmt.Sprintf("%v%v", sortTargetPrefix, s.Target))
+		}
+		segments = append(segments, filterSegment("sort", values...))
+	}
+
+	if mf.Trim != nil && len(mf.Trim.Relative) > 0 {
+		segments = append(segments, filterSegment("t", mf.Trim.Relative...))
+	} else if mf.Trim != nil {
+		segments = append(segments, filterSegment("t", formatSeconds(mf.Trim.Start), formatSeconds(mf.Trim.End)))
+	}
+
+	if mf.MediaTime != nil {
+		segments = append(segments, filterSegment("mt", formatSeconds(mf.MediaTime.Start), formatSeconds(mf.MediaTime.End)))
+	}
+
+	if mf.Sequence != nil {
+		segments = append(segments, filterSegment("seq", fmt.Sprint(mf.Sequence.Start), fmt.Sprint(mf.Sequence.End)))
+	}
+
+	if mf.DVR > 0 {
+		segments = append(segments, filterSegment("dvr", formatSeconds(mf.DVR)))
+	}
+
+	if mf.PlaylistType != "" {
+		segments = append(segments, filterSegment("type", mf.PlaylistType))
+	}
+
+	if mf.CDN != "" {
+		segments = append(segments, filterSegment("cdn", mf.CDN))
+	}
+
+	if mf.SCTE != "" {
+		segments = append(segments, filterSegment("scte", mf.SCTE))
+	}
+
+	if len(mf.DRM) > 0 {
+		segments = append(segments, filterSegment("drm", mf.DRM...))
+	}
+
+	if mf.DRMStrict {
+		segments = append(segments, filterSegment("drmstrict", "true"))
+	}
+
+	if tags := mf.Tags.values(); len(tags) > 0 {
+		segments = append(segments, filterSegment("tags", tags...))
+	}
+
+	if mf.AdSkip {
+		segments = append(segments, filterSegment("adskip", "true"))
+	}
+
+	if len(mf.FrameRate) > 0 {
+		var frameRates []string
+		for _, fr := range mf.FrameRate {
+			frameRates = append(frameRates, strings.ReplaceAll(fr, "/", ":"))
+		}
+		segments = append(segments, filterSegment("fps", frameRates...))
+	}
+
+	if len(mf.VideoRange) > 0 {
+		segments = append(segments, filterSegment("range", mf.VideoRange...))
+	}
+
+	if mf.DeWeave {
+		segments = append(segments, filterSegment("dw", "true"))
+	}
+
+	if mf.PreventHTTPStatusError {
+		segments = append(segments, filterSegment("phe", "true"))
+	}
+
+	if len(mf.Plugins) > 0 {
+		segments = append(segments, fmt.Sprintf("[%v]", strings.Join(mf.Plugins, ",")))
+	}
+
+	if len(segments) == 0 {
+		return ""
+	}
+
+	return "/" + strings.Join(segments, "/")
+}
+
+// String returns the canonical path of the filters
+func (mf *MediaFilters) String() string {
+	return mf.Path()
+}
+
+// values returns the nested filters in the order they are parsed:
+// codecs first, followed by allowed codecs, language, bitrate,
+// resolution and channel filters
+func (nf *NestedFilters) values() []string {
+	values := codecValues(nf.Codecs)
+
+	if len(nf.Only) > 0 {
+		values = append(values, filterSegment("only", codecValues(nf.Only)...))
+	}
+
+	if len(nf.Language) > 0 {
+		languages := nf.Language
+		if nf.ExactLanguage {
+			languages = append(append([]string{}, languages...), exactLanguage)
+		}
+		values = append(values, filterSegment("l", languages...))
+	}
+
+	if nf.Bitrate != nil {
+		values = append(values, nf.Bitrate.segment())
+	}
+
+	if nf.Height != nil {
+		values = append(values, filterSegment("res", fmt.Sprint(nf.Height.Min), fmt.Sprint(nf.Height.Max)))
+	}
+
+	if nf.Width != nil {
+		values = append(values, filterSegment("resw", fmt.Sprint(nf.Width.Min), fmt.Sprint(nf.Width.Max)))
+	}
+
+	if nf.Channels != nil {
+		values = append(values, filterSegment("ch", fmt.Sprint(nf.Channels.Min), fmt.Sprint(nf.Channels.Max)))
+	}
+
+	return values
+}
+
+// codecValues returns the codecs as set in the path
+func codecValues(codecs []string) []string {
+	var values []string
+	for i := 0; i < len(codecs); i++ {
+		// hdr10 is expanded to both hevc main 10 codecs when parsed
+		if codecs[i] == hdr10Codecs[0] && i+1 < len(codecs) && codecs[i+1] == hdr10Codecs[1] {
+			values = append(values, "hdr10")
+			i++
+			continue
+		}
+		values = append(values, codecs[i])
+	}
+
+	return values
+}
+
+func (b *Bitrate) segment() string {
+	return filterSegment("b", fmt.Sprint(b.Min), fmt.Sprint(b.Max))
+}
+
+func (t *Tags) values() []string {
+	var values []string
+	if t == nil {
+		return values
+	}
+
+	if t.Ads {
+		values = append(values, "ads")
+	}
+
+	if t.IFrame {
+		values = append(values, "iframe")
+	}
+
+	values = append(values, t.Custom...)
+
+	return values
+}
+
+// filterSegment formats a filter following the key(values) grammar
+func filterSegment(key string, values ...string) string {
+	return fmt.Sprintf("%v(%v)", key, strings.Join(values, ","))
+}
@@ -0,0 +1,166 @@
+package parsers
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMediaFilters_Path(t *testing.T) {
+	tests := []struct {
+		name       string
+		mf         MediaFilters
+		expectPath string
+	}{
+		{
+			name:       "when no filters are set, expect an empty path",
+			mf:         MediaFilters{Protocol: ProtocolHLS},
+			expectPath: "",
+		},
+		{
+			name: "when hdr10 was expanded while parsing, expect the alias to be restored",
+			mf: MediaFilters{
+				Videos: NestedFilters{
+					Codecs: []string{"hev1.2", "hvc1.2", "avc"},
+				},
+			},
+			expectPath: "/v(hdr10,avc)",
+		},
+		{
+			name: "when nested filters are set, expect codecs, language and bitrate in order",
+			mf: MediaFilters{
+				Audios: NestedFilters{
+					Codecs:   []string{"mp4a"},
+					Language: []string{"pt-BR", "en"},
+					Bitrate:  &Bitrate{Min: 10, Max: 20},
+				},
+			},
+			expectPath: "/a(mp4a,l(pt-BR,en),b(10,20))",
+		},
+		{
+			name: "when the trim range is relative to now, expect it as written",
+			mf: MediaFilters{
+				Trim: &Trim{Start: 1591005000, End: 1591005600, Relative: []string{"-600", ""}},
+			},
+			expectPath: "/t(-600,)",
+		},
+		{
+			name: "when every top level filter is set, expect them in canonical order",
+			mf: MediaFilters{
+				ContentTypes:           []string{"text", "image"},
+				Plugins:                []string{"dvsRoleOverride"},
+				Tags:                   &Tags{Ads: true, IFrame: true},
+				Trim:                   &Trim{Start: 100, End: 200},
+				Bitrate:                &Bitrate{Min: 0, Max: 4000},
+				Renditions:             &Renditions{Count: 3, Strategy: RenditionsSpread},
+				FrameRate:              []string{"30000/1001", "25"},
+				DeWeave:                true,
+				PreventHTTPStatusError: true,
+			},
+			expectPath: "/ct(text,image)/b(0,4000)/n(3,spread)/t(100,200)/tags(ads,iframe)/fps(30000:1001,25)/dw(true)/phe(true)/[dvsRoleOverride]",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.mf.Path(); got != tc.expectPath {
+				t.Errorf("Wrong path returned\ngot %v\nexpected: %v", got, tc.expectPath)
+			}
+		})
+	}
+}
+
+// roundTripPaths are filter paths that are expected to parse back
+// to the same filters once serialized
+var roundTripPaths = []string{
+	"/v(hdr10,hvc)/a(mp4a,l(pt-BR,en),b(10,20))/b(100,4000)/master.m3u8",
+	"/v(hvc1.2,avc)/master.m3u8",
+	"/v(hvc1.2,hev1.2)/i(only(hev1.2))/master.m3u8",
+	"/v(avc,b(100,))/b(,3000)/master.mpd",
+	"/v(avc,res(0,720),resw(1280,))/master.mpd",
+	"/v(hevc,only(hdr10,avc))/a(only(mp4a))/master.m3u8",
+	"/ct(audio,video)/c(wvtt,l(en))/master.mpd",
+	"/a(l(pt-BR,exact))/c(l(en))/master.mpd",
+	"/a(mp4a,ch(0,2))/master.m3u8",
+	"/i(hevc,b(0,500000),res(0,720))/master.m3u8",
+	"/l(en,es)/fps(30000:1001)/master.mpd",
+	"/t(100,1000)/tags(ads,i-frame)/master.m3u8",
+	"/tags(ads,EXT-X-ASSET)/master.m3u8",
+	"/t(1591005600.25,1591005660.5)/master.m3u8",
+	"/mt(30,90.5)/seq(10,)/master.m3u8",
+	"/dvr(1800)/master.mpd",
+	"/type(event)/master.m3u8",
+	"/cdn(akamai)/t(100,1000)/master.m3u8",
+	"/scte(daterange)/tags(ads)/master.m3u8",
+	"/drm(widevine,playready)/master.m3u8",
+	"/drm(playready)/drmstrict(true)/master.mpd",
+	"/fps(60)/range(pq,hlg)/master.m3u8",
+	"/adskip(true)/t(100,1000)/master.m3u8",
+	"/n(4)/v(avc)/master.mpd",
+	"/n(2,lowest)/master.m3u8",
+	"/sort(asc,bitrate:3000000)/master.mpd",
+	"/sort(bitrate:1500000)/master.m3u8",
+	"/dw(true)/phe(true)/[dvsRoleOverride]/master.m3u8",
+	"/master.m3u8",
+}
+
+func TestMediaFilters_Path_RoundTrip(t *testing.T) {
+	// relative trim ranges are resolved against now on each parse
+	current := time.Now()
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	for _, p := range roundTripPaths {
+		t.Run(p, func(t *testing.T) {
+			_, mf, err := URLParse(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, got, err := URLParse(mf.Path() + "/master.m3u8")
+			if err != nil {
+				t.Fatalf("Canonical path %v failed to parse: %v", mf.Path(), err)
+			}
+
+			// the protocol isn't part of the filters path
+			got.Protocol = mf.Protocol
+			if !cmp.Equal(got, mf) {
+				t.Errorf("Round trip returned different filters\ngot %v\nexpected: %v\ndiff: %v",
+					got, mf, cmp.Diff(got, mf))
+			}
+		})
+	}
+}
+
+func TestMediaFilters_JSON_RoundTrip(t *testing.T) {
+	// relative trim ranges are resolved against now on each parse
+	current := time.Now()
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	for _, p := range roundTripPaths {
+		t.Run(p, func(t *testing.T) {
+			manifestPath, mf, err := URLParse(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			body, err := json.Marshal(mf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, got, err := JSONParse(manifestPath, body, nil)
+			if err != nil {
+				t.Fatalf("JSON body %s failed to parse: %v", body, err)
+			}
+
+			if !cmp.Equal(got, mf) {
+				t.Errorf("Round trip returned different filters\ngot %v\nexpected: %v\ndiff: %v",
+					got, mf, cmp.Diff(got, mf))
+			}
+		})
+	}
+}
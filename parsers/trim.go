@@ -0,0 +1,215 @@
+package parsers
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// now returns the current time, it is overridden in tests
+var now = time.Now
+
+var isoDurationRegexp = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// parseTrim parses the start and end of a trim range. Each bound is either
+//
+//   - an absolute time, as epoch seconds with optional milliseconds, e.g. 1591005600.250,
+//     or an ISO-8601 timestamp, e.g. 2020-06-01T10:00:00.250Z
+//   - a negative offset relative to now, in seconds or as an ISO-8601 duration, e.g. -3600 or -PT1H
+//   - a positive offset, e.g. +600 or +PT10M. It is relative to the start when set
+//     as the end of the range, and relative to now otherwise
+//
+// An empty start defaults to the epoch, or to now when the end is relative to it.
+// An empty end defaults to now. When the range depends on now, the bounds are
+// kept as written in Relative.
+func parseTrim(values []string) (*Trim, error) {
+	if len(values) > 2 {
+		return nil, fmt.Errorf("expected a start and an end, got %v values", len(values))
+	}
+
+	current := roundMillis(float64(now().UnixNano()) / float64(time.Second))
+	start, end := strings.TrimSpace(values[0]), ""
+	if len(values) > 1 {
+		end = strings.TrimSpace(values[1])
+	}
+
+	t := &Trim{End: current}
+	relative := end == "" || strings.HasPrefix(end, "-")
+
+	switch {
+	case start == "" && isForwardOffset(end):
+		t.Start = current
+		relative = true
+	case start != "":
+		s, err := parseTrimBound(start, current)
+		if err != nil {
+			return nil, err
+		}
+		t.Start = s
+		relative = relative || isOffset(start)
+	}
+
+	if end != "" {
+		base := current
+		if isForwardOffset(end) {
+			base = t.Start
+		}
+
+		e, err := parseTrimBound(end, base)
+		if err != nil {
+			return nil, err
+		}
+		t.End = e
+	}
+
+	if relative {
+		t.Relative = []string{start, end}
+	}
+
+	return t, nil
+}
+
+// parseMediaTime parses a range of seconds, with millisecond precision, from
+// the start of a playlist. An empty start defaults to the start of the
+// playlist and an empty end to its end.
+func parseMediaTime(values []string) (*Trim, error) {
+	if len(values) > 2 {
+		return nil, fmt.Errorf("expected a start and an end, got %v values", len(values))
+	}
+
+	t := &Trim{End: math.MaxInt32}
+	if start := strings.TrimSpace(values[0]); start != "" {
+		s, err := parseSeconds(start)
+		if err != nil {
+			return nil, err
+		}
+		t.Start = s
+	}
+
+	if len(values) > 1 {
+		if end := strings.TrimSpace(values[1]); end != "" {
+			e, err := parseSeconds(end)
+			if err != nil {
+				return nil, err
+			}
+			t.End = e
+		}
+	}
+
+	return t, nil
+}
+
+// parseTrimBound parses a single trim bound, resolving offsets from base
+func parseTrimBound(value string, base float64) (float64, error) {
+	switch {
+	case strings.HasPrefix(value, "-"):
+		offset, err := parseOffset(value[1:])
+		if err != nil {
+			return 0, err
+		}
+		return base - offset, nil
+	case isForwardOffset(value):
+		offset, err := parseOffset(strings.TrimPrefix(value, "+"))
+		if err != nil {
+			return 0, err
+		}
+		return base + offset, nil
+	case strings.Contains(value, "T"):
+		ts, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid timestamp %v, expected ISO-8601", value)
+		}
+		return roundMillis(float64(ts.UnixNano()) / float64(time.Second)), nil
+	default:
+		return parseSeconds(value)
+	}
+}
+
+// isOffset returns true if the value is an offset rather than an absolute time
+func isOffset(value string) bool {
+	return strings.HasPrefix(value, "-") || isForwardOffset(value)
+}
+
+// isForwardOffset returns true if the value is an offset to be added to a base time
+func isForwardOffset(value string) bool {
+	return strings.HasPrefix(value, "+") || strings.HasPrefix(value, "P")
+}
+
+// parseOffset parses an offset given in seconds or as an ISO-8601 duration
+func parseOffset(value string) (float64, error) {
+	if strings.HasPrefix(value, "P") {
+		d, err := parseISODuration(value)
+		if err != nil {
+			return 0, err
+		}
+		return d.Seconds(), nil
+	}
+
+	return parseSeconds(value)
+}
+
+// parseSeconds parses seconds with up to millisecond precision
+func parseSeconds(value string) (float64, error) {
+	s, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsInf(s, 0) || math.IsNaN(s) {
+		return 0, fmt.Errorf("invalid time %v", value)
+	}
+
+	return roundMillis(s), nil
+}
+
+// parseISODuration parses ISO-8601 durations made of days, hours, minutes
+// and seconds, e.g. P1DT2H or PT10M30.5S. Years and months are not
+// supported as their length varies.
+func parseISODuration(value string) (time.Duration, error) {
+	parts := isoDurationRegexp.FindStringSubmatch(value)
+	if parts == nil || value == "P" || strings.HasSuffix(value, "T") {
+		return 0, fmt.Errorf("invalid duration %v, expected ISO-8601 such as PT1H30M", value)
+	}
+
+	var d time.Duration
+	units := []time.Duration{24 * time.Hour, time.Hour, time.Minute}
+	for i, unit := range units {
+		if parts[i+1] == "" {
+			continue
+		}
+
+		n, err := strconv.Atoi(parts[i+1])
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %v: %w", value, err)
+		}
+		d += time.Duration(n) * unit
+	}
+
+	if parts[4] != "" {
+		s, err := strconv.ParseFloat(parts[4], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %v: %w", value, err)
+		}
+		d += time.Duration(s * float64(time.Second))
+	}
+
+	return d, nil
+}
+
+// validate returns an error if the trim range is not a valid positive range
+func (t *Trim) validate() error {
+	if t.Start < 0 || t.Start >= t.End {
+		return fmt.Errorf("invalid range for provided values: ( %v, %v )", formatSeconds(t.Start), formatSeconds(t.End))
+	}
+
+	return nil
+}
+
+// roundMillis rounds seconds to millisecond precision
+func roundMillis(s float64) float64 {
+	return math.Round(s*1000) / 1000
+}
+
+// formatSeconds formats seconds without exponent, keeping milliseconds if set
+func formatSeconds(s float64) string {
+	return strconv.FormatFloat(s, 'f', -1, 64)
+}
@@ -0,0 +1,143 @@
+package parsers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestURLParse_Trim(t *testing.T) {
+	current := time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	epoch := float64(current.Unix())
+
+	tests := []struct {
+		name       string
+		input      string
+		expectTrim *Trim
+		expectErr  bool
+	}{
+		{
+			name:       "when absolute epoch seconds are set, expect them unchanged",
+			input:      "/t(100,1000)/master.m3u8",
+			expectTrim: &Trim{Start: 100, End: 1000},
+		},
+		{
+			name:       "when milliseconds are set, expect them kept",
+			input:      "/t(1591005600.25,1591005660.5)/master.m3u8",
+			expectTrim: &Trim{Start: 1591005600.25, End: 1591005660.5},
+		},
+		{
+			name:       "when start is relative to now and end is empty, expect a window ending now",
+			input:      "/t(-3600,)/master.m3u8",
+			expectTrim: &Trim{Start: epoch - 3600, End: epoch, Relative: []string{"-3600", ""}},
+		},
+		{
+			name:       "when start is empty and end is a forward offset, expect a window starting now",
+			input:      "/t(,+600)/master.m3u8",
+			expectTrim: &Trim{Start: epoch, End: epoch + 600, Relative: []string{"", "+600"}},
+		},
+		{
+			name:       "when end is a forward offset, expect it relative to the start",
+			input:      "/t(1591000000,+90.5)/master.m3u8",
+			expectTrim: &Trim{Start: 1591000000, End: 1591000090.5},
+		},
+		{
+			name:       "when ISO-8601 timestamps are set, expect epoch seconds",
+			input:      "/t(2020-06-01T09:00:00Z,2020-06-01T09:30:00.125Z)/master.m3u8",
+			expectTrim: &Trim{Start: epoch - 3600, End: epoch - 1800 + 0.125},
+		},
+		{
+			name:       "when ISO-8601 durations are set, expect them resolved",
+			input:      "/t(-PT1H30M,PT10M)/master.m3u8",
+			expectTrim: &Trim{Start: epoch - 5400, End: epoch - 4800, Relative: []string{"-PT1H30M", "PT10M"}},
+		},
+		{
+			name:       "when the end is in the future, expect no error",
+			input:      "/t(-60,+P1D)/master.m3u8",
+			expectTrim: &Trim{Start: epoch - 60, End: epoch - 60 + 86400, Relative: []string{"-60", "+P1D"}},
+		},
+		{
+			name:       "when start is absolute and end is empty, expect a window ending now",
+			input:      "/t(100,)/master.m3u8",
+			expectTrim: &Trim{Start: 100, End: epoch, Relative: []string{"100", ""}},
+		},
+		{
+			name:      "when a duration uses months, expect error",
+			input:     "/t(-P1M,)/master.m3u8",
+			expectErr: true,
+		},
+		{
+			name:      "when a timestamp is invalid, expect error",
+			input:     "/t(2020-06-01T25:00:00Z,)/master.m3u8",
+			expectErr: true,
+		},
+		{
+			name:      "when the relative start is after the end, expect error",
+			input:     "/t(-60,-120)/master.m3u8",
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, mf, err := URLParse(tc.input)
+			if !tc.expectErr && err != nil {
+				t.Errorf("Did not expect an error returned, got: %v", err)
+				return
+			} else if tc.expectErr && err == nil {
+				t.Errorf("Expected an error returned, got nil")
+				return
+			}
+
+			if tc.expectErr {
+				return
+			}
+
+			if !cmp.Equal(mf.Trim, tc.expectTrim) {
+				t.Errorf("Wrong trim parsed\ngot %v\nexpected: %v\ndiff: %v", mf.Trim, tc.expectTrim, cmp.Diff(mf.Trim, tc.expectTrim))
+			}
+		})
+	}
+}
+
+func TestURLParse_Trim_Now(t *testing.T) {
+	current := time.Date(2020, 6, 1, 10, 0, 0, 123456789, time.UTC)
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	epoch := float64(current.Unix())
+
+	tests := []struct {
+		name       string
+		input      string
+		expectTrim *Trim
+	}{
+		{
+			name:       "when end is empty, expect now rounded to milliseconds",
+			input:      "/t(100,)/master.m3u8",
+			expectTrim: &Trim{Start: 100, End: epoch + 0.123, Relative: []string{"100", ""}},
+		},
+		{
+			name:       "when start is relative to now, expect it rounded to milliseconds",
+			input:      "/t(-600,)/master.m3u8",
+			expectTrim: &Trim{Start: epoch - 600 + 0.123, End: epoch + 0.123, Relative: []string{"-600", ""}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, mf, err := URLParse(tc.input)
+			if err != nil {
+				t.Fatalf("Did not expect an error returned, got: %v", err)
+			}
+
+			if !cmp.Equal(mf.Trim, tc.expectTrim) {
+				t.Errorf("Wrong trim parsed\ngot %v\nexpected: %v\ndiff: %v", mf.Trim, tc.expectTrim, cmp.Diff(mf.Trim, tc.expectTrim))
+			}
+		})
+	}
+}
@@ -1,13 +1,17 @@
 package parsers
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
+	"net/url"
 	"path"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
-	"time"
 )
 
 // MediaFilters is a struct that carry all the information passed via url
@@ -15,12 +19,26 @@ type MediaFilters struct {
 	Videos                 NestedFilters `json:",omitempty"`
 	Audios                 NestedFilters `json:",omitempty"`
 	Captions               NestedFilters `json:",omitempty"`
+	IFrames                NestedFilters `json:",omitempty"`
 	ContentTypes           []string      `json:",omitempty"`
 	Plugins                []string      `json:",omitempty"`
+	Presets                []string      `json:",omitempty"`
 	Tags                   *Tags         `json:",omitempty"`
 	Trim                   *Trim         `json:",omitempty"`
+	MediaTime              *Trim         `json:",omitempty"`
+	Sequence               *Sequence     `json:",omitempty"`
+	DVR                    float64       `json:",omitempty"`
+	PlaylistType           string        `json:",omitempty"`
+	CDN                    string        `json:",omitempty"`
+	SCTE                   string        `json:",omitempty"`
+	DRM                    []string      `json:",omitempty"`
+	DRMStrict              bool          `json:",omitempty"`
 	Bitrate                *Bitrate      `json:",omitempty"`
+	Renditions             *Renditions   `json:",omitempty"`
+	Sort                   *Sort         `json:",omitempty"`
 	FrameRate              []string      `json:",omitempty"`
+	VideoRange             []string      `json:",omitempty"`
+	AdSkip                 bool          `json:",omitempty"`
 	DeWeave                bool          `json:",omitempty"`
 	PreventHTTPStatusError bool          `json:",omitempty"`
 	Protocol               Protocol      `json:"protocol"`
@@ -29,11 +47,20 @@ type MediaFilters struct {
 // NestedFilters is a struct that holds values of filters
 // that can be nested within certain Media Filters
 type NestedFilters struct {
-	Bitrate  *Bitrate `json:",omitempty"`
-	Codecs   []string `json:",omitempty"`
-	Language []string `json:",omitempty"`
+	Bitrate       *Bitrate    `json:",omitempty"`
+	Codecs        []string    `json:",omitempty"`
+	Only          []string    `json:",omitempty"`
+	Language      []string    `json:",omitempty"`
+	ExactLanguage bool        `json:",omitempty"`
+	Height        *Resolution `json:",omitempty"`
+	Width         *Resolution `json:",omitempty"`
+	Channels      *Channels   `json:",omitempty"`
 }
 
+// Presets maps preset names to their filters, written in the path grammar
+// and separated by commas, e.g. `v(avc,b(0,3000000)),a(mp4a)`
+type Presets map[string]string
+
 // Protocol describe the valid protocols
 type Protocol string
 
@@ -46,8 +73,19 @@ const (
 	ProtocolVTT Protocol = "vtt"
 )
 
-// Trim is a struct that carries the start and end times to trim playlist
+// Trim is a struct that carries the start and end times to trim playlist,
+// in epoch seconds with millisecond precision. Relative holds the start and
+// end as written when the range depends on the time of the request, e.g.
+// `-600` and an empty end, so it's resolved again on each request.
 type Trim struct {
+	Start    float64  `json:",omitempty"`
+	End      float64  `json:",omitempty"`
+	Relative []string `json:",omitempty"`
+}
+
+// Sequence is a struct that carries the first and last media sequence
+// numbers to trim playlist
+type Sequence struct {
 	Start int `json:",omitempty"`
 	End   int `json:",omitempty"`
 }
@@ -58,11 +96,133 @@ type Bitrate struct {
 	Min int `json:",omitempty"`
 }
 
+// Resolution is a struct that carries Min and Max values, in pixels,
+// for either the height or the width of a video rendition
+type Resolution struct {
+	Max int `json:",omitempty"`
+	Min int `json:",omitempty"`
+}
+
+// Renditions is a struct that carries the maximum number of video
+// renditions to keep and the strategy used to choose them
+type Renditions struct {
+	Count    int    `json:",omitempty"`
+	Strategy string `json:",omitempty"`
+}
+
+const (
+	// RenditionsHighest keeps the renditions with the highest bitrates
+	RenditionsHighest = "highest"
+	// RenditionsLowest keeps the renditions with the lowest bitrates
+	RenditionsLowest = "lowest"
+	// RenditionsSpread keeps renditions evenly spread across the ladder
+	RenditionsSpread = "spread"
+)
+
+var renditionStrategies = map[string]struct{}{
+	RenditionsHighest: struct{}{},
+	RenditionsLowest:  struct{}{},
+	RenditionsSpread:  struct{}{},
+}
+
+// Sort is a struct that carries the order of the variants by bitrate
+// and the target bitrate of the variant to promote to the first position
+type Sort struct {
+	Order  string `json:",omitempty"`
+	Target int    `json:",omitempty"`
+}
+
+const (
+	// SortAscending orders variants from the lowest to the highest bitrate
+	SortAscending = "asc"
+	// SortDescending orders variants from the highest to the lowest bitrate
+	SortDescending = "desc"
+)
+
+const (
+	// PlaylistTypeVOD closes playlists, making them video on demand
+	PlaylistTypeVOD = "vod"
+	// PlaylistTypeEvent makes playlists live playlists that only get appended to
+	PlaylistTypeEvent = "event"
+	// PlaylistTypeLive makes playlists live playlists with a sliding window
+	PlaylistTypeLive = "live"
+)
+
+var playlistTypes = map[string]struct{}{
+	PlaylistTypeVOD:   struct{}{},
+	PlaylistTypeEvent: struct{}{},
+	PlaylistTypeLive:  struct{}{},
+}
+
+const (
+	// SCTEDateRange signals ad breaks with EXT-X-DATERANGE tags carrying
+	// SCTE35-OUT and SCTE35-IN attributes
+	SCTEDateRange = "daterange"
+	// SCTEOATCLS signals ad breaks with EXT-OATCLS-SCTE35, EXT-X-CUE-OUT,
+	// EXT-X-CUE-OUT-CONT and EXT-X-CUE-IN tags
+	SCTEOATCLS = "oatcls"
+	// SCTECue signals ad breaks with EXT-X-CUE-OUT:DURATION and EXT-X-CUE-IN tags
+	SCTECue = "cue"
+)
+
+var scteDialects = map[string]struct{}{
+	SCTEDateRange: struct{}{},
+	SCTEOATCLS:    struct{}{},
+	SCTECue:       struct{}{},
+}
+
+const (
+	// DRMFairPlay keeps the keys of Apple FairPlay Streaming
+	DRMFairPlay = "fairplay"
+	// DRMWidevine keeps the keys of Google Widevine
+	DRMWidevine = "widevine"
+	// DRMPlayReady keeps the keys of Microsoft PlayReady
+	DRMPlayReady = "playready"
+	// DRMClear keeps the keys that don't require a drm system
+	DRMClear = "clear"
+)
+
+var drmSystems = map[string]struct{}{
+	DRMFairPlay:  struct{}{},
+	DRMWidevine:  struct{}{},
+	DRMPlayReady: struct{}{},
+	DRMClear:     struct{}{},
+}
+
+const (
+	// VideoRangeSDR removes standard dynamic range video
+	VideoRangeSDR = "sdr"
+	// VideoRangePQ removes high dynamic range video with the perceptual
+	// quantizer transfer function, e.g. HDR10 and Dolby Vision
+	VideoRangePQ = "pq"
+	// VideoRangeHLG removes high dynamic range video with the hybrid
+	// log-gamma transfer function
+	VideoRangeHLG = "hlg"
+)
+
+var videoRanges = map[string]struct{}{
+	VideoRangeSDR: struct{}{},
+	VideoRangePQ:  struct{}{},
+	VideoRangeHLG: struct{}{},
+}
+
+// sortTargetPrefix prefixes the target bitrate in the sort filter, e.g. `sort(bitrate:3000000)`
+const sortTargetPrefix = "bitrate:"
+
+// Channels is a struct that carries Min and Max values for the
+// number of channels of an audio rendition
+type Channels struct {
+	Max int `json:",omitempty"`
+	Min int `json:",omitempty"`
+}
+
 // Tags holds values of HLS tags that are to be suppressed
-// from the manifest
+// from the manifest. Custom holds the names of any other tags
+// to be removed, e.g. `EXT-X-ASSET`
 type Tags struct {
-	Ads    bool `json:",omitempty"`
-	IFrame bool `json:",omitempty"`
+	Ads    bool     `json:",omitempty"`
+	IFrame bool     `json:",omitempty"`
+	Custom []string `json:",omitempty"`
 }
 
 var urlParseRegexp = regexp.MustCompile(`(.*?)\((.*)\)`)
@@ -82,6 +242,11 @@ var codecSupported = map[string]struct{}{
 	"wvtt":  struct{}{}, //WebVTT
 }
 
+// hdr10Codecs are the hevc main 10 codecs the hdr10 alias expands to. They're
+// accepted on their own too, as filters parsed from JSON or serialized after
+// the alias was expanded carry them
+var hdr10Codecs = []string{"hev1.2", "hvc1.2"}
+
 var contentSupported = map[string]struct{}{
 	"image": struct{}{},
 	"text":  struct{}{},
@@ -89,17 +254,136 @@ var contentSupported = map[string]struct{}{
 	"video": struct{}{},
 }
 
-func keyError(key string, e error) (string, *MediaFilters, error) {
-	return "", &MediaFilters{}, fmt.Errorf("%v: %w", key, e)
+// filterKeys maps the keys of the filter grammar to the name of the filter
+var filterKeys = map[string]string{
+	"v":         "Video",
+	"a":         "Audio",
+	"c":         "Captions",
+	"i":         "I-Frame",
+	"ct":        "Content Type",
+	"l":         "Language",
+	"b":         "Bitrate",
+	"n":         "Renditions",
+	"sort":      "Sort",
+	"t":         "Trim",
+	"mt":        "Media Time",
+	"seq":       "Media Sequence",
+	"dvr":       "DVR",
+	"type":      "Playlist Type",
+	"cdn":       "CDN",
+	"scte":      "SCTE-35",
+	"drm":       "DRM",
+	"drmstrict": "DRM Strict",
+	"tags":      "Tags",
+	"adskip":    "Ad Skip",
+	"fps":       "Frame Rate",
+	"range":     "Video Range",
+	"dw":        "DeWeave",
+	"phe":       "PreventHTTPStatusError",
+	"res":       "Resolution",
+	"resw":      "Resolution Width",
+	"p":         "Preset",
+}
+
+// queryNestedKeys maps the name of a nested query parameter, as in `v.codecs`,
+// to its key in the path grammar. Codecs are the default nested filter
+// and don't carry a key.
+var queryNestedKeys = map[string]string{
+	"codecs":   "",
+	"co":       "",
+	"bitrate":  "b",
+	"b":        "b",
+	"language": "l",
+	"l":        "l",
+	"res":      "res",
+	"resw":     "resw",
+	"only":     "only",
+	"channels": "ch",
+	"ch":       "ch",
 }
 
+// exactLanguage is the value of the language filter that turns off
+// matching regional variants, e.g. `l(pt-BR,exact)`
+const exactLanguage = "exact"
+
+var languageRegexp = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]+)*$`)
+
 // URLParse will generate a MediaFilters struct with
 // all the filters that needs to be applied to the
 // master manifest. It will also return the master manifest
 // url without the filters.
 func URLParse(urlpath string) (string, *MediaFilters, error) {
+	return QueryParse(urlpath, nil, nil)
+}
+
+// QueryParse behaves like URLParse and additionally reads filters passed
+// as query parameters. Keys match the ones used in the path, with nested
+// filters addressed by a dot, e.g. `?v.codecs=avc&a.b=0,128000&b=0,4000000`.
+// The keys of the filters are reserved, and other query parameters, e.g.
+// tokens, are left to the origin. Presets referenced with p(name) are
+// looked up in presets.
+func QueryParse(urlpath string, query url.Values, presets Presets) (string, *MediaFilters, error) {
+	explicit := map[string]bool{}
+	masterManifestPath, mf, err := parsePath(urlpath, explicit)
+	if err != nil {
+		return "", &MediaFilters{}, err
+	}
+
+	if err := mf.parseQuery(query, explicit); err != nil {
+		return "", &MediaFilters{}, err
+	}
+
+	if err := mf.expandPresets(presets, explicit); err != nil {
+		return "", &MediaFilters{}, err
+	}
+
+	if err := mf.finalize(); err != nil {
+		return "", &MediaFilters{}, err
+	}
+
+	return masterManifestPath, mf, nil
+}
+
+// JSONParse behaves like URLParse and additionally reads filters from a
+// JSON encoded MediaFilters body. Fields set in the body take precedence
+// over filters found in the path. The protocol is always derived from the path.
+// Presets referenced in the path or the body are looked up in presets.
+func JSONParse(urlpath string, body []byte, presets Presets) (string, *MediaFilters, error) {
+	explicit := map[string]bool{}
+	masterManifestPath, mf, err := parsePath(urlpath, explicit)
+	if err != nil {
+		return "", &MediaFilters{}, err
+	}
+
+	if len(bytes.TrimSpace(body)) > 0 {
+		protocol := mf.Protocol
+		if err := json.Unmarshal(body, mf); err != nil {
+			return "", &MediaFilters{}, &ParseError{Filter: "Body", Segment: -1, Err: err}
+		}
+		mf.Protocol = protocol
+
+		if err := setBooleanKeys(body, explicit); err != nil {
+			return "", &MediaFilters{}, &ParseError{Filter: "Body", Segment: -1, Err: err}
+		}
+	}
+
+	if err := mf.expandPresets(presets, explicit); err != nil {
+		return "", &MediaFilters{}, err
+	}
+
+	if err := mf.finalize(); err != nil {
+		return "", &MediaFilters{}, err
+	}
+
+	return masterManifestPath, mf, nil
+}
+
+// parsePath reads the protocol and the filters set as path segments,
+// returning the master manifest path stripped from its filters. The keys
+// of the filters are recorded in explicit.
+func parsePath(urlpath string, explicit map[string]bool) (string, *MediaFilters, error) {
 	mf := new(MediaFilters)
-	parts := strings.Split(urlpath, "/")
+	parts := strings.Split(strings.TrimPrefix(urlpath, "/"), "/")
 	re := urlParseRegexp
 	masterManifestPath := "/"
 
@@ -110,10 +394,16 @@ func URLParse(urlpath string) (string, *MediaFilters, error) {
 	} else if strings.Contains(urlpath, ".vtt") {
 		mf.Protocol = ProtocolVTT
 	} else {
-		return keyError("Protocol", fmt.Errorf("unsupported protocol"))
+		return "", mf, &ParseError{
+			Filter:  "Protocol",
+			Segment: -1,
+			Value:   path.Base(urlpath),
+			Hint:    "manifest paths must end in .m3u8, .mpd or .vtt",
+			Err:     fmt.Errorf("unsupported protocol"),
+		}
 	}
 
-	for _, part := range parts {
+	for i, part := range parts {
 		// FindStringSubmatch should return a slice with
 		// the full string, the key and filters (3 elements).
 		// If it doesn't match, it means that the path is part
@@ -127,98 +417,736 @@ func URLParse(urlpath string) (string, *MediaFilters, error) {
 			continue
 		}
 
-		filters := strings.Split(subparts[2], ",")
-		nestedFilterRegexp := regexp.MustCompile(`\),`)
-		nestedFilters := splitAfter(subparts[2], nestedFilterRegexp)
+		if err := mf.parseSegment(subparts[1], subparts[2]); err != nil {
+			var pErr *ParseError
+			if errors.As(err, &pErr) {
+				pErr.Segment = i
+			}
+			return "", mf, err
+		}
+		explicit[subparts[1]] = true
+	}
 
-		switch key := subparts[1]; key {
-		case "v":
-			for _, nf := range nestedFilters {
-				if err := mf.Videos.parse(nf); err != nil {
-					return keyError("Video", err)
-				}
+	return masterManifestPath, mf, nil
+}
+
+// booleanFields maps the JSON fields of boolean filters to their keys
+var booleanFields = map[string]string{
+	"AdSkip":                 "adskip",
+	"DRMStrict":              "drmstrict",
+	"DeWeave":                "dw",
+	"PreventHTTPStatusError": "phe",
+}
+
+// setBooleanKeys records in explicit the keys of the boolean filters set in
+// the JSON body, as a false value can't be told apart from an unset one once
+// decoded. Other filters are nil or empty when not set.
+func setBooleanKeys(body []byte, explicit map[string]bool) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return err
+	}
+
+	for field := range fields {
+		for name, key := range booleanFields {
+			// encoding/json matches fields regardless of their case
+			if strings.EqualFold(field, name) {
+				explicit[key] = true
 			}
-		case "a":
-			for _, nf := range nestedFilters {
-				if err := mf.Audios.parse(nf); err != nil {
-					return keyError("Audio", err)
-				}
+		}
+	}
+
+	return nil
+}
+
+// queryFilterKey returns the key of the filter carried by a query
+// parameter, e.g. `v` for `v.codecs`, and whether the parameter carries a
+// filter at all. The keys of the filters are reserved, so a query parameter
+// meant for the origin can't share one.
+func queryFilterKey(k string) (string, bool) {
+	key := k
+	if i := strings.Index(k, "."); i != -1 {
+		key = k[:i]
+	}
+
+	if key == "plugins" {
+		return key, true
+	}
+
+	_, found := filterKeys[key]
+	return key, found
+}
+
+// OriginQuery returns the query parameters that don't carry filters, which
+// are meant for the origin
+func OriginQuery(query url.Values) url.Values {
+	origin := url.Values{}
+	for k, values := range query {
+		if _, filter := queryFilterKey(k); !filter {
+			origin[k] = values
+		}
+	}
+
+	return origin
+}
+
+// parseQuery reads filters passed as query parameters. Each parameter is
+// translated into the path grammar so both go through parseFilter. The keys
+// of the filters are recorded in explicit.
+func (mf *MediaFilters) parseQuery(query url.Values, explicit map[string]bool) error {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		if _, filter := queryFilterKey(k); filter {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		key, _ := queryFilterKey(k)
+		nested := strings.TrimPrefix(strings.TrimPrefix(k, key), ".")
+
+		for _, value := range query[k] {
+			if key == "plugins" {
+				mf.Plugins = append(mf.Plugins, strings.Split(value, ",")...)
+				continue
 			}
-		case "c":
-			for _, nf := range nestedFilters {
-				if err := mf.Captions.parse(nf); err != nil {
-					return keyError("Captions", err)
+
+			if nested != "" {
+				nestedKey, ok := queryNestedKeys[nested]
+				if !ok {
+					return filterError(key, nested, fmt.Errorf("unsupported nested filter %v", nested))
 				}
-			}
-		case "ct":
-			for _, contentType := range filters {
-				if _, valid := contentSupported[contentType]; !valid {
-					err := fmt.Errorf("Content Type %v is not supported", contentType)
-					return keyError("Content Type", err)
+
+				if nestedKey != "" {
+					value = filterSegment(nestedKey, value)
 				}
-				mf.ContentTypes = append(mf.ContentTypes, contentType)
-			}
-		case "l":
-			for _, lang := range filters {
-				mf.Audios.Language = append(mf.Audios.Language, lang)
-				mf.Captions.Language = append(mf.Captions.Language, lang)
 			}
-		case "b":
-			x, y, err := parseAndValidateInts(filters, math.MaxInt32)
-			if err != nil {
-				return keyError("Bitrate", err)
+
+			if err := mf.parseSegment(key, value); err != nil {
+				return err
 			}
+			explicit[key] = true
+		}
+	}
+
+	return nil
+}
+
+// parseSegment parses a single filter and validates it on its own, so
+// errors point at the place the filter was set
+func (mf *MediaFilters) parseSegment(key, values string) error {
+	segment := &MediaFilters{Protocol: mf.Protocol}
+	if err := segment.parseFilter(key, values); err != nil {
+		return err
+	}
+
+	// finalize normalizes the segment in place, e.g. moving the bitrate
+	// into the nested filters, so it's merged before being validated
+	mf.merge(key, segment)
 
-			mf.Bitrate = &Bitrate{
-				Min: x,
-				Max: y,
+	return segment.finalize()
+}
+
+// merge sets the filters of a segment holding the single filter of key as
+// parseFilter would have: lists are appended to and other filters replaced.
+// Boolean filters can't tell false apart from unset ones, so they're only
+// replaced when key is theirs.
+func (mf *MediaFilters) merge(key string, segment *MediaFilters) {
+	mf.Videos.merge(segment.Videos)
+	mf.Audios.merge(segment.Audios)
+	mf.Captions.merge(segment.Captions)
+	mf.IFrames.merge(segment.IFrames)
+	mf.ContentTypes = append(mf.ContentTypes, segment.ContentTypes...)
+	mf.Plugins = append(mf.Plugins, segment.Plugins...)
+	mf.Presets = append(mf.Presets, segment.Presets...)
+	mf.DRM = append(mf.DRM, segment.DRM...)
+	mf.FrameRate = append(mf.FrameRate, segment.FrameRate...)
+	mf.VideoRange = append(mf.VideoRange, segment.VideoRange...)
+
+	if segment.Tags != nil {
+		mf.Tags = segment.Tags
+	}
+
+	if segment.Trim != nil {
+		mf.Trim = segment.Trim
+	}
+
+	if segment.MediaTime != nil {
+		mf.MediaTime = segment.MediaTime
+	}
+
+	if segment.Sequence != nil {
+		mf.Sequence = segment.Sequence
+	}
+
+	if segment.Bitrate != nil {
+		mf.Bitrate = segment.Bitrate
+	}
+
+	if segment.Renditions != nil {
+		mf.Renditions = segment.Renditions
+	}
+
+	if segment.Sort != nil {
+		mf.Sort = segment.Sort
+	}
+
+	if segment.DVR != 0 {
+		mf.DVR = segment.DVR
+	}
+
+	if segment.PlaylistType != "" {
+		mf.PlaylistType = segment.PlaylistType
+	}
+
+	if segment.CDN != "" {
+		mf.CDN = segment.CDN
+	}
+
+	if segment.SCTE != "" {
+		mf.SCTE = segment.SCTE
+	}
+
+	switch key {
+	case "drmstrict":
+		mf.DRMStrict = segment.DRMStrict
+	case "adskip":
+		mf.AdSkip = segment.AdSkip
+	case "dw":
+		mf.DeWeave = segment.DeWeave
+	case "phe":
+		mf.PreventHTTPStatusError = segment.PreventHTTPStatusError
+	}
+}
+
+// parseFilter sets the filter associated to key using the values found
+// between its parenthesis, e.g. key(values)
+func (mf *MediaFilters) parseFilter(key, values string) error {
+	filters := strings.Split(values, ",")
+	nestedFilters := splitAfter(values, nestedFilterRegexp)
+
+	switch key {
+	case "v":
+		for _, nf := range nestedFilters {
+			if err := mf.Videos.parse(nf); err != nil {
+				return nestedFilterError(key, err)
 			}
-		case "t":
-			x, y, err := parseAndValidateInts(filters, int(time.Now().Unix()))
-			if err != nil {
-				return keyError("Trim", err)
+		}
+	case "a":
+		for _, nf := range nestedFilters {
+			if err := mf.Audios.parse(nf); err != nil {
+				return nestedFilterError(key, err)
 			}
+		}
+	case "c":
+		for _, nf := range nestedFilters {
+			if err := mf.Captions.parse(nf); err != nil {
+				return nestedFilterError(key, err)
+			}
+		}
+	case "i":
+		for _, nf := range nestedFilters {
+			if err := mf.IFrames.parse(nf); err != nil {
+				return nestedFilterError(key, err)
+			}
+		}
+	case "ct":
+		mf.ContentTypes = append(mf.ContentTypes, filters...)
+	case "l":
+		if err := mf.Audios.parseKeys(key, filters); err != nil {
+			return filterError(key, values, err)
+		}
+		if err := mf.Captions.parseKeys(key, filters); err != nil {
+			return filterError(key, values, err)
+		}
+	case "b":
+		x, y, err := parseInts(filters, math.MaxInt32)
+		if err != nil {
+			return filterError(key, values, err)
+		}
 
-			mf.Trim = &Trim{
-				Start: x,
-				End:   y,
+		mf.Bitrate = &Bitrate{
+			Min: x,
+			Max: y,
+		}
+	case "n":
+		r, err := parseRenditions(filters)
+		if err != nil {
+			return filterError(key, values, err)
+		}
+
+		mf.Renditions = r
+	case "sort":
+		s, err := parseSort(filters)
+		if err != nil {
+			return filterError(key, values, err)
+		}
+
+		mf.Sort = s
+	case "t":
+		t, err := parseTrim(filters)
+		if err != nil {
+			return filterError(key, values, err)
+		}
+
+		mf.Trim = t
+	case "mt":
+		t, err := parseMediaTime(filters)
+		if err != nil {
+			return filterError(key, values, err)
+		}
+
+		mf.MediaTime = t
+	case "seq":
+		x, y, err := parseInts(filters, math.MaxInt32)
+		if err != nil {
+			return filterError(key, values, err)
+		}
+
+		mf.Sequence = &Sequence{
+			Start: x,
+			End:   y,
+		}
+	case "dvr":
+		if len(filters) > 1 {
+			return filterError(key, values, fmt.Errorf("expected a single window duration, got %v values", len(filters)))
+		}
+
+		d, err := parseOffset(strings.TrimSpace(filters[0]))
+		if err != nil {
+			return filterError(key, values, err)
+		}
+
+		if d <= 0 {
+			return filterError(key, values, fmt.Errorf("window duration must be positive"))
+		}
+
+		mf.DVR = d
+	case "type":
+		if len(filters) > 1 {
+			return filterError(key, values, fmt.Errorf("expected a single playlist type, got %v values", len(filters)))
+		}
+
+		mf.PlaylistType = strings.TrimSpace(filters[0])
+	case "cdn":
+		if len(filters) > 1 {
+			return filterError(key, values, fmt.Errorf("expected a single cdn, got %v values", len(filters)))
+		}
+
+		mf.CDN = strings.TrimSpace(filters[0])
+	case "scte":
+		if len(filters) > 1 {
+			return filterError(key, values, fmt.Errorf("expected a single scte-35 dialect, got %v values", len(filters)))
+		}
+
+		mf.SCTE = strings.TrimSpace(filters[0])
+	case "drm":
+		for _, system := range filters {
+			mf.DRM = append(mf.DRM, strings.TrimSpace(system))
+		}
+	case "res", "resw": //shorthand for v(res(...)) and v(resw(...))
+		if err := mf.Videos.parseKeys(key, filters); err != nil {
+			return nestedFilterError("v", err)
+		}
+	case "p":
+		mf.Presets = append(mf.Presets, filters...)
+	case "tags":
+		mf.Tags = &Tags{}
+		if err := mf.Tags.parse(filters); err != nil {
+			return filterError(key, values, err)
+		}
+	case "fps": //fps types in hls=float64, dash=string
+		for _, framerate := range filters {
+			fr := strings.ReplaceAll(framerate, ":", "/")
+			mf.FrameRate = append(mf.FrameRate, fr)
+		}
+	case "range":
+		for _, videoRange := range filters {
+			mf.VideoRange = append(mf.VideoRange, strings.TrimSpace(videoRange))
+		}
+	case "drmstrict":
+		if len(filters) > 1 {
+			return filterError(key, values, fmt.Errorf("Only accepts one boolean value"))
+		}
+
+		s, err := parseAndValidateBooleanString(filters[0])
+		if err != nil {
+			return filterError(key, values, err)
+		}
+
+		mf.DRMStrict = s
+	case "adskip":
+		if len(filters) > 1 {
+			return filterError(key, values, fmt.Errorf("Only accepts one boolean value"))
+		}
+
+		s, err := parseAndValidateBooleanString(filters[0])
+		if err != nil {
+			return filterError(key, values, err)
+		}
+
+		mf.AdSkip = s
+	case "dw":
+		if len(filters) > 1 {
+			return filterError(key, values, fmt.Errorf("Only accepts one boolean value"))
+		}
+
+		w, err := parseAndValidateBooleanString(filters[0])
+		if err != nil {
+			return filterError(key, values, err)
+		}
+
+		mf.DeWeave = w
+	case "phe":
+		if len(filters) > 1 {
+			return filterError(key, values, fmt.Errorf("Only accepts one boolean value"))
+		}
+
+		f, err := parseAndValidateBooleanString(filters[0])
+		if err != nil {
+			return filterError(key, values, err)
+		}
+
+		mf.PreventHTTPStatusError = f
+	default:
+		pErr := filterError(key, values, fmt.Errorf("unsupported filter %v", key))
+		if suggestion := closest(key, sortedKeys(filterKeys)); suggestion != "" {
+			pErr.Hint = hint(suggestion, values)
+		}
+		return pErr
+	}
+
+	return nil
+}
+
+// finalize validates the filters and normalizes them into the form expected
+// by the filters package. Filters set in the path, the query or a JSON body
+// all go through here so the same rules apply regardless of their source.
+func (mf *MediaFilters) finalize() error {
+	for _, contentType := range mf.ContentTypes {
+		if _, valid := contentSupported[contentType]; !valid {
+			pErr := filterError("ct", contentType, fmt.Errorf("Content Type %v is not supported", contentType))
+			if suggestion := closest(contentType, sortedKeys(contentSupported)); suggestion != "" {
+				pErr.Hint = hint("ct", suggestion)
 			}
-		case "tags": //only applied when trimming/serving hls media playlists
-			mf.Tags = &Tags{}
-			mf.Tags.parse(filters)
-		case "fps": //fps types in hls=float64, dash=string
-			for _, framerate := range filters {
-				fr := strings.ReplaceAll(framerate, ":", "/")
-				mf.FrameRate = append(mf.FrameRate, fr)
+			return pErr
+		}
+	}
+
+	if err := mf.Videos.finalize(); err != nil {
+		return nestedFilterError("v", err)
+	}
+
+	if err := mf.Audios.finalize(); err != nil {
+		return nestedFilterError("a", err)
+	}
+
+	if err := mf.Captions.finalize(); err != nil {
+		return nestedFilterError("c", err)
+	}
+
+	if err := mf.IFrames.finalize(); err != nil {
+		return nestedFilterError("i", err)
+	}
+
+	if mf.Audios.Height != nil || mf.Audios.Width != nil {
+		return filterError("a", "", fmt.Errorf("resolution filters only apply to video"))
+	}
+
+	if mf.Captions.Height != nil || mf.Captions.Width != nil {
+		return filterError("c", "", fmt.Errorf("resolution filters only apply to video"))
+	}
+
+	if mf.Videos.Channels != nil {
+		return filterError("v", "", fmt.Errorf("channel filters only apply to audio"))
+	}
+
+	if mf.Captions.Channels != nil {
+		return filterError("c", "", fmt.Errorf("channel filters only apply to audio"))
+	}
+
+	if mf.IFrames.Language != nil || mf.IFrames.Channels != nil {
+		return filterError("i", "", fmt.Errorf("i-frame playlists only support codec, bitrate and resolution filters"))
+	}
+
+	if mf.Bitrate != nil {
+		if err := validateRange(mf.Bitrate.Min, mf.Bitrate.Max, math.MaxInt32); err != nil {
+			return rangeError("b", float64(mf.Bitrate.Min), float64(mf.Bitrate.Max), err)
+		}
+	}
+
+	if r := mf.Renditions; r != nil {
+		if r.Strategy == "" {
+			r.Strategy = RenditionsHighest
+		}
+
+		if r.Count < 1 {
+			return filterError("n", fmt.Sprint(r.Count), fmt.Errorf("at least one rendition must be kept"))
+		}
+
+		if _, valid := renditionStrategies[r.Strategy]; !valid {
+			pErr := filterError("n", r.Strategy, fmt.Errorf("strategy %v is not supported", r.Strategy))
+			if suggestion := closest(r.Strategy, sortedKeys(renditionStrategies)); suggestion != "" {
+				pErr.Hint = hint("n", fmt.Sprint(r.Count), suggestion)
 			}
-		case "dw":
-			if len(filters) > 1 {
-				return keyError("DeWeave", fmt.Errorf("Only accepts one boolean value"))
+			return pErr
+		}
+	}
+
+	if s := mf.Sort; s != nil {
+		if s.Order != "" && s.Order != SortAscending && s.Order != SortDescending {
+			pErr := filterError("sort", s.Order, fmt.Errorf("order %v is not supported", s.Order))
+			if suggestion := closest(s.Order, []string{SortAscending, SortDescending}); suggestion != "" {
+				pErr.Hint = hint("sort", suggestion)
 			}
+			return pErr
+		}
+
+		if s.Target < 0 {
+			return filterError("sort", fmt.Sprint(s.Target), fmt.Errorf("target bitrate must be positive"))
+		}
+	}
+
+	// relative ranges, e.g. set in a JSON body, are resolved against now
+	if mf.Trim != nil && len(mf.Trim.Relative) > 0 {
+		t, err := parseTrim(mf.Trim.Relative)
+		if err != nil {
+			return filterError("t", strings.Join(mf.Trim.Relative, ","), err)
+		}
+		mf.Trim = t
+	}
+
+	if mf.Trim != nil {
+		if err := mf.Trim.validate(); err != nil {
+			return rangeError("t", mf.Trim.Start, mf.Trim.End, err)
+		}
+	}
 
-			w, err := parseAndValidateBooleanString(filters[0])
-			if err != nil {
-				return keyError("DeWeave", err)
+	if mf.MediaTime != nil {
+		if err := mf.MediaTime.validate(); err != nil {
+			return rangeError("mt", mf.MediaTime.Start, mf.MediaTime.End, err)
+		}
+	}
+
+	if s := mf.Sequence; s != nil {
+		if s.Start < 0 || s.Start > s.End {
+			err := fmt.Errorf("invalid range for provided values: ( %v, %v )", s.Start, s.End)
+			return rangeError("seq", float64(s.Start), float64(s.End), err)
+		}
+	}
+
+	if t := mf.PlaylistType; t != "" {
+		if _, valid := playlistTypes[t]; !valid {
+			pErr := filterError("type", t, fmt.Errorf("playlist type %v is not supported", t))
+			if suggestion := closest(t, sortedKeys(playlistTypes)); suggestion != "" {
+				pErr.Hint = hint("type", suggestion)
 			}
+			return pErr
+		}
 
-			mf.DeWeave = w
-		case "phe":
-			if len(filters) > 1 {
-				return keyError("PreventHTTPStatusError", fmt.Errorf("Only accepts one boolean value"))
+		if mf.Protocol == ProtocolDASH && t != PlaylistTypeVOD {
+			pErr := filterError("type", t, fmt.Errorf("playlist type %v is not supported for dash", t))
+			pErr.Hint = hint("type", PlaylistTypeVOD)
+			return pErr
+		}
+	}
+
+	if d := mf.SCTE; d != "" {
+		if _, valid := scteDialects[d]; !valid {
+			pErr := filterError("scte", d, fmt.Errorf("scte-35 dialect %v is not supported", d))
+			if suggestion := closest(d, sortedKeys(scteDialects)); suggestion != "" {
+				pErr.Hint = hint("scte", suggestion)
 			}
+			return pErr
+		}
+	}
 
-			f, err := parseAndValidateBooleanString(filters[0])
-			if err != nil {
-				return keyError("PreventHTTPStatusError", err)
+	for i, system := range mf.DRM {
+		if _, valid := drmSystems[system]; !valid {
+			pErr := filterError("drm", system, fmt.Errorf("drm system %v is not supported", system))
+			if suggestion := closest(system, sortedKeys(drmSystems)); suggestion != "" {
+				systems := append([]string{}, mf.DRM...)
+				systems[i] = suggestion
+				pErr.Hint = hint("drm", systems...)
 			}
+			return pErr
+		}
+	}
 
-			mf.PreventHTTPStatusError = f
+	for i, videoRange := range mf.VideoRange {
+		if _, valid := videoRanges[videoRange]; !valid {
+			pErr := filterError("range", videoRange, fmt.Errorf("video range %v is not supported", videoRange))
+			if suggestion := closest(videoRange, sortedKeys(videoRanges)); suggestion != "" {
+				ranges := append([]string{}, mf.VideoRange...)
+				ranges[i] = suggestion
+				pErr.Hint = hint("range", ranges...)
+			}
+			return pErr
 		}
 	}
 
 	mf.normalizeBitrateFilter()
 
-	return masterManifestPath, mf, nil
+	return nil
+}
+
+// expandPresets sets the filters of the referenced presets that weren't
+// set explicitly. When presets conflict, the first one referenced wins.
+// explicit holds the keys of the filters set explicitly, telling boolean
+// filters set to false apart from unset ones.
+func (mf *MediaFilters) expandPresets(presets Presets, explicit map[string]bool) error {
+	for _, name := range mf.Presets {
+		definition, found := presets[name]
+		if !found {
+			pErr := filterError("p", name, fmt.Errorf("preset %v is not defined", name))
+			if suggestion := closest(name, sortedKeys(map[string]string(presets))); suggestion != "" {
+				pErr.Hint = hint("p", suggestion)
+			}
+			return pErr
+		}
+
+		preset := new(MediaFilters)
+		for _, segment := range splitFilters(definition) {
+			subparts := urlParseRegexp.FindStringSubmatch(segment)
+			if len(subparts) != 3 || subparts[1] == "p" {
+				return filterError("p", name, fmt.Errorf("preset %v has an invalid filter %v", name, segment))
+			}
+
+			if err := preset.parseFilter(subparts[1], subparts[2]); err != nil {
+				return err
+			}
+		}
+
+		mf.inherit(preset, explicit)
+	}
+
+	mf.Presets = nil
+
+	return nil
+}
+
+// Validate returns the error of the first preset, in alphabetical order,
+// whose filters fail to parse or validate
+func (p Presets) Validate() error {
+	for _, name := range sortedKeys(map[string]string(p)) {
+		mf := &MediaFilters{Presets: []string{name}}
+		err := mf.expandPresets(p, map[string]bool{})
+		if err == nil {
+			err = mf.finalize()
+		}
+
+		if err != nil {
+			return fmt.Errorf("preset %v: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// inherit sets the filters of the preset that are not set in mf
+func (mf *MediaFilters) inherit(preset *MediaFilters, explicit map[string]bool) {
+	// an explicit overall bitrate overrides the nested bitrates of the preset
+	if mf.Bitrate != nil {
+		preset.Videos.Bitrate = nil
+		preset.Audios.Bitrate = nil
+	}
+
+	mf.Videos.inherit(preset.Videos)
+	mf.Audios.inherit(preset.Audios)
+	mf.Captions.inherit(preset.Captions)
+	mf.IFrames.inherit(preset.IFrames)
+
+	if mf.ContentTypes == nil {
+		mf.ContentTypes = preset.ContentTypes
+	}
+
+	if mf.Plugins == nil {
+		mf.Plugins = preset.Plugins
+	}
+
+	if mf.Tags == nil {
+		mf.Tags = preset.Tags
+	}
+
+	if mf.Trim == nil {
+		mf.Trim = preset.Trim
+	}
+
+	if mf.MediaTime == nil {
+		mf.MediaTime = preset.MediaTime
+	}
+
+	if mf.Sequence == nil {
+		mf.Sequence = preset.Sequence
+	}
+
+	if mf.DVR == 0 {
+		mf.DVR = preset.DVR
+	}
+
+	if mf.PlaylistType == "" {
+		mf.PlaylistType = preset.PlaylistType
+	}
+
+	if mf.CDN == "" {
+		mf.CDN = preset.CDN
+	}
+
+	if mf.SCTE == "" {
+		mf.SCTE = preset.SCTE
+	}
+
+	if mf.DRM == nil {
+		mf.DRM = preset.DRM
+	}
+
+	if mf.Bitrate == nil {
+		mf.Bitrate = preset.Bitrate
+	}
+
+	if mf.Renditions == nil {
+		mf.Renditions = preset.Renditions
+	}
+
+	if mf.Sort == nil {
+		mf.Sort = preset.Sort
+	}
+
+	if mf.FrameRate == nil {
+		mf.FrameRate = preset.FrameRate
+	}
+
+	if mf.VideoRange == nil {
+		mf.VideoRange = preset.VideoRange
+	}
+
+	if !explicit["drmstrict"] {
+		mf.DRMStrict = mf.DRMStrict || preset.DRMStrict
+	}
+
+	if !explicit["adskip"] {
+		mf.AdSkip = mf.AdSkip || preset.AdSkip
+	}
+
+	if !explicit["dw"] {
+		mf.DeWeave = mf.DeWeave || preset.DeWeave
+	}
+
+	if !explicit["phe"] {
+		mf.PreventHTTPStatusError = mf.PreventHTTPStatusError || preset.PreventHTTPStatusError
+	}
+}
+
+// rangeError returns a ParseError for an invalid range, hinting at
+// the swapped range when the bounds are reversed
+func rangeError(key string, x, y float64, err error) *ParseError {
+	pErr := filterError(key, fmt.Sprintf("%v,%v", formatSeconds(x), formatSeconds(y)), err)
+	if x > y && y >= 0 {
+		pErr.Hint = hint(key, formatSeconds(y), formatSeconds(x))
+	}
+
+	return pErr
 }
 
 func (mf *MediaFilters) parsePlugins(path string) bool {
@@ -241,6 +1169,20 @@ func (nf *NestedFilters) parse(nestedFilter string) error {
 	var key string
 	var param []string
 
+	// the regexp isn't anchored, so input left after the closing parenthesis,
+	// e.g. l(en)x, would be dropped. Nested filters are split after the comma
+	// following them, which is the only input allowed there
+	if len(splitNestedFilter) > 0 {
+		rest := strings.TrimPrefix(nestedFilter, splitNestedFilter[0])
+		if rest != "" && rest != "," {
+			nErr := &nestedError{value: rest, err: fmt.Errorf("unexpected %v after %v", rest, splitNestedFilter[0])}
+			if _, valid := codecSupported[rest]; valid {
+				nErr.suggestion = splitNestedFilter[0] + "," + rest
+			}
+			return nErr
+		}
+	}
+
 	if len(splitNestedFilter) == 0 { //default behavior is codec values
 		key = "co"
 		param = strings.Split(nestedFilter, ",")
@@ -278,39 +1220,214 @@ func (nf *NestedFilters) parse(nestedFilter string) error {
 	return nil
 }
 
+// merge sets the nested filters parsed in another NestedFilters, appending
+// to lists and replacing ranges
+func (nf *NestedFilters) merge(other NestedFilters) {
+	nf.Codecs = append(nf.Codecs, other.Codecs...)
+	nf.Only = append(nf.Only, other.Only...)
+	nf.Language = append(nf.Language, other.Language...)
+	nf.ExactLanguage = nf.ExactLanguage || other.ExactLanguage
+
+	if other.Bitrate != nil {
+		nf.Bitrate = other.Bitrate
+	}
+
+	if other.Height != nil {
+		nf.Height = other.Height
+	}
+
+	if other.Width != nil {
+		nf.Width = other.Width
+	}
+
+	if other.Channels != nil {
+		nf.Channels = other.Channels
+	}
+}
+
 // ParseNestedFilter takes a NestedFilter and sets Audios' or Videos' values accordingly.
 func (nf *NestedFilters) parseKeys(key string, values []string) error {
 	switch key {
 	case "co":
-		for _, v := range values {
-			switch v {
-			case "hdr10":
-				nf.Codecs = append(nf.Codecs, "hev1.2", "hvc1.2")
-			default:
-				if _, valid := codecSupported[v]; !valid {
-					return fmt.Errorf("Codec %v is not supported", v)
-				}
-				nf.Codecs = append(nf.Codecs, v)
-			}
-		}
+		nf.Codecs = append(nf.Codecs, values...)
+	case "only":
+		nf.Only = append(nf.Only, values...)
 	case "l":
 		for _, v := range values {
+			if v == exactLanguage {
+				nf.ExactLanguage = true
+				continue
+			}
 			nf.Language = append(nf.Language, v)
 		}
 	case "b":
-		x, y, err := parseAndValidateInts(values, math.MaxInt32)
+		x, y, err := parseInts(values, math.MaxInt32)
 		if err != nil {
-			return err
+			return &nestedError{value: strings.Join(values, ","), err: err}
 		}
 		nf.Bitrate = &Bitrate{
 			Min: x,
 			Max: y,
 		}
+	case "res", "resw":
+		x, y, err := parseInts(values, math.MaxInt32)
+		if err != nil {
+			return &nestedError{value: strings.Join(values, ","), err: err}
+		}
+
+		r := &Resolution{
+			Min: x,
+			Max: y,
+		}
+		if key == "res" {
+			nf.Height = r
+		} else {
+			nf.Width = r
+		}
+	case "ch":
+		x, y, err := parseInts(values, math.MaxInt32)
+		if err != nil {
+			return &nestedError{value: strings.Join(values, ","), err: err}
+		}
+
+		nf.Channels = &Channels{
+			Min: x,
+			Max: y,
+		}
+	default:
+		nErr := &nestedError{value: key, err: fmt.Errorf("unsupported nested filter %v", key)}
+		if name := closest(key, sortedKeys(queryNestedKeys)); name != "" {
+			nErr.suggestion = strings.Join(values, ",")
+			if nestedKey := queryNestedKeys[name]; nestedKey != "" {
+				nErr.suggestion = filterSegment(nestedKey, values...)
+			}
+		}
+		return nErr
 	}
 
 	return nil
 }
 
+// inherit sets the nested filters of the preset that are not set in nf
+func (nf *NestedFilters) inherit(preset NestedFilters) {
+	if nf.Bitrate == nil {
+		nf.Bitrate = preset.Bitrate
+	}
+
+	if nf.Codecs == nil {
+		nf.Codecs = preset.Codecs
+	}
+
+	if nf.Only == nil {
+		nf.Only = preset.Only
+	}
+
+	if nf.Language == nil {
+		nf.Language = preset.Language
+		nf.ExactLanguage = preset.ExactLanguage
+	}
+
+	if nf.Height == nil {
+		nf.Height = preset.Height
+	}
+
+	if nf.Width == nil {
+		nf.Width = preset.Width
+	}
+
+	if nf.Channels == nil {
+		nf.Channels = preset.Channels
+	}
+}
+
+// finalize validates the nested filter values and expands codec aliases
+func (nf *NestedFilters) finalize() error {
+	codecs, err := expandCodecs(nf.Codecs)
+	if err != nil {
+		return err
+	}
+	nf.Codecs = codecs
+
+	only, err := expandCodecs(nf.Only)
+	if err != nil {
+		return err
+	}
+	nf.Only = only
+
+	if nf.ExactLanguage && len(nf.Language) == 0 {
+		return &nestedError{value: exactLanguage, err: fmt.Errorf("exact matching requires a language")}
+	}
+
+	if nf.Bitrate != nil {
+		if err := validateNestedRange("b", nf.Bitrate.Min, nf.Bitrate.Max); err != nil {
+			return err
+		}
+	}
+
+	if nf.Height != nil {
+		if err := validateNestedRange("res", nf.Height.Min, nf.Height.Max); err != nil {
+			return err
+		}
+	}
+
+	if nf.Width != nil {
+		if err := validateNestedRange("resw", nf.Width.Min, nf.Width.Max); err != nil {
+			return err
+		}
+	}
+
+	if nf.Channels != nil {
+		if err := validateNestedRange("ch", nf.Channels.Min, nf.Channels.Max); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// expandCodecs validates the codecs, expanding the hdr10 alias
+// into both hevc main 10 codecs
+func expandCodecs(values []string) ([]string, error) {
+	var codecs []string
+	for _, v := range values {
+		switch v {
+		case "hdr10":
+			codecs = append(codecs, hdr10Codecs...)
+		case hdr10Codecs[0], hdr10Codecs[1]:
+			codecs = append(codecs, v)
+		default:
+			if _, valid := codecSupported[v]; !valid {
+				nErr := &nestedError{value: v, err: fmt.Errorf("Codec %v is not supported", v)}
+				if languageRegexp.MatchString(v) {
+					nErr.suggestion = filterSegment("l", v)
+				} else {
+					nErr.suggestion = closest(v, sortedKeys(codecSupported))
+				}
+				return nil, nErr
+			}
+			codecs = append(codecs, v)
+		}
+	}
+
+	return codecs, nil
+}
+
+// validateNestedRange returns a nestedError for an invalid range,
+// suggesting the swapped range when the bounds are reversed
+func validateNestedRange(key string, x, y int) error {
+	err := validateRange(x, y, math.MaxInt32)
+	if err == nil {
+		return nil
+	}
+
+	nErr := &nestedError{value: fmt.Sprintf("%v,%v", x, y), err: err}
+	if x > y && y >= 0 {
+		nErr.suggestion = filterSegment(key, fmt.Sprint(y), fmt.Sprint(x))
+	}
+
+	return nErr
+}
+
 // normalizeBitrateFilter will finalize the nested bitrate filter by comparing it to
 // overall bitrate filter and overriding any necessary values
 func (mf *MediaFilters) normalizeBitrateFilter() {
@@ -329,8 +1446,9 @@ func (mf *MediaFilters) normalizeBitrateFilter() {
 	mf.Bitrate = nil
 }
 
-// parseAndValidateInts will parse a range of two ints and validate their range
-func parseAndValidateInts(values []string, max int) (int, int, error) {
+// parseInts will parse a range of two ints, defaulting to 0 and max
+// when either bound is not set
+func parseInts(values []string, max int) (int, int, error) {
 	var x, y int
 	var err error
 
@@ -352,14 +1470,67 @@ func parseAndValidateInts(values []string, max int) (int, int, error) {
 		y = max
 	}
 
+	return x, y, nil
+}
+
+// validateRange returns an error if x and y are not a valid positive range
+func validateRange(x, y, max int) error {
 	if !validatePositiveRange(x, y, max) {
-		return x, y, fmt.Errorf("invalid range for provided values: ( %v, %v )", x, y)
+		return fmt.Errorf("invalid range for provided values: ( %v, %v )", x, y)
 	}
 
-	return x, y, nil
+	return nil
+}
+
+// parseRenditions parses the number of renditions to keep, optionally
+// followed by the strategy used to choose them
+func parseRenditions(values []string) (*Renditions, error) {
+	if len(values) > 2 {
+		return nil, fmt.Errorf("expected a count and a strategy, got %v values", len(values))
+	}
+
+	count, err := strconv.Atoi(strings.TrimSpace(values[0]))
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Renditions{Count: count}
+	if len(values) > 1 {
+		r.Strategy = strings.TrimSpace(values[1])
+	}
+
+	return r, nil
+}
+
+// parseSort parses the order of the variants and the target bitrate,
+// e.g. `desc,bitrate:3000000`, in any order
+func parseSort(values []string) (*Sort, error) {
+	s := &Sort{}
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if !strings.HasPrefix(v, sortTargetPrefix) {
+			if s.Order != "" {
+				return nil, fmt.Errorf("expected a single order, got %v and %v", s.Order, v)
+			}
+			s.Order = v
+			continue
+		}
+
+		target, err := strconv.Atoi(strings.TrimPrefix(v, sortTargetPrefix))
+		if err != nil {
+			return nil, err
+		}
+		s.Target = target
+	}
+
+	if s.Order == "" && s.Target == 0 {
+		return nil, fmt.Errorf("expected an order or a target bitrate")
+	}
+
+	return s, nil
 }
 
-func (t *Tags) parse(values []string) {
+func (t *Tags) parse(values []string) error {
 	for _, tag := range values {
 		switch tag {
 		case "ads":
@@ -368,8 +1539,23 @@ func (t *Tags) parse(values []string) {
 			t.IFrame = true
 		case "iframe":
 			t.IFrame = true
+		default:
+			// any other tag is removed by name, with or without the leading #
+			name := strings.ToUpper(strings.TrimPrefix(tag, "#"))
+			if !strings.HasPrefix(name, "EXT") {
+				return fmt.Errorf("expected ads, iframe or the name of an hls tag, e.g. EXT-X-ASSET, got %v", tag)
+			}
+			t.Custom = append(t.Custom, name)
 		}
 	}
+
+	return nil
+}
+
+// Trimmed will evaluate whether the media playlists are trimmed, either by
+// program date time, media time or media sequence
+func (mf *MediaFilters) Trimmed() bool {
+	return mf.Trim != nil || mf.MediaTime != nil || mf.Sequence != nil
 }
 
 // SuppressAds will evaluate whether the ad tag was set
@@ -381,6 +1567,40 @@ func (mf *MediaFilters) SuppressAds() bool {
 	return mf.Tags.Ads
 }
 
+// ValidateCDN returns an error if the cdn selected by the filters isn't one
+// of the given cdns, hinting at the closest one
+func (mf *MediaFilters) ValidateCDN(cdns []string) error {
+	if mf.CDN == "" {
+		return nil
+	}
+
+	for _, cdn := range cdns {
+		if mf.CDN == cdn {
+			return nil
+		}
+	}
+
+	pErr := filterError("cdn", mf.CDN, fmt.Errorf("cdn %v is not configured", mf.CDN))
+	// the names may be shared by concurrent requests, so a copy is sorted
+	sorted := append([]string(nil), cdns...)
+	sort.Strings(sorted)
+	if suggestion := closest(mf.CDN, sorted); suggestion != "" {
+		pErr.Hint = hint("cdn", suggestion)
+	}
+
+	return pErr
+}
+
+// SuppressTags returns the names of the custom tags to be removed
+// from the manifest
+func (mf *MediaFilters) SuppressTags() []string {
+	if mf.Tags == nil {
+		return nil
+	}
+
+	return mf.Tags.Custom
+}
+
 // SuppressIFrame will evaluate whether the i-frame tag was set
 func (mf *MediaFilters) SuppressIFrame() bool {
 	if mf.Tags == nil {
@@ -2,7 +2,9 @@ package parsers
 
 import (
 	"encoding/json"
+	"errors"
 	"math"
+	"net/url"
 	"reflect"
 	"testing"
 
@@ -470,6 +472,19 @@ func TestURLParseUrl(t *testing.T) {
 			false,
 		},
 
+		{
+			"detect custom tags when passed in by name",
+			"tags(ads,ext-x-asset,#EXT-X-DATERANGE)/path/here/with/master.m3u8",
+			MediaFilters{
+				Protocol: ProtocolHLS,
+				Tags: &Tags{
+					Ads:    true,
+					Custom: []string{"EXT-X-ASSET", "EXT-X-DATERANGE"},
+				},
+			},
+			"/path/here/with/master.m3u8",
+			false,
+		},
 		{
 			"detect iframe filter when passed in url",
 			"tags(i-frame)/path/here/with/master.m3u8",
@@ -596,6 +611,65 @@ func TestURLParseUrl(t *testing.T) {
 			"/propeller/orgID/channelID/outputID/origin.m3u8",
 			false,
 		},
+		{
+			"nested bitrate set before the overall bitrate is kept",
+			"/v(b(100,200))/b(0,4000)/path/to/test.m3u8",
+			MediaFilters{
+				Protocol: ProtocolHLS,
+				Videos: NestedFilters{
+					Bitrate: &Bitrate{Min: 100, Max: 200},
+				},
+				Audios: NestedFilters{
+					Bitrate: &Bitrate{Min: 0, Max: 4000},
+				},
+			},
+			"/path/to/test.m3u8",
+			false,
+		},
+		{
+			"boolean filter set twice keeps the last value",
+			"/adskip(true)/adskip(false)/path/to/test.m3u8",
+			MediaFilters{
+				Protocol: ProtocolHLS,
+			},
+			"/path/to/test.m3u8",
+			false,
+		},
+		{
+			"drm strict",
+			"/drm(widevine)/drmstrict(true)/path/to/test.mpd",
+			MediaFilters{
+				Protocol:  ProtocolDASH,
+				DRM:       []string{DRMWidevine},
+				DRMStrict: true,
+			},
+			"/path/to/test.mpd",
+			false,
+		},
+		{
+			"drm strict throws error if value is not true or false",
+			"/drmstrict(yes)/path/to/test.mpd",
+			MediaFilters{},
+			"",
+			true,
+		},
+		{
+			"ad skip",
+			"/adskip(true)/path/to/test.m3u8",
+			MediaFilters{
+				Protocol: ProtocolHLS,
+				AdSkip:   true,
+			},
+			"/path/to/test.m3u8",
+			false,
+		},
+		{
+			"ad skip throws error if value is not true or false",
+			"/adskip(yes)/path/to/test.m3u8",
+			MediaFilters{},
+			"",
+			true,
+		},
 		{
 			"ensure DeWeaved filter is set to true",
 			"dw(true)/some/path/to/manifest.m3u8",
@@ -650,6 +724,300 @@ func TestURLParseUrl(t *testing.T) {
 			"/base64string.vtt",
 			false,
 		},
+		{
+			"resolution filter set at the top level applies to videos",
+			"/res(0,720)/master.m3u8",
+			MediaFilters{
+				Videos: NestedFilters{
+					Height: &Resolution{Min: 0, Max: 720},
+				},
+				Protocol: ProtocolHLS,
+			},
+			"/master.m3u8",
+			false,
+		},
+		{
+			"nested resolution filters for height and width",
+			"/v(avc,res(720,),resw(,1920))/master.mpd",
+			MediaFilters{
+				Videos: NestedFilters{
+					Codecs: []string{"avc"},
+					Height: &Resolution{Min: 720, Max: math.MaxInt32},
+					Width:  &Resolution{Min: 0, Max: 1920},
+				},
+				Protocol: ProtocolDASH,
+			},
+			"/master.mpd",
+			false,
+		},
+		{
+			"resolution filter with minimum greater than maximum throws error",
+			"/v(res(1080,720))/master.mpd",
+			MediaFilters{},
+			"",
+			true,
+		},
+		{
+			"resolution filter nested in audio throws error",
+			"/a(res(0,720))/master.mpd",
+			MediaFilters{},
+			"",
+			true,
+		},
+		{
+			"allowlist of nested codecs",
+			"/v(hevc,only(avc,hdr10))/a(only(mp4a))/master.m3u8",
+			MediaFilters{
+				Videos: NestedFilters{
+					Codecs: []string{"hevc"},
+					Only:   []string{"avc", "hev1.2", "hvc1.2"},
+				},
+				Audios: NestedFilters{
+					Only: []string{"mp4a"},
+				},
+				Protocol: ProtocolHLS,
+			},
+			"/master.m3u8",
+			false,
+		},
+		{
+			"allowlist with an unsupported codec throws error",
+			"/v(only(vp9))/master.m3u8",
+			MediaFilters{},
+			"",
+			true,
+		},
+		{
+			"media time and media sequence trim filters",
+			"/mt(30.5,)/seq(10,20)/path/to/test.m3u8",
+			MediaFilters{
+				Protocol:  ProtocolHLS,
+				MediaTime: &Trim{Start: 30.5, End: math.MaxInt32},
+				Sequence:  &Sequence{Start: 10, End: 20},
+			},
+			"/path/to/test.m3u8",
+			false,
+		},
+		{
+			"dvr window in seconds",
+			"/dvr(1800)/path/to/test.m3u8",
+			MediaFilters{
+				Protocol: ProtocolHLS,
+				DVR:      1800,
+			},
+			"/path/to/test.m3u8",
+			false,
+		},
+		{
+			"dvr window as an ISO-8601 duration",
+			"/dvr(PT30M)/path/to/test.mpd",
+			MediaFilters{
+				Protocol: ProtocolDASH,
+				DVR:      1800,
+			},
+			"/path/to/test.mpd",
+			false,
+		},
+		{
+			"playlist type",
+			"/type(vod)/path/to/test.m3u8",
+			MediaFilters{
+				Protocol:     ProtocolHLS,
+				PlaylistType: PlaylistTypeVOD,
+			},
+			"/path/to/test.m3u8",
+			false,
+		},
+		{
+			"cdn",
+			"/cdn(akamai)/path/to/test.m3u8",
+			MediaFilters{
+				Protocol: ProtocolHLS,
+				CDN:      "akamai",
+			},
+			"/path/to/test.m3u8",
+			false,
+		},
+		{
+			"scte-35 dialect",
+			"/scte(oatcls)/path/to/test.m3u8",
+			MediaFilters{
+				Protocol: ProtocolHLS,
+				SCTE:     SCTEOATCLS,
+			},
+			"/path/to/test.m3u8",
+			false,
+		},
+		{
+			"drm systems",
+			"/drm(fairplay,widevine)/path/to/test.m3u8",
+			MediaFilters{
+				Protocol: ProtocolHLS,
+				DRM:      []string{DRMFairPlay, DRMWidevine},
+			},
+			"/path/to/test.m3u8",
+			false,
+		},
+		{
+			"video ranges",
+			"/range(pq,hlg)/path/to/test.m3u8",
+			MediaFilters{
+				Protocol:   ProtocolHLS,
+				VideoRange: []string{VideoRangePQ, VideoRangeHLG},
+			},
+			"/path/to/test.m3u8",
+			false,
+		},
+		{
+			"dvr window that isn't positive throws error",
+			"/dvr(0)/path/to/test.m3u8",
+			MediaFilters{},
+			"",
+			true,
+		},
+		{
+			"media time filter where start time is greater than end time throws error",
+			"/mt(90,30)/path/to/test.m3u8",
+			MediaFilters{},
+			"",
+			true,
+		},
+		{
+			"media sequence filter where start is greater than end throws error",
+			"/seq(20,10)/path/to/test.m3u8",
+			MediaFilters{},
+			"",
+			true,
+		},
+		{
+			"i-frame nested filter",
+			"/i(b(0,500000),hevc)/master.m3u8",
+			MediaFilters{
+				Protocol: ProtocolHLS,
+				IFrames: NestedFilters{
+					Bitrate: &Bitrate{Min: 0, Max: 500000},
+					Codecs:  []string{"hevc"},
+				},
+			},
+			"/master.m3u8",
+			false,
+		},
+		{
+			"i-frame nested filter with a language throws error",
+			"/i(l(en))/master.m3u8",
+			MediaFilters{},
+			"",
+			true,
+		},
+		{
+			"audio channels filter",
+			"/a(ec-3,ch(,6))/master.mpd",
+			MediaFilters{
+				Protocol: ProtocolDASH,
+				Audios: NestedFilters{
+					Codecs:   []string{"ec-3"},
+					Channels: &Channels{Min: 0, Max: 6},
+				},
+			},
+			"/master.mpd",
+			false,
+		},
+		{
+			"channels filter nested in video throws error",
+			"/v(avc,ch(0,2))/master.mpd",
+			MediaFilters{},
+			"",
+			true,
+		},
+		{
+			"language filter with exact matching",
+			"/l(pt-BR,exact)/master.m3u8",
+			MediaFilters{
+				Protocol: ProtocolHLS,
+				Audios: NestedFilters{
+					Language:      []string{"pt-BR"},
+					ExactLanguage: true,
+				},
+				Captions: NestedFilters{
+					Language:      []string{"pt-BR"},
+					ExactLanguage: true,
+				},
+			},
+			"/master.m3u8",
+			false,
+		},
+		{
+			"exact language matching without a language throws error",
+			"/a(mp4a,l(exact))/master.m3u8",
+			MediaFilters{},
+			"",
+			true,
+		},
+		{
+			"sort filter with an order and a target bitrate",
+			"/sort(bitrate:3000000,desc)/master.m3u8",
+			MediaFilters{
+				Protocol: ProtocolHLS,
+				Sort:     &Sort{Order: SortDescending, Target: 3000000},
+			},
+			"/master.m3u8",
+			false,
+		},
+		{
+			"sort filter with an unsupported order throws error",
+			"/sort(up)/master.m3u8",
+			MediaFilters{},
+			"",
+			true,
+		},
+		{
+			"sort filter with an invalid target bitrate throws error",
+			"/sort(bitrate:high)/master.m3u8",
+			MediaFilters{},
+			"",
+			true,
+		},
+		{
+			"renditions filter defaults to the highest renditions",
+			"/n(3)/master.m3u8",
+			MediaFilters{
+				Protocol:   ProtocolHLS,
+				Renditions: &Renditions{Count: 3, Strategy: RenditionsHighest},
+			},
+			"/master.m3u8",
+			false,
+		},
+		{
+			"renditions filter with a strategy",
+			"/n(4,spread)/master.mpd",
+			MediaFilters{
+				Protocol:   ProtocolDASH,
+				Renditions: &Renditions{Count: 4, Strategy: RenditionsSpread},
+			},
+			"/master.mpd",
+			false,
+		},
+		{
+			"renditions filter with an unsupported strategy throws error",
+			"/n(4,middle)/master.mpd",
+			MediaFilters{},
+			"",
+			true,
+		},
+		{
+			"renditions filter keeping no rendition throws error",
+			"/n(0)/master.mpd",
+			MediaFilters{},
+			"",
+			true,
+		},
+		{
+			"unknown filter key throws error",
+			"/v(avc)/lang(en)/master.m3u8",
+			MediaFilters{},
+			"",
+			true,
+		},
 		{
 			"parse the http status error fallback filter throws error if multiple values are passed",
 			"phe(true,false)/base64string.vtt",
@@ -749,3 +1117,432 @@ func TestParsers_SuppressTags(t *testing.T) {
 		})
 	}
 }
+
+func TestQueryParse(t *testing.T) {
+	tests := []struct {
+		name                 string
+		path                 string
+		query                url.Values
+		equivalentPath       string
+		expectedManifestPath string
+		expectedErr          bool
+	}{
+		{
+			name:                 "when no query is passed, filters match the path grammar",
+			path:                 "/v(avc)/b(100,4000)/some/path/master.m3u8",
+			equivalentPath:       "/v(avc)/b(100,4000)/some/path/master.m3u8",
+			expectedManifestPath: "/some/path/master.m3u8",
+		},
+		{
+			name: "when top level filters are passed as query params, match the path grammar",
+			path: "/some/path/master.m3u8",
+			query: url.Values{
+				"b":    []string{"0,4000000"},
+				"ct":   []string{"text"},
+				"tags": []string{"ads"},
+				"dw":   []string{"true"},
+			},
+			equivalentPath:       "/b(0,4000000)/ct(text)/tags(ads)/dw(true)/some/path/master.m3u8",
+			expectedManifestPath: "/some/path/master.m3u8",
+		},
+		{
+			name: "when nested filters are passed as query params, match the path grammar",
+			path: "/some/path/master.m3u8",
+			query: url.Values{
+				"v.codecs":   []string{"hdr10,avc"},
+				"v.b":        []string{"100,"},
+				"a.language": []string{"en,es"},
+				"a":          []string{"mp4a"},
+			},
+			equivalentPath:       "/v(hdr10,avc,b(100,))/a(mp4a,l(en,es))/some/path/master.m3u8",
+			expectedManifestPath: "/some/path/master.m3u8",
+		},
+		{
+			name: "when filters are passed in both the path and query, combine them",
+			path: "/v(avc)/some/path/master.mpd",
+			query: url.Values{
+				"a.codecs": []string{"ec-3"},
+			},
+			equivalentPath:       "/v(avc)/a(ec-3)/some/path/master.mpd",
+			expectedManifestPath: "/some/path/master.mpd",
+		},
+		{
+			name: "when query params are not filter keys, ignore them",
+			path: "/some/path/master.m3u8",
+			query: url.Values{
+				"token":    []string{"abc"},
+				"_HLS_msn": []string{"10"},
+				"version":  []string{"2"},
+			},
+			equivalentPath:       "/some/path/master.m3u8",
+			expectedManifestPath: "/some/path/master.m3u8",
+		},
+		{
+			name: "when filters are passed along origin query params, match the path grammar",
+			path: "/some/path/master.m3u8",
+			query: url.Values{
+				"v.codecs": []string{"avc"},
+				"b":        []string{"0,4000000"},
+				"token":    []string{"abc"},
+			},
+			equivalentPath:       "/v(avc)/b(0,4000000)/some/path/master.m3u8",
+			expectedManifestPath: "/some/path/master.m3u8",
+		},
+		{
+			name: "when query params carry an unsupported codec, expect error",
+			path: "/some/path/master.m3u8",
+			query: url.Values{
+				"v.codecs": []string{"codec"},
+			},
+			expectedErr: true,
+		},
+		{
+			name: "when query params carry an unsupported nested filter, expect error",
+			path: "/some/path/master.m3u8",
+			query: url.Values{
+				"v.unknown": []string{"1"},
+			},
+			expectedErr: true,
+		},
+		{
+			name: "when query params carry an invalid range, expect error",
+			path: "/some/path/master.m3u8",
+			query: url.Values{
+				"b": []string{"4000,10"},
+			},
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			masterManifestPath, got, err := QueryParse(tc.path, tc.query, nil)
+			if !tc.expectedErr && err != nil {
+				t.Errorf("Did not expect an error returned, got: %v", err)
+				return
+			} else if tc.expectedErr && err == nil {
+				t.Errorf("Expected an error returned, got nil")
+				return
+			}
+
+			if tc.expectedErr {
+				return
+			}
+
+			_, expected, err := URLParse(tc.equivalentPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if masterManifestPath != tc.expectedManifestPath {
+				t.Errorf("wrong master manifest generated.\nwant %v\n\ngot %v", tc.expectedManifestPath, masterManifestPath)
+			}
+
+			if !cmp.Equal(got, expected) {
+				t.Errorf("wrong struct generated.\nwant %v\ngot %v\n diff: %v", expected, got, cmp.Diff(expected, got))
+			}
+		})
+	}
+}
+
+func TestOriginQuery(t *testing.T) {
+	query := url.Values{
+		"token":    []string{"abc"},
+		"_HLS_msn": []string{"10"},
+		"v.codecs": []string{"avc"},
+		"t":        []string{"-3600,"},
+	}
+
+	expected := url.Values{
+		"token":    []string{"abc"},
+		"_HLS_msn": []string{"10"},
+	}
+
+	if got := OriginQuery(query); !cmp.Equal(got, expected) {
+		t.Errorf("wrong origin query.\nwant %v\ngot %v\n diff: %v", expected, got, cmp.Diff(expected, got))
+	}
+}
+
+func TestJSONParse(t *testing.T) {
+	tests := []struct {
+		name                 string
+		path                 string
+		body                 string
+		equivalentPath       string
+		expectedManifestPath string
+		expectedErr          bool
+	}{
+		{
+			name:                 "when body is empty, filters match the path grammar",
+			path:                 "/v(avc)/some/path/master.m3u8",
+			equivalentPath:       "/v(avc)/some/path/master.m3u8",
+			expectedManifestPath: "/some/path/master.m3u8",
+		},
+		{
+			name:                 "when body sets filters, match the path grammar",
+			path:                 "/some/path/master.m3u8",
+			body:                 `{"Videos":{"Codecs":["hdr10","avc"]},"Audios":{"Language":["en"]},"Bitrate":{"Min":100,"Max":4000},"Tags":{"Ads":true}}`,
+			equivalentPath:       "/v(hdr10,avc)/a(l(en))/b(100,4000)/tags(ads)/some/path/master.m3u8",
+			expectedManifestPath: "/some/path/master.m3u8",
+		},
+		{
+			name:                 "when body sets a protocol, the protocol is still derived from the path",
+			path:                 "/some/path/master.mpd",
+			body:                 `{"protocol":"hls","ContentTypes":["text"]}`,
+			equivalentPath:       "/ct(text)/some/path/master.mpd",
+			expectedManifestPath: "/some/path/master.mpd",
+		},
+		{
+			name:        "when body carries an unsupported content type, expect error",
+			path:        "/some/path/master.m3u8",
+			body:        `{"ContentTypes":["content"]}`,
+			expectedErr: true,
+		},
+		{
+			name:        "when body carries an invalid nested range, expect error",
+			path:        "/some/path/master.m3u8",
+			body:        `{"Audios":{"Bitrate":{"Min":1000,"Max":1000}}}`,
+			expectedErr: true,
+		},
+		{
+			name:        "when body is not valid json, expect error",
+			path:        "/some/path/master.m3u8",
+			body:        `{"Audios":`,
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			masterManifestPath, got, err := JSONParse(tc.path, []byte(tc.body), nil)
+			if !tc.expectedErr && err != nil {
+				t.Errorf("Did not expect an error returned, got: %v", err)
+				return
+			} else if tc.expectedErr && err == nil {
+				t.Errorf("Expected an error returned, got nil")
+				return
+			}
+
+			if tc.expectedErr {
+				return
+			}
+
+			_, expected, err := URLParse(tc.equivalentPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if masterManifestPath != tc.expectedManifestPath {
+				t.Errorf("wrong master manifest generated.\nwant %v\n\ngot %v", tc.expectedManifestPath, masterManifestPath)
+			}
+
+			if !cmp.Equal(got, expected) {
+				t.Errorf("wrong struct generated.\nwant %v\ngot %v\n diff: %v", expected, got, cmp.Diff(expected, got))
+			}
+		})
+	}
+}
+
+func TestJSONParse_Presets(t *testing.T) {
+	presets := Presets{
+		"captions": "dw(true),adskip(true)",
+	}
+
+	tests := []struct {
+		name           string
+		path           string
+		body           string
+		equivalentPath string
+	}{
+		{
+			name:           "when the body doesn't set a boolean filter, expect the preset to set it",
+			path:           "/p(captions)/some/path/master.m3u8",
+			body:           `{"ContentTypes":["text"]}`,
+			equivalentPath: "/ct(text)/dw(true)/adskip(true)/some/path/master.m3u8",
+		},
+		{
+			name:           "when the body disables a boolean filter, expect it to override the preset",
+			path:           "/p(captions)/some/path/master.m3u8",
+			body:           `{"deweave":false}`,
+			equivalentPath: "/adskip(true)/some/path/master.m3u8",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, got, err := JSONParse(tc.path, []byte(tc.body), presets)
+			if err != nil {
+				t.Fatalf("Did not expect an error returned, got: %v", err)
+			}
+
+			_, expected, err := URLParse(tc.equivalentPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !cmp.Equal(got, expected) {
+				t.Errorf("wrong struct generated.\nwant %v\ngot %v\n diff: %v", expected, got, cmp.Diff(expected, got))
+			}
+		})
+	}
+}
+
+func TestPresets_Validate(t *testing.T) {
+	tests := []struct {
+		name        string
+		presets     Presets
+		expectedErr bool
+	}{
+		{
+			name: "when presets are valid, expect no error",
+			presets: Presets{
+				"mobile-cellular": "v(avc,b(0,3000000)),a(mp4a),tags(iframe)",
+				"hd":              "res(720,),fps(60)",
+			},
+		},
+		{
+			name:        "when a preset has an unknown filter, expect error",
+			presets:     Presets{"hd": "resolution(720,)"},
+			expectedErr: true,
+		},
+		{
+			name:        "when a preset has an invalid range, expect error",
+			presets:     Presets{"hd": "v(res(1080,720))"},
+			expectedErr: true,
+		},
+		{
+			name:        "when a preset references another preset, expect error",
+			presets:     Presets{"hd": "res(720,)", "nested": "p(hd)"},
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.presets.Validate()
+			if !tc.expectedErr && err != nil {
+				t.Errorf("Did not expect an error returned, got: %v", err)
+			} else if tc.expectedErr && err == nil {
+				t.Errorf("Expected an error returned, got nil")
+			}
+		})
+	}
+}
+
+func TestQueryParse_Presets(t *testing.T) {
+	presets := Presets{
+		"mobile-cellular": "v(avc,b(0,3000000)),a(mp4a),tags(iframe)",
+		"hd":              "res(720,),fps(60)",
+		"nested":          "p(hd)",
+		"captions":        "dw(true),adskip(true)",
+	}
+
+	tests := []struct {
+		name           string
+		path           string
+		query          url.Values
+		equivalentPath string
+		expectedErr    bool
+	}{
+		{
+			name:           "when a preset is referenced in the path, expect its filters to be set",
+			path:           "/p(mobile-cellular)/some/path/master.m3u8",
+			equivalentPath: "/v(avc,b(0,3000000))/a(mp4a)/tags(iframe)/some/path/master.m3u8",
+		},
+		{
+			name:           "when explicit filters conflict with a preset, expect explicit filters to win",
+			path:           "/p(mobile-cellular)/v(hevc)/a(b(0,128000))/some/path/master.m3u8",
+			equivalentPath: "/v(hevc,b(0,3000000))/a(mp4a,b(0,128000))/tags(iframe)/some/path/master.m3u8",
+		},
+		{
+			name:           "when an explicit overall bitrate is set, expect it to override the preset nested bitrate",
+			path:           "/b(0,1000000)/p(mobile-cellular)/some/path/master.m3u8",
+			equivalentPath: "/v(avc)/a(mp4a)/b(0,1000000)/tags(iframe)/some/path/master.m3u8",
+		},
+		{
+			name:           "when multiple presets are referenced, expect them to be combined",
+			path:           "/p(mobile-cellular,hd)/some/path/master.m3u8",
+			equivalentPath: "/v(avc,b(0,3000000),res(720,))/a(mp4a)/tags(iframe)/fps(60)/some/path/master.m3u8",
+		},
+		{
+			name: "when a preset is referenced as a query param, expect its filters to be set",
+			path: "/fps(30)/some/path/master.m3u8",
+			query: url.Values{
+				"p": []string{"hd"},
+			},
+			equivalentPath: "/res(720,)/fps(30)/some/path/master.m3u8",
+		},
+		{
+			name:           "when a boolean filter is explicitly disabled, expect it to override the preset",
+			path:           "/p(captions)/dw(false)/some/path/master.m3u8",
+			equivalentPath: "/adskip(true)/some/path/master.m3u8",
+		},
+		{
+			name: "when a boolean filter is explicitly disabled as a query param, expect it to override the preset",
+			path: "/p(captions)/some/path/master.m3u8",
+			query: url.Values{
+				"adskip": []string{"false"},
+			},
+			equivalentPath: "/dw(true)/some/path/master.m3u8",
+		},
+		{
+			name:        "when a preset is not defined, expect error",
+			path:        "/p(mobile)/some/path/master.m3u8",
+			expectedErr: true,
+		},
+		{
+			name:        "when a preset references another preset, expect error",
+			path:        "/p(nested)/some/path/master.m3u8",
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, got, err := QueryParse(tc.path, tc.query, presets)
+			if !tc.expectedErr && err != nil {
+				t.Errorf("Did not expect an error returned, got: %v", err)
+				return
+			} else if tc.expectedErr && err == nil {
+				t.Errorf("Expected an error returned, got nil")
+				return
+			}
+
+			if tc.expectedErr {
+				return
+			}
+
+			_, expected, err := URLParse(tc.equivalentPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !cmp.Equal(got, expected) {
+				t.Errorf("wrong struct generated.\nwant %v\ngot %v\n diff: %v", expected, got, cmp.Diff(expected, got))
+			}
+		})
+	}
+}
+
+func TestMediaFilters_ValidateCDN(t *testing.T) {
+	cdns := []string{"fastly", "akamai", "cloudfront"}
+	mf := &MediaFilters{CDN: "akamia"}
+
+	err := mf.ValidateCDN(cdns)
+	var pErr *ParseError
+	if !errors.As(err, &pErr) {
+		t.Fatalf("Expected a ParseError, got: %v", err)
+	}
+
+	if expected := "did you mean `cdn(akamai)`?"; pErr.Hint != expected {
+		t.Errorf("Wrong hint: expected %q, got %q", expected, pErr.Hint)
+	}
+
+	if expected := []string{"fastly", "akamai", "cloudfront"}; !cmp.Equal(cdns, expected) {
+		t.Errorf("Expected the cdns not to be modified, got %v", cdns)
+	}
+
+	if err := (&MediaFilters{CDN: "akamai"}).ValidateCDN(cdns); err != nil {
+		t.Errorf("Did not expect an error returned, got: %v", err)
+	}
+}
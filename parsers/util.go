@@ -2,6 +2,8 @@ package parsers
 
 import (
 	"regexp"
+	"sort"
+	"strings"
 )
 
 // splitAfter splits a string after the matches of the specified regexp
@@ -32,3 +34,97 @@ func validatePositiveRange(x, y, max int) bool {
 
 	return true
 }
+
+// splitFilters splits comma separated filters, ignoring the commas
+// found within their parenthesis, e.g. `v(avc,hevc),a(mp4a)`
+func splitFilters(s string) []string {
+	var filters []string
+	var depth, position int
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				filters = append(filters, strings.TrimSpace(s[position:i]))
+				position = i + 1
+			}
+		}
+	}
+
+	return append(filters, strings.TrimSpace(s[position:]))
+}
+
+// closest returns the candidate closest to value, as long as it is within
+// two edits of it or starts with it. An empty string is returned when no
+// candidate is close enough.
+func closest(value string, candidates []string) string {
+	best, bestDistance := "", 3
+	value = strings.ToLower(value)
+	for _, c := range candidates {
+		d := levenshtein(value, strings.ToLower(c))
+		if value != "" && strings.HasPrefix(strings.ToLower(c), value) {
+			d = min(d, 1)
+		}
+
+		if d < bestDistance {
+			best, bestDistance = c, d
+		}
+	}
+
+	return best
+}
+
+// levenshtein returns the edit distance between a and b
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
+
+func min(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+
+	return m
+}
+
+// sortedKeys returns the keys of a map in order, so suggestions are deterministic
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch m := m.(type) {
+	case map[string]string:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]struct{}:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
+}